@@ -0,0 +1,44 @@
+package dragontoothmg
+
+// In Antichess, captures are compulsory, the king is an ordinary piece that can
+// be captured and never in check, pawns may also promote to a king, and there
+// is no castling. A side wins by losing all its pieces or by being stalemated.
+
+// Generates the Antichess moves: the pseudo-legal moves with every promotion,
+// restricted to the captures if there are any.
+func (b *Board) generateAntichessMoves() []Move {
+	moves := make([]Move, 0, kDefaultMoveListLength)
+	b.generatePseudoLegalMoves(&moves)
+	captures := 0
+	for _, m := range moves {
+		if IsCapture(m, b) {
+			moves[captures] = m
+			captures++
+		}
+	}
+	if captures > 0 {
+		moves = moves[:captures]
+	}
+	for _, m := range moves {
+		if m.Promote() != Queen {
+			continue
+		}
+		for _, promote := range [...]Piece{Knight, Bishop, Rook, King} {
+			m.Setpromote(promote)
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// Whether the side to move has a capture, which it must then make.
+func (b *Board) antichessCaptureExists() bool {
+	moves := make([]Move, 0, kDefaultMoveListLength)
+	b.generatePseudoLegalMoves(&moves)
+	for _, m := range moves {
+		if IsCapture(m, b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestAntichessMoves(t *testing.T) {
+	tests := map[string]map[string]bool{
+		"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w - - 0 1": {"e4d5": true, "d2d4": false, "e1e2": false}, // forced capture
+		"8/P7/8/8/8/8/8/k7 w - - 0 1":                               {"a7a8q": true, "a7a8n": true, "a7a8k": true},
+		"8/8/8/8/8/8/r7/4K3 w - - 0 1":                              {"e1d2": true, "e1f1": true},                 // no check
+		"8/8/8/8/8/8/r7/r3K2R w KQ - 0 1":                           {"e1g1": false, "e1d1": true, "h1a1": false}, // no castling
+		"8/8/8/8/8/2n5/p7/7R b - - 0 1":                             {"a2a1q": true, "c3e4": true, "c3b1": true},  // a push to a1 is no capture
+	}
+	for fen, moves := range tests {
+		b := ParseVariantFen(fen, Antichess)
+		legal := make(map[Move]bool)
+		for _, m := range b.GenerateLegalMoves() {
+			legal[m] = true
+		}
+		for str, expected := range moves {
+			m := parseMove(str)
+			if b.IsLegal(m) != expected || legal[m] != expected {
+				t.Error("Expected", str, "to be legal:", expected, "in", fen)
+			}
+		}
+	}
+
+	b := ParseVariantFen("8/P7/8/8/8/8/8/k7 w - - 0 1", Antichess)
+	b.Apply(parseMove("a7a8k"))
+	if b.ToFen() != "K7/8/8/8/8/8/8/k7 b - - 0 1" {
+		t.Error("Expected a king promotion, got", b.ToFen())
+	}
+	if m, err := b.ParseSan("Kb1"); err != nil || m.String() != "a1b1" {
+		t.Error("Expected a1b1, got", m.String(), err)
+	}
+	b = ParseVariantFen("8/P7/8/8/8/8/8/k7 w - - 0 1", Antichess)
+	if m, err := b.ParseSan("a8=K"); err != nil || m.String() != "a7a8k" {
+		t.Error("Expected a7a8k, got", m.String(), err)
+	}
+	if b := ParseFen("8/P7/8/8/8/8/8/k6K w - - 0 1"); b.IsLegal(parseMove("a7a8k")) {
+		t.Error("Expected king promotions to be illegal in standard chess")
+	}
+}
+
+func TestAntichessOutcome(t *testing.T) {
+	g := NewGame(ParseVariantFen("8/8/8/8/8/1p6/P7/8 w - - 0 1", Antichess))
+	if err := g.Play(parseMove("a2b3")); err != nil {
+		t.Fatal(err)
+	}
+	if outcome, reason := g.Outcome(); outcome != BlackWins || reason != "lost all pieces" {
+		t.Error("Expected black to win by losing all pieces, got", outcome, reason)
+	}
+	g = NewGame(ParseVariantFen("8/8/8/8/8/p7/P7/8 w - - 0 1", Antichess))
+	if outcome, reason := g.Outcome(); outcome != WhiteWins || reason != "stalemate" {
+		t.Error("Expected white to win by stalemate, got", outcome, reason)
+	}
+}
@@ -4,6 +4,41 @@ package dragontoothmg
 // This function assumes that the given move is valid (i.e., is in the set of moves found by GenerateLegalMoves()).
 // If the move is not valid, this function has undefined behavior.
 func (b *Board) Apply(m Move) {
+	if m.IsDrop() {
+		b.applyDrop(m)
+		return
+	}
+	fromBitboard := (uint64(1) << m.From())
+	toBitboard := (uint64(1) << m.To())
+	var pieceType, capturedPieceType Piece
+	if b.Wtomove {
+		pieceType, _ = determinePieceType(&(b.White), fromBitboard)
+		capturedPieceType, _ = determinePieceType(&(b.Black), toBitboard)
+	} else {
+		pieceType, _ = determinePieceType(&(b.Black), fromBitboard)
+		capturedPieceType, _ = determinePieceType(&(b.White), toBitboard)
+	}
+	b.apply(m, pieceType, capturedPieceType)
+}
+
+// Applies an extended move to the board. Equivalent to Apply(), but uses the piece
+// types recorded in the move instead of probing the bitboards for them.
+// This function assumes that the given move was generated for this exact position.
+func (b *Board) ApplyExt(m ExtMove) {
+	if move := m.Move(); move.IsDrop() {
+		b.applyDrop(move)
+		return
+	}
+	capturedPieceType := m.Captured()
+	if m.IsEnpassant() { // the e.p. victim is not on the destination square
+		capturedPieceType = Nothing
+	}
+	b.apply(m.Move(), m.Piece(), capturedPieceType)
+}
+
+// The shared implementation of Apply() and ApplyExt().
+// The captured piece type is the piece on the destination square (so Nothing for e.p.).
+func (b *Board) apply(m Move, pieceType Piece, capturedPieceType Piece) {
 	// Configure data about which pieces move
 	var ourBitboardPtr, oppBitboardPtr *Bitboards
 	var epDelta int8                                // add this to the e.p. square to find the captured pawn
@@ -31,12 +66,12 @@ func (b *Board) Apply(m Move) {
 	}
 	fromBitboard := (uint64(1) << m.From())
 	toBitboard := (uint64(1) << m.To())
-	pieceType, pieceTypeBitboard := determinePieceType(ourBitboardPtr, fromBitboard)
+	pieceTypeBitboard := pieceBitboardPtr(ourBitboardPtr, pieceType)
 	castleStatus := 0
 	var oldRookLoc, newRookLoc uint8
 
 	// If it is any kind of capture or pawn move, reset halfmove clock.
-	if IsCapture(m, b) || pieceType == Pawn { 
+	if capturedPieceType != Nothing || pieceType == Pawn {
 		b.Halfmoveclock = 0 // reset halfmove clock
 	} else {
 		b.Halfmoveclock++
@@ -84,16 +119,23 @@ func (b *Board) Apply(m Move) {
 		// (Rook - 1) assumes that "Nothing" precedes "Rook" in the Piece constants list
 		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][oldRookLoc]
 		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][newRookLoc]
+		b.removeMaterial(ourPiecesPawnZobristIndex+(Rook-1), oldRookLoc)
+		b.addMaterial(ourPiecesPawnZobristIndex+(Rook-1), newRookLoc)
 	}
 
 	// Is this an e.p. capture? Strip the opponent pawn and reset the e.p. square
 	oldEpCaptureSquare := b.Enpassant
-	if pieceType == Pawn && m.To() == oldEpCaptureSquare && oldEpCaptureSquare != 0 {
+	enpassant := pieceType == Pawn && m.To() == oldEpCaptureSquare && oldEpCaptureSquare != 0
+	if b.Variant == Crazyhouse {
+		b.updatePockets(m, capturedPieceType, enpassant)
+	}
+	if enpassant {
 		epOpponentPawnLocation := uint8(int8(oldEpCaptureSquare) + epDelta)
 		oppBitboardPtr.Pawns &= ^(uint64(1) << epOpponentPawnLocation)
 		oppBitboardPtr.All &= ^(uint64(1) << epOpponentPawnLocation)
 		// Remove the opponent pawn from the board hash.
 		b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex][epOpponentPawnLocation]
+		b.removeMaterial(oppPiecesPawnZobristIndex, epOpponentPawnLocation)
 	}
 	// Update the en passant square
 	if pieceType == Pawn && (int8(m.To())+2*epDelta == int8(m.From())) { // pawn double push
@@ -118,13 +160,16 @@ func (b *Board) Apply(m Move) {
 	case Bishop:
 		destTypeBitboard = &(ourBitboardPtr.Bishops)
 		promotedToPieceType = Bishop
+	case King: // in Antichess
+		destTypeBitboard = &(ourBitboardPtr.Kings)
+		promotedToPieceType = King
 	default:
 		destTypeBitboard = pieceTypeBitboard
 		promotedToPieceType = pieceType
 	}
 
 	// Apply the move
-	capturedPieceType, capturedBitboard := determinePieceType(oppBitboardPtr, toBitboard)
+	capturedBitboard := pieceBitboardPtr(oppBitboardPtr, capturedPieceType)
 	ourBitboardPtr.All &= ^fromBitboard // remove at "from"
 	ourBitboardPtr.All |= toBitboard    // add at "to"
 	*pieceTypeBitboard &= ^fromBitboard // remove at "from"
@@ -133,9 +178,12 @@ func (b *Board) Apply(m Move) {
 		*capturedBitboard &= ^toBitboard
 		oppBitboardPtr.All &= ^toBitboard
 		b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex+(int(capturedPieceType)-1)][m.To()] // remove the captured piece from the hash
+		b.removeMaterial(oppPiecesPawnZobristIndex+(int(capturedPieceType)-1), m.To())
 	}
 	b.hash ^= pieceSquareZobristC[(int(pieceType)-1)+ourPiecesPawnZobristIndex][m.From()]         // remove piece at "from"
 	b.hash ^= pieceSquareZobristC[(int(promotedToPieceType)-1)+ourPiecesPawnZobristIndex][m.To()] // add piece at "to"
+	b.removeMaterial((int(pieceType)-1)+ourPiecesPawnZobristIndex, m.From())
+	b.addMaterial((int(promotedToPieceType)-1)+ourPiecesPawnZobristIndex, m.To())
 
 	// If a rook was captured, it strips castling rights
 	if capturedPieceType == Rook {
@@ -145,6 +193,9 @@ func (b *Board) Apply(m Move) {
 			b.flipOppQueensideCastle()
 		}
 	}
+	if b.Variant == Atomic && (capturedPieceType != Nothing || enpassant) {
+		b.explode(m.To())
+	}
 	// flip the side to move in the hash
 	b.hash ^= whiteToMoveZobristC
 	b.Wtomove = !b.Wtomove
@@ -152,6 +203,10 @@ func (b *Board) Apply(m Move) {
 	// remove the old en passant square from the hash, and add the new one
 	b.hash ^= uint64(oldEpCaptureSquare)
 	b.hash ^= uint64(b.Enpassant)
+
+	if b.Variant == ThreeCheck && b.OurKingInCheck() {
+		b.giveCheck(!b.Wtomove)
+	}
 }
 
 func determinePieceType(ourBitboardPtr *Bitboards, squareMask uint64) (Piece, *uint64) {
@@ -178,3 +233,23 @@ func determinePieceType(ourBitboardPtr *Bitboards, squareMask uint64) (Piece, *u
 	}
 	return pieceType, pieceTypeBitboard
 }
+
+// Returns a pointer to the bitboard for the given piece type.
+// Nothing maps to the All bitboard, matching determinePieceType().
+func pieceBitboardPtr(ourBitboardPtr *Bitboards, pieceType Piece) *uint64 {
+	switch pieceType {
+	case Pawn:
+		return &(ourBitboardPtr.Pawns)
+	case Knight:
+		return &(ourBitboardPtr.Knights)
+	case Bishop:
+		return &(ourBitboardPtr.Bishops)
+	case Rook:
+		return &(ourBitboardPtr.Rooks)
+	case Queen:
+		return &(ourBitboardPtr.Queens)
+	case King:
+		return &(ourBitboardPtr.Kings)
+	}
+	return &(ourBitboardPtr.All)
+}
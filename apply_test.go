@@ -91,7 +91,8 @@ func TestApplyUnapply(t *testing.T) {
 		if fenBefore != fenAfter {
 			t.Error("Fen changed during generation for board", k)
 		}
-		unapply := b.Apply(v)
+		before := b
+		b.Apply(v)
 		if b.ToFen() != results[k] {
 			t.Error("Move application of\n", &v, "\ndidn't produce expected result for\n", k, "->\n",
 				results[k], "\nInstead, we got:\n", b.ToFen())
@@ -100,7 +101,7 @@ func TestApplyUnapply(t *testing.T) {
 			t.Error("Move apply changed board hash from expected result",
 				"\nwith move", &v)
 		}
-		unapply()
+		b = before // unapply
 		newHash := b.Hash()
 		if oldHash != newHash {
 			t.Error("(0) Move unapply (or previous apply) changed board hash for:\n",
@@ -138,3 +139,78 @@ func TestApplyUnapply(t *testing.T) {
 		}*/
 	}
 }
+
+// ApplyExt must produce exactly the same board as Apply, for every legal move,
+// and the extended moves built by the generators must match ExtendMove.
+func TestApplyExt(t *testing.T) {
+	positions := []struct {
+		fen     string
+		variant Variant
+	}{
+		{Startpos, Standard},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0", Standard},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", Standard},
+		{"r3k3/1ppp1ppr/8/3Pp3/8/8/1PP1PPPP/R3K2R w - e6 3 0", Standard},
+		{"r3k3/1ppp1ppr/8/8/2Pp4/8/1P2PPPP/R3K2R b - c3 0 0", Standard},
+		{"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", Standard},
+		{"k3r3/8/8/8/8/8/4P3/4K3 w - - 0 1", Standard}, // pinned pawn double push
+		{"8/8/8/8/8/5k2/6p1/4K2B b - - 0 1", Standard}, // pinned pawn capturing its pinner
+		{"8/8/8/8/8/5k2/6p1/4K2Q b - - 0 1", Standard},
+		{"3qk3/8/8/8/8/8/3R4/3K2n1 w - - 0 1", Standard}, // pinned rook capturing its pinner
+		{"4k3/8/8/4b3/8/2Q5/8/K7 w - - 0 1", Standard},   // pinned queen
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R[QPPbn] w KQkq - 0 1", Crazyhouse},
+		{Horde.Startpos(), Horde},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +0+0", ThreeCheck},
+		{Atomic.Startpos(), Atomic},
+	}
+	for _, pos := range positions {
+		fen := pos.fen
+		b := ParseVariantFen(fen, pos.variant)
+		moves := b.GenerateLegalMoves()
+		extMoves := b.GenerateLegalExtMoves()
+		if len(moves) != len(extMoves) {
+			t.Error("Extended move generation returned a different number of moves for", fen)
+			continue
+		}
+		for i, ext := range extMoves {
+			if ext.Move() != moves[i] {
+				t.Error("Extended move", &ext, "does not match", &moves[i])
+			} else if ext != b.ExtendMove(moves[i]) {
+				t.Error("Extended move", &ext, "is annotated differently from ExtendMove in", fen)
+			}
+			b1, b2 := b, b
+			b1.Apply(moves[i])
+			b2.ApplyExt(ext)
+			if b1 != b2 {
+				t.Error("ApplyExt of", &ext, "differs from Apply in", fen, "\n",
+					b1.ToFen(), "\n", b2.ToFen())
+			}
+		}
+	}
+}
+
+func TestExtendMove(t *testing.T) {
+	b := ParseFen("r3k3/1ppp1ppr/8/3Pp3/8/8/1PP1PPPP/R3K2R w K e6 3 0")
+	tests := []struct {
+		move                          string
+		piece, captured               Piece
+		castle, enpassant, doublePush bool
+	}{
+		{"d5e6", Pawn, Pawn, false, true, false},
+		{"e1g1", King, Nothing, true, false, false},
+		{"b2b4", Pawn, Nothing, false, false, true},
+		{"a1a8", Rook, Rook, false, false, false},
+		{"e1f1", King, Nothing, false, false, false},
+	}
+	for _, test := range tests {
+		ext := b.ExtendMove(parseMove(test.move))
+		if ext.Piece() != test.piece || ext.Captured() != test.captured ||
+			ext.IsCastle() != test.castle || ext.IsEnpassant() != test.enpassant ||
+			ext.IsDoublePush() != test.doublePush || ext.IsCapture() != (test.captured != Nothing) {
+			t.Error("Incorrect extended move annotation for", test.move)
+		}
+		if ext.String() != test.move {
+			t.Error("Extended move string", ext.String(), "does not match", test.move)
+		}
+	}
+}
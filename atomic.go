@@ -0,0 +1,105 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// In Atomic, a capture explodes: the capturing piece, the captured piece and
+// every piece other than a pawn next to the capture square leave the board.
+// Kings may not capture, a move may not explode our own king, and exploding the
+// opponent's king wins. Kings standing next to each other cannot give check, as
+// capturing one would explode the other.
+
+// Removes the piece on a capture square and every piece other than a pawn next
+// to it, updating the hash, material and castling rights.
+func (b *Board) explode(square uint8) {
+	blast := kingMasks[square]&^(b.White.Pawns|b.Black.Pawns) | uint64(1)<<square
+	for color, side := range [2]*Bitboards{&(b.White), &(b.Black)} {
+		if side.All&blast == 0 {
+			continue
+		}
+		for piece := Piece(Pawn); piece <= King; piece++ {
+			bitboard := pieceBitboardPtr(side, piece)
+			zobristIndex := color*6 + int(piece) - 1
+			for hit := *bitboard & blast; hit != 0; hit &= hit - 1 {
+				sq := uint8(bits.TrailingZeros64(hit))
+				b.hash ^= pieceSquareZobristC[zobristIndex][sq]
+				b.removeMaterial(zobristIndex, sq)
+			}
+			*bitboard &^= blast
+		}
+		side.All &^= blast
+	}
+	// Exploding a king or a rook in its corner loses the castling rights.
+	if b.whiteCanCastleKingside() && blast&(uint64(1)<<4|uint64(1)<<7) != 0 {
+		b.flipWhiteKingsideCastle()
+	}
+	if b.whiteCanCastleQueenside() && blast&(uint64(1)<<4|uint64(1)<<0) != 0 {
+		b.flipWhiteQueensideCastle()
+	}
+	if b.blackCanCastleKingside() && blast&(uint64(1)<<60|uint64(1)<<63) != 0 {
+		b.flipBlackKingsideCastle()
+	}
+	if b.blackCanCastleQueenside() && blast&(uint64(1)<<60|uint64(1)<<56) != 0 {
+		b.flipBlackQueensideCastle()
+	}
+}
+
+// Whether the king of the side to move is in check, in Atomic: it is attacked,
+// and the kings are not next to each other.
+func (b *Board) atomicInCheck() bool {
+	ourKings, oppKings := b.White.Kings, b.Black.Kings
+	if !b.Wtomove {
+		ourKings, oppKings = oppKings, ourKings
+	}
+	if ourKings == 0 || oppKings == 0 {
+		return false
+	}
+	kingLocation := uint8(bits.TrailingZeros64(ourKings))
+	if kingMasks[kingLocation]&oppKings != 0 {
+		return false
+	}
+	count, _ := b.countAttacks(b.Wtomove, kingLocation, 1)
+	return count >= 1
+}
+
+// Whether a pseudo-legal move is legal in Atomic: kings do not capture or castle
+// through check, and afterwards our king survives and is not in check, unless
+// the opponent's king exploded.
+func (b *Board) atomicAllows(m Move) bool {
+	var ourPieces, oppPieces *Bitboards
+	if b.Wtomove {
+		ourPieces, oppPieces = &(b.White), &(b.Black)
+	} else {
+		ourPieces, oppPieces = &(b.Black), &(b.White)
+	}
+	fromBitboard := uint64(1) << m.From()
+	if ourPieces.Kings&fromBitboard != 0 {
+		if oppPieces.All&(uint64(1)<<m.To()) != 0 {
+			return false
+		}
+		if m.To() == m.From()+2 || int(m.To()) == int(m.From())-2 {
+			if b.atomicInCheck() {
+				return false
+			}
+			passed := (m.From() + m.To()) / 2
+			if kingMasks[passed]&oppPieces.Kings == 0 && b.UnderDirectAttack(b.Wtomove, passed) {
+				return false
+			}
+		}
+	}
+	next := *b
+	next.Apply(m)
+	if ourPieces == &(b.White) {
+		ourPieces, oppPieces = &(next.White), &(next.Black)
+	} else {
+		ourPieces, oppPieces = &(next.Black), &(next.White)
+	}
+	if ourPieces.Kings == 0 {
+		return false
+	} else if oppPieces.Kings == 0 {
+		return true
+	}
+	next.Wtomove = !next.Wtomove // look at our king
+	return !next.atomicInCheck()
+}
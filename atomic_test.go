@@ -0,0 +1,62 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestAtomicApply(t *testing.T) {
+	tests := map[string]struct {
+		move     string
+		expected string
+	}{
+		"4k3/8/8/3pn3/4P3/8/8/4K3 w - - 0 1":   {"e4d5", "4k3/8/8/8/8/8/8/4K3 b - - 0 1"},
+		"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1":    {"e5d6", "4k3/8/8/8/8/8/8/4K3 b - - 0 1"}, // en passant
+		"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1": {"a1a8", "4k2r/8/8/8/8/8/8/4K2R b Kk - 0 1"},
+		"4k3/8/8/2PNp3/8/8/8/4K3 w - - 0 1":    {"d5e7", "4k3/4N3/8/2P1p3/8/8/8/4K3 b - - 1 1"}, // no capture
+	}
+	for fen, test := range tests {
+		b := ParseVariantFen(fen, Atomic)
+		m := parseMove(test.move)
+		b.Apply(m)
+		if b.ToFen() != test.expected {
+			t.Error("Expected", test.expected, "after", test.move, "in", fen, "but got", b.ToFen())
+		}
+		if b.Hash() != recomputeBoardHash(&b) {
+			t.Error("The hash is out of date after", test.move, "in", fen)
+		}
+	}
+}
+
+func TestAtomicLegality(t *testing.T) {
+	tests := map[string]map[string]bool{
+		"4k3/8/8/8/8/8/4p3/4K3 w - - 0 1":  {"e1e2": false},                             // kings do not capture
+		"4k3/8/8/8/8/8/8/R2nK3 w - - 0 1":  {"a1d1": false},                             // our king would explode
+		"3nk3/8/8/8/8/8/8/3QK2r w - - 0 1": {"d1d8": true, "d1d2": false, "e1f2": true}, // their king explodes
+		"8/8/8/8/8/8/3k4/r3K3 w - - 0 1":   {"e1d1": true, "e1f1": false, "e1f2": true}, // adjacent kings
+	}
+	for fen, moves := range tests {
+		b := ParseVariantFen(fen, Atomic)
+		legal := make(map[Move]bool)
+		for _, m := range b.GenerateLegalMoves() {
+			legal[m] = true
+		}
+		for str, expected := range moves {
+			m := parseMove(str)
+			if b.IsLegal(m) != expected || legal[m] != expected {
+				t.Error("Expected", str, "to be legal:", expected, "in", fen)
+			}
+		}
+	}
+
+	b := ParseVariantFen("8/8/8/8/8/8/3k4/r3K3 w - - 0 1", Atomic)
+	if b.OurKingInCheck() {
+		t.Error("Expected no check between adjacent kings")
+	}
+	g := NewGame(ParseVariantFen("3nk3/8/8/8/8/8/8/3QK2r w - - 0 1", Atomic))
+	if err := g.Play(parseMove("d1d8")); err != nil {
+		t.Fatal(err)
+	}
+	if outcome, reason := g.Outcome(); outcome != WhiteWins || reason != "king exploded" {
+		t.Error("Expected white to win by exploding the king, got", outcome, reason)
+	}
+}
@@ -50,7 +50,7 @@ func benchmarkStartpos5(b *testing.B) {
 	pos := dragontoothmg.Startpos
 	board := dragontoothmg.ParseFen(pos)
 	for i := 0; i < b.N; i++ {
-		startposResult5 = dragontoothmg.Perft(&board,6)
+		startposResult5 = dragontoothmg.Perft(board,6)
 	}
 }
 
@@ -59,7 +59,7 @@ func benchmarkStartpos6(b *testing.B) {
 	pos := dragontoothmg.Startpos
 	board := dragontoothmg.ParseFen(pos)
 	for i := 0; i < b.N; i++ {
-		startposResult6 = dragontoothmg.Perft(&board, 6)
+		startposResult6 = dragontoothmg.Perft(board, 6)
 	}
 }
 
@@ -68,7 +68,7 @@ func benchmarkKiwipete(b *testing.B) {
 	pos := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0"
 	board := dragontoothmg.ParseFen(pos)
 	for i := 0; i < b.N; i++ {
-		kpResult = dragontoothmg.Perft(&board, 5)
+		kpResult = dragontoothmg.Perft(board, 5)
 	}
 }
 
@@ -77,7 +77,7 @@ func benchmarkDense(b *testing.B) {
 	pos := "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"
 	board := dragontoothmg.ParseFen(pos)
 	for i := 0; i < b.N; i++ {
-		denseResult = dragontoothmg.Perft(&board, 6)
+		denseResult = dragontoothmg.Perft(board, 6)
 	}
 }
 
@@ -86,6 +86,6 @@ func benchmarkEndgameRP(b *testing.B) {
 	pos := "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 0"
 	board := dragontoothmg.ParseFen(pos)
 	for i := 0; i < b.N; i++ {
-		endgameResult = dragontoothmg.Perft(&board, 7)
+		endgameResult = dragontoothmg.Perft(board, 7)
 	}
 }
@@ -0,0 +1,152 @@
+package dragontoothmg
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// The binary board format, from the first byte:
+//
+//	1 byte: format version (binaryBoardVersion)
+//	8 bytes: occupancy bitboard, little-endian
+//	1 nibble per occupied square, in square order, low nibble first: the
+//	  Piece, plus 8 for black; padded to a whole byte
+//	1 byte: 1 if white is to move, plus the castle rights shifted left by one
+//	1 byte: en passant square, or 0
+//	1 byte: halfmove clock
+//	2 bytes: fullmove number, little-endian
+//	1 byte: Variant
+//	then the variant's state: 1 byte of checks given in ThreeCheck (white in
+//	  the low nibble), or in Crazyhouse 10 bytes of pocket counts (white then
+//	  black, pawn to queen) and the promoted pieces bitboard, little-endian.
+//
+// A position with all 32 pieces takes 31 bytes.
+const binaryBoardVersion = 1
+
+var errTruncatedBinaryBoard = errors.New("Truncated binary board.")
+
+// Encodes the board in a compact binary format, which is much smaller and faster
+// to decode than FEN. Implements encoding.BinaryMarshaler.
+func (b *Board) MarshalBinary() ([]byte, error) {
+	return b.AppendBinary(make([]byte, 0, 32))
+}
+
+// Appends the binary encoding of MarshalBinary to buf, to avoid allocating when
+// encoding many positions.
+func (b *Board) AppendBinary(buf []byte) ([]byte, error) {
+	occupancy := b.White.All | b.Black.All
+	buf = append(buf, binaryBoardVersion)
+	buf = binary.LittleEndian.AppendUint64(buf, occupancy)
+	highNibble := false
+	for occupied := occupancy; occupied != 0; occupied &= occupied - 1 {
+		square := occupied & -occupied
+		piece, _ := determinePieceType(&(b.White), square)
+		code := byte(piece)
+		if piece == Nothing {
+			piece, _ = determinePieceType(&(b.Black), square)
+			code = byte(piece) | 8
+		}
+		if highNibble {
+			buf[len(buf)-1] |= code << 4
+		} else {
+			buf = append(buf, code)
+		}
+		highNibble = !highNibble
+	}
+	flags := b.castlerights << 1
+	if b.Wtomove {
+		flags |= 1
+	}
+	buf = append(buf, flags, b.Enpassant, b.Halfmoveclock)
+	buf = binary.LittleEndian.AppendUint16(buf, b.Fullmoveno)
+	buf = append(buf, byte(b.Variant))
+	switch b.Variant {
+	case ThreeCheck:
+		buf = append(buf, b.checks[0]|b.checks[1]<<4)
+	case Crazyhouse:
+		for color := 0; color < 2; color++ {
+			buf = append(buf, b.pockets[color][Pawn:]...)
+		}
+		buf = binary.LittleEndian.AppendUint64(buf, b.promoted)
+	}
+	return buf, nil
+}
+
+// Decodes a board encoded by MarshalBinary, replacing the board. Returns an
+// error, and leaves the board unchanged, if the data is malformed. Implements
+// encoding.BinaryUnmarshaler.
+func (b *Board) UnmarshalBinary(data []byte) error {
+	if len(data) < 9 {
+		return errTruncatedBinaryBoard
+	}
+	if data[0] != binaryBoardVersion {
+		return errors.New("Unsupported binary board version.")
+	}
+	occupancy := binary.LittleEndian.Uint64(data[1:9])
+	data = data[9:]
+	codeBytes := (bits.OnesCount64(occupancy) + 1) / 2
+	if len(data) < codeBytes+6 {
+		return errTruncatedBinaryBoard
+	}
+	var nb Board
+	i := 0
+	for occupied := occupancy; occupied != 0; occupied &= occupied - 1 {
+		code := data[i/2] >> (4 * uint(i%2)) & 0xf
+		i++
+		side := &(nb.White)
+		if code&8 != 0 {
+			side = &(nb.Black)
+		}
+		piece := Piece(code & 7)
+		if piece < Pawn || piece > King {
+			return errors.New("Invalid piece in binary board.")
+		}
+		square := occupied & -occupied
+		*pieceBitboardPtr(side, piece) |= square
+		side.All |= square
+	}
+	data = data[codeBytes:]
+	flags := data[0]
+	nb.Wtomove = flags&1 != 0
+	nb.castlerights = flags >> 1
+	nb.Enpassant, nb.Halfmoveclock = data[1], data[2]
+	nb.Fullmoveno = binary.LittleEndian.Uint16(data[3:5])
+	nb.Variant = Variant(data[5])
+	data = data[6:]
+	if flags > 0x1f || nb.Enpassant > 63 || int(nb.Variant) >= len(variantNames) {
+		return errors.New("Invalid binary board.")
+	}
+	switch nb.Variant {
+	case ThreeCheck:
+		if len(data) < 1 {
+			return errTruncatedBinaryBoard
+		}
+		nb.checks = [2]uint8{data[0] & 0xf, data[0] >> 4}
+		if nb.checks[0] > 3 || nb.checks[1] > 3 {
+			return errors.New("Invalid binary board.")
+		}
+		data = data[1:]
+	case Crazyhouse:
+		if len(data) < 18 {
+			return errTruncatedBinaryBoard
+		}
+		for color := 0; color < 2; color++ {
+			for piece := Pawn; piece <= Queen; piece++ {
+				nb.pockets[color][piece] = data[color*5+piece-1]
+				if nb.pockets[color][piece] > 16 {
+					return errors.New("Invalid binary board.")
+				}
+			}
+		}
+		nb.promoted = binary.LittleEndian.Uint64(data[10:18]) & occupancy
+		data = data[18:]
+	}
+	if len(data) != 0 {
+		return errors.New("Trailing data in binary board.")
+	}
+	nb.hash = recomputeBoardHash(&nb)
+	nb.recomputeMaterial()
+	*b = nb
+	return nil
+}
@@ -0,0 +1,59 @@
+package dragontoothmg
+
+import (
+	"encoding"
+	"testing"
+)
+
+var _ encoding.BinaryMarshaler = &Board{}
+var _ encoding.BinaryUnmarshaler = &Board{}
+
+func TestMarshalBinary(t *testing.T) {
+	tests := []struct {
+		fen     string
+		variant Variant
+	}{
+		{Startpos, Standard},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", Standard},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", Standard},
+		{"8/8/8/8/8/8/8/K6k b - - 57 300", Standard},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+1", ThreeCheck},
+		{"Q~3k3/8/8/8/8/8/8/4K2q~[QPPbn] b - - 0 1", Crazyhouse},
+		{Horde.Startpos(), Horde},
+	}
+	for _, test := range tests {
+		b := ParseVariantFen(test.fen, test.variant)
+		data, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Board
+		if err := decoded.UnmarshalBinary(data); err != nil || decoded != b {
+			t.Error("Expected", test.fen, "but got", decoded.ToFen(), err)
+		}
+	}
+
+	b := ParseFen(Startpos)
+	data, _ := b.MarshalBinary()
+	if len(data) != 31 {
+		t.Error("Expected 31 bytes for the starting position, got", len(data))
+	}
+	if appended, _ := b.AppendBinary([]byte{42}); len(appended) != 32 || appended[0] != 42 {
+		t.Error("Expected the encoding to be appended, got", appended)
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":      {},
+		"version":    append([]byte{2}, data[1:]...),
+		"truncated":  data[:len(data)-1],
+		"trailing":   append(append([]byte{}, data...), 0),
+		"piece":      append(append([]byte{}, data[:9]...), append([]byte{0x77}, data[10:]...)...),
+		"en passant": append(append([]byte{}, data[:26]...), append([]byte{64}, data[27:]...)...),
+		"variant":    append(append([]byte{}, data[:30]...), 99),
+	} {
+		decoded := b
+		if err := decoded.UnmarshalBinary(bad); err == nil || decoded != b {
+			t.Error("Expected an error for a bad", name, "and no change to the board, got", err)
+		}
+	}
+}
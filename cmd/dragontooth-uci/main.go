@@ -0,0 +1,12 @@
+// Command dragontooth-uci is a chess engine that speaks the UCI protocol on
+// standard input and output, using the dragontoothmg move generator and the
+// reference search.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	newUciEngine(os.Stdout).run(os.Stdin)
+}
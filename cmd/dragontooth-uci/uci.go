@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/search"
+)
+
+const (
+	engineName   = "Dragontooth"
+	engineAuthor = "Dylan D. Hunn"
+
+	defaultHashMb = 16
+	maxHashMb     = 4096
+)
+
+// The state of a UCI session. Commands are handled one at a time by run(), while
+// searches run in their own goroutine and report through the same writer.
+type uciEngine struct {
+	out   io.Writer
+	outMu sync.Mutex
+
+	board    dragontoothmg.Board
+	history  []uint64 // hashes of the positions before board, for repetition detection
+	searcher *search.Searcher
+
+	cancel    context.CancelFunc // cancels the running search, if any
+	infinite  bool               // whether the running search only ends on "stop"
+	searching sync.WaitGroup
+}
+
+func newUciEngine(out io.Writer) *uciEngine {
+	return &uciEngine{
+		out:      out,
+		board:    dragontoothmg.ParseFen(dragontoothmg.Startpos),
+		searcher: search.NewSearcher(nil, defaultHashMb),
+	}
+}
+
+// Reads and handles commands until "quit" or the end of the input.
+// At the end of the input, a running search is allowed to finish (unless it is
+// infinite), so that scripted input can be piped in.
+func (e *uciEngine) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !e.handle(scanner.Text()) {
+			e.stop()
+			return
+		}
+	}
+	if e.infinite {
+		e.stop()
+	}
+	e.searching.Wait()
+}
+
+func (e *uciEngine) send(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// Handles a single command line. Returns false if the engine should exit.
+func (e *uciEngine) handle(line string) bool {
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		return true
+	}
+	switch tokens[0] {
+	case "uci":
+		e.send("id name %s", engineName)
+		e.send("id author %s", engineAuthor)
+		e.send("option name Hash type spin default %d min 1 max %d", defaultHashMb, maxHashMb)
+		e.send("option name Threads type spin default 1 min 1 max 1")
+		e.send("option name UCI_Chess960 type check default false")
+		e.send("uciok")
+	case "isready":
+		e.send("readyok")
+	case "ucinewgame":
+		e.stop()
+		e.searcher.Clear()
+		e.board = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+		e.history = e.history[:0]
+	case "setoption":
+		e.stop()
+		e.setOption(tokens[1:])
+	case "position":
+		e.stop()
+		e.position(tokens[1:])
+	case "go":
+		e.stop()
+		e.goSearch(tokens[1:])
+	case "stop":
+		e.stop()
+	case "quit":
+		return false
+	default:
+		e.send("info string unknown command %s", tokens[0])
+	}
+	return true
+}
+
+// Handles "setoption name <id> [value <x>]".
+func (e *uciEngine) setOption(tokens []string) {
+	var name, value []string
+	var current *[]string
+	for _, token := range tokens {
+		switch token {
+		case "name":
+			current = &name
+		case "value":
+			current = &value
+		default:
+			if current != nil {
+				*current = append(*current, token)
+			}
+		}
+	}
+	switch strings.ToLower(strings.Join(name, " ")) {
+	case "hash":
+		mb, err := strconv.Atoi(strings.Join(value, ""))
+		if err != nil || mb < 1 || mb > maxHashMb {
+			e.send("info string invalid Hash value")
+			return
+		}
+		e.searcher.SetHashSize(mb)
+	case "threads":
+		if strings.Join(value, "") != "1" {
+			e.send("info string only one search thread is supported")
+		}
+	case "uci_chess960":
+		if strings.ToLower(strings.Join(value, "")) == "true" {
+			e.send("info string Chess960 castling is not supported")
+		}
+	default:
+		e.send("info string unknown option %s", strings.Join(name, " "))
+	}
+}
+
+// Handles "position [startpos | fen <fen>] [moves <move>...]".
+func (e *uciEngine) position(tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	movesIdx := len(tokens)
+	for i, token := range tokens {
+		if token == "moves" {
+			movesIdx = i
+			break
+		}
+	}
+	var board dragontoothmg.Board
+	switch tokens[0] {
+	case "startpos":
+		board = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	case "fen":
+		if movesIdx < 2 {
+			e.send("info string missing FEN")
+			return
+		}
+		var err error
+		board, err = dragontoothmg.ParseFenChecked(strings.Join(tokens[1:movesIdx], " "))
+		if err != nil {
+			e.send("info string invalid FEN: %v", err)
+			return
+		}
+	default:
+		e.send("info string invalid position command")
+		return
+	}
+	var history []uint64
+	var moves []string
+	if movesIdx < len(tokens) {
+		moves = tokens[movesIdx+1:]
+	}
+	for _, str := range moves {
+		m, err := dragontoothmg.ParseMove(str)
+		if err != nil || !board.IsLegal(m) {
+			e.send("info string illegal move %s", str)
+			break
+		}
+		history = append(history, board.Hash())
+		board.Apply(m)
+	}
+	e.board = board
+	e.history = history
+}
+
+// Handles "go" with its search limits, starting the search in the background.
+func (e *uciEngine) goSearch(tokens []string) {
+	var limits search.Limits
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "infinite" {
+			limits.Infinite = true
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		value, err := strconv.ParseInt(tokens[i+1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ms := time.Duration(value) * time.Millisecond
+		switch tokens[i] {
+		case "depth":
+			limits.Depth = int(value)
+		case "nodes":
+			limits.Nodes = uint64(value)
+		case "movetime":
+			limits.MoveTime = ms
+		case "wtime":
+			limits.WTime = ms
+		case "btime":
+			limits.BTime = ms
+		case "winc":
+			limits.WInc = ms
+		case "binc":
+			limits.BInc = ms
+		case "movestogo":
+			limits.MovesToGo = int(value)
+		default:
+			continue
+		}
+		i++
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.infinite = limits.Infinite
+	board, history := e.board, append([]uint64(nil), e.history...)
+	e.searcher.OnInfo = e.sendInfo
+	e.searching.Add(1)
+	go func() {
+		defer e.searching.Done()
+		result := e.searcher.Search(ctx, board, history, limits)
+		if limits.Infinite {
+			<-ctx.Done() // the GUI expects bestmove only after "stop"
+		}
+		if result.BestMove == 0 {
+			e.send("bestmove 0000")
+		} else if len(result.PV) > 1 {
+			e.send("bestmove %v ponder %v", &result.PV[0], &result.PV[1])
+		} else {
+			e.send("bestmove %v", &result.BestMove)
+		}
+	}()
+}
+
+func (e *uciEngine) sendInfo(info search.Info) {
+	score := "cp " + strconv.Itoa(info.Score)
+	if moves, mate := search.MateIn(info.Score); mate {
+		score = "mate " + strconv.Itoa(moves)
+	}
+	ms := info.Time.Milliseconds()
+	nps := uint64(0)
+	if ms > 0 {
+		nps = info.Nodes * 1000 / uint64(ms)
+	}
+	pv := make([]string, len(info.PV))
+	for i := range info.PV {
+		pv[i] = info.PV[i].String()
+	}
+	e.send("info depth %d seldepth %d score %s nodes %d nps %d hashfull %d time %d pv %s",
+		info.Depth, info.SelDepth, score, info.Nodes, nps, info.Hashfull, ms, strings.Join(pv, " "))
+}
+
+// Stops the running search (if any), and waits for it to report its best move.
+func (e *uciEngine) stop() {
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.searching.Wait()
+}
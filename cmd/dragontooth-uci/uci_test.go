@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Runs a scripted session, and returns the engine's output lines.
+func runScript(script string) []string {
+	var out bytes.Buffer
+	newUciEngine(&out).run(strings.NewReader(script))
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func contains(lines []string, prefix string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHandshake(t *testing.T) {
+	lines := runScript("uci\nisready\n")
+	for _, expected := range []string{"id name", "option name Hash", "option name Threads",
+		"option name UCI_Chess960", "uciok", "readyok"} {
+		if !contains(lines, expected) {
+			t.Error("Missing", expected, "in handshake output:", lines)
+		}
+	}
+}
+
+func TestGoDepth(t *testing.T) {
+	lines := runScript("ucinewgame\nposition fen 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1\ngo depth 3\n")
+	if lines[len(lines)-1] != "bestmove a1a8" {
+		t.Error("Expected the back rank mate, got:", lines)
+	}
+	if !contains(lines, "info depth 1 ") || !contains(lines, "info depth 3 ") ||
+		!strings.Contains(lines[0], "score mate 1") {
+		t.Error("Missing or incorrect info output:", lines)
+	}
+}
+
+func TestPositionMoves(t *testing.T) {
+	// After 1. f3 e5 2. g4, black mates with Qh4.
+	lines := runScript("position startpos moves f2f3 e7e5 g2g4\ngo depth 2\n")
+	if lines[len(lines)-1] != "bestmove d8h4" {
+		t.Error("Expected fool's mate, got:", lines)
+	}
+	lines = runScript("position startpos moves e2e4 e2e4\n")
+	if !contains(lines, "info string illegal move e2e4") {
+		t.Error("Illegal move was not reported:", lines)
+	}
+	// A malformed FEN is reported, and the previous position is kept.
+	lines = runScript("position fen 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1\nposition fen 8/8/8 w - - 0 1\ngo depth 3\n")
+	if !contains(lines, "info string invalid FEN") || lines[len(lines)-1] != "bestmove a1a8" {
+		t.Error("Expected the malformed FEN to be reported and ignored, got:", lines)
+	}
+}
+
+func TestInfiniteAndStop(t *testing.T) {
+	lines := runScript("setoption name Hash value 32\nposition startpos\ngo infinite\nisready\nstop\n")
+	if !contains(lines, "readyok") || !strings.HasPrefix(lines[len(lines)-1], "bestmove ") {
+		t.Error("Infinite search did not stop correctly:", lines)
+	}
+	lines = runScript("position startpos\ngo wtime 1000 btime 1000 winc 10 binc 10\n")
+	if !strings.HasPrefix(lines[len(lines)-1], "bestmove ") {
+		t.Error("Clock search did not return a move:", lines)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	lines := runScript("setoption name UCI_Chess960 value true\nsetoption name Hash value 0\n" +
+		"setoption name Threads value 1\nsetoption name Foo value 1\n")
+	for _, expected := range []string{"info string Chess960", "info string invalid Hash",
+		"info string unknown option Foo"} {
+		if !contains(lines, expected) {
+			t.Error("Missing", expected, "in output:", lines)
+		}
+	}
+	if len(lines) != 3 {
+		t.Error("Unexpected output for a valid option:", lines)
+	}
+}
@@ -0,0 +1,12 @@
+// Command dragontooth-xboard is a chess engine that speaks the XBoard (CECP)
+// protocol on standard input and output, using the dragontoothmg move generator
+// and the reference search.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	newXboardEngine(os.Stdout).run(os.Stdin)
+}
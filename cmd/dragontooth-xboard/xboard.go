@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/search"
+)
+
+const (
+	engineName = "Dragontooth"
+
+	defaultHashMb = 16
+	maxHashMb     = 4096
+)
+
+// The state of an XBoard (CECP) session. Commands are handled one at a time by
+// run(), while the engine thinks in its own goroutine. Game state is only
+// modified while no search is running, except for the engine's own move, which
+// is played under mu.
+type xboardEngine struct {
+	out   io.Writer
+	outMu sync.Mutex
+
+	mu       sync.Mutex
+	game     *dragontoothmg.Game
+	searcher *search.Searcher
+
+	force       bool // whether the engine only records moves, without playing
+	engineWhite bool // the side the engine plays, when not in force mode
+	post        bool // whether to send thinking output
+
+	depth     int           // from "sd", or 0
+	moveTime  time.Duration // from "st", or 0
+	movesPer  int           // moves per time control from "level", or 0 for the whole game
+	increment time.Duration // from "level"
+	clock     time.Duration // from "time": the engine's remaining time
+	otherTime time.Duration // from "otim": the opponent's remaining time
+
+	cancel    context.CancelFunc // interrupts the running search, if any
+	discard   bool               // whether the running search should not play its move
+	searching sync.WaitGroup
+}
+
+func newXboardEngine(out io.Writer) *xboardEngine {
+	e := &xboardEngine{out: out, searcher: search.NewSearcher(nil, defaultHashMb)}
+	e.newGame()
+	return e
+}
+
+// Reads and handles commands until "quit" or the end of the input.
+// At the end of the input, the engine is allowed to finish thinking, so that
+// scripted input can be piped in.
+func (e *xboardEngine) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !e.handle(scanner.Text()) {
+			e.stop()
+			return
+		}
+	}
+	e.searching.Wait()
+}
+
+func (e *xboardEngine) send(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// Handles a single command line. Returns false if the engine should exit.
+func (e *xboardEngine) handle(line string) bool {
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 {
+		return true
+	}
+	args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), tokens[0]))
+	switch tokens[0] {
+	case "xboard", "accepted", "rejected", "random", "hard", "easy", "computer", "name", "ics", "draw":
+		// nothing to do; draw offers are declined by ignoring them
+	case "protover":
+		e.send("feature done=0")
+		e.send("feature myname=\"%s\" variants=\"normal\" setboard=1 usermove=1 ping=1 time=1 "+
+			"playother=1 colors=0 san=0 draw=0 sigint=0 sigterm=0 reuse=1 analyze=0 memory=1", engineName)
+		e.send("feature done=1")
+	case "new":
+		e.stop()
+		e.newGame()
+	case "variant":
+		if args != "normal" {
+			e.send("Error (unsupported variant): %s", args)
+		}
+	case "force", "result":
+		e.stop()
+		e.force = true
+	case "go":
+		e.stop()
+		e.force = false
+		e.engineWhite = e.game.Board.Wtomove
+		e.think()
+	case "playother":
+		e.stop()
+		e.force = false
+		e.engineWhite = !e.game.Board.Wtomove
+	case "?":
+		if e.cancel != nil {
+			e.cancel() // move now
+		}
+	case "setboard":
+		e.stop()
+		e.setBoard(args)
+	case "usermove":
+		e.stop()
+		e.userMove(args)
+	case "undo":
+		e.stop()
+		e.takeBack(1)
+	case "remove":
+		e.stop()
+		e.takeBack(2)
+	case "level":
+		e.level(tokens[1:])
+	case "st":
+		if seconds, err := strconv.ParseFloat(args, 64); err == nil {
+			e.moveTime = time.Duration(seconds * float64(time.Second))
+		}
+	case "sd":
+		if depth, err := strconv.Atoi(args); err == nil {
+			e.depth = depth
+		}
+	case "time", "otim":
+		centis, err := strconv.Atoi(args)
+		if err != nil {
+			break
+		}
+		if tokens[0] == "time" {
+			e.clock = time.Duration(centis) * 10 * time.Millisecond
+		} else {
+			e.otherTime = time.Duration(centis) * 10 * time.Millisecond
+		}
+	case "post":
+		e.post = true
+	case "nopost":
+		e.post = false
+	case "memory":
+		mb, err := strconv.Atoi(args)
+		if err == nil && mb >= 1 && mb <= maxHashMb {
+			e.stop()
+			e.searcher.SetHashSize(mb)
+		}
+	case "ping":
+		e.send("pong %s", args)
+	case "quit":
+		return false
+	default:
+		// Old interfaces send bare moves, without "usermove".
+		if _, err := dragontoothmg.ParseMove(tokens[0]); err == nil && len(tokens) == 1 {
+			e.stop()
+			e.userMove(tokens[0])
+		} else {
+			e.send("Error (unknown command): %s", tokens[0])
+		}
+	}
+	return true
+}
+
+// Resets the game, as for "new": the engine plays black, and the depth limit is removed.
+func (e *xboardEngine) newGame() {
+	e.game = dragontoothmg.NewGame(dragontoothmg.ParseFen(dragontoothmg.Startpos))
+	e.force, e.engineWhite, e.depth = false, false, 0
+	e.searcher.Clear()
+}
+
+// Handles "setboard <fen>".
+func (e *xboardEngine) setBoard(fen string) {
+	board, err := dragontoothmg.ParseFenChecked(fen)
+	if err != nil {
+		e.send("tellusererror Illegal position: %s", err)
+		return
+	}
+	e.game = dragontoothmg.NewGame(board)
+}
+
+// Handles "usermove <move>", in coordinate notation or SAN. If the engine is on
+// move afterwards, it starts thinking.
+func (e *xboardEngine) userMove(str string) {
+	m, err := dragontoothmg.ParseMove(str)
+	if err != nil || !e.game.Board.IsLegal(m) {
+		if m, err = e.game.Board.ParseSan(str); err != nil {
+			e.send("Illegal move: %s", str)
+			return
+		}
+	}
+	e.play(m)
+	if !e.force && e.game.Board.Wtomove == e.engineWhite {
+		e.think()
+	}
+}
+
+// Plays a legal move, and announces the result if it ends the game.
+func (e *xboardEngine) play(m dragontoothmg.Move) {
+	e.game.Play(m)
+	if result := gameResult(e.game); result != "" {
+		e.send("%s", result)
+	}
+}
+
+// Takes back the last n moves, for "undo" and "remove", by replaying the game
+// without them.
+func (e *xboardEngine) takeBack(n int) {
+	moves := e.game.Moves
+	if n > len(moves) {
+		n = len(moves)
+	}
+	if n == 0 {
+		return
+	}
+	game := dragontoothmg.NewGame(e.game.Start)
+	for _, m := range moves[:len(moves)-n] {
+		game.Play(m)
+	}
+	e.game = game
+}
+
+// Handles "level <moves per control> <base> <increment>", where the increment is
+// in seconds. The base time is not needed, since XBoard sends "time" before each move.
+func (e *xboardEngine) level(tokens []string) {
+	if len(tokens) != 3 {
+		return
+	}
+	movesPer, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return
+	}
+	increment, err := strconv.ParseFloat(tokens[2], 64)
+	if err != nil {
+		return
+	}
+	e.movesPer = movesPer
+	e.increment = time.Duration(increment * float64(time.Second))
+	e.moveTime = 0
+}
+
+// The comments XBoard shows for draws, by the reason from Game.Outcome.
+var drawComments = map[string]string{
+	"stalemate":             "Stalemate",
+	"threefold repetition":  "Draw by repetition",
+	"fifty-move rule":       "Fifty move rule",
+	"insufficient material": "Insufficient material",
+}
+
+// Reports the result of the game in the format XBoard expects, or "" if the game
+// is not over.
+func gameResult(g *dragontoothmg.Game) string {
+	outcome, reason := g.Outcome()
+	comment := reason
+	switch {
+	case outcome == dragontoothmg.Ongoing:
+		return ""
+	case outcome == dragontoothmg.Draw && drawComments[reason] != "":
+		comment = drawComments[reason]
+	case reason == "checkmate" && outcome == dragontoothmg.WhiteWins:
+		comment = "White mates"
+	case reason == "checkmate":
+		comment = "Black mates"
+	}
+	return fmt.Sprintf("%v {%s}", outcome, comment)
+}
+
+// Starts thinking about the engine's move in the background, unless the game is over.
+func (e *xboardEngine) think() {
+	if gameResult(e.game) != "" {
+		return
+	}
+	limits := search.Limits{Depth: e.depth, MoveTime: e.moveTime}
+	if e.moveTime == 0 && e.clock > 0 {
+		limits.WTime, limits.BTime = e.clock, e.otherTime
+		limits.WInc, limits.BInc = e.increment, e.increment
+		if !e.engineWhite {
+			limits.WTime, limits.BTime = e.otherTime, e.clock
+		}
+		if e.movesPer > 0 {
+			limits.MovesToGo = e.movesPer - (int(e.game.Board.Fullmoveno)-1)%e.movesPer
+		}
+	}
+	if limits.Depth == 0 && limits.MoveTime == 0 && limits.WTime == 0 {
+		limits.MoveTime = 5 * time.Second // no time control was given
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	board, history := e.game.Board, append([]uint64(nil), e.game.History()...)
+	e.searcher.OnInfo = nil
+	if e.post {
+		e.searcher.OnInfo = func(info search.Info) { e.sendThinking(board, info) }
+	}
+	e.searching.Add(1)
+	go func() {
+		defer e.searching.Done()
+		result := e.searcher.Search(ctx, board, history, limits)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.discard || result.BestMove == 0 {
+			return
+		}
+		e.send("move %v", &result.BestMove)
+		e.play(result.BestMove)
+	}()
+}
+
+// Sends a line of thinking output: ply, score, time in centiseconds, nodes and
+// the principal variation in SAN. Mates are reported as 100000 + moves.
+func (e *xboardEngine) sendThinking(board dragontoothmg.Board, info search.Info) {
+	score := info.Score
+	if moves, mate := search.MateIn(info.Score); mate {
+		if moves > 0 {
+			score = 100000 + moves
+		} else {
+			score = -100000 + moves
+		}
+	}
+	pv := make([]string, len(info.PV))
+	for i, m := range info.PV {
+		pv[i] = board.ToSan(m)
+		board.Apply(m)
+	}
+	e.send("%d %d %d %d %s", info.Depth, score, info.Time.Milliseconds()/10, info.Nodes,
+		strings.Join(pv, " "))
+}
+
+// Stops thinking (if the engine is), without playing a move.
+func (e *xboardEngine) stop() {
+	if e.cancel == nil {
+		return
+	}
+	e.mu.Lock()
+	e.discard = true
+	e.mu.Unlock()
+	e.cancel()
+	e.cancel = nil
+	e.searching.Wait()
+	e.discard = false
+}
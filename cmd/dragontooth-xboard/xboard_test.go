@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Runs a scripted session, and returns the engine's output lines.
+func runScript(script string) []string {
+	var out bytes.Buffer
+	newXboardEngine(&out).run(strings.NewReader(script))
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func contains(lines []string, prefix string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFeatures(t *testing.T) {
+	lines := runScript("xboard\nprotover 2\nping 7\n")
+	if lines[0] != "feature done=0" || lines[2] != "feature done=1" || lines[3] != "pong 7" {
+		t.Error("Unexpected feature negotiation:", lines)
+	}
+	for _, feature := range []string{"setboard=1", "usermove=1", "ping=1", "myname=\"Dragontooth\""} {
+		if !strings.Contains(lines[1], feature) {
+			t.Error("Missing feature", feature, "in", lines[1])
+		}
+	}
+}
+
+func TestEnginePlaysBlack(t *testing.T) {
+	// After 1. f3 e5 2. g4, the engine (black) mates with Qh4.
+	lines := runScript("new\nsd 2\nusermove f2f3\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "move ") {
+		t.Error("The engine should reply to 1. f3:", lines)
+	}
+	lines = runScript("new\nsd 3\nforce\nusermove f2f3\nusermove e5\nplayother\nusermove g4\n")
+	if !contains(lines, "move d8h4") || lines[len(lines)-1] != "0-1 {Black mates}" {
+		t.Error("Expected fool's mate and the result, got:", lines)
+	}
+}
+
+func TestThinkingOutput(t *testing.T) {
+	lines := runScript("new\nsetboard 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1\npost\nsd 2\ngo\n")
+	if !contains(lines, "1 100001 ") || !strings.HasSuffix(lines[0], " Ra8#") {
+		t.Error("Expected a mate score and a SAN PV, got:", lines)
+	}
+	if lines[len(lines)-2] != "move a1a8" || lines[len(lines)-1] != "1-0 {White mates}" {
+		t.Error("Expected the back rank mate, got:", lines)
+	}
+}
+
+func TestUndoAndErrors(t *testing.T) {
+	lines := runScript("new\nforce\nusermove e2e4\nusermove e7e5\nremove\nusermove e2e5\n" +
+		"usermove e5\nundo\nusermove e2e4\nsetboard nonsense\nfoo\nvariant suicide\n")
+	for _, expected := range []string{"Illegal move: e2e5", "Illegal move: e5",
+		"tellusererror Illegal position", "Error (unknown command): foo", "Error (unsupported variant)"} {
+		if !contains(lines, expected) {
+			t.Error("Missing", expected, "in output:", lines)
+		}
+	}
+	if len(lines) != 5 {
+		t.Error("Unexpected output:", lines)
+	}
+	for _, fen := range []string{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1", "P3k3/8/8/8/8/8/8/4K3 w - - 0 1"} {
+		lines = runScript("new\nsetboard " + fen + "\n")
+		if len(lines) != 1 || !strings.HasPrefix(lines[0], "tellusererror Illegal position") {
+			t.Error("Expected", fen, "to be rejected, got:", lines)
+		}
+	}
+}
+
+func TestTimeControls(t *testing.T) {
+	lines := runScript("new\nlevel 40 5 0\ntime 1000\notim 1000\nusermove e2e4\n")
+	if !strings.HasPrefix(lines[len(lines)-1], "move ") {
+		t.Error("Clock search did not return a move:", lines)
+	}
+	start := time.Now()
+	lines = runScript("new\nst 0.1\nforce\nusermove e2e4\ngo\n")
+	if elapsed := time.Since(start); elapsed > time.Second || !strings.HasPrefix(lines[0], "move ") {
+		t.Error("Move time was not respected:", elapsed, lines)
+	}
+	lines = runScript("new\nforce\nsetboard 8/8/8/8/8/8/8/K6k w - - 0 1\ngo\nsetboard 8/8/8/8/8/8/8/KN5k b - - 0 1\n" +
+		"usermove h1g2\n")
+	if len(lines) != 1 || lines[0] != "1/2-1/2 {Insufficient material}" {
+		t.Error("Insufficient material was not detected:", lines)
+	}
+	// Bishops on squares of one color cannot mate either.
+	lines = runScript("new\nforce\nsetboard 4k3/8/8/8/8/2B5/8/B3K3 w - - 0 1\nusermove e1d2\n")
+	if len(lines) != 1 || lines[0] != "1/2-1/2 {Insufficient material}" {
+		t.Error("Insufficient material with same-colored bishops was not detected:", lines)
+	}
+}
+
+func TestDrawDetection(t *testing.T) {
+	lines := runScript("new\nforce\ng1f3\ng8f6\nf3g1\nf6g8\ng1f3\ng8f6\nf3g1\nf6g8\n")
+	if lines[len(lines)-1] != "1/2-1/2 {Draw by repetition}" {
+		t.Error("Threefold repetition was not detected:", lines)
+	}
+	lines = runScript("new\nsetboard 7k/5Q2/6K1/8/8/8/8/8 w - - 0 1\nforce\nusermove Qf7g6\n")
+	if lines[len(lines)-1] != "Illegal move: Qf7g6" {
+		t.Error("Unexpected output:", lines)
+	}
+	lines = runScript("new\nsetboard 7k/8/5QK1/8/8/8/8/8 w - - 0 1\nforce\nusermove Qf7\n")
+	if lines[len(lines)-1] != "1/2-1/2 {Stalemate}" {
+		t.Error("Stalemate was not detected:", lines)
+	}
+}
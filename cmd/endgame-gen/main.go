@@ -0,0 +1,77 @@
+// Command endgame-gen generates endgame tables by retrograde analysis and writes
+// them to a file, or probes positions in a file of tables.
+//
+// Examples:
+//
+//	endgame-gen -o kbnk.dteg KBNK
+//	endgame-gen -tables kbnk.dteg -probe "8/8/8/3k4/8/8/8/KBN5 w - - 0 1"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/endgame"
+)
+
+func main() {
+	out := flag.String("o", "endgame.dteg", "file to write the generated tables to")
+	tables := flag.String("tables", "", "file of tables to probe, instead of generating")
+	fen := flag.String("probe", "", "FEN of a position to probe")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: endgame-gen [-o file] material...")
+		fmt.Fprintln(os.Stderr, "       endgame-gen -tables file -probe fen")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *tables != "" {
+		if *fen == "" {
+			flag.Usage()
+			os.Exit(2)
+		}
+		file, err := os.Open(*tables)
+		if err != nil {
+			fail(err)
+		}
+		tb, err := endgame.Read(file)
+		file.Close()
+		if err != nil {
+			fail(err)
+		}
+		b := dragontoothmg.ParseFen(*fen)
+		m, result, err := tb.BestMove(&b)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println("result", result.WDL, "plies", result.Plies, "bestmove", m.String())
+		return
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	tb, err := endgame.Generate(flag.Args()...)
+	if err != nil {
+		fail(err)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		fail(err)
+	}
+	if _, err := tb.WriteTo(file); err != nil {
+		fail(err)
+	}
+	if err := file.Close(); err != nil {
+		fail(err)
+	}
+	fmt.Println("Wrote", tb.Materials(), "to", *out)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,167 @@
+// Command match plays games between two UCI engines, and reports the Elo
+// difference between them. Games are adjudicated with dragontoothmg, which keeps
+// the authoritative game state. Openings are played twice, with colors swapped.
+// With -sprt, the match stops as soon as a sequential probability ratio test
+// accepts either hypothesis.
+//
+// Example:
+//
+//	match -engine1 ./new -engine2 ./old -tc 10+0.1 -openings book.epd -games 1000 -sprt -elo0 0 -elo1 5 -pgnout games.pgn
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/uci"
+)
+
+// The settings of a match.
+type matchConfig struct {
+	games    int
+	tc       timeControl
+	openings []opening
+	hashMb   int
+	sprt     bool
+	elo0     float64
+	elo1     float64
+	alpha    float64
+	beta     float64
+	event    string
+}
+
+// The running totals of a match, from the first engine's point of view.
+type matchScore struct {
+	wins, losses, draws int
+}
+
+func main() {
+	engine1 := flag.String("engine1", "", "path of the first engine")
+	engine2 := flag.String("engine2", "", "path of the second engine")
+	tcStr := flag.String("tc", "10+0.1", "time control, as [moves/]seconds[+increment]")
+	openingsPath := flag.String("openings", "", "EPD or PGN file of openings (default: the standard position)")
+	pgnPath := flag.String("pgnout", "", "file to append finished games to, in PGN")
+	config := matchConfig{event: "Dragontooth match"}
+	flag.IntVar(&config.games, "games", 100, "maximum number of games")
+	flag.IntVar(&config.hashMb, "hash", 16, "hash size in megabytes, for engines with a Hash option")
+	flag.BoolVar(&config.sprt, "sprt", false, "stop early when the SPRT accepts a hypothesis")
+	flag.Float64Var(&config.elo0, "elo0", 0, "SPRT null hypothesis Elo difference")
+	flag.Float64Var(&config.elo1, "elo1", 5, "SPRT alternative hypothesis Elo difference")
+	flag.Float64Var(&config.alpha, "alpha", 0.05, "SPRT false positive rate")
+	flag.Float64Var(&config.beta, "beta", 0.05, "SPRT false negative rate")
+	flag.Parse()
+	if *engine1 == "" || *engine2 == "" {
+		fmt.Fprintln(os.Stderr, "Both -engine1 and -engine2 are required.")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	if config.tc, err = parseTimeControl(*tcStr); err != nil {
+		fail(err)
+	}
+	config.openings = []opening{{board: dragontoothmg.ParseFen(dragontoothmg.Startpos)}}
+	if *openingsPath != "" {
+		if config.openings, err = loadOpenings(*openingsPath); err != nil {
+			fail(err)
+		}
+	}
+	var pgn io.Writer = io.Discard
+	if *pgnPath != "" {
+		file, err := os.OpenFile(*pgnPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fail(err)
+		}
+		defer file.Close()
+		pgn = file
+	}
+
+	first, err := startPlayer(*engine1, config.hashMb)
+	if err != nil {
+		fail(err)
+	}
+	defer first.engine.Close()
+	second, err := startPlayer(*engine2, config.hashMb)
+	if err != nil {
+		fail(err)
+	}
+	defer second.engine.Close()
+	if first.name == second.name {
+		first.name, second.name = first.name+" (1)", second.name+" (2)"
+	}
+	runMatch(first, second, config, pgn, os.Stdout)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// Launches an engine, and sets its hash size if it has one.
+func startPlayer(path string, hashMb int) (player, error) {
+	engine, err := uci.Start(path)
+	if err != nil {
+		return player{}, err
+	}
+	if _, ok := engine.Options["hash"]; ok {
+		engine.SetOption("Hash", strconv.Itoa(hashMb))
+	}
+	if err := engine.IsReady(); err != nil {
+		engine.Close()
+		return player{}, err
+	}
+	name := engine.Name
+	if name == "" {
+		name = path
+	}
+	return player{name: name, engine: engine}, nil
+}
+
+// Plays the match, printing the score after each game, and returns the final score.
+func runMatch(first player, second player, config matchConfig, pgn io.Writer, log io.Writer) matchScore {
+	var score matchScore
+	lower, upper := sprtBounds(config.alpha, config.beta)
+	for i := 0; i < config.games; i++ {
+		start := config.openings[(i/2)%len(config.openings)]
+		white, black := first, second
+		if i%2 == 1 {
+			white, black = second, first
+		}
+		record := playGame(white, black, start, config.tc)
+		record.round = i + 1
+		if err := writePGN(pgn, record, config.event, config.tc); err != nil {
+			fmt.Fprintln(log, "Could not write PGN:", err)
+		}
+
+		firstWins := (record.outcome == dragontoothmg.WhiteWins) == (i%2 == 0)
+		switch {
+		case record.outcome == dragontoothmg.Draw:
+			score.draws++
+		case firstWins:
+			score.wins++
+		default:
+			score.losses++
+		}
+		elo, margin := eloEstimate(score.wins, score.losses, score.draws)
+		fmt.Fprintf(log, "Game %d: %s vs %s: %v {%s}\n", i+1, white.name, black.name, record.outcome, record.reason)
+		fmt.Fprintf(log, "Score of %s vs %s: %d - %d - %d, Elo %.1f +/- %.1f\n",
+			first.name, second.name, score.wins, score.losses, score.draws, elo, margin)
+		if !config.sprt {
+			continue
+		}
+		llr := sprtLLR(score.wins, score.losses, score.draws, config.elo0, config.elo1)
+		fmt.Fprintf(log, "SPRT: llr %.3f (%.3f, %.3f) [%g, %g]\n", llr, lower, upper, config.elo0, config.elo1)
+		if llr >= upper {
+			fmt.Fprintln(log, "SPRT: H1 accepted")
+			break
+		} else if llr <= lower {
+			fmt.Fprintln(log, "SPRT: H0 accepted")
+			break
+		}
+	}
+	return score
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/uci"
+)
+
+// How long past its clock an engine may take before its search is stopped.
+// The engine still loses on time, but the reply keeps the protocol in sync.
+const flagGrace = time.Second
+
+// A time control: moves per period (0 for the whole game), base time per
+// period, and increment per move.
+type timeControl struct {
+	moves     int
+	base      time.Duration
+	increment time.Duration
+}
+
+// Parses a time control in the PGN style, "[moves/]seconds[+increment]", e.g.
+// "40/60", "10+0.1" or "300".
+func parseTimeControl(str string) (timeControl, error) {
+	var tc timeControl
+	invalid := errors.New("Invalid time control " + str)
+	if i := strings.Index(str, "/"); i >= 0 {
+		moves, err := strconv.Atoi(str[:i])
+		if err != nil || moves <= 0 {
+			return tc, invalid
+		}
+		tc.moves, str = moves, str[i+1:]
+	}
+	baseStr, incStr := str, "0"
+	if i := strings.Index(str, "+"); i >= 0 {
+		baseStr, incStr = str[:i], str[i+1:]
+	}
+	base, err := strconv.ParseFloat(baseStr, 64)
+	if err != nil || base <= 0 {
+		return tc, invalid
+	}
+	inc, err := strconv.ParseFloat(incStr, 64)
+	if err != nil || inc < 0 {
+		return tc, invalid
+	}
+	tc.base = time.Duration(base * float64(time.Second))
+	tc.increment = time.Duration(inc * float64(time.Second))
+	return tc, nil
+}
+
+func (tc timeControl) String() string {
+	str := strconv.FormatFloat(tc.base.Seconds(), 'f', -1, 64)
+	if tc.moves > 0 {
+		str = strconv.Itoa(tc.moves) + "/" + str
+	}
+	if tc.increment > 0 {
+		str += "+" + strconv.FormatFloat(tc.increment.Seconds(), 'f', -1, 64)
+	}
+	return str
+}
+
+// A player: a running engine and the name to record in the PGN.
+type player struct {
+	name   string
+	engine *uci.Engine
+}
+
+// A finished game.
+type gameRecord struct {
+	round       int
+	white       string
+	black       string
+	game        *dragontoothmg.Game
+	outcome     dragontoothmg.Outcome
+	termination string // the PGN Termination tag: normal, time forfeit or rules infraction
+	reason      string // a human-readable explanation, e.g. "White mates" or "Black loses on time"
+}
+
+// Plays one game from an opening. The game is adjudicated by the rules, and an
+// engine that fails, plays an illegal move, or oversteps its clock loses.
+func playGame(white player, black player, start opening, tc timeControl) gameRecord {
+	g := dragontoothmg.NewGame(start.board)
+	for _, m := range start.moves {
+		g.Play(m) // already checked when the openings were loaded
+	}
+	record := gameRecord{white: white.name, black: black.name, game: g, termination: "normal"}
+	players := [2]player{black, white}
+	sideNames := [2]string{"Black", "White"}
+	for _, p := range players {
+		if err := p.engine.NewGame(); err != nil {
+			return forfeit(record, p == white, "rules infraction", p.name+" failed: "+err.Error())
+		}
+	}
+
+	clocks := [2]time.Duration{tc.base, tc.base}
+	movesMade := [2]int{}
+	for {
+		if outcome, reason := g.Outcome(); outcome != dragontoothmg.Ongoing {
+			record.outcome, record.reason = outcome, describe(outcome, reason)
+			return record
+		}
+		side := 0
+		if g.Board.Wtomove {
+			side = 1
+		}
+		engine := players[side].engine
+		limits := uci.Limits{WTime: clocks[1], BTime: clocks[0], WInc: tc.increment, BInc: tc.increment}
+		if tc.moves > 0 {
+			limits.MovesToGo = tc.moves - movesMade[side]%tc.moves
+		}
+		if err := engine.SetPosition(g.Start, g.Moves); err != nil {
+			return forfeit(record, side == 1, "rules infraction", players[side].name+" failed: "+err.Error())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), clocks[side]+flagGrace)
+		started := time.Now()
+		result, err := engine.Go(ctx, limits, nil)
+		elapsed := time.Since(started)
+		cancel()
+		if elapsed > clocks[side] {
+			return forfeit(record, side == 1, "time forfeit", sideNames[side]+" loses on time")
+		}
+		if err != nil || result.BestMove == 0 {
+			if err == nil {
+				err = errors.New("No move.")
+			}
+			return forfeit(record, side == 1, "rules infraction",
+				sideNames[side]+" makes an illegal move: "+err.Error())
+		}
+		clocks[side] += tc.increment - elapsed
+		movesMade[side]++
+		if tc.moves > 0 && movesMade[side]%tc.moves == 0 {
+			clocks[side] += tc.base
+		}
+		if err := g.Play(result.BestMove); err != nil {
+			return forfeit(record, side == 1, "rules infraction",
+				sideNames[side]+" makes an illegal move: "+err.Error())
+		}
+	}
+}
+
+// Ends the game with a loss for one side.
+func forfeit(record gameRecord, whiteLoses bool, termination string, reason string) gameRecord {
+	record.outcome = dragontoothmg.WhiteWins
+	if whiteLoses {
+		record.outcome = dragontoothmg.BlackWins
+	}
+	record.termination, record.reason = termination, reason
+	return record
+}
+
+// Describes a game ended by the rules, given the reason from Game.Outcome, as
+// in the PGN comments written by GUIs.
+func describe(outcome dragontoothmg.Outcome, reason string) string {
+	winner := "White"
+	switch {
+	case outcome == dragontoothmg.Draw:
+		return fmt.Sprintf("Draw by %s", reason)
+	case outcome == dragontoothmg.BlackWins:
+		winner = "Black"
+	}
+	if reason == "checkmate" {
+		return winner + " mates"
+	}
+	return fmt.Sprintf("%s wins: %s", winner, reason)
+}
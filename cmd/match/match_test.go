@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The tests run this test binary as tiny engines, selected by MATCH_ENGINE:
+// "first" plays the first legal move, "illegal" plays an illegal move,
+// "king" promotes a pawn on a7 to a king, and "slow" thinks until it is told to
+// stop.
+func TestMain(m *testing.M) {
+	if kind := os.Getenv("MATCH_ENGINE"); kind != "" {
+		tinyEngine(kind)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func tinyEngine(kind string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	var board dragontoothmg.Board
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+		switch tokens[0] {
+		case "uci":
+			fmt.Println("id name Tiny " + kind)
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "position":
+			board = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+			movesIdx := len(tokens)
+			for i, token := range tokens {
+				if token == "moves" {
+					movesIdx = i
+				}
+			}
+			if tokens[1] == "fen" {
+				board = dragontoothmg.ParseFen(strings.Join(tokens[2:movesIdx], " "))
+			}
+			for i := movesIdx + 1; i < len(tokens); i++ {
+				m, _ := dragontoothmg.ParseMove(tokens[i])
+				board.Apply(m)
+			}
+		case "go":
+			switch kind {
+			case "first":
+				moves := board.GenerateLegalMoves()
+				fmt.Printf("bestmove %v\n", &moves[0])
+			case "illegal":
+				fmt.Println("bestmove a1a1")
+			case "king":
+				fmt.Println("bestmove a7a8k")
+			}
+		case "stop":
+			moves := board.GenerateLegalMoves()
+			fmt.Printf("bestmove %v\n", &moves[0])
+		case "quit":
+			return
+		}
+	}
+}
+
+func startTiny(t *testing.T, kind string) player {
+	os.Setenv("MATCH_ENGINE", kind)
+	defer os.Unsetenv("MATCH_ENGINE")
+	p, err := startPlayer(os.Args[0], 1)
+	if err != nil {
+		t.Fatal("Could not start the tiny engine:", err)
+	}
+	return p
+}
+
+func TestTimeControl(t *testing.T) {
+	tests := map[string]timeControl{
+		"10+0.1": {0, 10 * time.Second, 100 * time.Millisecond},
+		"40/60":  {40, time.Minute, 0},
+		"0.5":    {0, 500 * time.Millisecond, 0},
+	}
+	for str, expected := range tests {
+		tc, err := parseTimeControl(str)
+		if err != nil || tc != expected || tc.String() != str {
+			t.Error("Expected", expected, "for", str, "but got", tc, err)
+		}
+	}
+	for _, str := range []string{"", "x", "0/60", "10+", "-5", "10+-1"} {
+		if _, err := parseTimeControl(str); err == nil {
+			t.Error("Invalid time control", str, "was accepted.")
+		}
+	}
+}
+
+func TestElo(t *testing.T) {
+	if elo, margin := eloEstimate(10, 10, 10); elo != 0 || margin <= 0 {
+		t.Error("An even score should be 0 Elo, got", elo, margin)
+	}
+	if elo, _ := eloEstimate(75, 25, 0); math.Abs(elo-190.8) > 0.1 {
+		t.Error("A 75% score should be about 191 Elo, got", elo)
+	}
+	if elo, margin := eloEstimate(5, 0, 0); !math.IsInf(elo, 1) || !math.IsInf(margin, 1) {
+		t.Error("A perfect score should be infinite, got", elo, margin)
+	}
+
+	lower, upper := sprtBounds(0.05, 0.05)
+	if math.Abs(lower+2.944) > 0.001 || math.Abs(upper-2.944) > 0.001 {
+		t.Error("Unexpected SPRT bounds:", lower, upper)
+	}
+	if llr := sprtLLR(0, 0, 0, 0, 5); llr != 0 {
+		t.Error("No games should give no evidence, got", llr)
+	}
+	if sprtLLR(600, 400, 1000, 0, 5) <= upper || sprtLLR(400, 600, 1000, 0, 5) >= lower {
+		t.Error("A clear result was not accepted.")
+	}
+	if llr := sprtLLR(3, 0, 0, 0, 5); llr <= 0 || math.IsInf(llr, 0) {
+		t.Error("One-sided results should give finite positive evidence, got", llr)
+	}
+}
+
+func TestOpenings(t *testing.T) {
+	epd, err := parseEPD("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 bm e5;\n\n" +
+		"4k3/8/8/8/8/8/8/4K3 w - -\n")
+	if err != nil || len(epd) != 2 || epd[0].board.Wtomove || len(epd[1].moves) != 0 {
+		t.Error("EPD was not parsed:", epd, err)
+	}
+	for _, line := range []string{"bad", "8/8/8 w - -", "4k3/8/8/8/8/8/8/P3K3 w - -", "4k3/8/8/8/8/8/8/4K3 w Kx -"} {
+		if _, err := parseEPD(line + "\n"); err == nil {
+			t.Error("The invalid EPD line", line, "was accepted.")
+		}
+	}
+	pgn := `[Event "one"]
+[White "?"]
+
+1. e4 {best by test} e5 2. Nf3 (2. f4 exf4) Nc6 $1 3. Bb5 a6 4. O-O 1-0
+
+[Event "two"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1"]
+
+1... Kd7 ; a comment
+2. e4 *
+`
+	openings, err := parsePGN(pgn)
+	if err != nil || len(openings) != 2 || len(openings[0].moves) != 7 || len(openings[1].moves) != 2 {
+		t.Fatal("PGN was not parsed:", openings, err)
+	}
+	if openings[0].moves[6].String() != "e1g1" || openings[1].board.Wtomove {
+		t.Error("PGN moves or FEN were not parsed:", openings)
+	}
+	if _, err := parsePGN("1. e4 e4\n"); err == nil {
+		t.Error("An illegal PGN move was accepted.")
+	}
+	if _, err := parsePGN("[FEN \"4k3/8/8/8/8/8/8/4K3 w KQ - 0 1 x\"]\n\n1. Kd2 *\n"); err == nil {
+		t.Error("An invalid PGN FEN was accepted.")
+	}
+}
+
+func TestPlayGame(t *testing.T) {
+	first, second := startTiny(t, "first"), startTiny(t, "first")
+	defer first.engine.Close()
+	defer second.engine.Close()
+	epd, _ := parseEPD("4k3/8/8/8/8/8/3PP3/4K3 w - -\n")
+	tc := timeControl{moves: 10, base: 5 * time.Second}
+	record := playGame(first, second, epd[0], tc)
+	if record.outcome == dragontoothmg.Ongoing || record.termination != "normal" ||
+		!strings.HasPrefix(record.reason, "Draw by") && !strings.HasSuffix(record.reason, " mates") {
+		t.Error("The game was not adjudicated:", record.outcome, record.reason)
+	}
+	if outcome, _ := record.game.Outcome(); outcome != record.outcome {
+		t.Error("The recorded outcome does not match the game:", outcome, record.outcome)
+	}
+
+	// The PGN must contain the whole game.
+	var out bytes.Buffer
+	record.round = 1
+	if err := writePGN(&out, record, "Test", tc); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"[Round \"1\"]", "[White \"Tiny first\"]", "[FEN \"4k3/8/8/8/8/8/3PP3/4K3 w - - 0 1\"]",
+		"[TimeControl \"10/5\"]", "[Result \"" + record.outcome.String() + "\"]"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Error("Missing", expected, "in PGN:", out.String())
+		}
+	}
+	openings, err := parsePGN(out.String())
+	if err != nil || len(openings) != 1 || len(openings[0].moves) != len(record.game.Moves) {
+		t.Error("The PGN could not be read back:", err, out.String())
+	}
+}
+
+func TestIllegalPromotion(t *testing.T) {
+	king, first := startTiny(t, "king"), startTiny(t, "first")
+	defer king.engine.Close()
+	defer first.engine.Close()
+	start := opening{board: dragontoothmg.ParseFen("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")}
+	record := playGame(king, first, start, timeControl{base: 5 * time.Second})
+	if record.outcome != dragontoothmg.BlackWins || record.termination != "rules infraction" ||
+		len(record.game.Moves) != 0 {
+		t.Error("Expected white to forfeit for promoting to a king, got", record.outcome, record.termination,
+			record.reason, record.game.Moves)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		outcome  dragontoothmg.Outcome
+		reason   string
+		expected string
+	}{
+		{dragontoothmg.WhiteWins, "checkmate", "White mates"},
+		{dragontoothmg.BlackWins, "checkmate", "Black mates"},
+		{dragontoothmg.WhiteWins, "three checks", "White wins: three checks"},
+		{dragontoothmg.BlackWins, "horde captured", "Black wins: horde captured"},
+		{dragontoothmg.Draw, "stalemate", "Draw by stalemate"},
+	}
+	for _, test := range tests {
+		if result := describe(test.outcome, test.reason); result != test.expected {
+			t.Error("Expected", test.expected, "but got", result)
+		}
+	}
+}
+
+func TestForfeits(t *testing.T) {
+	first, illegal := startTiny(t, "first"), startTiny(t, "illegal")
+	defer first.engine.Close()
+	defer illegal.engine.Close()
+	config := matchConfig{games: 10, tc: timeControl{base: time.Second},
+		openings: []opening{{board: dragontoothmg.ParseFen(dragontoothmg.Startpos)}},
+		sprt:     true, elo0: 0, elo1: 5, alpha: 0.05, beta: 0.05}
+	var log bytes.Buffer
+	score := runMatch(first, illegal, config, &bytes.Buffer{}, &log)
+	if score.losses != 0 || score.draws != 0 || score.wins == 0 || score.wins == 10 {
+		t.Error("Expected an early stop with only wins, got", score)
+	}
+	if !strings.Contains(log.String(), "makes an illegal move") || !strings.Contains(log.String(), "H1 accepted") {
+		t.Error("Unexpected log:", log.String())
+	}
+
+	slow := startTiny(t, "slow")
+	defer slow.engine.Close()
+	start := opening{board: dragontoothmg.ParseFen(dragontoothmg.Startpos)}
+	record := playGame(slow, first, start, timeControl{base: 100 * time.Millisecond})
+	if record.outcome != dragontoothmg.BlackWins || record.termination != "time forfeit" {
+		t.Error("Expected a loss on time, got", record.outcome, record.reason)
+	}
+}
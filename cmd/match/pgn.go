@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A starting position for a pair of games, with any moves that lead from it.
+type opening struct {
+	board dragontoothmg.Board
+	moves []dragontoothmg.Move
+}
+
+// Loads openings from an EPD file (one position per line) or a PGN file (the
+// moves of each game, from its FEN tag if present).
+func loadOpenings(path string) ([]opening, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var openings []opening
+	if strings.ToLower(filepath.Ext(path)) == ".pgn" {
+		openings, err = parsePGN(string(data))
+	} else {
+		openings, err = parseEPD(string(data))
+	}
+	if err == nil && len(openings) == 0 {
+		err = errors.New("No openings in " + path)
+	}
+	return openings, err
+}
+
+// Parses EPD lines. Only the position fields are used; operations are ignored.
+func parseEPD(data string) ([]opening, error) {
+	var openings []opening
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, errors.New("Invalid EPD line: " + line)
+		}
+		board, err := dragontoothmg.ParseFenChecked(strings.Join(fields[:4], " ") + " 0 1")
+		if err != nil {
+			return nil, errors.New("Invalid EPD line: " + line)
+		}
+		openings = append(openings, opening{board: board})
+	}
+	return openings, nil
+}
+
+// Parses the games of a PGN file. Comments, variations, annotations and
+// results are skipped.
+func parsePGN(data string) ([]opening, error) {
+	var openings []opening
+	fen, movetext := "", ""
+	inGame, inTags := false, false
+	finish := func() error {
+		if !inGame {
+			return nil
+		}
+		o, err := parseGame(fen, movetext)
+		if err != nil {
+			return err
+		}
+		openings = append(openings, o)
+		fen, movetext, inGame = "", "", false
+		return nil
+	}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "%") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !inTags {
+				if err := finish(); err != nil {
+					return nil, err
+				}
+				inTags = true
+			}
+			inGame = true
+			if strings.HasPrefix(line, "[FEN ") {
+				fen = strings.Trim(strings.TrimPrefix(line, "[FEN "), "\"]")
+			}
+			continue
+		}
+		inTags = false
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i]
+		}
+		if line != "" {
+			movetext += " " + line
+			inGame = true
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return openings, nil
+}
+
+// Plays the movetext of one game from fen, or from the standard position.
+func parseGame(fen string, movetext string) (opening, error) {
+	if fen == "" {
+		fen = dragontoothmg.Startpos
+	}
+	board, err := dragontoothmg.ParseFenChecked(fen)
+	if err != nil {
+		return opening{}, errors.New("Invalid FEN " + fen + ": " + err.Error())
+	}
+	o := opening{board: board}
+	depth := 0 // of nested comments and variations
+	for _, token := range strings.Fields(strings.NewReplacer("{", " { ", "}", " } ",
+		"(", " ( ", ")", " ) ").Replace(movetext)) {
+		switch {
+		case token == "{" || token == "(":
+			depth++
+		case token == "}" || token == ")":
+			depth--
+		case depth > 0 || strings.HasPrefix(token, "$"):
+		case token == "1-0" || token == "0-1" || token == "1/2-1/2" || token == "*":
+		default:
+			// strip move numbers such as "12." and "12..."
+			if i := strings.LastIndex(token, "."); i >= 0 {
+				token = token[i+1:]
+			}
+			if token == "" {
+				continue
+			}
+			m, err := board.ParseSan(token)
+			if err != nil {
+				return o, err
+			}
+			o.moves = append(o.moves, m)
+			board.Apply(m)
+		}
+	}
+	return o, nil
+}
+
+// Writes a finished game in PGN.
+func writePGN(w io.Writer, record gameRecord, event string, tc timeControl) error {
+	g := record.game
+	var out strings.Builder
+	tag := func(name, value string) { fmt.Fprintf(&out, "[%s \"%s\"]\n", name, value) }
+	tag("Event", event)
+	tag("Site", "?")
+	tag("Date", time.Now().Format("2006.01.02"))
+	tag("Round", fmt.Sprint(record.round))
+	tag("White", record.white)
+	tag("Black", record.black)
+	tag("Result", record.outcome.String())
+	if fen := g.Start.ToFen(); fen != dragontoothmg.Startpos {
+		tag("SetUp", "1")
+		tag("FEN", fen)
+	}
+	tag("TimeControl", tc.String())
+	tag("Termination", record.termination)
+	out.WriteString("\n")
+
+	board := g.Start
+	lineLength := 0
+	write := func(token string) {
+		if lineLength+len(token) >= 80 {
+			out.WriteString("\n")
+			lineLength = 0
+		} else if lineLength > 0 {
+			out.WriteString(" ")
+			lineLength++
+		}
+		out.WriteString(token)
+		lineLength += len(token)
+	}
+	for i, m := range g.Moves {
+		if board.Wtomove {
+			write(fmt.Sprintf("%d.", board.Fullmoveno))
+		} else if i == 0 {
+			write(fmt.Sprintf("%d...", board.Fullmoveno))
+		}
+		write(board.ToSan(m))
+		board.Apply(m)
+	}
+	write("{" + record.reason + "}")
+	write(record.outcome.String())
+	out.WriteString("\n\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+)
+
+// The expected score of a player rated elo points above the opponent.
+func expectedScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// The Elo difference implied by an expected score, which is infinite for a
+// score of 0 or 1.
+func eloFromScore(score float64) float64 {
+	if score >= 1 {
+		return math.Inf(1)
+	} else if score <= 0 {
+		return math.Inf(-1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
+
+// The score per game, and its variance, for a wins/losses/draws record.
+func scoreAndVariance(wins, losses, draws float64) (float64, float64) {
+	n := wins + losses + draws
+	if n == 0 {
+		return 0.5, 0
+	}
+	score := (wins + draws/2) / n
+	variance := (wins*math.Pow(1-score, 2) + losses*math.Pow(score, 2) + draws*math.Pow(0.5-score, 2)) / n
+	return score, variance
+}
+
+// Estimates the Elo difference and its 95% error margin. The results are
+// infinite if either side has scored nothing.
+func eloEstimate(wins, losses, draws int) (elo float64, margin float64) {
+	score, variance := scoreAndVariance(float64(wins), float64(losses), float64(draws))
+	n := float64(wins + losses + draws)
+	if n == 0 || score == 0 || score == 1 {
+		return eloFromScore(score), math.Inf(1)
+	}
+	deviation := 1.96 * math.Sqrt(variance/n)
+	low, high := eloFromScore(score-deviation), eloFromScore(score+deviation)
+	return eloFromScore(score), (high - low) / 2
+}
+
+// The log-likelihood ratio of the hypotheses that the Elo difference is elo1
+// rather than elo0, using the normal approximation to the trinomial
+// distribution of game results. As in fishtest, empty counts are replaced by a
+// small number, so that one-sided results still give a finite ratio.
+func sprtLLR(wins, losses, draws int, elo0 float64, elo1 float64) float64 {
+	if wins+losses+draws == 0 {
+		return 0
+	}
+	counts := [3]float64{float64(wins), float64(losses), float64(draws)}
+	for i := range counts {
+		counts[i] = math.Max(counts[i], 1e-3)
+	}
+	score, variance := scoreAndVariance(counts[0], counts[1], counts[2])
+	s0, s1 := expectedScore(elo0), expectedScore(elo1)
+	n := counts[0] + counts[1] + counts[2]
+	return n * (s1 - s0) * (2*score - s0 - s1) / (2 * variance)
+}
+
+// The LLR bounds for the given false positive (alpha) and false negative (beta) rates.
+// The test accepts elo0 below the lower bound, and elo1 above the upper bound.
+func sprtBounds(alpha float64, beta float64) (lower float64, upper float64) {
+	return math.Log(beta / (1 - alpha)), math.Log((1 - beta) / alpha)
+}
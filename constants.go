@@ -28,6 +28,26 @@ func generateZobristConstants() {
 	for i := 0; i < 4; i++ {
 		castleRightsZobristC[i] = rand.Uint64()
 	}
+	for i := 0; i < 2; i++ {
+		for j := 1; j < 4; j++ {
+			checksZobristC[i][j] = rand.Uint64()
+		}
+	}
+	for i := 0; i < 12; i++ {
+		for j := 0; j < 64; j++ {
+			materialZobristC[i][j] = rand.Uint64()
+		}
+	}
+	for i := 0; i < 2; i++ {
+		for j := Pawn; j <= Queen; j++ {
+			for k := 1; k < 17; k++ {
+				pocketZobristC[i][j][k] = rand.Uint64()
+			}
+		}
+	}
+	for i := 0; i < 64; i++ {
+		promotedZobristC[i] = rand.Uint64()
+	}
 }
 
 func generateRookMagicTable() {
@@ -176,7 +196,14 @@ const Startpos = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 // Zobrist Constants
 var pieceSquareZobristC [12][64]uint64
 var castleRightsZobristC [4]uint64
-var whiteToMoveZobristC uint64 // active if white is to move
+var whiteToMoveZobristC uint64      // active if white is to move
+var checksZobristC [2][4]uint64     // by color and number of checks given, in ThreeCheck
+var pocketZobristC [2][6][17]uint64 // by color, Piece and number in the pocket, in Crazyhouse
+var promotedZobristC [64]uint64     // by square of a promoted piece, in Crazyhouse
+
+// Material key constants, by piece (as in pieceSquareZobristC) and the index of
+// the piece among those of its kind.
+var materialZobristC [12][64]uint64
 
 const kDefaultMoveListLength int = 65
 
@@ -0,0 +1,209 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// In Crazyhouse, a captured piece goes to the capturer's pocket, and instead of
+// moving, a side may drop a piece from its pocket onto any empty square (pawns
+// not on the first or eighth rank). Promoted pieces are tracked, since they
+// revert to pawns when captured.
+
+// The letters of the pieces that can be in a pocket, indexed by Piece, as used
+// in drop moves ("N@f3") and in the FEN pocket ("[QNp]").
+const dropLetters = " PNBRQ"
+
+// The number of pieces of a type in a side's pocket, in Crazyhouse.
+func (b *Board) Pocket(white bool, piece Piece) int {
+	if piece < Pawn || piece > Queen {
+		return 0
+	}
+	if white {
+		return int(b.pockets[0][piece])
+	}
+	return int(b.pockets[1][piece])
+}
+
+// A bitboard of the pieces (of both colors) that were promoted from pawns, in
+// Crazyhouse.
+func (b *Board) Promoted() uint64 {
+	return b.promoted
+}
+
+// Adds a piece to a side's pocket, updating the hash.
+func (b *Board) addToPocket(white bool, piece Piece) {
+	color := 1
+	if white {
+		color = 0
+	}
+	count := b.pockets[color][piece]
+	b.hash ^= pocketZobristC[color][piece][count] ^ pocketZobristC[color][piece][count+1]
+	b.pockets[color][piece]++
+}
+
+// Removes a piece from a side's pocket, updating the hash.
+func (b *Board) takeFromPocket(white bool, piece Piece) {
+	color := 1
+	if white {
+		color = 0
+	}
+	count := b.pockets[color][piece]
+	b.hash ^= pocketZobristC[color][piece][count] ^ pocketZobristC[color][piece][count-1]
+	b.pockets[color][piece]--
+}
+
+// Generates the drops of the side to move onto the empty squares in allowDest.
+// Drops never expose our king, so when in check allowDest only needs to hold
+// the squares that block it.
+func (b *Board) dropMoves(list moveList, allowDest uint64) {
+	pocket := &(b.pockets[1])
+	if b.Wtomove {
+		pocket = &(b.pockets[0])
+	}
+	empty := ^(b.White.All | b.Black.All) & allowDest
+	for piece := Piece(Pawn); piece <= Queen; piece++ {
+		if pocket[piece] == 0 {
+			continue
+		}
+		targets := empty
+		if piece == Pawn {
+			targets &^= onlyRank[0] | onlyRank[7]
+		}
+		for targets != 0 {
+			to := bits.TrailingZeros64(targets)
+			targets &= targets - 1
+			var move Move
+			move.Setto(Square(to)).Setdrop(piece)
+			list.add(move, piece, Nothing, 0)
+		}
+	}
+}
+
+// Whether a drop is allowed by the pocket and the board, without considering
+// whether our king is in check.
+func (b *Board) isPseudoLegalDrop(m Move) bool {
+	piece := m.Drop()
+	toBitboard := uint64(1) << m.To()
+	if b.Variant != Crazyhouse || b.Pocket(b.Wtomove, piece) == 0 || toBitboard&(b.White.All|b.Black.All) != 0 {
+		return false
+	}
+	return piece != Pawn || toBitboard&(onlyRank[0]|onlyRank[7]) == 0
+}
+
+// Whether a pseudo-legal drop leaves our king out of check: it must block the
+// only attacker.
+func (b *Board) isLegalDrop(m Move) bool {
+	var kingLocation uint8
+	if b.Wtomove {
+		kingLocation = uint8(bits.TrailingZeros64(b.White.Kings))
+	} else {
+		kingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
+	}
+	kingAttackers, blockerDestinations := b.countAttacks(b.Wtomove, kingLocation, 2)
+	return kingAttackers == 0 || (kingAttackers == 1 && blockerDestinations&(uint64(1)<<m.To()) != 0)
+}
+
+// Applies a drop. Like pawn moves, pawn drops reset the halfmove clock.
+func (b *Board) applyDrop(m Move) {
+	piece := m.Drop()
+	ourBitboardPtr := &(b.White)
+	zobristIndex := int(piece) - 1
+	if !b.Wtomove {
+		ourBitboardPtr = &(b.Black)
+		zobristIndex += 6
+		b.Fullmoveno++ // increment after black's move
+	}
+	toBitboard := uint64(1) << m.To()
+	*pieceBitboardPtr(ourBitboardPtr, piece) |= toBitboard
+	ourBitboardPtr.All |= toBitboard
+	b.hash ^= pieceSquareZobristC[zobristIndex][m.To()]
+	b.addMaterial(zobristIndex, m.To())
+	b.takeFromPocket(b.Wtomove, piece)
+
+	if piece == Pawn {
+		b.Halfmoveclock = 0
+	} else {
+		b.Halfmoveclock++
+	}
+	b.hash ^= uint64(b.Enpassant)
+	b.Enpassant = 0
+	b.hash ^= whiteToMoveZobristC
+	b.Wtomove = !b.Wtomove
+}
+
+// Updates the pockets and promoted pieces for a move about to be applied by the
+// side to move: a captured piece goes to our pocket (as a pawn, if it was
+// promoted), and promoted pieces keep their mark as they move.
+func (b *Board) updatePockets(m Move, capturedPieceType Piece, enpassant bool) {
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	if capturedPieceType != Nothing {
+		if b.promoted&toBitboard != 0 {
+			capturedPieceType = Pawn
+			b.promoted &^= toBitboard
+			b.hash ^= promotedZobristC[m.To()]
+		}
+		b.addToPocket(b.Wtomove, capturedPieceType)
+	} else if enpassant {
+		b.addToPocket(b.Wtomove, Pawn)
+	}
+	if b.promoted&fromBitboard != 0 || m.Promote() != Nothing {
+		b.promoted = b.promoted&^fromBitboard | toBitboard
+		b.hash ^= promotedZobristC[m.To()]
+		if m.Promote() == Nothing {
+			b.hash ^= promotedZobristC[m.From()]
+		}
+	}
+}
+
+// Splits the pocket ("[QNp]") and the promoted piece marks ("Q~") off the board
+// field of a Crazyhouse FEN, returning the plain board field.
+func parsePocketFen(field string) (string, [2][6]uint8, uint64) {
+	var pockets [2][6]uint8
+	var promoted uint64
+	if open := strings.IndexByte(field, '['); open >= 0 {
+		for _, c := range strings.TrimSuffix(field[open+1:], "]") {
+			if piece := strings.IndexRune(dropLetters, c); piece > 0 {
+				pockets[0][piece]++
+			} else if piece = strings.IndexRune(strings.ToLower(dropLetters), c); piece > 0 {
+				pockets[1][piece]++
+			}
+		}
+		field = field[:open]
+	}
+	var board strings.Builder
+	square := 56 // the square of the next character, from a8
+	for _, c := range field {
+		switch {
+		case c == '~':
+			if square > 0 {
+				promoted |= uint64(1) << uint(square-1)
+			}
+			continue
+		case c == '/':
+			square -= 16
+		case c >= '1' && c <= '8':
+			square += int(c - '0')
+		default:
+			square++
+		}
+		board.WriteRune(c)
+	}
+	return board.String(), pockets, promoted
+}
+
+// Formats the pockets as in a Crazyhouse FEN, e.g. "[QNp]".
+func (b *Board) pocketFen() string {
+	pocket := "["
+	for color := 0; color < 2; color++ {
+		for piece := Queen; piece >= Pawn; piece-- {
+			letter := dropLetters[piece : piece+1]
+			if color == 1 {
+				letter = strings.ToLower(letter)
+			}
+			pocket += strings.Repeat(letter, int(b.pockets[color][piece]))
+		}
+	}
+	return pocket + "]"
+}
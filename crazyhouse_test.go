@@ -0,0 +1,120 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestParseDrop(t *testing.T) {
+	m, err := ParseMove("N@f3")
+	if err != nil || !m.IsDrop() || m.Drop() != Knight || m.To() != 21 || m.Promote() != Nothing {
+		t.Error("Expected a knight drop on f3, got", m.String(), err)
+	}
+	if m.String() != "N@f3" {
+		t.Error("Expected N@f3 but got", m.String())
+	}
+	for _, str := range []string{"K@f3", "N@f9", "x@e4"} {
+		if _, err := ParseMove(str); err == nil {
+			t.Error("Expected an error for", str)
+		}
+	}
+}
+
+func TestCrazyhouseFen(t *testing.T) {
+	for _, fen := range []string{
+		Crazyhouse.Startpos(),
+		"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R[QPPbn] w KQkq - 0 1",
+		"Q~3k3/8/8/8/8/8/8/4K2q~[NNp] b - - 0 1",
+	} {
+		b := ParseVariantFen(fen, Crazyhouse)
+		if b.ToFen() != fen {
+			t.Error("Expected", fen, "but got", b.ToFen())
+		}
+	}
+	b := ParseVariantFen("Q~3k3/8/8/8/8/8/8/4K3[QPPbn] w - - 0 1", Crazyhouse)
+	if b.Pocket(true, Queen) != 1 || b.Pocket(true, Pawn) != 2 || b.Pocket(false, Knight) != 1 ||
+		b.Pocket(false, Queen) != 0 || b.Promoted() != uint64(1)<<56 {
+		t.Error("Expected the pockets and the promoted queen of", b.ToFen())
+	}
+}
+
+func TestCrazyhouseApply(t *testing.T) {
+	tests := []struct {
+		fen      string
+		moves    []string
+		expected string
+	}{
+		{"4k3/8/8/8/8/8/8/4K3[N] w - - 0 1", []string{"N@f3"},
+			"4k3/8/8/8/8/5N2/8/4K3[] b - - 1 1"}, // a drop
+		{"4k3/8/8/8/8/8/8/3qK3[] w - - 0 1", []string{"e1d1"},
+			"4k3/8/8/8/8/8/8/3K4[Q] b - - 0 1"}, // a capture
+		{"4k3/8/8/8/8/8/8/3q~K3[] w - - 0 1", []string{"e1d1"},
+			"4k3/8/8/8/8/8/8/3K4[P] b - - 0 1"}, // a promoted piece reverts to a pawn
+		{"4k3/P7/8/8/8/8/8/4K3[] w - - 0 1", []string{"a7a8q", "e8e7", "a8b8"},
+			"1Q~6/4k3/8/8/8/8/8/4K3[] b - - 2 2"}, // a promoted piece keeps its mark
+		{"4k3/8/8/3pP3/8/8/8/4K3[] w - d6 0 1", []string{"e5d6", "e8d7", "P@e7"},
+			"8/3kP3/3P4/8/8/8/8/4K3[] b - - 0 2"}, // en passant
+	}
+	for _, test := range tests {
+		b := ParseVariantFen(test.fen, Crazyhouse)
+		for _, str := range test.moves {
+			m := parseMove(str)
+			if !b.IsLegal(m) {
+				t.Error("Expected", str, "to be legal in", b.ToFen())
+			}
+			b.Apply(m)
+			if b.Hash() != recomputeBoardHash(&b) {
+				t.Error("The hash is out of date after", str, "in", b.ToFen())
+			}
+		}
+		if b.ToFen() != test.expected {
+			t.Error("Expected", test.expected, "but got", b.ToFen())
+		}
+	}
+}
+
+func TestCrazyhouseDrops(t *testing.T) {
+	// Drops must block a check, and pawns may not be dropped on the back ranks.
+	tests := map[string]map[string]bool{
+		"4k3/8/8/8/8/8/8/r3K3[NP] w - - 0 1":  {"N@d1": true, "N@b1": true, "N@e4": false, "P@d1": false, "Q@d1": false},
+		"4k3/8/8/8/8/8/8/4K3[P] w - - 0 1":    {"P@e2": true, "P@a7": true, "P@a8": false, "P@h1": false, "P@e1": false},
+		"4k3/8/8/8/8/3n4/8/4K2r[Q] w - - 0 1": {"Q@f1": false, "Q@a4": false}, // double check
+		"4k3/8/8/8/8/8/8/4K3[N] b - - 0 1":    {"N@f6": false},                // not in black's pocket
+	}
+	for fen, moves := range tests {
+		b := ParseVariantFen(fen, Crazyhouse)
+		legal := make(map[Move]bool)
+		for _, m := range b.GenerateLegalMoves() {
+			legal[m] = true
+		}
+		for str, expected := range moves {
+			m := parseMove(str)
+			if b.IsLegal(m) != expected || legal[m] != expected {
+				t.Error("Expected", str, "to be legal:", expected, "in", fen)
+			}
+		}
+	}
+	b := ParseVariantFen("4k3/8/8/8/8/8/8/4K3[N] w - - 0 1", Crazyhouse)
+	if b.IsLegal(parseMove("N@f3")) != true {
+		t.Error("Expected N@f3 to be legal in Crazyhouse")
+	}
+	b.Variant = Standard
+	if b.IsLegal(parseMove("N@f3")) != false {
+		t.Error("Expected drops to be illegal in standard chess")
+	}
+}
+
+func TestCrazyhouseSan(t *testing.T) {
+	b := ParseVariantFen("4k3/8/8/8/8/8/8/4K3[NP] w - - 0 1", Crazyhouse)
+	for san, expected := range map[string]string{"N@f3": "N@f3", "P@e4": "P@e4", "@e4": "P@e4", "N@d6+": "N@d6"} {
+		m, err := b.ParseSan(san)
+		if err != nil || m.String() != expected {
+			t.Error("Expected", expected, "for", san, "but got", m.String(), err)
+		}
+	}
+	if san := b.ToSan(parseMove("N@d6")); san != "N@d6+" {
+		t.Error("Expected N@d6+ but got", san)
+	}
+	if _, err := b.ParseSan("Q@e4"); err == nil {
+		t.Error("Expected an error for a drop of a piece not in the pocket")
+	}
+}
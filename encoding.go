@@ -0,0 +1,244 @@
+package dragontoothmg
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Boards encode as FEN and moves as UCI long algebraic notation, in text and in
+// JSON. The marshaling methods have value receivers, so that boards and moves
+// stored by value in other structures encode the same way as pointers to them.
+
+// Encodes the board as FEN. Implements encoding.TextMarshaler.
+func (b Board) MarshalText() ([]byte, error) {
+	return []byte(b.ToFen()), nil
+}
+
+// Decodes a board from FEN, with the rules of the board's current Variant.
+// Returns an error, and leaves the board unchanged, if the FEN is malformed.
+// Implements encoding.TextUnmarshaler.
+func (b *Board) UnmarshalText(text []byte) error {
+	nb, err := ParseVariantFenChecked(string(text), b.Variant)
+	if err != nil {
+		return err
+	}
+	*b = nb
+	return nil
+}
+
+// Encodes the board as a JSON string holding its FEN. Implements json.Marshaler.
+func (b Board) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.ToFen())
+}
+
+// Decodes a board from a JSON string holding a FEN, with the rules of the
+// board's current Variant, or from the object of Board.Verbose. Implements
+// json.Unmarshaler.
+func (b *Board) UnmarshalJSON(data []byte) error {
+	var fen string
+	if err := json.Unmarshal(data, &fen); err == nil {
+		return b.UnmarshalText([]byte(fen))
+	}
+	var verbose VerboseBoard
+	if err := json.Unmarshal(data, &verbose); err != nil {
+		return err
+	}
+	v := b.Variant
+	if verbose.Variant != "" {
+		var err error
+		if v, err = ParseVariant(verbose.Variant); err != nil {
+			return err
+		}
+	}
+	nb, err := ParseVariantFenChecked(verbose.Fen, v)
+	if err != nil {
+		return err
+	}
+	*b = nb
+	return nil
+}
+
+// Parses a board from a FEN string, as ParseFen, but returns an error instead of
+// panicking or building a broken board if the string is malformed. Use it for
+// FENs from users, files and other programs.
+func ParseFenChecked(fen string) (Board, error) {
+	return ParseVariantFenChecked(fen, Standard)
+}
+
+// Parses a board from a FEN string with a variant's rules, as ParseVariantFen,
+// but returns an error if the string is malformed.
+func ParseVariantFenChecked(fen string, v Variant) (Board, error) {
+	if err := validateFen(fen, v); err != nil {
+		return Board{}, err
+	}
+	return ParseVariantFen(fen, v), nil
+}
+
+// Checks each field of a FEN string, as ParseVariantFen reads it for the
+// variant, and describes the first problem found.
+func validateFen(fen string, v Variant) error {
+	var fields []string
+	for i, field := range strings.Fields(fen) {
+		if v == ThreeCheck && i > 0 && isCheckCountField(field) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) < 4 || len(fields) > 6 {
+		return errors.New("A FEN must have 4 to 6 fields.")
+	}
+
+	placement := fields[0]
+	if open := strings.IndexByte(placement, '['); open >= 0 && v == Crazyhouse {
+		pocket := placement[open+1:]
+		if !strings.HasSuffix(pocket, "]") || strings.Trim(pocket[:len(pocket)-1], "PNBRQpnbrq") != "" {
+			return errors.New("Invalid pocket in FEN: " + placement[open:] + ".")
+		}
+		placement = placement[:open]
+	}
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return errors.New("A FEN must have 8 ranks.")
+	}
+	var kings [2]int
+	for i, rank := range ranks {
+		squares := 0
+		for j := 0; j < len(rank); j++ {
+			c := rank[j]
+			switch {
+			case c >= '1' && c <= '8':
+				squares += int(c - '0')
+			case (c == 'P' || c == 'p') && (i == 0 || i == 7 && !(c == 'P' && v == Horde)):
+				// Horde starts with white pawns on the first rank.
+				return errors.New("Pawn on the back rank in FEN rank " + strconv.Itoa(8-i) + ".")
+			case strings.IndexByte("PNBRQK", c) >= 0:
+				squares++
+				if c == 'K' {
+					kings[0]++
+				}
+			case strings.IndexByte("pnbrqk", c) >= 0:
+				squares++
+				if c == 'k' {
+					kings[1]++
+				}
+			case c == '~' && v == Crazyhouse && j > 0 && strings.IndexByte("NBRQnbrq", rank[j-1]) >= 0:
+			default:
+				return errors.New("Invalid character " + strconv.Quote(string(c)) + " in FEN rank " +
+					strconv.Itoa(8-i) + ".")
+			}
+		}
+		if squares != 8 {
+			return errors.New("FEN rank " + strconv.Itoa(8-i) + " does not have 8 squares.")
+		}
+	}
+	switch v {
+	case Antichess: // kings are ordinary pieces
+	case Horde:
+		if kings[0] > 1 || kings[1] != 1 {
+			return errors.New("Black must have exactly one king, and white at most one.")
+		}
+	default:
+		if kings != [2]int{1, 1} {
+			return errors.New("Each side must have exactly one king.")
+		}
+	}
+
+	if fields[1] != "w" && fields[1] != "b" {
+		return errors.New("The side to move must be w or b, not " + fields[1] + ".")
+	}
+	if castling := fields[2]; castling != "-" {
+		for i := 0; i < len(castling); i++ {
+			if strings.IndexByte("KQkq", castling[i]) < 0 || strings.IndexByte(castling[i+1:], castling[i]) >= 0 {
+				return errors.New("Invalid castling rights in FEN: " + castling + ".")
+			}
+		}
+	}
+	if ep := fields[3]; ep != "-" {
+		_, err := AlgebraicToIndex(ep)
+		rank := byte('6')
+		if fields[1] == "b" {
+			rank = '3'
+		}
+		if err != nil || len(ep) != 2 || ep[1] != rank {
+			return errors.New("Invalid en passant square in FEN: " + ep + ".")
+		}
+	}
+	if len(fields) > 4 {
+		if _, err := strconv.ParseUint(fields[4], 10, 8); err != nil {
+			return errors.New("Invalid halfmove clock in FEN: " + fields[4] + ".")
+		}
+	}
+	if len(fields) > 5 {
+		if _, err := strconv.ParseUint(fields[5], 10, 16); err != nil {
+			return errors.New("Invalid fullmove number in FEN: " + fields[5] + ".")
+		}
+	}
+	return nil
+}
+
+// Whether a field is a ThreeCheck check count, "+2+1" or "1+2".
+func isCheckCountField(field string) bool {
+	counts := strings.TrimPrefix(field, "+")
+	return len(counts) == 3 && counts[1] == '+' && counts[0] >= '0' && counts[0] <= '3' &&
+		counts[2] >= '0' && counts[2] <= '3'
+}
+
+// Encodes the move in UCI long algebraic notation, as Move.String. Implements
+// encoding.TextMarshaler.
+func (m Move) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Decodes a move in UCI long algebraic notation, as ParseMove. Implements
+// encoding.TextUnmarshaler.
+func (m *Move) UnmarshalText(text []byte) error {
+	mv, err := ParseMove(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mv
+	return nil
+}
+
+// A verbose description of a position, for clients that do not parse FEN. It
+// encodes to JSON as, for example:
+//
+//	{"fen": "...", "variant": "chess", "pieces": {"e1": "K", "e8": "k", ...},
+//	 "side_to_move": "white", "check": false, "legal_moves": ["e1d1", ...]}
+type VerboseBoard struct {
+	Fen        string            `json:"fen"`
+	Variant    string            `json:"variant"`
+	Pieces     map[string]string `json:"pieces"` // FEN letters, by square
+	SideToMove string            `json:"side_to_move"`
+	Check      bool              `json:"check"`
+	LegalMoves []Move            `json:"legal_moves"`
+}
+
+// Describes the board for the verbose JSON form.
+func (b *Board) Verbose() VerboseBoard {
+	verbose := VerboseBoard{
+		Fen:        b.ToFen(),
+		Variant:    b.Variant.String(),
+		Pieces:     make(map[string]string),
+		SideToMove: "white",
+		Check:      b.OurKingInCheck(),
+		LegalMoves: b.GenerateLegalMoves(),
+	}
+	if !b.Wtomove {
+		verbose.SideToMove = "black"
+	}
+	for square := uint8(0); square < 64; square++ {
+		piece, white := GetPieceType(square, b)
+		if piece == Nothing {
+			continue
+		}
+		letter := string(" PNBRQK"[piece])
+		if !white {
+			letter = strings.ToLower(letter)
+		}
+		verbose.Pieces[IndexToAlgebraic(Square(square))] = letter
+	}
+	return verbose
+}
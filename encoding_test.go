@@ -0,0 +1,153 @@
+package dragontoothmg
+
+import (
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+var _ encoding.TextMarshaler = Board{}
+var _ encoding.TextUnmarshaler = &Board{}
+var _ json.Marshaler = Board{}
+var _ json.Unmarshaler = &Board{}
+var _ encoding.TextMarshaler = Move(0)
+var _ encoding.TextUnmarshaler = new(Move)
+
+func TestMarshalJSON(t *testing.T) {
+	type request struct {
+		Board Board  `json:"board"`
+		Moves []Move `json:"moves"`
+	}
+	fen := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+	in := request{ParseFen(fen), []Move{parseMove("e2a6"), parseMove("a7a8q")}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"board":"` + fen + `","moves":["e2a6","a7a8q"]}`
+	if string(data) != expected {
+		t.Error("Expected", expected, "but got", string(data))
+	}
+	var out request
+	if err := json.Unmarshal(data, &out); err != nil || out.Board != in.Board || len(out.Moves) != 2 ||
+		out.Moves[0] != in.Moves[0] || out.Moves[1] != in.Moves[1] {
+		t.Error("Expected to decode", expected, "but got", out.Board.ToFen(), out.Moves, err)
+	}
+
+	// A variant board decodes with the variant set beforehand.
+	zh := ParseVariantFen("Q~3k3/8/8/8/8/8/8/4K3[QPPbn] w - - 0 1", Crazyhouse)
+	data, _ = json.Marshal(zh)
+	decoded := Board{Variant: Crazyhouse}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != zh {
+		t.Error("Expected", zh.ToFen(), "but got", decoded.ToFen(), err)
+	}
+
+	for _, str := range []string{`"8/8 w - -"`, `"not a fen"`, `42`, `"e2e4"`} {
+		b := ParseFen(Startpos)
+		if err := json.Unmarshal([]byte(str), &b); err == nil {
+			t.Error("Expected an error decoding", str)
+		} else if b.ToFen() != Startpos {
+			t.Error("Expected the board to be unchanged after decoding", str)
+		}
+	}
+	var m Move
+	if err := m.UnmarshalText([]byte("e2e9")); err == nil {
+		t.Error("Expected an error decoding e2e9")
+	}
+}
+
+func TestUnmarshalTextValidation(t *testing.T) {
+	valid := []struct {
+		fen     string
+		variant Variant
+	}{
+		{Startpos, Standard},
+		{"4k3/8/8/8/8/8/8/4K3 w - -", Standard}, // no move numbers
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", Standard},
+		{"rnbqkbnr/pppp1ppp/8/8/3Pp3/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 2", Standard},
+		{ThreeCheck.Startpos(), ThreeCheck},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 1+2 0 1", ThreeCheck},
+		{"Q~3k3/8/8/8/8/8/8/4K2q~[QPPbn] b - - 0 1", Crazyhouse},
+		{Horde.Startpos(), Horde},
+		{"8/8/8/8/8/8/8/R6r w - - 0 1", Antichess}, // no kings
+	}
+	for _, test := range valid {
+		b := Board{Variant: test.variant}
+		if err := b.UnmarshalText([]byte(test.fen)); err != nil {
+			t.Error("Expected", test.fen, "to be valid, got", err)
+		}
+	}
+	invalid := map[string]Variant{
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":                  Standard, // 9 squares
+		"rnbqkbnr/pppppppp/8/p8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":                 Standard, // rank too long
+		"rnbqkbnr/pppppppp/7/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":                  Standard, // rank too short
+		"rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":                    Standard, // 7 ranks
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1":                  Standard, // unknown piece
+		"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1":                    Standard, // no black king
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1":                    Standard, // two white kings
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1":                  Standard, // side to move
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR W KQkq - 0 1":                  Standard,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1":                  Standard, // castling
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKq - 0 1":                   Standard,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1":                 Standard, // en passant
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e3 0 1":                 Standard, // wrong rank
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1":                 Standard, // halfmove clock
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 x":                Standard, // extra field
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR~ w KQkq - 0 1":                 Standard, // promotion mark
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Kx] w KQkq - 0 1":              Crazyhouse,
+		"rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w - - 0 1": Atomic, // no white king outside Horde
+		"P3k3/8/8/8/8/8/8/4K3 w - - 0 1":                                            Horde,  // pawn on the last rank
+		"4k3/8/8/8/8/8/8/p3K3 b - - 0 1":                                            Horde,
+		"8/8/8 w - - 0 1":                                                           Standard,
+	}
+	for fen, v := range invalid {
+		b := ParseFen(Startpos)
+		if err := b.UnmarshalText([]byte(fen)); err == nil {
+			t.Error("Expected an error for", fen)
+		} else if b.ToFen() != Startpos {
+			t.Error("Expected the board to be unchanged after decoding", fen)
+		}
+		b.Variant = v
+		if err := b.UnmarshalText([]byte(fen)); err == nil {
+			t.Error("Expected an error for", fen, "in", v)
+		}
+	}
+}
+
+func TestParseFenChecked(t *testing.T) {
+	if b, err := ParseFenChecked(Startpos); err != nil || b != ParseFen(Startpos) {
+		t.Error("Expected the starting position, got", b.ToFen(), err)
+	}
+	for _, fen := range []string{"", "8/8/8 w - - 0 1", "4k3/8/8/8/8/8/8/P3K3 w - - 0 1"} {
+		if _, err := ParseFenChecked(fen); err == nil {
+			t.Error("Expected an error for", fen)
+		}
+	}
+	if _, err := ParseVariantFenChecked(Horde.Startpos(), Horde); err != nil {
+		t.Error("Expected the Horde start position to be valid, got", err)
+	}
+}
+
+func TestVerboseJSON(t *testing.T) {
+	b := ParseFen("4k3/8/8/8/8/8/8/r3K3 w - - 0 1")
+	verbose := b.Verbose()
+	if verbose.Fen != b.ToFen() || verbose.Variant != "chess" || verbose.SideToMove != "white" || !verbose.Check {
+		t.Error("Unexpected description", verbose)
+	}
+	if len(verbose.Pieces) != 3 || verbose.Pieces["e1"] != "K" || verbose.Pieces["e8"] != "k" || verbose.Pieces["a1"] != "r" {
+		t.Error("Expected the pieces K e1, k e8, r a1 but got", verbose.Pieces)
+	}
+	if len(verbose.LegalMoves) != 3 {
+		t.Error("Expected 3 legal moves but got", verbose.LegalMoves)
+	}
+
+	horde := ParseVariantFen(Horde.Startpos(), Horde)
+	data, err := json.Marshal(horde.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Board
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Variant != Horde || decoded.ToFen() != Horde.Startpos() {
+		t.Error("Expected to decode the verbose Horde start position, got", decoded.ToFen(), err)
+	}
+}
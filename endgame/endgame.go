@@ -0,0 +1,249 @@
+// Package endgame generates endgame tables by retrograde analysis, and probes
+// them. A table holds the result of every position with a given material, such
+// as KQvK or KBNvK, and its distance to mate with perfect play.
+//
+// Tables count plies to mate, not to the next capture or pawn move, and ignore
+// the 50-move rule, so their results can differ from Syzygy tables only where
+// the 50-move rule does. En passant is considered when probing, but not when
+// generating, so tables with pawns on both sides may be wrong where an en
+// passant capture is best. Positions with castling rights are not covered.
+package endgame
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A result with perfect play, from the side to move's point of view.
+type WDL int
+
+const (
+	Loss WDL = -1
+	Draw WDL = 0
+	Win  WDL = 1
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case Win:
+		return "win"
+	}
+	return "draw"
+}
+
+// The result of a position, and for wins and losses, the number of plies to mate
+// with perfect play.
+type Result struct {
+	WDL   WDL
+	Plies int
+}
+
+func resultOf(value uint8) Result {
+	if value == 0 {
+		return Result{WDL: Draw}
+	}
+	plies := int(value) - 1
+	if plies%2 == 1 {
+		return Result{WDL: Win, Plies: plies}
+	}
+	return Result{WDL: Loss, Plies: plies}
+}
+
+// The result before a move that leads to this one.
+func (r Result) before() Result {
+	if r.WDL == Draw {
+		return r
+	}
+	return Result{WDL: -r.WDL, Plies: r.Plies + 1}
+}
+
+// Orders results from best to worst: faster wins, draws, then slower losses.
+func (r Result) rank() int {
+	switch r.WDL {
+	case Win:
+		return 1000 - r.Plies
+	case Loss:
+		return -1000 + r.Plies
+	}
+	return 0
+}
+
+var (
+	ErrCastling      = errors.New("Endgame tables do not cover positions with castling rights.")
+	ErrTooManyPieces = errors.New("Too many pieces for an endgame table.")
+	ErrNoMoves       = errors.New("The position has no legal moves.")
+)
+
+// A set of endgame tables.
+type Tablebase struct {
+	tables map[string]*table // by name, such as "KQvK"
+}
+
+// Generates the tables for some materials, given as "KQvK" or "KQK", along with
+// the tables for every material they can reach by captures and promotions.
+func Generate(materials ...string) (*Tablebase, error) {
+	tb := &Tablebase{tables: map[string]*table{}}
+	for _, material := range materials {
+		white, black, err := parseMaterial(material)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tableName(white, black)
+		if err := tb.generate(name); err != nil {
+			return nil, err
+		}
+	}
+	return tb, nil
+}
+
+func (tb *Tablebase) generate(name string) error {
+	if _, ok := tb.tables[name]; ok || name == "KvK" {
+		return nil
+	}
+	sides := strings.Split(name, "v")
+	for _, dep := range dependencies(sides[0], sides[1]) {
+		if err := tb.generate(dep); err != nil {
+			return err
+		}
+	}
+	t := newTable(name)
+	if err := tb.solve(t); err != nil {
+		return err
+	}
+	tb.tables[name] = t
+	return nil
+}
+
+// The names of the tables, sorted.
+func (tb *Tablebase) Materials() []string {
+	var names []string
+	for name := range tb.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Finds the value of a position in the tables.
+func (tb *Tablebase) lookup(pieces []placed, wtm bool) (uint8, error) {
+	var white, black string
+	for _, p := range pieces {
+		if p.white {
+			white += string(kindLetters[p.kind])
+		} else {
+			black += string(kindLetters[p.kind])
+		}
+	}
+	if len(pieces) == 2 {
+		return 0, nil // two kings
+	}
+	white, black = sortSide(white), sortSide(black)
+	name, flipped := tableName(white, black)
+	t, ok := tb.tables[name]
+	if !ok {
+		return 0, errors.New("Missing endgame table " + white + "v" + black + ".")
+	}
+	squares := make([]int, len(t.pieces))
+	used := 0
+	for i, tp := range t.pieces {
+		for j, p := range pieces {
+			if used&(1<<uint(j)) == 0 && p.kind == tp.kind && p.white != flipped == tp.white {
+				used |= 1 << uint(j)
+				squares[i] = p.sq
+				if flipped {
+					squares[i] ^= 56
+				}
+				break
+			}
+		}
+	}
+	t.canonicalize(squares)
+	return t.values[t.index(squares, wtm != flipped)], nil
+}
+
+// Probes the result of a position, and its distance to mate.
+func (tb *Tablebase) Probe(b *dragontoothmg.Board) (Result, error) {
+	if b.HasCastlingRights() {
+		return Result{}, ErrCastling
+	}
+	if b.Enpassant != 0 {
+		// The tables do not know about en passant.
+		_, result, err := tb.search(b)
+		return result, err
+	}
+	var pieces []placed
+	for _, side := range []struct {
+		bitboards *dragontoothmg.Bitboards
+		white     bool
+	}{{&b.White, true}, {&b.Black, false}} {
+		bbs := side.bitboards
+		for _, kind := range []struct {
+			bitboard uint64
+			kind     dragontoothmg.Piece
+		}{{bbs.Kings, dragontoothmg.King}, {bbs.Queens, dragontoothmg.Queen}, {bbs.Rooks, dragontoothmg.Rook},
+			{bbs.Bishops, dragontoothmg.Bishop}, {bbs.Knights, dragontoothmg.Knight}, {bbs.Pawns, dragontoothmg.Pawn}} {
+			for bb := kind.bitboard; bb != 0; bb &= bb - 1 {
+				pieces = append(pieces, placed{piece: piece{kind: kind.kind, white: side.white}, sq: bits.TrailingZeros64(bb)})
+			}
+		}
+	}
+	if len(pieces) > maxPieces {
+		return Result{}, ErrTooManyPieces
+	}
+	value, err := tb.lookup(pieces, b.Wtomove)
+	return resultOf(value), err
+}
+
+// Finds the best move of a position: the fastest mate if it is won, a drawing
+// move if it is drawn, and otherwise the slowest mate. The result is the
+// position's, as from Probe.
+func (tb *Tablebase) BestMove(b *dragontoothmg.Board) (dragontoothmg.Move, Result, error) {
+	if b.HasCastlingRights() {
+		return 0, Result{}, ErrCastling
+	}
+	m, result, err := tb.search(b)
+	if err == nil && m == 0 {
+		err = ErrNoMoves
+	}
+	return m, result, err
+}
+
+// Probes every move of a position. The move is 0 if there are none.
+func (tb *Tablebase) search(b *dragontoothmg.Board) (dragontoothmg.Move, Result, error) {
+	var best dragontoothmg.Move
+	var bestResult Result
+	for _, m := range b.GenerateLegalMoves() {
+		// The move generator only makes queen promotions.
+		for _, promote := range promotions {
+			if m.Promote() == dragontoothmg.Nothing && promote != dragontoothmg.Queen {
+				break
+			}
+			if m.Promote() != dragontoothmg.Nothing {
+				m.Setpromote(promote)
+			}
+			next := *b
+			next.Apply(m)
+			result, err := tb.Probe(&next)
+			if err != nil {
+				return 0, Result{}, err
+			}
+			result = result.before()
+			if best == 0 || result.rank() > bestResult.rank() {
+				best, bestResult = m, result
+			}
+		}
+	}
+	if best == 0 {
+		if b.OurKingInCheck() {
+			return 0, Result{WDL: Loss}, nil
+		}
+		return 0, Result{WDL: Draw}, nil
+	}
+	return best, bestResult, nil
+}
@@ -0,0 +1,222 @@
+package endgame
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The board of a placement of a table's pieces.
+func boardOf(t *table, squares []int, wtm bool) dragontoothmg.Board {
+	var grid [64]byte
+	for i, sq := range squares {
+		letter := kindLetters[t.pieces[i].kind]
+		if !t.pieces[i].white {
+			letter += 'a' - 'A'
+		}
+		grid[sq] = letter
+	}
+	var fen strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			if grid[rank*8+file] == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			fen.WriteByte(grid[rank*8+file])
+		}
+		if empty > 0 {
+			fen.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			fen.WriteByte('/')
+		}
+	}
+	if wtm {
+		fen.WriteString(" w - - 0 1")
+	} else {
+		fen.WriteString(" b - - 0 1")
+	}
+	return dragontoothmg.ParseFen(fen.String())
+}
+
+func TestGenerate(t *testing.T) {
+	tb, err := Generate("KQK", "KRvK", "KPvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"KBvK", "KNvK", "KPvK", "KQvK", "KRvK"}
+	if names := tb.Materials(); strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Error("Expected tables", expected, "but got", names)
+	}
+
+	// The longest mates are known: 10 moves with a queen, 16 with a rook, and
+	// 28 with a pawn.
+	for name, longest := range map[string]int{"KQvK": 19, "KRvK": 31, "KPvK": 55, "KBvK": 0} {
+		max := 0
+		for _, value := range tb.tables[name].values {
+			if r := resultOf(value); r.WDL == Win && r.Plies > max {
+				max = r.Plies
+			}
+		}
+		if max != longest {
+			t.Error("Expected the longest mate in", name, "to be", longest, "plies, but got", max)
+		}
+	}
+
+	// Every position's value agrees with a search of its moves, which uses the
+	// library's move generator instead of the solver's, and with the KPK bitbase.
+	for _, name := range []string{"KQvK", "KPvK"} {
+		tab := tb.tables[name]
+		squares, scratch := make([]int, len(tab.pieces)), make([]int, len(tab.pieces))
+		for idx := range tab.values {
+			wtm := tab.decode(idx, squares)
+			if !tab.valid(squares, wtm, scratch) {
+				continue
+			}
+			b := boardOf(tab, squares, wtm)
+			_, searched, err := tb.search(&b)
+			if err != nil || searched != resultOf(tab.values[idx]) {
+				t.Fatal("Expected", resultOf(tab.values[idx]), "for", b.ToFen(), "but the search found", searched, err)
+			}
+			whiteWins := searched.WDL == Win && wtm || searched.WDL == Loss && !wtm
+			if win, ok := dragontoothmg.ProbeKPK(&b); name == "KPvK" && (!ok || win != whiteWins) {
+				t.Fatal("Expected", searched, "for", b.ToFen(), "but the KPK bitbase has", win, ok)
+			}
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tb, err := Generate("KPK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Plies are only checked for wins and losses when given.
+	tests := map[string]struct {
+		wdl   WDL
+		plies int
+	}{
+		"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1":  {Loss, 0}, // checkmate
+		"8/8/8/8/8/8/1Q6/k1K5 b - - 0 1":  {Loss, 0},
+		"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1":  {Draw, 0}, // stalemate
+		"k7/8/1K6/8/8/8/8/2Q5 w - - 0 1":  {Win, 1},
+		"6k1/8/6K1/8/8/8/8/R7 w - - 0 1":  {Win, 1},
+		"8/8/8/8/8/2k5/1Q6/7K b - - 0 1":  {Draw, 0}, // the queen hangs
+		"8/8/8/8/8/8/4P3/K6k w - - 0 1":   {Win, -1}, // the pawn outruns the king
+		"8/8/8/8/8/8/4p3/k6K b - - 0 1":   {Win, -1}, // colors reversed
+		"k7/8/8/8/8/8/P7/K7 w - - 0 1":    {Draw, 0}, // a rook pawn
+		"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1": {Win, -1},
+		"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1": {Loss, -1},
+		"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1": {Draw, 0}, // stalemate
+		"6k1/8/5K2/8/8/8/8/B7 w - - 0 1":  {Draw, 0},
+		"8/8/8/8/8/8/8/K6k w - - 0 1":     {Draw, 0},
+	}
+	for fen, expected := range tests {
+		b := dragontoothmg.ParseFen(fen)
+		result, err := tb.Probe(&b)
+		if err != nil || result.WDL != expected.wdl || (expected.plies >= 0 && result.Plies != expected.plies) {
+			t.Error("Expected", expected, "for", fen, "but got", result, err)
+		}
+	}
+
+	for fen, expected := range map[string]error{
+		"r3k3/8/8/8/8/8/8/4K3 w q - 0 1":                        ErrCastling,
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1": ErrTooManyPieces,
+	} {
+		b := dragontoothmg.ParseFen(fen)
+		if _, err := tb.Probe(&b); err != expected {
+			t.Error("Expected", expected, "for", fen, "but got", err)
+		}
+	}
+	b := dragontoothmg.ParseFen("8/8/8/3k4/8/8/1NN5/K7 w - - 0 1")
+	if _, err := tb.Probe(&b); err == nil || err.Error() != "Missing endgame table KNNvK." {
+		t.Error("Expected a missing table, got", err)
+	}
+}
+
+func TestBestMove(t *testing.T) {
+	tb, err := Generate("KQvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fen, expected := range map[string]string{
+		"k7/8/1K6/8/8/8/8/2Q5 w - - 0 1": "c1c8", // mate
+		"8/8/8/8/8/2k5/1Q6/7K b - - 0 1": "c3b2", // the only draw
+	} {
+		b := dragontoothmg.ParseFen(fen)
+		if m, _, err := tb.BestMove(&b); err != nil || m.String() != expected {
+			t.Error("Expected", expected, "for", fen, "but got", m.String(), err)
+		}
+	}
+
+	// Following the best moves mates in the promised number of plies.
+	b := dragontoothmg.ParseFen("8/8/8/3k4/8/8/8/KQ6 w - - 0 1")
+	_, result, err := tb.BestMove(&b)
+	if err != nil || result.WDL != Win {
+		t.Fatal("Expected a win, got", result, err)
+	}
+	for plies := 0; ; plies++ {
+		m, r, err := tb.BestMove(&b)
+		if err == ErrNoMoves {
+			if plies != result.Plies || !b.OurKingInCheck() {
+				t.Error("Expected mate in", result.Plies, "plies, got", plies)
+			}
+			break
+		} else if err != nil || r.Plies != result.Plies-plies {
+			t.Fatal("Expected", result.Plies-plies, "plies to mate, got", r, err)
+		}
+		b.Apply(m)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	tb, err := Generate("KRvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := tb.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read.tables["KRvK"].values, tb.tables["KRvK"].values) {
+		t.Error("The table changed when written and read")
+	}
+	if size > len(tb.tables["KRvK"].values)/4 {
+		t.Error("Expected a compressed file, got", size, "bytes")
+	}
+	if _, err := Read(strings.NewReader("DTEG\x01\x00\x00\x00")); err != errCorrupt {
+		t.Error("Expected a corrupt file, got", err)
+	}
+}
+
+func TestKBNK(t *testing.T) {
+	if testing.Short() {
+		t.Skip("KBNvK takes a while to generate")
+	}
+	tb, err := Generate("KBNK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	max := 0
+	for _, value := range tb.tables["KBNvK"].values {
+		if r := resultOf(value); r.WDL == Win && r.Plies > max {
+			max = r.Plies
+		}
+	}
+	if max != 65 {
+		t.Error("Expected the longest mate in KBNvK to be 65 plies, but got", max)
+	}
+}
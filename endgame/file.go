@@ -0,0 +1,104 @@
+package endgame
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A tablebase file starts with the magic, the number of tables, and for each
+// table its name (one byte of length, then the name) and the offset and length
+// of its data, as 32-bit little-endian numbers. The offsets count from the end
+// of this index. The data of each table is its values, compressed with DEFLATE.
+var fileMagic = []byte("DTEG")
+
+var errCorrupt = errors.New("Corrupt endgame table file.")
+
+// Writes the tables to a file that Read can load.
+func (tb *Tablebase) WriteTo(w io.Writer) (int64, error) {
+	names := tb.Materials()
+	var data [][]byte
+	for _, name := range names {
+		var buf bytes.Buffer
+		zw, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return 0, err
+		}
+		zw.Write(tb.tables[name].values)
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		data = append(data, buf.Bytes())
+	}
+
+	var index bytes.Buffer
+	index.Write(fileMagic)
+	binary.Write(&index, binary.LittleEndian, uint32(len(names)))
+	var offset uint32
+	for i, name := range names {
+		index.WriteByte(byte(len(name)))
+		index.WriteString(name)
+		binary.Write(&index, binary.LittleEndian, [2]uint32{offset, uint32(len(data[i]))})
+		offset += uint32(len(data[i]))
+	}
+
+	bw := bufio.NewWriter(w)
+	written, _ := bw.Write(index.Bytes())
+	for _, d := range data {
+		n, _ := bw.Write(d)
+		written += n
+	}
+	return int64(written), bw.Flush()
+}
+
+// Reads tables written by WriteTo.
+func Read(r io.Reader) (*Tablebase, error) {
+	file, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(file) < 8 || !bytes.Equal(file[:4], fileMagic) {
+		return nil, errCorrupt
+	}
+	count := int(binary.LittleEndian.Uint32(file[4:]))
+	type entry struct {
+		name           string
+		offset, length int
+	}
+	var entries []entry
+	pos := 8
+	for i := 0; i < count; i++ {
+		if pos >= len(file) || pos+1+int(file[pos])+8 > len(file) {
+			return nil, errCorrupt
+		}
+		e := entry{name: string(file[pos+1 : pos+1+int(file[pos])])}
+		pos += 1 + len(e.name)
+		e.offset = int(binary.LittleEndian.Uint32(file[pos:]))
+		e.length = int(binary.LittleEndian.Uint32(file[pos+4:]))
+		pos += 8
+		entries = append(entries, e)
+	}
+
+	tb := &Tablebase{tables: map[string]*table{}}
+	data := file[pos:]
+	for _, e := range entries {
+		white, black, err := parseMaterial(e.name)
+		if err != nil {
+			return nil, err
+		}
+		if name, _ := tableName(white, black); name != e.name || e.offset+e.length > len(data) {
+			return nil, errCorrupt
+		}
+		t := newTable(e.name)
+		t.values = make([]uint8, t.size())
+		zr := flate.NewReader(bytes.NewReader(data[e.offset : e.offset+e.length]))
+		if _, err := io.ReadFull(zr, t.values); err != nil {
+			return nil, errCorrupt
+		}
+		tb.tables[e.name] = t
+	}
+	return tb, nil
+}
@@ -0,0 +1,320 @@
+package endgame
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+const (
+	fileA = 0x0101010101010101
+	fileH = 0x8080808080808080
+)
+
+var promotions = []dragontoothmg.Piece{dragontoothmg.Queen, dragontoothmg.Rook, dragontoothmg.Bishop,
+	dragontoothmg.Knight}
+
+// Marks a position with a capture or promotion that draws or wins, so that it
+// is never lost.
+const notLost = 0xff
+
+func bit(sq int) uint64 {
+	return uint64(1) << uint(sq)
+}
+
+// The squares a piece attacks.
+func attacks(p piece, sq int, occupied uint64) uint64 {
+	switch p.kind {
+	case dragontoothmg.King:
+		return dragontoothmg.CalculateKingMoveBitboard(uint8(sq))
+	case dragontoothmg.Queen:
+		return dragontoothmg.CalculateRookMoveBitboard(uint8(sq), occupied) |
+			dragontoothmg.CalculateBishopMoveBitboard(uint8(sq), occupied)
+	case dragontoothmg.Rook:
+		return dragontoothmg.CalculateRookMoveBitboard(uint8(sq), occupied)
+	case dragontoothmg.Bishop:
+		return dragontoothmg.CalculateBishopMoveBitboard(uint8(sq), occupied)
+	case dragontoothmg.Knight:
+		return dragontoothmg.CalculateKnightMoveBitboard(uint8(sq))
+	}
+	if p.white {
+		return bit(sq)<<7&^fileH | bit(sq)<<9&^fileA
+	}
+	return bit(sq)>>9&^fileH | bit(sq)>>7&^fileA
+}
+
+func occupancy(squares []int) uint64 {
+	var occupied uint64
+	for _, sq := range squares {
+		if sq >= 0 {
+			occupied |= bit(sq)
+		}
+	}
+	return occupied
+}
+
+// Whether a side attacks a square. Captured pieces have the square -1.
+func (t *table) attacked(squares []int, target int, byWhite bool, occupied uint64) bool {
+	for i, p := range t.pieces {
+		if p.white == byWhite && squares[i] >= 0 && attacks(p, squares[i], occupied)&bit(target) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Calls f for each legal move of the side to move, with the placement after the
+// move in after, the index of the moving piece, the index of the captured piece
+// (or -1), and the promotion (or Nothing). En passant is not considered.
+func (t *table) forEachMove(squares []int, wtm bool, after []int,
+	f func(moved int, captured int, promote dragontoothmg.Piece)) {
+	occupied := occupancy(squares)
+	var own uint64
+	for i, p := range t.pieces {
+		if p.white == wtm {
+			own |= bit(squares[i])
+		}
+	}
+	king := t.king(wtm)
+	for i, p := range t.pieces {
+		if p.white != wtm {
+			continue
+		}
+		var targets uint64
+		if p.kind == dragontoothmg.Pawn {
+			targets = attacks(p, squares[i], occupied) & occupied &^ own
+			push, startRank := squares[i]+8, 1
+			if !wtm {
+				push, startRank = squares[i]-8, 6
+			}
+			if occupied&bit(push) == 0 {
+				targets |= bit(push)
+				double := 2*push - squares[i]
+				if squares[i]>>3 == startRank && occupied&bit(double) == 0 {
+					targets |= bit(double)
+				}
+			}
+		} else {
+			targets = attacks(p, squares[i], occupied) &^ own
+		}
+		for ; targets != 0; targets &= targets - 1 {
+			to := bits.TrailingZeros64(targets)
+			copy(after, squares)
+			after[i] = to
+			captured := -1
+			for j, sq := range squares {
+				if sq == to && j != i {
+					captured = j
+					after[j] = -1
+				}
+			}
+			if t.attacked(after, after[king], !wtm, occupied&^bit(squares[i])|bit(to)) {
+				continue
+			}
+			if p.kind == dragontoothmg.Pawn && (to>>3 == 0 || to>>3 == 7) {
+				for _, promote := range promotions {
+					f(i, captured, promote)
+				}
+			} else {
+				f(i, captured, dragontoothmg.Nothing)
+			}
+		}
+	}
+}
+
+// Calls f for each legal position that the side not to move could have made a
+// quiet move from, with that position in before. Captures and promotions come
+// from other tables, and are not considered.
+func (t *table) forEachUnmove(squares []int, wtm bool, before []int, f func()) {
+	occupied := occupancy(squares)
+	king := t.king(wtm)
+	for i, p := range t.pieces {
+		if p.white == wtm {
+			continue
+		}
+		var sources uint64
+		if p.kind == dragontoothmg.Pawn {
+			from, canMove, doubleRank := squares[i]-8, squares[i]>>3 >= 2, 3
+			if !p.white {
+				from, canMove, doubleRank = squares[i]+8, squares[i]>>3 <= 5, 4
+			}
+			if canMove && occupied&bit(from) == 0 {
+				sources |= bit(from)
+				if squares[i]>>3 == doubleRank && occupied&bit(2*from-squares[i]) == 0 {
+					sources |= bit(2*from - squares[i])
+				}
+			}
+		} else {
+			sources = attacks(p, squares[i], occupied) &^ occupied
+		}
+		for ; sources != 0; sources &= sources - 1 {
+			from := bits.TrailingZeros64(sources)
+			copy(before, squares)
+			before[i] = from
+			if !t.attacked(before, before[king], !wtm, occupied&^bit(squares[i])|bit(from)) {
+				f()
+			}
+		}
+	}
+}
+
+// Builds a table by retrograde analysis. The tables its captures and promotions
+// lead to must already be in tb.
+func (tb *Tablebase) solve(t *table) error {
+	n, size := len(t.pieces), t.size()
+	t.values = make([]uint8, size)
+	// The number of distinct positions that quiet moves lead to, less those
+	// found to be won for the other side.
+	remaining := make([]uint8, size)
+	// notLost, or the plies to the slowest mate by a capture or promotion that
+	// loses, plus one.
+	exits := make([]uint8, size)
+	processed := make([]bool, size)
+
+	// Positions are processed in order of their distance to mate, so each is
+	// final when it is processed. Positions that are won by a capture or
+	// promotion are pushed before they are known to be final, in case there is a
+	// faster win by a quiet move.
+	var buckets [][]int32
+	push := func(plies int, idx int) {
+		for len(buckets) <= plies {
+			buckets = append(buckets, nil)
+		}
+		buckets[plies] = append(buckets[plies], int32(idx))
+	}
+
+	squares, other, scratch := make([]int, n), make([]int, n), make([]int, n)
+	var children []int
+	var err error
+	for idx := 0; idx < size; idx++ {
+		wtm := t.decode(idx, squares)
+		if !t.valid(squares, wtm, scratch) {
+			continue
+		}
+		moves, fastestWin, slowestLoss, drawn := 0, -1, 0, false
+		children = children[:0]
+		t.forEachMove(squares, wtm, other, func(moved int, captured int, promote dragontoothmg.Piece) {
+			moves++
+			if captured < 0 && promote == dragontoothmg.Nothing {
+				t.canonicalize(other)
+				children = appendUnique(children, t.index(other, !wtm))
+				return
+			}
+			value, e := tb.lookup(t.placement(other, moved, promote), !wtm)
+			if e != nil {
+				err = e
+				return
+			}
+			result := resultOf(value)
+			switch result.WDL {
+			case Draw:
+				drawn = true
+			case Loss:
+				if fastestWin < 0 || result.Plies+1 < fastestWin {
+					fastestWin = result.Plies + 1
+				}
+			case Win:
+				if result.Plies+1 > slowestLoss {
+					slowestLoss = result.Plies + 1
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		remaining[idx] = uint8(len(children))
+		switch {
+		case moves == 0:
+			if t.attacked(squares, squares[t.king(wtm)], !wtm, occupancy(squares)) {
+				t.values[idx] = 1 // checkmate
+				push(0, idx)
+			}
+		case fastestWin >= 0:
+			exits[idx] = notLost
+			push(fastestWin, idx)
+		case drawn:
+			exits[idx] = notLost
+		case len(children) == 0:
+			t.values[idx] = uint8(slowestLoss + 1)
+			push(slowestLoss, idx)
+		default:
+			exits[idx] = uint8(slowestLoss + 1)
+		}
+	}
+
+	var parents []int
+	for plies := 0; plies < len(buckets); plies++ {
+		if plies+2 > 0xff {
+			return errors.New("Mate in " + t.name + " is too long for the table format.")
+		}
+		for _, i := range buckets[plies] {
+			idx := int(i)
+			if processed[idx] {
+				continue
+			}
+			if t.values[idx] == 0 {
+				t.values[idx] = uint8(plies + 1)
+			} else if int(t.values[idx]) != plies+1 {
+				continue
+			}
+			processed[idx] = true
+
+			wtm := t.decode(idx, squares)
+			parents = parents[:0]
+			t.forEachUnmove(squares, wtm, other, func() {
+				t.canonicalize(other)
+				parents = appendUnique(parents, t.index(other, !wtm))
+			})
+			for _, parent := range parents {
+				if t.values[parent] != 0 {
+					continue
+				}
+				if plies%2 == 0 {
+					// The parent wins by moving here.
+					t.values[parent] = uint8(plies + 2)
+					push(plies+1, parent)
+					continue
+				}
+				remaining[parent]--
+				if remaining[parent] == 0 && exits[parent] != notLost {
+					// Every move of the parent loses.
+					loss := plies + 1
+					if int(exits[parent])-1 > loss {
+						loss = int(exits[parent]) - 1
+					}
+					t.values[parent] = uint8(loss + 1)
+					push(loss, parent)
+				}
+			}
+		}
+		buckets[plies] = nil
+	}
+	return nil
+}
+
+// The pieces of a table's placement after a move, with the promotion of the
+// moved piece. Captured pieces are left out.
+func (t *table) placement(squares []int, moved int, promote dragontoothmg.Piece) []placed {
+	pieces := make([]placed, 0, len(squares))
+	for i, sq := range squares {
+		if sq < 0 {
+			continue
+		}
+		p := placed{piece: t.pieces[i], sq: sq}
+		if i == moved && promote != dragontoothmg.Nothing {
+			p.kind = promote
+		}
+		pieces = append(pieces, p)
+	}
+	return pieces
+}
+
+func appendUnique(list []int, x int) []int {
+	for _, y := range list {
+		if y == x {
+			return list
+		}
+	}
+	return append(list, x)
+}
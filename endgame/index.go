@@ -0,0 +1,167 @@
+package endgame
+
+import (
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A table holds one byte for every placement of its pieces and side to move.
+// Positions are reduced by symmetry: the white king is always on files a-d, and
+// without pawns, in the a1-d1-d4 triangle, with the first piece off the a1-h8
+// diagonal below it if the king is on the diagonal. Other indices are unused.
+//
+// A value is the number of plies to mate plus one, so that 0 is a draw (or an
+// unused index). An odd number of plies is a win for the side to move.
+type table struct {
+	name      string
+	pieces    []piece // white's in KQRBNP order, then black's
+	blackKing int     // the index of black's king in pieces
+	hasPawns  bool
+	values    []uint8
+}
+
+var (
+	// Encodes the a1-d1-d4 triangle as 0..9, and other squares as -1.
+	triangle [64]int
+	// The square of each triangle code.
+	triangleSquares [10]int
+)
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		triangle[sq] = -1
+		if sq&7 <= 3 && sq>>3 <= sq&7 {
+			triangle[sq] = code
+			triangleSquares[code] = sq
+			code++
+		}
+	}
+}
+
+func newTable(name string) *table {
+	t := &table{name: name}
+	white := true
+	for i := 0; i < len(name); i++ {
+		if name[i] == 'v' {
+			white = false
+			t.blackKing = len(t.pieces)
+			continue
+		}
+		kind := letterKinds[name[i]]
+		t.pieces = append(t.pieces, piece{kind: kind, white: white})
+		if kind == dragontoothmg.Pawn {
+			t.hasPawns = true
+		}
+	}
+	return t
+}
+
+// The number of indices of the table.
+func (t *table) size() int {
+	kings := 10
+	if t.hasPawns {
+		kings = 32
+	}
+	return kings << (6 * uint(len(t.pieces)-1)) * 2
+}
+
+// The index of the king of a side in pieces.
+func (t *table) king(white bool) int {
+	if white {
+		return 0
+	}
+	return t.blackKing
+}
+
+func transpose(sq int) int {
+	return sq&7<<3 | sq>>3
+}
+
+// Moves a placement of the table's pieces to its symmetric equivalent that the
+// table stores.
+func (t *table) canonicalize(squares []int) {
+	if squares[0]&7 > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+	if t.hasPawns {
+		return
+	}
+	if squares[0]>>3 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+	if squares[0]>>3 > squares[0]&7 {
+		for i := range squares {
+			squares[i] = transpose(squares[i])
+		}
+	}
+	if squares[0]>>3 != squares[0]&7 {
+		return
+	}
+	for _, sq := range squares[1:] {
+		if sq>>3 == sq&7 {
+			continue
+		}
+		if sq>>3 > sq&7 {
+			for i := range squares {
+				squares[i] = transpose(squares[i])
+			}
+		}
+		return
+	}
+}
+
+// The index of a canonical placement.
+func (t *table) index(squares []int, wtm bool) int {
+	var idx int
+	if t.hasPawns {
+		idx = squares[0]>>3*4 + squares[0]&7
+	} else {
+		idx = triangle[squares[0]]
+	}
+	for _, sq := range squares[1:] {
+		idx = idx<<6 | sq
+	}
+	idx <<= 1
+	if !wtm {
+		idx |= 1
+	}
+	return idx
+}
+
+// Finds the placement of an index, and returns whether white is to move.
+func (t *table) decode(idx int, squares []int) bool {
+	wtm := idx&1 == 0
+	idx >>= 1
+	for i := len(squares) - 1; i > 0; i-- {
+		squares[i] = idx & 63
+		idx >>= 6
+	}
+	if t.hasPawns {
+		squares[0] = idx>>2*8 + idx&3
+	} else {
+		squares[0] = triangleSquares[idx]
+	}
+	return wtm
+}
+
+// Whether a placement is canonical and legal: no two pieces share a square, no
+// pawns are on the first or last rank, and the side not to move is not in check.
+func (t *table) valid(squares []int, wtm bool, scratch []int) bool {
+	copy(scratch, squares)
+	t.canonicalize(scratch)
+	var occupied uint64
+	for i, sq := range squares {
+		if scratch[i] != sq || occupied&bit(sq) != 0 {
+			return false
+		}
+		if t.pieces[i].kind == dragontoothmg.Pawn && (sq>>3 == 0 || sq>>3 == 7) {
+			return false
+		}
+		occupied |= bit(sq)
+	}
+	return !t.attacked(squares, squares[t.king(!wtm)], wtm, occupied)
+}
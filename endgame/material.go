@@ -0,0 +1,131 @@
+package endgame
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The largest number of pieces, including kings, that a table may have. A table
+// takes 10 (or, with pawns, 32) times 64^(pieces-1) times 2 bytes of memory.
+const maxPieces = 5
+
+// The order of pieces within each side of a material signature.
+const pieceLetters = "KQRBNP"
+
+var letterKinds = map[byte]dragontoothmg.Piece{
+	'K': dragontoothmg.King,
+	'Q': dragontoothmg.Queen,
+	'R': dragontoothmg.Rook,
+	'B': dragontoothmg.Bishop,
+	'N': dragontoothmg.Knight,
+	'P': dragontoothmg.Pawn,
+}
+
+var kindLetters = map[dragontoothmg.Piece]byte{
+	dragontoothmg.King:   'K',
+	dragontoothmg.Queen:  'Q',
+	dragontoothmg.Rook:   'R',
+	dragontoothmg.Bishop: 'B',
+	dragontoothmg.Knight: 'N',
+	dragontoothmg.Pawn:   'P',
+}
+
+var letterValues = map[byte]int{'Q': 9, 'R': 5, 'B': 3, 'N': 3, 'P': 1}
+
+// A kind of piece and its color.
+type piece struct {
+	kind  dragontoothmg.Piece
+	white bool
+}
+
+// A piece on a square.
+type placed struct {
+	piece
+	sq int
+}
+
+// Parses a material signature, such as "KQvK" or "KQK", into the pieces of each
+// side, in KQRBNP order.
+func parseMaterial(material string) (white string, black string, err error) {
+	upper := strings.ToUpper(material)
+	if i := strings.IndexByte(upper, 'V'); i >= 0 {
+		white, black = upper[:i], upper[i+1:]
+	} else if i := strings.LastIndexByte(upper, 'K'); i > 0 {
+		white, black = upper[:i], upper[i:]
+	}
+	white, black = sortSide(white), sortSide(black)
+	if !validSide(white) || !validSide(black) {
+		return "", "", errors.New("Invalid material " + material + ".")
+	}
+	if len(white)+len(black) > maxPieces {
+		return "", "", ErrTooManyPieces
+	}
+	return white, black, nil
+}
+
+func sortSide(side string) string {
+	letters := []byte(side)
+	sort.Slice(letters, func(i, j int) bool {
+		return strings.IndexByte(pieceLetters, letters[i]) < strings.IndexByte(pieceLetters, letters[j])
+	})
+	return string(letters)
+}
+
+func validSide(side string) bool {
+	if len(side) == 0 || side[0] != 'K' {
+		return false
+	}
+	for i := 1; i < len(side); i++ {
+		if side[i] == 'K' || strings.IndexByte(pieceLetters, side[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sideValue(side string) int {
+	value := 0
+	for i := 0; i < len(side); i++ {
+		value += letterValues[side[i]]
+	}
+	return value
+}
+
+// The name of the table that holds a material, which has the stronger side as
+// white, and whether the colors must be flipped to use it.
+func tableName(white string, black string) (string, bool) {
+	wv, bv := sideValue(white), sideValue(black)
+	flipped := bv > wv || (bv == wv && (len(black) > len(white) || (len(black) == len(white) && black > white)))
+	if flipped {
+		white, black = black, white
+	}
+	return white + "v" + black, flipped
+}
+
+// The names of the tables that a table's captures and promotions lead to.
+func dependencies(white string, black string) []string {
+	var deps []string
+	for side := 0; side < 2; side++ {
+		for i := 1; i < len(white); i++ {
+			// Captures, and for pawns, promotions.
+			changed := []string{white[:i] + white[i+1:]}
+			if white[i] == 'P' {
+				for _, promote := range "QRBN" {
+					changed = append(changed, sortSide(white[:i]+string(promote)+white[i+1:]))
+				}
+			}
+			for _, c := range changed {
+				if c == "K" && black == "K" {
+					continue
+				}
+				name, _ := tableName(c, black)
+				deps = append(deps, name)
+			}
+		}
+		white, black = black, white
+	}
+	return deps
+}
@@ -0,0 +1,334 @@
+package dragontoothmg
+
+import (
+	"encoding/json"
+	"io"
+	"math/bits"
+)
+
+// An Evaluator statically scores a position in centipawns, from the perspective
+// of the side to move. It is only called on positions that are not checkmate or
+// stalemate.
+type Evaluator interface {
+	Evaluate(b *Board) int
+}
+
+// A middlegame and an endgame value. Scores are interpolated between the two
+// according to the material left on the board. In JSON, this is a pair [mg, eg].
+type PhaseScore [2]int
+
+// The game phase: 24 with all minor and major pieces on the board, 0 with none.
+const (
+	maxPhase    = 24
+	knightPhase = 1
+	bishopPhase = 1
+	rookPhase   = 2
+	queenPhase  = 4
+)
+
+// The weights of the classical evaluator. Tables indexed by Piece ignore
+// Nothing and (where it makes no sense) King. Piece-square tables are indexed
+// by square (a1 = 0, h8 = 63) from white's point of view, and are mirrored for
+// black. Passed pawn bonuses are indexed by rank, from the owner's point of view.
+type EvalWeights struct {
+	Material     [7]PhaseScore     `json:"material"`
+	PieceSquare  [7][64]PhaseScore `json:"piece_square"`
+	Mobility     [7]PhaseScore     `json:"mobility"` // per reachable square
+	DoubledPawn  PhaseScore        `json:"doubled_pawn"`
+	IsolatedPawn PhaseScore        `json:"isolated_pawn"`
+	PassedPawn   [8]PhaseScore     `json:"passed_pawn"`
+	BishopPair   PhaseScore        `json:"bishop_pair"`
+	PawnShield   PhaseScore        `json:"pawn_shield"` // per pawn in front of the king
+	KingAttack   PhaseScore        `json:"king_attack"` // per attack on a square next to the enemy king
+}
+
+// Piece-square tables, as seen from white with a8 in the top left. They are
+// flipped into square order by init().
+var (
+	pawnTableMg = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0}
+	pawnTableEg = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		40, 40, 40, 40, 40, 40, 40, 40,
+		25, 25, 25, 25, 25, 25, 25, 25,
+		15, 15, 15, 15, 15, 15, 15, 15,
+		5, 5, 5, 5, 5, 5, 5, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0}
+	knightTable = [64]int{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50}
+	bishopTable = [64]int{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20}
+	rookTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0}
+	queenTable = [64]int{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20}
+	kingTableMg = [64]int{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20}
+	kingTableEg = [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50}
+)
+
+var defaultEvalWeights = EvalWeights{
+	Material: [7]PhaseScore{
+		Pawn: {100, 120}, Knight: {320, 300}, Bishop: {330, 320}, Rook: {500, 520}, Queen: {900, 950}},
+	Mobility: [7]PhaseScore{
+		Knight: {4, 4}, Bishop: {5, 5}, Rook: {2, 4}, Queen: {1, 2}},
+	DoubledPawn:  PhaseScore{-10, -20},
+	IsolatedPawn: PhaseScore{-10, -15},
+	PassedPawn: [8]PhaseScore{
+		{0, 0}, {5, 10}, {5, 15}, {10, 25}, {20, 45}, {35, 75}, {60, 120}, {0, 0}},
+	BishopPair: PhaseScore{30, 50},
+	PawnShield: PhaseScore{10, 0},
+	KingAttack: PhaseScore{8, 0},
+}
+
+// Squares on the files next to each file.
+var adjacentFiles [8]uint64
+
+// For each color (white = 1) and square, the squares in front of a pawn on the
+// same and adjacent files. A pawn is passed if no enemy pawns are there.
+var passedPawnMasks [2][64]uint64
+
+// For each color and king square, the squares of the pawn shield: the two ranks
+// in front of the king, on its file and the adjacent ones.
+var pawnShieldMasks [2][64]uint64
+
+func init() {
+	tables := [7][2]*[64]int{
+		Pawn:   {&pawnTableMg, &pawnTableEg},
+		Knight: {&knightTable, &knightTable},
+		Bishop: {&bishopTable, &bishopTable},
+		Rook:   {&rookTable, &rookTable},
+		Queen:  {&queenTable, &queenTable},
+		King:   {&kingTableMg, &kingTableEg},
+	}
+	for piece := Pawn; piece <= King; piece++ {
+		for sq := 0; sq < 64; sq++ {
+			defaultEvalWeights.PieceSquare[piece][sq] = PhaseScore{
+				tables[piece][0][sq^56], tables[piece][1][sq^56]}
+		}
+	}
+	initPieceSquareScores()
+
+	for file := 0; file < 8; file++ {
+		if file > 0 {
+			adjacentFiles[file] |= onlyFile[file-1]
+		}
+		if file < 7 {
+			adjacentFiles[file] |= onlyFile[file+1]
+		}
+	}
+	for sq := 0; sq < 64; sq++ {
+		file, rank := sq%8, sq/8
+		span := onlyFile[file] | adjacentFiles[file]
+		for r := 0; r < 8; r++ {
+			if r > rank {
+				passedPawnMasks[1][sq] |= span & onlyRank[r]
+			} else if r < rank {
+				passedPawnMasks[0][sq] |= span & onlyRank[r]
+			}
+			if r > rank && r <= rank+2 {
+				pawnShieldMasks[1][sq] |= span & onlyRank[r]
+			} else if r < rank && r >= rank-2 {
+				pawnShieldMasks[0][sq] |= span & onlyRank[r]
+			}
+		}
+	}
+}
+
+// Returns a copy of the built-in evaluation weights.
+func DefaultEvalWeights() *EvalWeights {
+	w := defaultEvalWeights
+	return &w
+}
+
+// Reads evaluation weights from JSON. Weights missing from the input keep their
+// default values, so a file may override only some of them.
+func LoadEvalWeights(r io.Reader) (*EvalWeights, error) {
+	w := DefaultEvalWeights()
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// A reference hand-crafted evaluator: material, tapered piece-square tables,
+// mobility, pawn structure, king safety and the bishop pair. It is safe for
+// concurrent use, as long as the weights are not modified.
+type ClassicalEvaluator struct {
+	Weights *EvalWeights
+	// Whether the material and piece-square weights are the defaults, so that
+	// the Board's incremental PieceSquareScore can be used.
+	incremental bool
+}
+
+// Creates a classical evaluator. If weights is nil, the defaults are used.
+func NewClassicalEvaluator(weights *EvalWeights) *ClassicalEvaluator {
+	if weights == nil {
+		weights = DefaultEvalWeights()
+	}
+	return &ClassicalEvaluator{Weights: weights, incremental: weights.Material == defaultEvalWeights.Material &&
+		weights.PieceSquare == defaultEvalWeights.PieceSquare}
+}
+
+func (e *ClassicalEvaluator) Evaluate(b *Board) int {
+	white := e.evaluateSide(b, true)
+	black := e.evaluateSide(b, false)
+	if e.incremental {
+		white[0] += b.pieceSquareScore[0]
+		white[1] += b.pieceSquareScore[1]
+	}
+	phase := knightPhase*bits.OnesCount64(b.White.Knights|b.Black.Knights) +
+		bishopPhase*bits.OnesCount64(b.White.Bishops|b.Black.Bishops) +
+		rookPhase*bits.OnesCount64(b.White.Rooks|b.Black.Rooks) +
+		queenPhase*bits.OnesCount64(b.White.Queens|b.Black.Queens)
+	if phase > maxPhase {
+		phase = maxPhase // possible after promotions
+	}
+	mg, eg := white[0]-black[0], white[1]-black[1]
+	score := (mg*phase + eg*(maxPhase-phase)) / maxPhase
+	if !b.Wtomove {
+		score = -score
+	}
+	return score
+}
+
+// Sums the terms for one side.
+func (e *ClassicalEvaluator) evaluateSide(b *Board, white bool) PhaseScore {
+	w := e.Weights
+	var ourPieces, oppPieces *Bitboards
+	color, flip := 1, 0
+	if white {
+		ourPieces, oppPieces = &(b.White), &(b.Black)
+	} else {
+		ourPieces, oppPieces = &(b.Black), &(b.White)
+		color, flip = 0, 56
+	}
+	allPieces := b.White.All | b.Black.All
+	oppPawnAttacks := pawnAttacks(oppPieces.Pawns, !white)
+	var oppKingZone uint64
+	if oppPieces.Kings != 0 {
+		oppKingZone = kingMasks[bits.TrailingZeros64(oppPieces.Kings)]
+	}
+	var score PhaseScore
+	add := func(term PhaseScore, times int) {
+		score[0] += term[0] * times
+		score[1] += term[1] * times
+	}
+
+	// Material, piece-square tables, mobility and attacks near the enemy king
+	pieces := [7]uint64{Pawn: ourPieces.Pawns, Knight: ourPieces.Knights, Bishop: ourPieces.Bishops,
+		Rook: ourPieces.Rooks, Queen: ourPieces.Queens, King: ourPieces.Kings}
+	for piece := Pawn; piece <= King; piece++ {
+		for x := pieces[piece]; x != 0; x &= x - 1 {
+			sq := uint8(bits.TrailingZeros64(x))
+			if !e.incremental {
+				add(w.Material[piece], 1)
+				add(w.PieceSquare[piece][int(sq)^flip], 1)
+			}
+			var attacks uint64
+			switch piece {
+			case Knight:
+				attacks = knightMasks[sq]
+			case Bishop:
+				attacks = CalculateBishopMoveBitboard(sq, allPieces)
+			case Rook:
+				attacks = CalculateRookMoveBitboard(sq, allPieces)
+			case Queen:
+				attacks = CalculateBishopMoveBitboard(sq, allPieces) | CalculateRookMoveBitboard(sq, allPieces)
+			default:
+				continue
+			}
+			add(w.Mobility[piece], bits.OnesCount64(attacks&^ourPieces.All&^oppPawnAttacks))
+			add(w.KingAttack, bits.OnesCount64(attacks&oppKingZone))
+		}
+	}
+
+	// Pawn structure
+	for file := 0; file < 8; file++ {
+		onFile := bits.OnesCount64(ourPieces.Pawns & onlyFile[file])
+		if onFile > 1 {
+			add(w.DoubledPawn, onFile-1)
+		}
+		if onFile > 0 && ourPieces.Pawns&adjacentFiles[file] == 0 {
+			add(w.IsolatedPawn, onFile)
+		}
+	}
+	for x := ourPieces.Pawns; x != 0; x &= x - 1 {
+		sq := bits.TrailingZeros64(x)
+		if passedPawnMasks[color][sq]&oppPieces.Pawns == 0 {
+			add(w.PassedPawn[(sq^flip)/8], 1)
+		}
+	}
+
+	// King safety and the bishop pair
+	if ourPieces.Kings != 0 {
+		kingSquare := bits.TrailingZeros64(ourPieces.Kings)
+		add(w.PawnShield, bits.OnesCount64(pawnShieldMasks[color][kingSquare]&ourPieces.Pawns))
+	}
+	if bits.OnesCount64(ourPieces.Bishops) >= 2 {
+		add(w.BishopPair, 1)
+	}
+	return score
+}
+
+// The squares attacked by a set of pawns.
+func pawnAttacks(pawns uint64, white bool) uint64 {
+	if white {
+		return (pawns&^onlyFile[0])<<7 | (pawns&^onlyFile[7])<<9
+	}
+	return (pawns&^onlyFile[7])>>7 | (pawns&^onlyFile[0])>>9
+}
@@ -0,0 +1,106 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// Swaps the colors and flips the board vertically.
+func mirrorBoard(b Board) Board {
+	flip := func(bb Bitboards) Bitboards {
+		return Bitboards{Pawns: bits.ReverseBytes64(bb.Pawns), Knights: bits.ReverseBytes64(bb.Knights),
+			Bishops: bits.ReverseBytes64(bb.Bishops), Rooks: bits.ReverseBytes64(bb.Rooks),
+			Queens: bits.ReverseBytes64(bb.Queens), Kings: bits.ReverseBytes64(bb.Kings),
+			All: bits.ReverseBytes64(bb.All)}
+	}
+	mirrored := b
+	mirrored.White, mirrored.Black = flip(b.Black), flip(b.White)
+	mirrored.Wtomove = !b.Wtomove
+	mirrored.recomputeMaterial()
+	return mirrored
+}
+
+func TestEvaluateSymmetry(t *testing.T) {
+	e := NewClassicalEvaluator(nil)
+	start := ParseFen(Startpos)
+	if score := e.Evaluate(&start); score != 0 {
+		t.Error("The starting position should be even, got", score)
+	}
+	for _, fen := range movePickerPositions {
+		b := ParseFen(fen)
+		mirrored := mirrorBoard(b)
+		if e.Evaluate(&b) != e.Evaluate(&mirrored) {
+			t.Error("Evaluation is not symmetric for", fen, e.Evaluate(&b), e.Evaluate(&mirrored))
+		}
+		b.Wtomove = !b.Wtomove
+		if e.Evaluate(&b) != -e.Evaluate(&mirrored) {
+			t.Error("Evaluation does not depend on the side to move for", fen)
+		}
+	}
+}
+
+func TestEvaluateIncremental(t *testing.T) {
+	incremental := NewClassicalEvaluator(nil)
+	full := &ClassicalEvaluator{Weights: DefaultEvalWeights()}
+	for _, fen := range movePickerPositions {
+		b := ParseFen(fen)
+		for _, m := range b.GenerateLegalMoves() {
+			after := b
+			after.Apply(m)
+			if incremental.Evaluate(&after) != full.Evaluate(&after) {
+				t.Error("Incremental evaluation differs after", &m, "in", fen)
+			}
+		}
+	}
+}
+
+func TestEvaluateTerms(t *testing.T) {
+	e := NewClassicalEvaluator(nil)
+	score := func(fen string) int {
+		b := ParseFen(fen)
+		return e.Evaluate(&b)
+	}
+	better := map[string]string{
+		// extra material
+		"4k3/8/8/8/8/8/8/3QK3 w - - 0 1": "4k3/8/8/8/8/8/8/3RK3 w - - 0 1",
+		// a passed pawn, rather than a blocked one
+		"4k3/8/8/3P4/8/8/8/4K3 w - - 0 1": "4k3/8/3p4/3P4/8/8/8/4K3 w - - 0 1",
+		// healthy pawns, rather than doubled and isolated ones
+		"4k3/8/8/8/8/8/2P1P3/4K3 w - - 0 1": "4k3/8/8/8/8/4P3/4P3/4K3 w - - 0 1",
+		// the bishop pair
+		"4k3/8/8/8/8/8/8/2BBK3 w - - 0 1": "4k3/8/8/8/8/8/8/2BNK3 w - - 0 1",
+		// a pawn shield
+		"r2q2k1/5ppp/8/8/8/8/5PPP/R2Q2K1 w - - 0 1": "r2q2k1/5ppp/8/8/5PPP/8/8/R2Q2K1 w - - 0 1",
+		// a centralized knight has more squares
+		"4k3/8/8/8/3N4/8/8/4K3 w - - 0 1": "4k3/8/8/8/8/8/8/N3K3 w - - 0 1",
+		// a centralized king in the endgame
+		"8/8/8/3k4/8/8/8/4K3 b - - 0 1": "8/8/8/8/8/8/8/k3K3 b - - 0 1",
+	}
+	for good, bad := range better {
+		if score(good) <= score(bad) {
+			t.Error("Expected", good, "to beat", bad, "but got", score(good), "and", score(bad))
+		}
+	}
+}
+
+func TestLoadEvalWeights(t *testing.T) {
+	w, err := LoadEvalWeights(strings.NewReader(`{"material": [[0,0],[200,200],[320,300],[330,320],[500,520],[900,950],[0,0]], "bishop_pair": [0, 0]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Material[Pawn] != (PhaseScore{200, 200}) || w.BishopPair != (PhaseScore{}) ||
+		w.DoubledPawn != defaultEvalWeights.DoubledPawn {
+		t.Error("Weights were not loaded over the defaults:", w.Material, w.BishopPair, w.DoubledPawn)
+	}
+	b := ParseFen("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	if NewClassicalEvaluator(w).Evaluate(&b) <= NewClassicalEvaluator(nil).Evaluate(&b) {
+		t.Error("The loaded weights were not used.")
+	}
+	if _, err := LoadEvalWeights(strings.NewReader(`{"mobilty": []}`)); err == nil {
+		t.Error("An unknown weight was accepted.")
+	}
+	if DefaultEvalWeights() == DefaultEvalWeights() {
+		t.Error("The default weights should be copied.")
+	}
+}
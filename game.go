@@ -0,0 +1,130 @@
+package dragontoothmg
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// The outcome of a game, as in the PGN result tag.
+type Outcome int
+
+const (
+	Ongoing Outcome = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case WhiteWins:
+		return "1-0"
+	case BlackWins:
+		return "0-1"
+	case Draw:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// A game in progress: the starting position, the moves played, and the current
+// position. The move history is kept so that repetitions can be detected.
+type Game struct {
+	Start Board
+	Board Board
+	Moves []Move
+
+	hashes []uint64 // the hash of the position before each move
+}
+
+// Starts a game from the given position.
+func NewGame(start Board) *Game {
+	return &Game{Start: start, Board: start}
+}
+
+// Plays a move, after checking that it is legal.
+func (g *Game) Play(m Move) error {
+	if !g.Board.IsLegal(m) {
+		return errors.New("Illegal move " + m.String())
+	}
+	g.hashes = append(g.hashes, g.Board.Hash())
+	g.Moves = append(g.Moves, m)
+	g.Board.Apply(m)
+	return nil
+}
+
+// Returns the hashes of the positions before the current one, oldest first, for
+// repetition detection in search.
+func (g *Game) History() []uint64 {
+	return g.hashes
+}
+
+// Counts how many times the current position occurred earlier in the game.
+// Only positions since the last capture or pawn move can repeat.
+func (g *Game) Repetitions() int {
+	hash := g.Board.Hash()
+	oldest := len(g.hashes) - int(g.Board.Halfmoveclock)
+	if oldest < 0 {
+		oldest = 0
+	}
+	count := 0
+	for i := len(g.hashes) - 2; i >= oldest; i -= 2 {
+		if g.hashes[i] == hash {
+			count++
+		}
+	}
+	return count
+}
+
+// Reports whether the game is over by the rules: the variant's own win
+// conditions, checkmate, stalemate, threefold repetition, the fifty-move rule,
+// or insufficient material. The reason is empty while the game is ongoing.
+func (g *Game) Outcome() (Outcome, string) {
+	b := &g.Board
+	if outcome, reason := b.VariantOutcome(); outcome != Ongoing {
+		return outcome, reason
+	}
+	if len(b.GenerateLegalMoves()) == 0 {
+		if !b.OurKingInCheck() {
+			return Draw, "stalemate"
+		} else if b.Wtomove {
+			return BlackWins, "checkmate"
+		}
+		return WhiteWins, "checkmate"
+	}
+	if g.Repetitions() >= 2 {
+		return Draw, "threefold repetition"
+	}
+	if b.Halfmoveclock >= 100 {
+		return Draw, "fifty-move rule"
+	}
+	if b.InsufficientMaterial() {
+		return Draw, "insufficient material"
+	}
+	return Ongoing, ""
+}
+
+// Whether neither side can possibly checkmate: bare kings, a single minor piece,
+// or only bishops that all stand on squares of one color. In variants that can
+// be won without mating, only bare kings in ThreeCheck and Atomic are
+// insufficient, and in Crazyhouse, bare kings with empty pockets.
+func (b *Board) InsufficientMaterial() bool {
+	switch b.Variant {
+	case ThreeCheck, Atomic:
+		return b.White.All|b.Black.All == b.White.Kings|b.Black.Kings
+	case Crazyhouse:
+		return b.White.All|b.Black.All == b.White.Kings|b.Black.Kings && b.pockets == [2][6]uint8{}
+	case KingOfTheHill, RacingKings, Antichess, Horde:
+		return false
+	}
+	if b.White.Pawns|b.Black.Pawns|b.White.Rooks|b.Black.Rooks|b.White.Queens|b.Black.Queens != 0 {
+		return false
+	}
+	knights := b.White.Knights | b.Black.Knights
+	bishops := b.White.Bishops | b.Black.Bishops
+	if bits.OnesCount64(knights|bishops) <= 1 {
+		return true
+	}
+	const darkSquares = 0xaa55aa55aa55aa55
+	return knights == 0 && (bishops&darkSquares == 0 || bishops&^darkSquares == 0)
+}
@@ -0,0 +1,64 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func playAll(t *testing.T, g *Game, moves ...string) {
+	for _, str := range moves {
+		m, err := g.Board.ParseSan(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Play(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGameOutcome(t *testing.T) {
+	g := NewGame(ParseFen(Startpos))
+	playAll(t, g, "f3", "e5", "g4")
+	if outcome, _ := g.Outcome(); outcome != Ongoing || outcome.String() != "*" {
+		t.Error("The game should not be over yet.")
+	}
+	playAll(t, g, "Qh4#")
+	if outcome, reason := g.Outcome(); outcome != BlackWins || reason != "checkmate" {
+		t.Error("Expected fool's mate, got", outcome, reason)
+	}
+	if len(g.Moves) != 4 || len(g.History()) != 4 || g.History()[0] != g.Start.Hash() {
+		t.Error("Moves or history were not recorded.")
+	}
+
+	g = NewGame(ParseFen(Startpos))
+	playAll(t, g, "Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1")
+	if outcome, _ := g.Outcome(); outcome != Ongoing || g.Repetitions() != 1 {
+		t.Error("The position has only occurred twice.")
+	}
+	playAll(t, g, "Ng8")
+	if outcome, reason := g.Outcome(); outcome != Draw || reason != "threefold repetition" {
+		t.Error("Expected a repetition, got", outcome, reason)
+	}
+	m, _ := ParseMove("e2e5")
+	if g.Play(m) == nil {
+		t.Error("An illegal move was played.")
+	}
+
+	draws := map[string]string{
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1":                                   "stalemate",
+		"7k/8/6K1/8/8/8/8/R7 w - - 100 80":                                 "fifty-move rule",
+		"7k/8/6K1/8/8/8/8/B7 w - - 0 1":                                    "insufficient material",
+		"7k/8/6K1/8/8/2b5/8/B7 w - - 0 1":                                  "insufficient material",
+		"4k3/8/8/8/8/8/8/4K3 b - - 0 1":                                    "insufficient material",
+		"7k/8/6K1/8/8/8/8/R6r w - - 99 80":                                 "",
+		"7k/8/6K1/8/8/1b6/8/B7 w - - 0 1":                                  "",
+		"7k/8/6K1/8/8/8/8/NN6 w - - 0 1":                                   "",
+		"7k/6Q1/6K1/8/8/8/8/8 b - - 0 1":                                   "checkmate",
+		"r1bqkbnr/pppp1Qpp/2n5/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4": "checkmate",
+	}
+	for fen, expected := range draws {
+		if _, reason := NewGame(ParseFen(fen)).Outcome(); reason != expected {
+			t.Error("Expected", expected, "but got", reason, "for", fen)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Command kpkgen generates kpk_bitbase.go, the King and Pawn vs King bitbase,
+// with the library's own move generation. Starting from the positions decided
+// by a promotion or capture, it marks positions won until none change: white
+// to move wins if a move wins, and black to move loses if every move does.
+//
+// It is run by go generate in the repository root.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+const positions = 24 * 64 * 64 * 2
+
+const (
+	unknown = iota
+	win
+	draw
+	invalid
+)
+
+// The same layout as kpkIndex in kpk.go.
+func index(wtm bool, whiteKing int, blackKing int, pawn int) int {
+	idx := (((pawn>>3-1)*4+pawn&7)*64+whiteKing)*64 + blackKing
+	idx <<= 1
+	if !wtm {
+		idx |= 1
+	}
+	return idx
+}
+
+func decode(idx int) (wtm bool, whiteKing int, blackKing int, pawn int) {
+	wtm = idx&1 == 0
+	idx >>= 1
+	blackKing, whiteKing = idx&63, idx>>6&63
+	idx >>= 12
+	pawn = (idx/4+1)*8 + idx%4
+	return
+}
+
+func fen(wtm bool, whiteKing int, blackKing int, pawn int) string {
+	var grid [64]byte
+	grid[whiteKing], grid[blackKing], grid[pawn] = 'K', 'k', 'P'
+	var s strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		for file := 0; file < 8; file++ {
+			if c := grid[rank*8+file]; c != 0 {
+				s.WriteByte(c)
+			} else {
+				s.WriteByte('1')
+			}
+		}
+		if rank > 0 {
+			s.WriteByte('/')
+		}
+	}
+	if wtm {
+		return s.String() + " w - - 0 1"
+	}
+	return s.String() + " b - - 0 1"
+}
+
+// Whether a king and a promoted queen or rook win after white promotes, with
+// black to move: they do unless black is stalemated or takes the new piece.
+func promotionWins(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	for _, promote := range []dragontoothmg.Piece{dragontoothmg.Queen, dragontoothmg.Rook} {
+		m.Setpromote(promote)
+		next := *b
+		next.Apply(m)
+		replies := next.GenerateLegalMoves()
+		wins := len(replies) > 0 || next.OurKingInCheck()
+		for _, reply := range replies {
+			if reply.To() == m.To() {
+				wins = false
+			}
+		}
+		if wins {
+			return true
+		}
+	}
+	return false
+}
+
+// The index of a position after a move, mirrored to put the pawn on files a-d.
+func childIndex(b *dragontoothmg.Board) int {
+	whiteKing := bits.TrailingZeros64(b.White.Kings)
+	blackKing := bits.TrailingZeros64(b.Black.Kings)
+	pawn := bits.TrailingZeros64(b.White.Pawns)
+	if pawn&7 > 3 {
+		whiteKing, blackKing, pawn = whiteKing^7, blackKing^7, pawn^7
+	}
+	return index(b.Wtomove, whiteKing, blackKing, pawn)
+}
+
+func generate() []uint64 {
+	status := make([]uint8, positions)
+	children := make([][]int32, positions)
+	for idx := range status {
+		wtm, whiteKing, blackKing, pawn := decode(idx)
+		if pawn&7 > 3 || whiteKing == blackKing || whiteKing == pawn || blackKing == pawn ||
+			dragontoothmg.CalculateKingMoveBitboard(uint8(whiteKing))&(uint64(1)<<uint(blackKing)) != 0 {
+			status[idx] = invalid
+			continue
+		}
+		b := dragontoothmg.ParseFen(fen(wtm, whiteKing, blackKing, pawn))
+		if wtm && b.UnderDirectAttack(false, uint8(blackKing)) {
+			status[idx] = invalid // black is in check with white to move
+			continue
+		}
+		moves := b.GenerateLegalMoves()
+		if len(moves) == 0 {
+			status[idx] = draw
+			if !wtm && b.OurKingInCheck() {
+				status[idx] = win
+			}
+			continue
+		}
+		for _, m := range moves {
+			if m.Promote() != dragontoothmg.Nothing {
+				if promotionWins(&b, m) {
+					status[idx] = win
+				}
+				continue
+			}
+			next := b
+			next.Apply(m)
+			if next.White.Pawns == 0 {
+				status[idx] = draw // black took the pawn
+				break
+			}
+			children[idx] = append(children[idx], int32(childIndex(&next)))
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for idx, s := range status {
+			if s != unknown {
+				continue
+			}
+			wtm := idx&1 == 0
+			won := !wtm
+			for _, child := range children[idx] {
+				if wtm && status[child] == win {
+					won = true
+					break
+				} else if !wtm && status[child] != win {
+					won = false
+					break
+				}
+			}
+			if won {
+				status[idx] = win
+				changed = true
+			}
+		}
+	}
+
+	bitbase := make([]uint64, positions/64)
+	for idx, s := range status {
+		if s == win {
+			bitbase[idx/64] |= uint64(1) << uint(idx%64)
+		}
+	}
+	return bitbase
+}
+
+func main() {
+	out := flag.String("o", "kpk_bitbase.go", "file to write")
+	flag.Parse()
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by internal/kpkgen; DO NOT EDIT.\n\npackage dragontoothmg\n\n")
+	src.WriteString("// One bit per KPK position, set if white wins; see kpkIndex.\n")
+	src.WriteString("var kpkBitbase = [kpkPositions / 64]uint64{\n")
+	for i, word := range generate() {
+		fmt.Fprintf(&src, "0x%016x,", word)
+		if i%4 == 3 {
+			src.WriteByte('\n')
+		} else {
+			src.WriteByte(' ')
+		}
+	}
+	src.WriteString("}\n")
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, formatted, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
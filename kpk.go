@@ -0,0 +1,46 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+//go:generate go run ./internal/kpkgen -o kpk_bitbase.go
+
+// The KPK bitbase holds one bit per position with white's king, black's king
+// and a white pawn, set if white wins. Positions are mirrored so that the pawn
+// is on files a-d; the pawn's rank (2-7) and file give 24 pawn squares.
+const kpkPositions = 24 * 64 * 64 * 2
+
+// The index of a KPK position in kpkBitbase. The pawn must be on files a-d.
+// internal/kpkgen uses the same layout.
+func kpkIndex(wtm bool, whiteKing uint8, blackKing uint8, pawn uint8) int {
+	idx := ((int(pawn>>3-1)*4+int(pawn&7))*64+int(whiteKing))*64 + int(blackKing)
+	idx <<= 1
+	if !wtm {
+		idx |= 1
+	}
+	return idx
+}
+
+// Probes the built-in King and Pawn vs King bitbase. ok is false unless the
+// board has only the two kings and a pawn; then win reports whether the side
+// with the pawn wins with perfect play.
+func ProbeKPK(b *Board) (win bool, ok bool) {
+	pawns := b.White.Pawns | b.Black.Pawns
+	if bits.OnesCount64(pawns) != 1 || b.White.All|b.Black.All != pawns|b.White.Kings|b.Black.Kings ||
+		b.White.Kings == 0 || b.Black.Kings == 0 {
+		return false, false
+	}
+	whiteKing := uint8(bits.TrailingZeros64(b.White.Kings))
+	blackKing := uint8(bits.TrailingZeros64(b.Black.Kings))
+	pawn := uint8(bits.TrailingZeros64(pawns))
+	wtm := b.Wtomove
+	if b.Black.Pawns != 0 { // swap the colors
+		whiteKing, blackKing, pawn, wtm = blackKing^56, whiteKing^56, pawn^56, !wtm
+	}
+	if pawn&7 > 3 {
+		whiteKing, blackKing, pawn = whiteKing^7, blackKing^7, pawn^7
+	}
+	idx := kpkIndex(wtm, whiteKing, blackKing, pawn)
+	return kpkBitbase[idx/64]&(uint64(1)<<uint(idx%64)) != 0, true
+}
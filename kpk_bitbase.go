@@ -0,0 +1,775 @@
+// Code generated by internal/kpkgen; DO NOT EDIT.
+
+package dragontoothmg
+
+// One bit per KPK position, set if white wins; see kpkIndex.
+var kpkBitbase = [kpkPositions / 64]uint64{
+	0xd000d000d550fff0, 0xd000d000d000d000, 0xd000d000d540ffc0, 0xd000d000d000d000,
+	0xd000d000d500ff01, 0xd000d000d000d000, 0xd000d000d404fc05, 0xd000d000d000d000,
+	0xd000d000d014f035, 0xd000d000d000d000, 0xd000d000c054c0f5, 0xd000d000d000d000,
+	0xd000d000015403f5, 0xd000d000d000d000, 0xd000d00005540ff5, 0xd000d000d000d000,
+	0x0000000000000000, 0x0000000000000000, 0xd000d540ffc0ffc0, 0xd000d000d000d000,
+	0xd000d500ff00ff01, 0xd000d000d000d000, 0xd000d400fc04fc05, 0xd000d000d000d000,
+	0xd000d000f014f035, 0xd000d000d000d000, 0xd000c000c054c0f5, 0xd000d000d000d000,
+	0xd0000000015403f5, 0xd000d000d000d000, 0xd000000005540ff5, 0xd000d000d000d000,
+	0xd540ff40ff50ffff, 0xd000d000d000d000, 0xd540ffc0ffc0ffff, 0xd000d000d000d000,
+	0xd500ff00ff00fff5, 0xd000d000d000d000, 0xd400fc00fc04fff5, 0xd000d000d000d000,
+	0xd000f000f014fff5, 0xd000d000d000d000, 0xc000c000c054fff5, 0xd000d000d000d000,
+	0x000000000154fff5, 0xd000d000d000d000, 0x000000000554fff5, 0xd000d000d000d000,
+	0xff40ff50fff4fff5, 0xf400f400f400f540, 0xffc0ffc0fff4fff5, 0xf400f400f400f540,
+	0xff00ff00fff4fff5, 0xf400f400f400f500, 0xfc00fc00fff4fff5, 0xf400f400f400f400,
+	0xf000f000ff54fff5, 0xf400f400f400f000, 0xc000c000fd54fff5, 0xd000d000d000c000,
+	0x00000000f554fff5, 0xd000d000d0000000, 0x00000000d554fff5, 0xd000d000d0000000,
+	0xff40ff50fff4fff5, 0xfd00fd00fd40ff40, 0xffc0fff0fff4fff5, 0xfd00fd00fd40ffc0,
+	0xff00fff0fff4fff5, 0xfd00fd00fd00ff00, 0xfc00ffd0fff4fff5, 0xfd00fd00fc00fc00,
+	0xf000ff40ff54fff5, 0xf400f400f000f000, 0xc000fd00fd54fff5, 0xd000d000c000c000,
+	0x0000f400f554fff5, 0xd000d00000000000, 0x0000d000d554fff5, 0xd000d00000000000,
+	0xff40ff50fff4fff5, 0xff40ff40ff40ff40, 0xffd0ffd0fff4fff5, 0xff40ff40ffc0ffc0,
+	0xff40ff50fff4fff5, 0xff40ff00ff00ff00, 0xfd00fd50fff4fff5, 0xfd00fc00fc00fc00,
+	0xf400f540ff54fff5, 0xf400f000f000f000, 0xd000d500fd54fff5, 0xd000c000c000c000,
+	0xd000d400f554fff5, 0xd000000000000000, 0xd000d000d554fff5, 0xd000000000000000,
+	0xff40ff40ff54fff5, 0xff40ff40ff40ff40, 0xff40ff40ff54fff5, 0xffc0ffc0ff40ff40,
+	0xff40ff40ff54fff5, 0xff00ff00ff00ff40, 0xfd00fd00fd54fff5, 0xfc00fc00fc00fd00,
+	0xf400f400f554fff5, 0xf000f000f000f400, 0xd000d000d554fff5, 0xc000c000c000d000,
+	0xd000d000d554fff5, 0x000000000000d000, 0xd000d000d554fff5, 0x000000000000d000,
+	0xfd00fd00fd54fff5, 0xff40fd40fd00fd00, 0xfd00fd00fd54fff5, 0xffc0fd40fd00fd00,
+	0xfd00fd00fd54fff5, 0xff00fd00fd00fd00, 0xfd00fd00fd54fff5, 0xfc00fc00fd00fd00,
+	0xf400f400f554fff5, 0xf000f000f400f400, 0xd000d000d554fff5, 0xc000c000d000d000,
+	0xd000d000d554fff5, 0x00000000d000d000, 0xd000d000d554fff5, 0x00000000d000d000,
+	0xff40ff40ffd0fff0, 0xff55ff40ff40ff40, 0xff40ff40ffc0ffc0, 0xff55ff40ff40ff40,
+	0xfd00fd00fd01ff03, 0xfd55fd00fd00fd00, 0xfd00fd00fc01fc05, 0xfd55fd00fd00fd00,
+	0xf400f400f011f015, 0xf555f400f400f400, 0xd000d000c051c0d5, 0xd555d000d000d000,
+	0x40004000015103d5, 0x5400400040004000, 0x4000400005510fd5, 0x5000400040004000,
+	0xffd0ffc0fff0fff0, 0xffffffd5ffd0ffd5, 0x0000000000000000, 0x0000000000000000,
+	0xff41ff00ff03ff03, 0xffffff55ff41ff55, 0xfd01fc00fc01fc05, 0xfffffd55fd01fd51,
+	0xf400f000f011f015, 0xfffff555f400f540, 0xd000c000c051c0d5, 0xfd55d500d000d500,
+	0x40000000015103d5, 0xf400540040005400, 0x4000000005510fd5, 0xd000500040005000,
+	0xfff0ffe0fff0ffff, 0xfffffffffff5fff6, 0xffc0ffc0ffc0ffff, 0xffffffffffd5ffd9,
+	0xff03ff02ff03ffff, 0xffffffffff57ff67, 0xfc01fc00fc01ffd5, 0xfffffffffd55fd91,
+	0xf000f000f011ffd5, 0xffffff55f540f640, 0xc000c000c051ffd5, 0xfd55fd00d500d900,
+	0x000000000151ffd5, 0xf400f40054006400, 0x000000000551ffd5, 0xd000d000d000d000,
+	0xfff0ffc0ffd1ffd5, 0xfffffffffffffff0, 0xffc0ffc0ffd1ffd5, 0xffffffffffffffc0,
+	0xff03ff00ffd1ffd5, 0xffffffffffffff03, 0xfc01fc00ffd1ffd5, 0xffffffffffd5fc01,
+	0xf000f000ffd1ffd5, 0xffffff55ff40f000, 0xc000c000fd51ffd5, 0xfd55fd00fd00c000,
+	0x00000000f551ffd5, 0xf400f400f4000000, 0x00000000d551ffd5, 0xd000d000d0000000,
+	0xffd0ffc0ffd1ffd5, 0xfffffffffff0fff0, 0xffc0ffc0ffd1ffd5, 0xffffffffffc0ffc0,
+	0xff01ffc0ffd1ffd5, 0xffffffffff03ff03, 0xfc01ffc0ffd1ffd5, 0xfffffffffc05fc01,
+	0xf000ff40ffd1ffd5, 0xffffff55f000f000, 0xc000fd00fd51ffd5, 0xfd55fd00c000c000,
+	0x0000f400f551ffd5, 0xf400f40000000000, 0x0000d000d551ffd5, 0xd000d00000000000,
+	0xff40ff40ffd1ffd5, 0xfffffff0fff0ff50, 0xff40ff40ffd1ffd5, 0xffffffc0ffc0ff40,
+	0xff40ff40ffd1ffd5, 0xffffff03ff03ff01, 0xfd00fd40ffd1ffd5, 0xfffffc05fc01fc01,
+	0xf400f540ffd1ffd5, 0xfffff015f000f000, 0xd000d500fd51ffd5, 0xfd55c000c000c000,
+	0x40005400f551ffd5, 0xf400000000000000, 0x40005000d551ffd5, 0xd000000000000000,
+	0xfd00fd00fd51ffd5, 0xfff0fff0fd50fd00, 0xfd00fd00fd51ffd5, 0xffc0ffc0fd40fd00,
+	0xfd00fd00fd51ffd5, 0xff03ff03fd01fd00, 0xfd00fd00fd51ffd5, 0xfc05fc01fc01fd00,
+	0xf400f400f551ffd5, 0xf015f000f000f400, 0xd000d000d551ffd5, 0xc055c000c000d000,
+	0x400040005551ffd5, 0x0000000000004000, 0x400040005551ffd5, 0x0000000000004000,
+	0xf400f400f551ffd5, 0xfff0f550f400f400, 0xf400f400f551ffd5, 0xffc0f540f400f400,
+	0xf400f400f551ffd5, 0xff03f501f400f400, 0xf400f400f551ffd5, 0xfc01f401f400f400,
+	0xf400f400f551ffd5, 0xf000f000f400f400, 0xd000d000d551ffd5, 0xc000c000d000d000,
+	0x400040005551ffd5, 0x0000000040004000, 0x400040005551ffd5, 0x0000000040004000,
+	0xfd00fd00ff40ff50, 0xfd55fd00fd00fd00, 0xfd00fd00ff40ffc0, 0xfd55fd00fd00fd00,
+	0xfd01fd01ff03ff03, 0xfd55fd01fd01fd01, 0xf401f401f407fc0f, 0xf555f401f401f401,
+	0xf401f401f007f017, 0xf555f401f401f401, 0xd001d001c047c057, 0xd555d001d001d001,
+	0x4000400001450357, 0x5555400040004000, 0x0000000005450f57, 0x5000000000000000,
+	0xff40ff00ff40ff50, 0xffffff55ff40ff45, 0xff41ff00ffc0ffc0, 0xffffff55ff41ff55,
+	0x0000000000000000, 0x0000000000000000, 0xfd07fc03fc0ffc0f, 0xfffffd57fd07fd57,
+	0xf407f003f007f017, 0xfffff557f407f547, 0xd001c001c047c057, 0xffffd555d001d501,
+	0x4000000001450357, 0xf555540040005400, 0x0000000005450f57, 0xd000500000005000,
+	0xff40ff00ff40ff57, 0xffffffffff55ff46, 0xffc0ff80ffc0ffff, 0xffffffffffd5ffd9,
+	0xff03ff03ff03ffff, 0xffffffffff57ff67, 0xfc0ffc0bfc0fffff, 0xfffffffffd5ffd9f,
+	0xf007f003f007ff57, 0xfffffffff557f647, 0xc001c001c047ff57, 0xfffffd55d501d901,
+	0x000000000145ff57, 0xf555f40054006400, 0x000000000545ff57, 0xd000d00050009000,
+	0xff40ff00ff47ff57, 0xffffffffff57ff40, 0xffc0ff00ff47ff57, 0xffffffffffffffc0,
+	0xff03ff03ff47ff57, 0xffffffffffffff03, 0xfc0ffc03ff47ff57, 0xfffffffffffffc0f,
+	0xf007f003ff47ff57, 0xffffffffff57f007, 0xc001c001ff47ff57, 0xfffffd55fd01c001,
+	0x00000000f545ff57, 0xf555f400f4000000, 0x00000000d545ff57, 0xd000d000d0000000,
+	0xff40ff03ff47ff57, 0xffffffffff50ff40, 0xff40ff03ff47ff57, 0xffffffffffc0ffc0,
+	0xff03ff03ff47ff57, 0xffffffffff03ff03, 0xfc07ff03ff47ff57, 0xfffffffffc0ffc0f,
+	0xf007ff03ff47ff57, 0xfffffffff017f007, 0xc001fd01ff47ff57, 0xfffffd55c001c001,
+	0x0000f400f545ff57, 0xf555f40000000000, 0x0000d000d545ff57, 0xd000d00000000000,
+	0xfd00fd01ff47ff57, 0xffffff50ff40fd40, 0xfd01fd01ff47ff57, 0xffffffc0ffc0fd40,
+	0xfd01fd01ff47ff57, 0xffffff03ff03fd01, 0xfd01fd01ff47ff57, 0xfffffc0ffc0ffc05,
+	0xf401f501ff47ff57, 0xfffff017f007f005, 0xd001d501ff47ff57, 0xffffc055c001c001,
+	0x40005400f545ff57, 0xf555000000000000, 0x00005000d545ff57, 0xd000000000000000,
+	0xf400f400f545ff57, 0xff50ff40f540f400, 0xf400f400f545ff57, 0xffc0ffc0f540f400,
+	0xf400f400f545ff57, 0xff03ff03f501f400, 0xf400f400f545ff57, 0xfc0ffc0ff405f400,
+	0xf400f400f545ff57, 0xf017f007f005f400, 0xd000d000d545ff57, 0xc055c001c001d000,
+	0x400040005545ff57, 0x0155000000004000, 0x000000005545ff57, 0x0000000000000000,
+	0xd000d000d545ff57, 0xff40d540d000d000, 0xd000d000d545ff57, 0xffc0d540d000d000,
+	0xd000d000d545ff57, 0xff03d501d000d000, 0xd000d000d545ff57, 0xfc0fd405d000d000,
+	0xd000d000d545ff57, 0xf007d005d000d000, 0xd000d000d545ff57, 0xc001c001d000d000,
+	0x400040005545ff57, 0x0000000040004000, 0x000000005545ff57, 0x0000000000000000,
+	0xf400f400fd10fd50, 0xf555f400f400f400, 0xf401f401fd00fd40, 0xf555f401f401f401,
+	0xf401f401fd01ff03, 0xf555f401f401f401, 0xf407f407fc0ffc0f, 0xf557f407f407f407,
+	0xd007d007d01ff03f, 0xd557d007d007d007, 0xd007d007c01fc05f, 0xd557d007d007d007,
+	0x40074007011f015f, 0x5557400740074007, 0x0001000105150d5f, 0x5555000100010001,
+	0xf400f400fd10fd50, 0xfffff555f400f405, 0xfd01fc00fd00fd40, 0xfffffd55fd01fd15,
+	0xfd07fc03ff03ff03, 0xfffffd57fd07fd57, 0x0000000000000000, 0x0000000000000000,
+	0xf41ff00ff03ff03f, 0xfffff55ff41ff55f, 0xd01fc00fc01fc05f, 0xffffd55fd01fd51f,
+	0x40070007011f015f, 0xffff555740075407, 0x0001000105150d5f, 0xd555500100015001,
+	0xf400f400fd10fd5f, 0xfffff557f405f406, 0xfd00fc00fd00fd5f, 0xfffffffffd55fd19,
+	0xff03fe03ff03ffff, 0xffffffffff57ff67, 0xfc0ffc0ffc0fffff, 0xfffffffffd5ffd9f,
+	0xf03ff02ff03fffff, 0xfffffffff57ff67f, 0xc01fc00fc01ffd5f, 0xffffffffd55fd91f,
+	0x00070007011ffd5f, 0xfffff55754076407, 0x000100010515fd5f, 0xd555d00150019001,
+	0xf400f400fd1ffd5f, 0xfffff557f407f400, 0xfd00fc00fd1ffd5f, 0xfffffffffd5ffd00,
+	0xff03fc03fd1ffd5f, 0xffffffffffffff03, 0xfc0ffc0ffd1ffd5f, 0xfffffffffffffc0f,
+	0xf03ff00ffd1ffd5f, 0xfffffffffffff03f, 0xc01fc00ffd1ffd5f, 0xfffffffffd5fc01f,
+	0x00070007fd1ffd5f, 0xfffff557f4070007, 0x00010001d515fd5f, 0xd555d001d0010001,
+	0xf400f407fd1ffd5f, 0xfffff557f400f400, 0xfd00fc0ffd1ffd5f, 0xfffffffffd40fd00,
+	0xfd03fc0ffd1ffd5f, 0xffffffffff03ff03, 0xfc0ffc0ffd1ffd5f, 0xfffffffffc0ffc0f,
+	0xf01ffc0ffd1ffd5f, 0xfffffffff03ff03f, 0xc01ffc0ffd1ffd5f, 0xffffffffc05fc01f,
+	0x0007f407fd1ffd5f, 0xfffff55700070007, 0x0001d001d515fd5f, 0xd555d00100010001,
+	0xf400f405fd1ffd5f, 0xfffff550f400f400, 0xf401f405fd1ffd5f, 0xfffffd40fd00f500,
+	0xf407f407fd1ffd5f, 0xffffff03ff03f503, 0xf407f407fd1ffd5f, 0xfffffc0ffc0ff407,
+	0xf407f407fd1ffd5f, 0xfffff03ff03ff017, 0xd007d407fd1ffd5f, 0xffffc05fc01fc017,
+	0x40075407fd1ffd5f, 0xffff015700070007, 0x00015001d515fd5f, 0xd555000100010001,
+	0xd000d000d515fd5f, 0xf550f400d400d000, 0xd001d001d515fd5f, 0xfd40fd00d500d001,
+	0xd001d001d515fd5f, 0xff03ff03d501d001, 0xd001d001d515fd5f, 0xfc0ffc0fd405d001,
+	0xd001d001d515fd5f, 0xf03ff03fd015d001, 0xd001d001d515fd5f, 0xc05fc01fc015d001,
+	0x400140015515fd5f, 0x0157000700054001, 0x000100015515fd5f, 0x0555000100010001,
+	0x400040005515fd5f, 0xf400540040004000, 0x400040005515fd5f, 0xfd00550040004000,
+	0x400040005515fd5f, 0xff03550140004000, 0x400040005515fd5f, 0xfc0f540540004000,
+	0x400040005515fd5f, 0xf03f501540004000, 0x400040005515fd5f, 0xc01f401540004000,
+	0x400040005515fd5f, 0x0007000540004000, 0x000000005515fd5f, 0x0001000100000000,
+	0xd000d000d550fff0, 0xd000d000d000d000, 0xd000d000d540ffc0, 0xd000d000d000d000,
+	0xd000d000d501ff03, 0xd000d000d000d000, 0xd000d000d405fc0f, 0xd000d000d000d000,
+	0xd000d000d015f03f, 0xd000d000d000d000, 0xd000d000c055c0ff, 0xd000d000d000d000,
+	0xd000d000015503ff, 0xd000d000d000d000, 0xd000d00005550fff, 0xd000d000d000d000,
+	0xd000d540ff50fff0, 0xd000d000d000d000, 0xd000d540ffc0ffc0, 0xd000d000d000d000,
+	0xd000d500ff01ff03, 0xd000d000d000d000, 0xd000d400fc05fc0f, 0xd000d000d000d000,
+	0xd000d000f015f03f, 0xd000d000d000d000, 0xd000c000c055c0ff, 0xd000d000d000d000,
+	0xd0000000015503ff, 0xd000d000d000d000, 0xd000000005550fff, 0xd000d000d000d000,
+	0x0000000000000000, 0x0000000000000000, 0xd540ffc0ffc0ffff, 0xd000d000d000d000,
+	0xd500ff00ff01ffff, 0xd000d000d000d000, 0xd400fc00fc05ffff, 0xd000d000d000d000,
+	0xd000f000f015ffff, 0xd000d000d000d000, 0xc000c000c055ffff, 0xd000d000d000d000,
+	0x000000000155ffff, 0xd000d000d000d000, 0x000000000555ffff, 0xd000d000d000d000,
+	0xff40ff50ffffffff, 0xf400f400f400f540, 0xffc0ffc0ffffffff, 0xf400f400f400f540,
+	0xff00ff00fff5ffff, 0xf400f400f400f500, 0xfc00fc00ffd5ffff, 0xf400f400f400f400,
+	0xf000f000ff55ffff, 0xf400f400f400f000, 0xc000c000fd55ffff, 0xd000d000d000c000,
+	0x00000000f555ffff, 0xd000d000d0000000, 0x00000000d555ffff, 0xd000d000d0000000,
+	0xff40ff54fff5ffff, 0xfd00fd00fd40ff40, 0xffc0fff4fff5ffff, 0xfd00fd00fd40ffc0,
+	0xff00ffd0ffd5ffff, 0xfd00fd00fd00ff00, 0xfc00ff40ff55ffff, 0xfd00fd00fc00fc00,
+	0xf000fd00fd55ffff, 0xf400f400f000f000, 0xc000f400f555ffff, 0xd000d000c000c000,
+	0x0000d000d555ffff, 0xd000d00000000000, 0x0000d000d555ffff, 0xd000d00000000000,
+	0xff40ff50ffd5ffff, 0xff40ff40ff40ff40, 0xffd0ffd0ffd5ffff, 0xff40ff40ffc0ffc0,
+	0xff40ff50ffd5ffff, 0xff40ff00ff00ff00, 0xfd00fd40ff55ffff, 0xfd00fc00fc00fc00,
+	0xf400f500fd55ffff, 0xf400f000f000f000, 0xd000d400f555ffff, 0xd000c000c000c000,
+	0xd000d000d555ffff, 0xd000000000000000, 0xd000d000d555ffff, 0xd000000000000000,
+	0xff40ff40ff55ffff, 0xff40ff40ff40ff40, 0xff40ff40ff55ffff, 0xffc0ffc0ff40ff40,
+	0xff40ff40ff55ffff, 0xff00ff00ff00ff40, 0xfd00fd00fd55ffff, 0xfc00fc00fc00fd00,
+	0xf400f400f555ffff, 0xf000f000f000f400, 0xd000d000d555ffff, 0xc000c000c000d000,
+	0xd000d000d555ffff, 0x000000000000d000, 0xd000d000d555ffff, 0x000000000000d000,
+	0xfd00fd00fd55ffff, 0xff40fd40fd00fd00, 0xfd00fd00fd55ffff, 0xffc0fd40fd00fd00,
+	0xfd00fd00fd55ffff, 0xff00fd00fd00fd00, 0xfd00fd00fd55ffff, 0xfc00fc00fd00fd00,
+	0xf400f400f555ffff, 0xf000f000f400f400, 0xd000d000d555ffff, 0xc000c000d000d000,
+	0xd000d000d555ffff, 0x00000000d000d000, 0xd000d000d555ffff, 0x00000000d000d000,
+	0xfd00fd40ff50fff0, 0xfd00fd00fd00fd00, 0xfd00fd40ff40ffc0, 0xfd00fd00fd00fd00,
+	0xfd00fd40ff01ff03, 0xfd00fd00fd00fd00, 0xf400f400f405fc0f, 0xf400f400f400f400,
+	0xf400f400f015f03f, 0xf400f400f400f400, 0xd000d000c055c0ff, 0xd000d000d000d000,
+	0x40004000015503ff, 0x4000400040004000, 0x4000400005550fff, 0x4000400040004000,
+	0xff40ffd0fff0fff0, 0xff40ff40ff40ff40, 0xff40ffc0ffc0ffc0, 0xff40ff40ff40ff40,
+	0xfd00fd01ff03ff03, 0xfd00fd00fd00fd00, 0xfd00fc01fc05fc0f, 0xfd00fd00fd00fd00,
+	0xf400f000f015f03f, 0xf400f400f400f400, 0xd000c000c055c0ff, 0xd000d000d000d000,
+	0x40000000015503ff, 0x4000400040004000, 0x4000000005550fff, 0x4000400040004000,
+	0xffc0fff0fff0ffff, 0xffd5ffd0ffd5ffd0, 0x0000000000000000, 0x0000000000000000,
+	0xff00ff03ff03ffff, 0xff55ff41ff55ff41, 0xfc00fc01fc05ffff, 0xfd55fd01fd51fd01,
+	0xf000f000f015ffff, 0xf555f400f540f400, 0xc000c000c055ffff, 0xd500d000d500d000,
+	0x000000000155ffff, 0x5400400054004000, 0x000000000555ffff, 0x5000400050004000,
+	0xffe0fff0ffffffff, 0xfffffff5fff6fff0, 0xffc0ffc0ffffffff, 0xffffffd5ffd9ffc0,
+	0xff02ff03ffffffff, 0xffffff57ff67ff03, 0xfc00fc01ffd5ffff, 0xfffffd55fd91fc01,
+	0xf000f000ff55ffff, 0xff55f540f640f000, 0xc000c000fd55ffff, 0xfd00d500d900c000,
+	0x00000000f555ffff, 0xf400540064000000, 0x00000000d555ffff, 0xd000d000d0000000,
+	0xffc0ffd1ffd5ffff, 0xfffffffffff0fff0, 0xffc0ffd1ffd5ffff, 0xffffffffffc0ffc0,
+	0xff00ffd1ffd5ffff, 0xffffffffff03ff03, 0xfc00ff41ff55ffff, 0xffffffd5fc01fc01,
+	0xf000fd00fd55ffff, 0xff55ff40f000f000, 0xc000f400f555ffff, 0xfd00fd00c000c000,
+	0x0000d000d555ffff, 0xf400f40000000000, 0x000040005555ffff, 0xd000d00000000000,
+	0xff40ff40ff55ffff, 0xfffffff0fff0ffd0, 0xff40ff40ff55ffff, 0xffffffc0ffc0ffc0,
+	0xff40ff40ff55ffff, 0xffffff03ff03ff01, 0xfd00fd40ff55ffff, 0xfffffc05fc01fc01,
+	0xf400f500fd55ffff, 0xff55f000f000f000, 0xd000d400f555ffff, 0xfd00c000c000c000,
+	0x40005000d555ffff, 0xf400000000000000, 0x400040005555ffff, 0xd000000000000000,
+	0xfd00fd00fd55ffff, 0xfff0fff0ff50fd40, 0xfd00fd00fd55ffff, 0xffc0ffc0ff40fd40,
+	0xfd00fd00fd55ffff, 0xff03ff03ff01fd40, 0xfd00fd00fd55ffff, 0xfc05fc01fc01fd00,
+	0xf400f400f555ffff, 0xf015f000f000f400, 0xd000d000d555ffff, 0xc000c000c000d000,
+	0x400040005555ffff, 0x0000000000004000, 0x400040005555ffff, 0x0000000000004000,
+	0xf400f400f555ffff, 0xfff0fd50f500f400, 0xf400f400f555ffff, 0xffc0fd40f500f400,
+	0xf400f400f555ffff, 0xff03fd01f500f400, 0xf400f400f555ffff, 0xfc01fc01f500f400,
+	0xf400f400f555ffff, 0xf000f000f400f400, 0xd000d000d555ffff, 0xc000c000d000d000,
+	0x400040005555ffff, 0x0000000040004000, 0x400040005555ffff, 0x0000000040004000,
+	0xf400f500fd50fff0, 0xf400f400f400f400, 0xf400f501fd40ffc0, 0xf400f400f400f400,
+	0xf400f501fd01ff03, 0xf400f400f400f400, 0xf400f501fc05fc0f, 0xf400f400f400f400,
+	0xd000d001d015f03f, 0xd000d000d000d000, 0xd000d001c055c0ff, 0xd000d000d000d000,
+	0x40004000015503ff, 0x4000400040004000, 0x0000000005550fff, 0x0000000000000000,
+	0xfd00ff40ff50fff0, 0xfd00fd00fd00fd00, 0xfd00ff40ffc0ffc0, 0xfd00fd00fd00fd00,
+	0xfd01ff03ff03ff03, 0xfd01fd01fd01fd01, 0xf401f407fc0ffc0f, 0xf401f401f401f401,
+	0xf401f007f017f03f, 0xf401f401f401f401, 0xd001c001c055c0ff, 0xd001d001d001d001,
+	0x40000000015503ff, 0x4000400040004000, 0x0000000005550fff, 0x0000000000000000,
+	0xff00ff40ff50ffff, 0xff55ff40ff45ff40, 0xff00ffc0ffc0ffff, 0xff55ff41ff55ff41,
+	0x0000000000000000, 0x0000000000000000, 0xfc03fc0ffc0fffff, 0xfd57fd07fd57fd07,
+	0xf003f007f017ffff, 0xf557f407f547f407, 0xc001c001c055ffff, 0xd555d001d501d001,
+	0x000000000155ffff, 0x5400400054004000, 0x000000000555ffff, 0x5000000050000000,
+	0xff00ff40ff57ffff, 0xffffff55ff46ff40, 0xff80ffc0ffffffff, 0xffffffd5ffd9ffc0,
+	0xff03ff03ffffffff, 0xffffff57ff67ff03, 0xfc0bfc0fffffffff, 0xfffffd5ffd9ffc0f,
+	0xf003f007ff57ffff, 0xfffff557f647f007, 0xc001c001fd55ffff, 0xfd55d501d901c001,
+	0x00000000f555ffff, 0xf400540064000000, 0x00000000d555ffff, 0xd000500090000000,
+	0xff00ff41ff55ffff, 0xffffff57ff40ff40, 0xff00ff47ff57ffff, 0xffffffffffc0ffc0,
+	0xff03ff47ff57ffff, 0xffffffffff03ff03, 0xfc03ff47ff57ffff, 0xfffffffffc0ffc0f,
+	0xf003fd07fd57ffff, 0xffffff57f007f007, 0xc001f401f555ffff, 0xfd55fd01c001c001,
+	0x0000d000d555ffff, 0xf400f40000000000, 0x000040005555ffff, 0xd000d00000000000,
+	0xfd00fd01fd55ffff, 0xffffff50ff40ff40, 0xfd01fd01fd55ffff, 0xffffffc0ffc0ff40,
+	0xfd01fd01fd55ffff, 0xffffff03ff03ff03, 0xfd01fd01fd55ffff, 0xfffffc0ffc0ffc07,
+	0xf401f501fd55ffff, 0xfffff017f007f007, 0xd001d401f555ffff, 0xfd55c001c001c001,
+	0x40005000d555ffff, 0xf400000000000000, 0x000040005555ffff, 0xd000000000000000,
+	0xf400f400f555ffff, 0xff50ff40fd40f500, 0xf400f400f555ffff, 0xffc0ffc0fd40f501,
+	0xf400f400f555ffff, 0xff03ff03fd01f501, 0xf400f400f555ffff, 0xfc0ffc0ffc05f501,
+	0xf400f400f555ffff, 0xf017f007f005f401, 0xd000d000d555ffff, 0xc055c001c001d001,
+	0x400040005555ffff, 0x0000000000004000, 0x000000005555ffff, 0x0000000000000000,
+	0xd000d000d555ffff, 0xff40f540d400d000, 0xd000d000d555ffff, 0xffc0f540d400d000,
+	0xd000d000d555ffff, 0xff03f501d400d000, 0xd000d000d555ffff, 0xfc0ff405d400d000,
+	0xd000d000d555ffff, 0xf007f005d400d000, 0xd000d000d555ffff, 0xc001c001d000d000,
+	0x400040005555ffff, 0x0000000040004000, 0x000000005555ffff, 0x0000000000000000,
+	0xd000d400f550fff0, 0xd000d000d000d000, 0xd000d400f540ffc0, 0xd000d000d000d000,
+	0xd001d405f503ff03, 0xd001d001d001d001, 0xd001d405f407fc0f, 0xd001d001d001d001,
+	0xd001d405f017f03f, 0xd001d001d001d001, 0x400140054057c0ff, 0x4001400140014001,
+	0x40014005015703ff, 0x4001400140014001, 0x0001000105550fff, 0x0001000100010001,
+	0xf400f400f550fff0, 0xf400f400f400f400, 0xf401fd00fd40ffc0, 0xf401f401f401f401,
+	0xf401fd01ff03ff03, 0xf401f401f401f401, 0xf407fc0ffc0ffc0f, 0xf407f407f407f407,
+	0xd007d01ff03ff03f, 0xd007d007d007d007, 0xd007c01fc05fc0ff, 0xd007d007d007d007,
+	0x40070007015703ff, 0x4007400740074007, 0x0001000105550fff, 0x0001000100010001,
+	0xf400f400f550ffff, 0xf555f400f405f400, 0xfc00fd00fd40ffff, 0xfd55fd01fd15fd01,
+	0xfc03ff03ff03ffff, 0xfd57fd07fd57fd07, 0x0000000000000000, 0x0000000000000000,
+	0xf00ff03ff03fffff, 0xf55ff41ff55ff41f, 0xc00fc01fc05fffff, 0xd55fd01fd51fd01f,
+	0x000700070157ffff, 0x5557400754074007, 0x000100010555ffff, 0x5001000150010001,
+	0xf400f400f557ffff, 0xf557f405f406f400, 0xfc00fd00fd5fffff, 0xfffffd55fd19fd00,
+	0xfe03ff03ffffffff, 0xffffff57ff67ff03, 0xfc0ffc0fffffffff, 0xfffffd5ffd9ffc0f,
+	0xf02ff03fffffffff, 0xfffff57ff67ff03f, 0xc00fc01ffd5fffff, 0xffffd55fd91fc01f,
+	0x00070007f557ffff, 0xf557540764070007, 0x00010001d555ffff, 0xd001500190010001,
+	0xf400f401f555ffff, 0xf557f407f400f400, 0xfc00fd07fd57ffff, 0xfffffd5ffd00fd00,
+	0xfc03fd1ffd5fffff, 0xffffffffff03ff03, 0xfc0ffd1ffd5fffff, 0xfffffffffc0ffc0f,
+	0xf00ffd1ffd5fffff, 0xfffffffff03ff03f, 0xc00ff41ff55fffff, 0xfffffd5fc01fc01f,
+	0x0007d007d557ffff, 0xf557f40700070007, 0x000140015555ffff, 0xd001d00100010001,
+	0xf400f401f555ffff, 0xf557f400f400f400, 0xf401f405f557ffff, 0xfffffd40fd00fd00,
+	0xf407f407f557ffff, 0xffffff03ff03fd03, 0xf407f407f557ffff, 0xfffffc0ffc0ffc0f,
+	0xf407f407f557ffff, 0xfffff03ff03ff01f, 0xd007d407f557ffff, 0xffffc05fc01fc01f,
+	0x40075007d557ffff, 0xf557000700070007, 0x000140015555ffff, 0xd001000100010001,
+	0xd000d000d555ffff, 0xf550f400f400d400, 0xd001d001d555ffff, 0xfd40fd00f500d401,
+	0xd001d001d555ffff, 0xff03ff03f503d405, 0xd001d001d555ffff, 0xfc0ffc0ff407d405,
+	0xd001d001d555ffff, 0xf03ff03ff017d405, 0xd001d001d555ffff, 0xc05fc01fc017d005,
+	0x400140015555ffff, 0x0157000700074005, 0x000100015555ffff, 0x0001000100010001,
+	0x400040005555ffff, 0xf400d40050004000, 0x400040005555ffff, 0xfd00d50050014000,
+	0x400040005555ffff, 0xff03d50150014000, 0x400040005555ffff, 0xfc0fd40550014000,
+	0x400040005555ffff, 0xf03fd01550014000, 0x400040005555ffff, 0xc01fc01550014000,
+	0x400040005555ffff, 0x0007000540014000, 0x000000005555ffff, 0x0001000100010000,
+	0xf400f555fff0fff0, 0xf400f400f400f400, 0xf400f555ffc0ffc0, 0xf400f400f400f400,
+	0xf400f555ff03ff03, 0xf400f400f400f400, 0xf400f555fc0ffc0f, 0xf400f400f400f400,
+	0xf400f555f03ff03f, 0xf400f400f400f400, 0xf400f555c0ffc0ff, 0xf400f400f400f400,
+	0xf400f55503ff03ff, 0xf400f400f400f400, 0xf400f5550fff0fff, 0xf400f400f400f400,
+	0xf400f550fff0fff0, 0xf400f400f400f400, 0xf400f540ffc0ffc0, 0xf400f400f400f400,
+	0xf400f501ff03ff03, 0xf400f400f400f400, 0xf400f405fc0ffc0f, 0xf400f400f400f400,
+	0xf400f015f03ff03f, 0xf400f400f400f400, 0xf400c055c0ffc0ff, 0xf400f400f400f400,
+	0xf400015503ff03ff, 0xf400f400f400f400, 0xf40005550fff0fff, 0xf400f400f400f400,
+	0xf540ff50fff0ffff, 0xf400f400f400f400, 0xf540ffc0ffc0ffff, 0xf400f400f400f400,
+	0xf500ff01ff03ffff, 0xf400f400f400f400, 0xf400fc05fc0fffff, 0xf400f400f400f400,
+	0xf000f015f03fffff, 0xf400f400f400f400, 0xc000c055c0ffffff, 0xf400f400f400f400,
+	0x0000015503ffffff, 0xf400f400f400f400, 0x040005550fffffff, 0xf400f400f400f400,
+	0x0000000000000000, 0x0000000000000000, 0xffc0ffc0ffffffff, 0xf400f400f400f540,
+	0xff00ff01ffffffff, 0xf400f400f400f500, 0xfc00fc05ffffffff, 0xf400f400f400f400,
+	0xf000f015ffffffff, 0xf400f400f400f000, 0xc000c055ffffffff, 0xf400f400f400c000,
+	0x00000155ffffffff, 0xf400f400f4000000, 0x04000555ffffffff, 0xf400f400f4000400,
+	0xff50ffffffffffff, 0xfd00fd00fd40ff40, 0xffc0ffffffffffff, 0xfd00fd00fd40ffc0,
+	0xff00fff5ffffffff, 0xfd00fd00fd00ff00, 0xfc00ffd5ffffffff, 0xfd00fd00fc00fc00,
+	0xf000ff55ffffffff, 0xf400f400f000f000, 0xc000fd55ffffffff, 0xf400f400c000c000,
+	0x0000f555ffffffff, 0xf400f40000000000, 0x0400f555ffffffff, 0xf400f40004000400,
+	0xff54fff5ffffffff, 0xff40ff40ff40ff40, 0xfff4fff5ffffffff, 0xff40ff40ffc0ffc0,
+	0xffd0ffd5ffffffff, 0xff40ff00ff00ff00, 0xff40ff55ffffffff, 0xfd00fc00fc00fc00,
+	0xfd00fd55ffffffff, 0xf400f000f000f000, 0xf400f555ffffffff, 0xf400c000c000c000,
+	0xf400f555ffffffff, 0xf400000000000000, 0xf400f555ffffffff, 0xf400040004000400,
+	0xff50ffd5ffffffff, 0xff40ff40ff40ff40, 0xffd0ffd5ffffffff, 0xffc0ffc0ffc0ffd0,
+	0xff50ffd5ffffffff, 0xff00ff00ff00ff40, 0xfd40ff55ffffffff, 0xfc00fc00fc00fd00,
+	0xf500fd55ffffffff, 0xf000f000f000f400, 0xf400f555ffffffff, 0xc000c000c000f400,
+	0xf400f555ffffffff, 0x000000000000f400, 0xf400f555ffffffff, 0x040004000400f400,
+	0xff40ff55ffffffff, 0xff40ff40ff40ff40, 0xff40ff55ffffffff, 0xffc0ff40ff40ff40,
+	0xff40ff55ffffffff, 0xff00ff00ff40ff40, 0xfd00fd55ffffffff, 0xfc00fc00fd00fd00,
+	0xf400f555ffffffff, 0xf000f000f400f400, 0xf400f555ffffffff, 0xc000c000f400f400,
+	0xf400f555ffffffff, 0x00000000f400f400, 0xf400f555ffffffff, 0x04000400f400f400,
+	0xf400f555fff0fff0, 0xf400f400f400f400, 0xf400f555ffc0ffc0, 0xf400f400f400f400,
+	0xf400f555ff03ff03, 0xf400f400f400f400, 0xf400f555fc0ffc0f, 0xf400f400f400f400,
+	0xd000d555f03ff03f, 0xd000d000d000d000, 0xd000d555c0ffc0ff, 0xd000d000d000d000,
+	0xd000d55503ff03ff, 0xd000d000d000d000, 0xd000d5550fff0fff, 0xd000d000d000d000,
+	0xfd40ff50fff0fff0, 0xfd00fd00fd00fd00, 0xfd40ff40ffc0ffc0, 0xfd00fd00fd00fd00,
+	0xfd40ff01ff03ff03, 0xfd00fd00fd00fd00, 0xf400f405fc0ffc0f, 0xf400f400f400f400,
+	0xf400f015f03ff03f, 0xf400f400f400f400, 0xd000c055c0ffc0ff, 0xd000d000d000d000,
+	0xd000015503ff03ff, 0xd000d000d000d000, 0xd00005550fff0fff, 0xd000d000d000d000,
+	0xffd0fff0fff0ffff, 0xff40ff40ff40ff40, 0xffc0ffc0ffc0ffff, 0xff40ff40ff40ff40,
+	0xfd01ff03ff03ffff, 0xfd00fd00fd00fd00, 0xfc01fc05fc0fffff, 0xfd00fd00fd00fd00,
+	0xf000f015f03fffff, 0xf400f400f400f400, 0xc000c055c0ffffff, 0xd000d000d000d000,
+	0x0000015503ffffff, 0xd000d000d000d000, 0x000005550fffffff, 0xd000d000d000d000,
+	0xfff0fff0ffffffff, 0xffd0ffd5ffd0ffc0, 0x0000000000000000, 0x0000000000000000,
+	0xff03ff03ffffffff, 0xff41ff55ff41ff00, 0xfc01fc05ffffffff, 0xfd01fd51fd01fc00,
+	0xf000f015ffffffff, 0xf400f540f400f000, 0xc000c055ffffffff, 0xd000d500d000c000,
+	0x00000155ffffffff, 0xd000d400d0000000, 0x00000555ffffffff, 0xd000d000d0000000,
+	0xfff0ffffffffffff, 0xfff5fff6fff0ffe0, 0xffc0ffffffffffff, 0xffd5ffd9ffc0ffc0,
+	0xff03ffffffffffff, 0xff57ff67ff03ff02, 0xfc01ffd5ffffffff, 0xfd55fd91fc01fc00,
+	0xf000ff55ffffffff, 0xf540f640f000f000, 0xc000fd55ffffffff, 0xd500d900c000c000,
+	0x0000f555ffffffff, 0xf400f40000000000, 0x0000d555ffffffff, 0xd000d00000000000,
+	0xffd1ffd5ffffffff, 0xfffffff0fff0ffc0, 0xffd1ffd5ffffffff, 0xffffffc0ffc0ffc0,
+	0xffd1ffd5ffffffff, 0xffffff03ff03ff00, 0xff41ff55ffffffff, 0xffd5fc01fc01fc00,
+	0xfd00fd55ffffffff, 0xff40f000f000f000, 0xf400f555ffffffff, 0xfd00c000c000c000,
+	0xd000d555ffffffff, 0xf400000000000000, 0xd000d555ffffffff, 0xd000000000000000,
+	0xff40ff55ffffffff, 0xfff0fff0ffd0ff40, 0xff40ff55ffffffff, 0xffc0ffc0ffc0ff40,
+	0xff40ff55ffffffff, 0xff03ff03ff01ff40, 0xfd40ff55ffffffff, 0xfc05fc01fc01fd00,
+	0xf500fd55ffffffff, 0xf000f000f000f400, 0xd400f555ffffffff, 0xc000c000c000d000,
+	0xd000d555ffffffff, 0x000000000000d000, 0xd000d555ffffffff, 0x000000000000d000,
+	0xfd00fd55ffffffff, 0xfff0ff50fd40fd00, 0xfd00fd55ffffffff, 0xffc0ff40fd40fd00,
+	0xfd00fd55ffffffff, 0xff03ff01fd40fd00, 0xfd00fd55ffffffff, 0xfc01fc01fd00fd00,
+	0xf400f555ffffffff, 0xf000f000f400f400, 0xd000d555ffffffff, 0xc000c000d000d000,
+	0xd000d555ffffffff, 0x00000000d000d000, 0xd000d555ffffffff, 0x00000000d000d000,
+	0xd000d555fff0fff0, 0xd000d000d000d000, 0xd000d555ffc0ffc0, 0xd000d000d000d000,
+	0xd000d555ff03ff03, 0xd000d000d000d000, 0xd000d555fc0ffc0f, 0xd000d000d000d000,
+	0xd000d555f03ff03f, 0xd000d000d000d000, 0x40005555c0ffc0ff, 0x4000400040004000,
+	0x4000555503ff03ff, 0x4000400040004000, 0x400055550fff0fff, 0x4000400040004000,
+	0xf500fd50fff0fff0, 0xf400f400f400f400, 0xf501fd40ffc0ffc0, 0xf400f400f400f400,
+	0xf501fd01ff03ff03, 0xf400f400f400f400, 0xf501fc05fc0ffc0f, 0xf400f400f400f400,
+	0xd001d015f03ff03f, 0xd000d000d000d000, 0xd001c055c0ffc0ff, 0xd000d000d000d000,
+	0x4000015503ff03ff, 0x4000400040004000, 0x400005550fff0fff, 0x4000400040004000,
+	0xff40ff50fff0ffff, 0xfd00fd00fd00fd00, 0xff40ffc0ffc0ffff, 0xfd00fd00fd00fd00,
+	0xff03ff03ff03ffff, 0xfd01fd01fd01fd01, 0xf407fc0ffc0fffff, 0xf401f401f401f401,
+	0xf007f017f03fffff, 0xf401f401f401f401, 0xc001c055c0ffffff, 0xd001d001d001d001,
+	0x0000015503ffffff, 0x4000400040004000, 0x000005550fffffff, 0x4000400040004000,
+	0xff40ff50ffffffff, 0xff40ff45ff40ff00, 0xffc0ffc0ffffffff, 0xff41ff55ff41ff00,
+	0x0000000000000000, 0x0000000000000000, 0xfc0ffc0fffffffff, 0xfd07fd57fd07fc03,
+	0xf007f017ffffffff, 0xf407f547f407f003, 0xc001c055ffffffff, 0xd001d501d001c001,
+	0x00000155ffffffff, 0x4000540040000000, 0x00000555ffffffff, 0x4000500040000000,
+	0xff40ff57ffffffff, 0xff55ff46ff40ff00, 0xffc0ffffffffffff, 0xffd5ffd9ffc0ff80,
+	0xff03ffffffffffff, 0xff57ff67ff03ff03, 0xfc0fffffffffffff, 0xfd5ffd9ffc0ffc0b,
+	0xf007ff57ffffffff, 0xf557f647f007f003, 0xc001fd55ffffffff, 0xd501d901c001c001,
+	0x0000f555ffffffff, 0x5400640000000000, 0x0000d555ffffffff, 0xd000d00000000000,
+	0xff41ff55ffffffff, 0xff57ff40ff40ff00, 0xff47ff57ffffffff, 0xffffffc0ffc0ff00,
+	0xff47ff57ffffffff, 0xffffff03ff03ff03, 0xff47ff57ffffffff, 0xfffffc0ffc0ffc03,
+	0xfd07fd57ffffffff, 0xff57f007f007f003, 0xf401f555ffffffff, 0xfd01c001c001c001,
+	0xd000d555ffffffff, 0xf400000000000000, 0x40005555ffffffff, 0xd000000000000000,
+	0xfd01fd55ffffffff, 0xff50ff40ff40fd00, 0xfd01fd55ffffffff, 0xffc0ffc0ff40fd01,
+	0xfd01fd55ffffffff, 0xff03ff03ff03fd01, 0xfd01fd55ffffffff, 0xfc0ffc0ffc07fd01,
+	0xf501fd55ffffffff, 0xf017f007f007f401, 0xd401f555ffffffff, 0xc001c001c001d001,
+	0x5000d555ffffffff, 0x0000000000004000, 0x40005555ffffffff, 0x0000000000004000,
+	0xf400f555ffffffff, 0xff40fd40f500f400, 0xf400f555ffffffff, 0xffc0fd40f501f400,
+	0xf400f555ffffffff, 0xff03fd01f501f400, 0xf400f555ffffffff, 0xfc0ffc05f501f400,
+	0xf400f555ffffffff, 0xf007f005f401f400, 0xd000d555ffffffff, 0xc001c001d001d000,
+	0x40005555ffffffff, 0x0000000040004000, 0x40005555ffffffff, 0x0000000040004000,
+	0x40005555fff0fff0, 0x4000400040004000, 0x40005555ffc0ffc0, 0x4000400040004000,
+	0x40005555ff03ff03, 0x4000400040004000, 0x40005555fc0ffc0f, 0x4000400040004000,
+	0x40005555f03ff03f, 0x4000400040004000, 0x40005555c0ffc0ff, 0x4000400040004000,
+	0x0000555503ff03ff, 0x0000000000000000, 0x000055550fff0fff, 0x0000000000000000,
+	0xd400f550fff0fff0, 0xd000d000d000d000, 0xd400f540ffc0ffc0, 0xd000d000d000d000,
+	0xd405f503ff03ff03, 0xd001d001d001d001, 0xd405f407fc0ffc0f, 0xd001d001d001d001,
+	0xd405f017f03ff03f, 0xd001d001d001d001, 0x40054057c0ffc0ff, 0x4001400140014001,
+	0x4005015703ff03ff, 0x4001400140014001, 0x000105550fff0fff, 0x0001000100010001,
+	0xf400f550fff0ffff, 0xf400f400f400f400, 0xfd00fd40ffc0ffff, 0xf401f401f401f401,
+	0xfd01ff03ff03ffff, 0xf401f401f401f401, 0xfc0ffc0ffc0fffff, 0xf407f407f407f407,
+	0xd01ff03ff03fffff, 0xd007d007d007d007, 0xc01fc05fc0ffffff, 0xd007d007d007d007,
+	0x0007015703ffffff, 0x4007400740074007, 0x000105550fffffff, 0x0001000100010001,
+	0xf400f550ffffffff, 0xf400f405f400f400, 0xfd00fd40ffffffff, 0xfd01fd15fd01fc00,
+	0xff03ff03ffffffff, 0xfd07fd57fd07fc03, 0x0000000000000000, 0x0000000000000000,
+	0xf03ff03fffffffff, 0xf41ff55ff41ff00f, 0xc01fc05fffffffff, 0xd01fd51fd01fc00f,
+	0x00070157ffffffff, 0x4007540740070007, 0x00010555ffffffff, 0x0001500100010001,
+	0xf400f557ffffffff, 0xf405f406f400f400, 0xfd00fd5fffffffff, 0xfd55fd19fd00fc00,
+	0xff03ffffffffffff, 0xff57ff67ff03fe03, 0xfc0fffffffffffff, 0xfd5ffd9ffc0ffc0f,
+	0xf03fffffffffffff, 0xf57ff67ff03ff02f, 0xc01ffd5fffffffff, 0xd55fd91fc01fc00f,
+	0x0007f557ffffffff, 0x5407640700070007, 0x0001d555ffffffff, 0x5001900100010001,
+	0xf401f555ffffffff, 0xf407f400f400f400, 0xfd07fd57ffffffff, 0xfd5ffd00fd00fc00,
+	0xfd1ffd5fffffffff, 0xffffff03ff03fc03, 0xfd1ffd5fffffffff, 0xfffffc0ffc0ffc0f,
+	0xfd1ffd5fffffffff, 0xfffff03ff03ff00f, 0xf41ff55fffffffff, 0xfd5fc01fc01fc00f,
+	0xd007d557ffffffff, 0xf407000700070007, 0x40015555ffffffff, 0xd001000100010001,
+	0xf401f555ffffffff, 0xf400f400f400f400, 0xf405f557ffffffff, 0xfd40fd00fd00f401,
+	0xf407f557ffffffff, 0xff03ff03fd03f407, 0xf407f557ffffffff, 0xfc0ffc0ffc0ff407,
+	0xf407f557ffffffff, 0xf03ff03ff01ff407, 0xd407f557ffffffff, 0xc05fc01fc01fd007,
+	0x5007d557ffffffff, 0x0007000700074007, 0x40015555ffffffff, 0x0001000100010001,
+	0xd000d555ffffffff, 0xf400f400d400d000, 0xd001d555ffffffff, 0xfd00f500d401d001,
+	0xd001d555ffffffff, 0xff03f503d405d001, 0xd001d555ffffffff, 0xfc0ff407d405d001,
+	0xd001d555ffffffff, 0xf03ff017d405d001, 0xd001d555ffffffff, 0xc01fc017d005d001,
+	0x40015555ffffffff, 0x0007000740054001, 0x00015555ffffffff, 0x0001000100010001,
+	0xfd55fffffff0fff0, 0xfd00fd00fd00fd00, 0xfd55ffffffc0ffc0, 0xfd00fd00fd00fd00,
+	0xfd55ffffff03ff03, 0xfd00fd00fd00fd00, 0xfd55fffffc0ffc0f, 0xfd00fd00fd00fd00,
+	0xfd55fffff03ff03f, 0xfd00fd00fd00fd00, 0xfd55ffffc0ffc0ff, 0xfd00fd00fd00fd00,
+	0xfd55ffff03ff03ff, 0xfd00fd00fd00fd00, 0xfd55ffff0fff0fff, 0xfd00fd00fd00fd00,
+	0xfd55fff0fff0fff0, 0xfd00fd00fd00fd00, 0xfd55ffc0ffc0ffc0, 0xfd00fd00fd00fd00,
+	0xfd55ff03ff03ff03, 0xfd00fd00fd00fd00, 0xfd55fc0ffc0ffc0f, 0xfd00fd00fd00fd00,
+	0xfd55f03ff03ff03f, 0xfd00fd00fd00fd00, 0xfd55c0ffc0ffc0ff, 0xfd00fd00fd00fd00,
+	0xfd5503ff03ff03ff, 0xfd00fd00fd00fd00, 0xfd550fff0fff0fff, 0xfd00fd00fd00fd00,
+	0xfd50fff0fff0ffff, 0xfd00fd00fd00fd00, 0xfd40ffc0ffc0ffff, 0xfd00fd00fd00fd00,
+	0xfd01ff03ff03ffff, 0xfd00fd00fd00fd00, 0xfc05fc0ffc0fffff, 0xfd00fd00fd00fd00,
+	0xf015f03ff03fffff, 0xfd00fd00fd00fd00, 0xc055c0ffc0ffffff, 0xfd00fd00fd00fd00,
+	0x015503ff03ffffff, 0xfd00fd00fd00fd00, 0x0d550fff0fffffff, 0xfd00fd00fd00fd00,
+	0xff50fff0ffffffff, 0xfd00fd00fd00fd40, 0xffc0ffc0ffffffff, 0xfd00fd00fd00fd40,
+	0xff01ff03ffffffff, 0xfd00fd00fd00fd00, 0xfc05fc0fffffffff, 0xfd00fd00fd00fc00,
+	0xf015f03fffffffff, 0xfd00fd00fd00f000, 0xc055c0ffffffffff, 0xfd00fd00fd00c000,
+	0x015503ffffffffff, 0xfd00fd00fd000100, 0x0d550fffffffffff, 0xfd00fd00fd000d00,
+	0x0000000000000000, 0x0000000000000000, 0xffc0ffffffffffff, 0xfd00fd00fd40ffc0,
+	0xff01ffffffffffff, 0xfd00fd00fd00ff00, 0xfc05ffffffffffff, 0xfd00fd00fc00fc00,
+	0xf015ffffffffffff, 0xfd00fd00f000f000, 0xc055ffffffffffff, 0xfd00fd00c000c000,
+	0x0155ffffffffffff, 0xfd00fd0001000100, 0x0d55ffffffffffff, 0xfd00fd000d000d00,
+	0xffffffffffffffff, 0xff40ff40ff40ff50, 0xffffffffffffffff, 0xff40ff40ffc0ffc0,
+	0xfff5ffffffffffff, 0xff40ff00ff00ff00, 0xffd5ffffffffffff, 0xfd00fc00fc00fc00,
+	0xff55ffffffffffff, 0xfd00f000f000f000, 0xfd55ffffffffffff, 0xfd00c000c000c000,
+	0xfd55ffffffffffff, 0xfd00010001000100, 0xfd55ffffffffffff, 0xfd000d000d000d00,
+	0xfff5ffffffffffff, 0xff40ff40ff40ff54, 0xfff5ffffffffffff, 0xffc0ffc0ffc0fff4,
+	0xffd5ffffffffffff, 0xff00ff00ff00ffd0, 0xff55ffffffffffff, 0xfc00fc00fc00ff40,
+	0xfd55ffffffffffff, 0xf000f000f000fd00, 0xfd55ffffffffffff, 0xc000c000c000fd00,
+	0xfd55ffffffffffff, 0x010001000100fd00, 0xfd55ffffffffffff, 0x0d000d000d00fd00,
+	0xffd5ffffffffffff, 0xff40ff40ff40ff50, 0xffd5ffffffffffff, 0xffc0ffc0ffd0ffd0,
+	0xffd5ffffffffffff, 0xff00ff00ff40ff50, 0xff55ffffffffffff, 0xfc00fc00fd00fd40,
+	0xfd55ffffffffffff, 0xf000f000fd00fd00, 0xfd55ffffffffffff, 0xc000c000fd00fd00,
+	0xfd55ffffffffffff, 0x01000100fd00fd00, 0xfd55ffffffffffff, 0x0d000d00fd00fd00,
+	0xf555fffffff0fff0, 0xf400f400f400f400, 0xf555ffffffc0ffc0, 0xf400f400f400f400,
+	0xf555ffffff03ff03, 0xf400f400f400f400, 0xf555fffffc0ffc0f, 0xf400f400f400f400,
+	0xf555fffff03ff03f, 0xf400f400f400f400, 0xf555ffffc0ffc0ff, 0xf400f400f400f400,
+	0xf555ffff03ff03ff, 0xf400f400f400f400, 0xf555ffff0fff0fff, 0xf400f400f400f400,
+	0xf555fff0fff0fff0, 0xf400f400f400f400, 0xf555ffc0ffc0ffc0, 0xf400f400f400f400,
+	0xf555ff03ff03ff03, 0xf400f400f400f400, 0xf555fc0ffc0ffc0f, 0xf400f400f400f400,
+	0xf555f03ff03ff03f, 0xf400f400f400f400, 0xf555c0ffc0ffc0ff, 0xf400f400f400f400,
+	0xf55503ff03ff03ff, 0xf400f400f400f400, 0xf5550fff0fff0fff, 0xf400f400f400f400,
+	0xff50fff0fff0ffff, 0xfd00fd00fd00fd40, 0xff40ffc0ffc0ffff, 0xfd00fd00fd00fd40,
+	0xff01ff03ff03ffff, 0xfd00fd00fd00fd40, 0xf405fc0ffc0fffff, 0xf400f400f400f400,
+	0xf015f03ff03fffff, 0xf400f400f400f400, 0xc055c0ffc0ffffff, 0xf400f400f400f400,
+	0x015503ff03ffffff, 0xf400f400f400f400, 0x05550fff0fffffff, 0xf400f400f400f400,
+	0xfff0fff0ffffffff, 0xff40ff40ff40ffd0, 0xffc0ffc0ffffffff, 0xff40ff40ff40ffc0,
+	0xff03ff03ffffffff, 0xfd00fd00fd00fd01, 0xfc05fc0fffffffff, 0xfd00fd00fd00fc01,
+	0xf015f03fffffffff, 0xfd00f500f400f000, 0xc055c0ffffffffff, 0xf400f400f400c000,
+	0x015503ffffffffff, 0xf400f400f4000000, 0x05550fffffffffff, 0xf400f400f4000400,
+	0xfff0ffffffffffff, 0xffd5ffd0ffc0fff0, 0x0000000000000000, 0x0000000000000000,
+	0xff03ffffffffffff, 0xff55ff41ff00ff03, 0xfc05ffffffffffff, 0xff55ff41fc00fc01,
+	0xf015ffffffffffff, 0xfd40fd00f000f000, 0xc055ffffffffffff, 0xf500f400c000c000,
+	0x0155ffffffffffff, 0xf400f40000000000, 0x0555ffffffffffff, 0xf400f40004000400,
+	0xffffffffffffffff, 0xfffffff0ffe0fff0, 0xffffffffffffffff, 0xffffffc0ffc0ffc0,
+	0xffffffffffffffff, 0xffffff03ff02ff03, 0xffd5ffffffffffff, 0xffd5fc01fc00fc01,
+	0xff55ffffffffffff, 0xff40f000f000f000, 0xfd55ffffffffffff, 0xfd00c000c000c000,
+	0xf555ffffffffffff, 0xf400000000000000, 0xf555ffffffffffff, 0xf400040004000400,
+	0xffd5ffffffffffff, 0xfff0fff0ffc0ffd1, 0xffd5ffffffffffff, 0xffc0ffc0ffc0ffd1,
+	0xffd5ffffffffffff, 0xff03ff03ff00ffd1, 0xff55ffffffffffff, 0xfc05fc01fc00ff41,
+	0xfd55ffffffffffff, 0xf000f000f000fd00, 0xf555ffffffffffff, 0xc000c000c000f400,
+	0xf555ffffffffffff, 0x000000000000f400, 0xf555ffffffffffff, 0x040004000400f400,
+	0xff55ffffffffffff, 0xfff0ffd0ff40ff40, 0xff55ffffffffffff, 0xffc0ffc0ff40ff40,
+	0xff55ffffffffffff, 0xff03ff01ff40ff40, 0xff55ffffffffffff, 0xfc01fc01fd00fd40,
+	0xfd55ffffffffffff, 0xf000f000f400f500, 0xf555ffffffffffff, 0xc000c000f400f400,
+	0xf555ffffffffffff, 0x00000000f400f400, 0xf555ffffffffffff, 0x04000400f400f400,
+	0xd555fffffff0fff0, 0xd000d000d000d000, 0xd555ffffffc0ffc0, 0xd000d000d000d000,
+	0xd555ffffff03ff03, 0xd000d000d000d000, 0xd555fffffc0ffc0f, 0xd000d000d000d000,
+	0xd555fffff03ff03f, 0xd000d000d000d000, 0xd555ffffc0ffc0ff, 0xd000d000d000d000,
+	0xd555ffff03ff03ff, 0xd000d000d000d000, 0xd555ffff0fff0fff, 0xd000d000d000d000,
+	0xd555fff0fff0fff0, 0xd000d000d000d000, 0xd555ffc0ffc0ffc0, 0xd000d000d000d000,
+	0xd555ff03ff03ff03, 0xd000d000d000d000, 0xd555fc0ffc0ffc0f, 0xd000d000d000d000,
+	0xd555f03ff03ff03f, 0xd000d000d000d000, 0xd555c0ffc0ffc0ff, 0xd000d000d000d000,
+	0xd55503ff03ff03ff, 0xd000d000d000d000, 0xd5550fff0fff0fff, 0xd000d000d000d000,
+	0xfd50fff0fff0ffff, 0xf400f400f400f500, 0xfd40ffc0ffc0ffff, 0xf400f400f400f501,
+	0xfd01ff03ff03ffff, 0xf400f400f400f501, 0xfc05fc0ffc0fffff, 0xf400f400f400f501,
+	0xd015f03ff03fffff, 0xd000d000d000d001, 0xc055c0ffc0ffffff, 0xd000d000d000d001,
+	0x015503ff03ffffff, 0xd000d000d000d000, 0x05550fff0fffffff, 0xd000d000d000d000,
+	0xff50fff0ffffffff, 0xfd00fd00fd00ff40, 0xffc0ffc0ffffffff, 0xfd00fd00fd00ff40,
+	0xff03ff03ffffffff, 0xfd01fd01fd01ff03, 0xfc0ffc0fffffffff, 0xf401f401f401f407,
+	0xf017f03fffffffff, 0xf401f401f401f007, 0xc055c0ffffffffff, 0xf401d401d001c001,
+	0x015503ffffffffff, 0xd000d000d0000000, 0x05550fffffffffff, 0xd000d000d0000000,
+	0xff50ffffffffffff, 0xff55ff41ff00ff40, 0xffc0ffffffffffff, 0xff55ff41ff00ffc0,
+	0x0000000000000000, 0x0000000000000000, 0xfc0fffffffffffff, 0xfd57fd07fc03fc0f,
+	0xf017ffffffffffff, 0xfd57fd07f003f007, 0xc055ffffffffffff, 0xf501f401c001c001,
+	0x0155ffffffffffff, 0xd400d00000000000, 0x0555ffffffffffff, 0xd000d00000000000,
+	0xff57ffffffffffff, 0xff57ff40ff00ff40, 0xffffffffffffffff, 0xffffffc0ff80ffc0,
+	0xffffffffffffffff, 0xffffff03ff03ff03, 0xffffffffffffffff, 0xfffffc0ffc0bfc0f,
+	0xff57ffffffffffff, 0xff57f007f003f007, 0xfd55ffffffffffff, 0xfd01c001c001c001,
+	0xf555ffffffffffff, 0xf400000000000000, 0xd555ffffffffffff, 0xd000000000000000,
+	0xff55ffffffffffff, 0xff50ff40ff00ff41, 0xff57ffffffffffff, 0xffc0ffc0ff00ff47,
+	0xff57ffffffffffff, 0xff03ff03ff03ff47, 0xff57ffffffffffff, 0xfc0ffc0ffc03ff47,
+	0xfd57ffffffffffff, 0xf017f007f003fd07, 0xf555ffffffffffff, 0xc001c001c001f401,
+	0xd555ffffffffffff, 0x000000000000d000, 0xd555ffffffffffff, 0x000000000000d000,
+	0xfd55ffffffffffff, 0xff40ff40fd00fd01, 0xfd55ffffffffffff, 0xffc0ff40fd01fd01,
+	0xfd55ffffffffffff, 0xff03ff03fd01fd01, 0xfd55ffffffffffff, 0xfc0ffc07fd01fd01,
+	0xfd55ffffffffffff, 0xf007f007f401f501, 0xf555ffffffffffff, 0xc001c001d001d401,
+	0xd555ffffffffffff, 0x00000000d000d000, 0xd555ffffffffffff, 0x00000000d000d000,
+	0x5555fffffff0fff0, 0x4000400040004000, 0x5555ffffffc0ffc0, 0x4000400040004000,
+	0x5555ffffff03ff03, 0x4000400040004000, 0x5555fffffc0ffc0f, 0x4000400040004000,
+	0x5555fffff03ff03f, 0x4000400040004000, 0x5555ffffc0ffc0ff, 0x4000400040004000,
+	0x5555ffff03ff03ff, 0x4000400040004000, 0x5555ffff0fff0fff, 0x4000400040004000,
+	0x5555fff0fff0fff0, 0x4000400040004000, 0x5555ffc0ffc0ffc0, 0x4000400040004000,
+	0x5555ff03ff03ff03, 0x4000400040004000, 0x5555fc0ffc0ffc0f, 0x4000400040004000,
+	0x5555f03ff03ff03f, 0x4000400040004000, 0x5555c0ffc0ffc0ff, 0x4000400040004000,
+	0x555503ff03ff03ff, 0x4000400040004000, 0x55550fff0fff0fff, 0x4000400040004000,
+	0xf550fff0fff0ffff, 0xd000d000d000d400, 0xf540ffc0ffc0ffff, 0xd000d000d000d400,
+	0xf503ff03ff03ffff, 0xd001d001d001d405, 0xf407fc0ffc0fffff, 0xd001d001d001d405,
+	0xf017f03ff03fffff, 0xd001d001d001d405, 0x4057c0ffc0ffffff, 0x4001400140014005,
+	0x015703ff03ffffff, 0x4001400140014005, 0x05550fff0fffffff, 0x4001400140014001,
+	0xf550fff0ffffffff, 0xf401f401f400f400, 0xfd40ffc0ffffffff, 0xf401f401f401fd00,
+	0xff03ff03ffffffff, 0xf401f401f401fd01, 0xfc0ffc0fffffffff, 0xf407f407f407fc0f,
+	0xf03ff03fffffffff, 0xd007d007d007d01f, 0xc05fc0ffffffffff, 0xd007d007d007c01f,
+	0x015703ffffffffff, 0xd007500740070007, 0x05550fffffffffff, 0x4001400140010001,
+	0xf550ffffffffffff, 0xf405f401f400f400, 0xfd40ffffffffffff, 0xfd57fd07fc00fd00,
+	0xff03ffffffffffff, 0xfd57fd07fc03ff03, 0x0000000000000000, 0x0000000000000000,
+	0xf03fffffffffffff, 0xf55ff41ff00ff03f, 0xc05fffffffffffff, 0xf55ff41fc00fc01f,
+	0x0157ffffffffffff, 0xd407d00700070007, 0x0555ffffffffffff, 0x5001400100010001,
+	0xf557ffffffffffff, 0xf407f400f400f400, 0xfd5fffffffffffff, 0xfd5ffd00fc00fd00,
+	0xffffffffffffffff, 0xffffff03fe03ff03, 0xffffffffffffffff, 0xfffffc0ffc0ffc0f,
+	0xffffffffffffffff, 0xfffff03ff02ff03f, 0xfd5fffffffffffff, 0xfd5fc01fc00fc01f,
+	0xf557ffffffffffff, 0xf407000700070007, 0xd555ffffffffffff, 0xd001000100010001,
+	0xf555ffffffffffff, 0xf400f400f400f401, 0xfd57ffffffffffff, 0xfd40fd00fc00fd07,
+	0xfd5fffffffffffff, 0xff03ff03fc03fd1f, 0xfd5fffffffffffff, 0xfc0ffc0ffc0ffd1f,
+	0xfd5fffffffffffff, 0xf03ff03ff00ffd1f, 0xf55fffffffffffff, 0xc05fc01fc00ff41f,
+	0xd557ffffffffffff, 0x000700070007d007, 0x5555ffffffffffff, 0x0001000100014001,
+	0xf555ffffffffffff, 0xf400f400f400f401, 0xf557ffffffffffff, 0xfd00fd00f401f405,
+	0xf557ffffffffffff, 0xff03fd03f407f407, 0xf557ffffffffffff, 0xfc0ffc0ff407f407,
+	0xf557ffffffffffff, 0xf03ff01ff407f407, 0xf557ffffffffffff, 0xc01fc01fd007d407,
+	0xd557ffffffffffff, 0x0007000740075007, 0x5555ffffffffffff, 0x0001000140014001,
+	0xfffffffffff0fff0, 0xff40ff40ff40ff55, 0xffffffffffc0ffc0, 0xff40ff40ff40ff55,
+	0xffffffffff03ff03, 0xff40ff40ff40ff55, 0xfffffffffc0ffc0f, 0xff40ff40ff40ff55,
+	0xfffffffff03ff03f, 0xff40ff40ff40ff55, 0xffffffffc0ffc0ff, 0xff40ff40ff40ff55,
+	0xffffffff03ff03ff, 0xff40ff40ff40ff55, 0xffffffff0fff0fff, 0xff40ff40ff40ff55,
+	0xfffffff0fff0fff0, 0xff40ff40ff40ff55, 0xffffffc0ffc0ffc0, 0xff40ff40ff40ff55,
+	0xffffff03ff03ff03, 0xff40ff40ff40ff55, 0xfffffc0ffc0ffc0f, 0xff40ff40ff40ff55,
+	0xfffff03ff03ff03f, 0xff40ff40ff40ff55, 0xffffc0ffc0ffc0ff, 0xff40ff40ff40ff55,
+	0xffff03ff03ff03ff, 0xff40ff40ff40ff55, 0xffff0fff0fff0fff, 0xff40ff40ff40ff55,
+	0xfff0fff0fff0ffff, 0xff40ff40ff40ff55, 0xffc0ffc0ffc0ffff, 0xff40ff40ff40ff55,
+	0xff03ff03ff03ffff, 0xff40ff40ff40ff55, 0xfc0ffc0ffc0fffff, 0xff40ff40ff40ff55,
+	0xf03ff03ff03fffff, 0xff40ff40ff40ff55, 0xc0ffc0ffc0ffffff, 0xff40ff40ff40ff55,
+	0x03ff03ff03ffffff, 0xff40ff40ff40ff55, 0x0fff0fff0fffffff, 0xff40ff40ff40ff55,
+	0xfff0fff0ffffffff, 0xff40ff40ff40ff50, 0xffc0ffc0ffffffff, 0xff40ff40ff40ff40,
+	0xff03ff03ffffffff, 0xff40ff40ff40ff01, 0xfc0ffc0fffffffff, 0xff40ff40ff40fc05,
+	0xf03ff03fffffffff, 0xff40ff40ff40f015, 0xc0ffc0ffffffffff, 0xff40ff40ff40c055,
+	0x03ff03ffffffffff, 0xff40ff40ff400355, 0x0fff0fffffffffff, 0xff40ff40ff400f55,
+	0xfff0ffffffffffff, 0xff40ff40ff40ff50, 0xffc0ffffffffffff, 0xff40ff40ff40ffc0,
+	0xff03ffffffffffff, 0xff40ff40ff00ff01, 0xfc0fffffffffffff, 0xff40ff40fc00fc05,
+	0xf03fffffffffffff, 0xff40ff40f000f015, 0xc0ffffffffffffff, 0xff40ff40c040c055,
+	0x03ffffffffffffff, 0xff40ff4003400355, 0x0fffffffffffffff, 0xff40ff400f400f55,
+	0x0000000000000000, 0x0000000000000000, 0xffffffffffffffff, 0xffd0ffc0ffc0ffc0,
+	0xffffffffffffffff, 0xffd0ff00ff00ff01, 0xffffffffffffffff, 0xff40fc00fc00fc05,
+	0xffffffffffffffff, 0xff40f000f000f015, 0xffffffffffffffff, 0xff40c040c040c055,
+	0xffffffffffffffff, 0xff40034003400355, 0xffffffffffffffff, 0xff400f400f400f55,
+	0xffffffffffffffff, 0xff40ff40ff50ffff, 0xffffffffffffffff, 0xffc0ffc0ffc0ffff,
+	0xffffffffffffffff, 0xff00ff00ff00fff5, 0xffffffffffffffff, 0xfc00fc00fc00ffd5,
+	0xffffffffffffffff, 0xf000f000f000ff55, 0xffffffffffffffff, 0xc040c040c040ff55,
+	0xffffffffffffffff, 0x034003400340ff55, 0xffffffffffffffff, 0x0f400f400f40ff55,
+	0xffffffffffffffff, 0xff40ff40ff50ffd5, 0xffffffffffffffff, 0xffc0ffc0fff4fff5,
+	0xffffffffffffffff, 0xff00ff00ffd0ffd5, 0xffffffffffffffff, 0xfc00fc00ff40ff55,
+	0xffffffffffffffff, 0xf000f000ff40ff55, 0xffffffffffffffff, 0xc040c040ff40ff55,
+	0xffffffffffffffff, 0x03400340ff40ff55, 0xffffffffffffffff, 0x0f400f40ff40ff55,
+	0xfffffffffff0fff0, 0xfd00fd00fd00fd55, 0xffffffffffc0ffc0, 0xfd00fd00fd00fd55,
+	0xffffffffff03ff03, 0xfd00fd00fd00fd55, 0xfffffffffc0ffc0f, 0xfd00fd00fd00fd55,
+	0xfffffffff03ff03f, 0xfd00fd00fd00fd55, 0xffffffffc0ffc0ff, 0xfd00fd00fd00fd55,
+	0xffffffff03ff03ff, 0xfd00fd00fd00fd55, 0xffffffff0fff0fff, 0xfd00fd00fd00fd55,
+	0xfffffff0fff0fff0, 0xfd00fd00fd00fd55, 0xffffffc0ffc0ffc0, 0xfd00fd00fd00fd55,
+	0xffffff03ff03ff03, 0xfd00fd00fd00fd55, 0xfffffc0ffc0ffc0f, 0xfd00fd00fd00fd55,
+	0xfffff03ff03ff03f, 0xfd00fd00fd00fd55, 0xffffc0ffc0ffc0ff, 0xfd00fd00fd00fd55,
+	0xffff03ff03ff03ff, 0xfd00fd00fd00fd55, 0xffff0fff0fff0fff, 0xfd00fd00fd00fd55,
+	0xfff0fff0fff0ffff, 0xfd00fd00fd00fd55, 0xffc0ffc0ffc0ffff, 0xfd00fd00fd00fd55,
+	0xff03ff03ff03ffff, 0xfd00fd00fd00fd55, 0xfc0ffc0ffc0fffff, 0xfd00fd00fd00fd55,
+	0xf03ff03ff03fffff, 0xfd00fd00fd00fd55, 0xc0ffc0ffc0ffffff, 0xfd00fd00fd00fd55,
+	0x03ff03ff03ffffff, 0xfd00fd00fd00fd55, 0x0fff0fff0fffffff, 0xfd00fd00fd00fd55,
+	0xfff0fff0ffffffff, 0xff40ff40ff40ff50, 0xffc0ffc0ffffffff, 0xff40ff40ff40ff40,
+	0xff03ff03ffffffff, 0xff40ff40ff40ff01, 0xfc0ffc0fffffffff, 0xff40fd40fd00fc05,
+	0xf03ff03fffffffff, 0xff40fd40fd00f015, 0xc0ffc0ffffffffff, 0xfd00fd00fd00c055,
+	0x03ff03ffffffffff, 0xfd00fd00fd000155, 0x0fff0fffffffffff, 0xfd00fd00fd000d55,
+	0xfff0ffffffffffff, 0xffd1ffc0ffd0fff0, 0xffc0ffffffffffff, 0xffd1ffc0ffc0ffc0,
+	0xff03ffffffffffff, 0xffd1ffc0ff01ff03, 0xfc0fffffffffffff, 0xffd1ffc0fc01fc05,
+	0xf03fffffffffffff, 0xff40ff40f000f015, 0xc0ffffffffffffff, 0xfd00fd00c000c055,
+	0x03ffffffffffffff, 0xfd00fd0001000155, 0x0fffffffffffffff, 0xfd00fd000d000d55,
+	0xffffffffffffffff, 0xfff6ffe0fff0fff0, 0x0000000000000000, 0x0000000000000000,
+	0xffffffffffffffff, 0xff66ff00ff03ff03, 0xffffffffffffffff, 0xffd1fc00fc01fc05,
+	0xffffffffffffffff, 0xff40f000f000f015, 0xffffffffffffffff, 0xfd00c000c000c055,
+	0xffffffffffffffff, 0xfd00010001000155, 0xffffffffffffffff, 0xfd000d000d000d55,
+	0xffffffffffffffff, 0xfff0ffe0fff0ffff, 0xffffffffffffffff, 0xffc0ffc0ffc0ffff,
+	0xffffffffffffffff, 0xff01ff02ff03ffff, 0xffffffffffffffff, 0xfc01fc00fc01ffd5,
+	0xffffffffffffffff, 0xf000f000f000ff55, 0xffffffffffffffff, 0xc000c000c000fd55,
+	0xffffffffffffffff, 0x010001000100fd55, 0xffffffffffffffff, 0x0d000d000d00fd55,
+	0xffffffffffffffff, 0xfff0ffc0ffd1ffd5, 0xffffffffffffffff, 0xffc0ffc0ffd1ffd5,
+	0xffffffffffffffff, 0xff01ff00ffd1ffd5, 0xffffffffffffffff, 0xfc00fc00ff41ff55,
+	0xffffffffffffffff, 0xf000f000fd00fd55, 0xffffffffffffffff, 0xc000c000fd00fd55,
+	0xffffffffffffffff, 0x01000100fd00fd55, 0xffffffffffffffff, 0x0d000d00fd00fd55,
+	0xfffffffffff0fff0, 0xf400f400f400f555, 0xffffffffffc0ffc0, 0xf400f400f400f555,
+	0xffffffffff03ff03, 0xf400f400f400f555, 0xfffffffffc0ffc0f, 0xf400f400f400f555,
+	0xfffffffff03ff03f, 0xf400f400f400f555, 0xffffffffc0ffc0ff, 0xf400f400f400f555,
+	0xffffffff03ff03ff, 0xf400f400f400f555, 0xffffffff0fff0fff, 0xf400f400f400f555,
+	0xfffffff0fff0fff0, 0xf400f400f400f555, 0xffffffc0ffc0ffc0, 0xf400f400f400f555,
+	0xffffff03ff03ff03, 0xf400f400f400f555, 0xfffffc0ffc0ffc0f, 0xf400f400f400f555,
+	0xfffff03ff03ff03f, 0xf400f400f400f555, 0xffffc0ffc0ffc0ff, 0xf400f400f400f555,
+	0xffff03ff03ff03ff, 0xf400f400f400f555, 0xffff0fff0fff0fff, 0xf400f400f400f555,
+	0xfff0fff0fff0ffff, 0xf400f400f400f555, 0xffc0ffc0ffc0ffff, 0xf400f400f400f555,
+	0xff03ff03ff03ffff, 0xf400f400f400f555, 0xfc0ffc0ffc0fffff, 0xf400f400f400f555,
+	0xf03ff03ff03fffff, 0xf400f400f400f555, 0xc0ffc0ffc0ffffff, 0xf400f400f400f555,
+	0x03ff03ff03ffffff, 0xf400f400f400f555, 0x0fff0fff0fffffff, 0xf400f400f400f555,
+	0xfff0fff0ffffffff, 0xfd01fd01fd00fd50, 0xffc0ffc0ffffffff, 0xfd01fd01fd01fd40,
+	0xff03ff03ffffffff, 0xfd01fd01fd01fd01, 0xfc0ffc0fffffffff, 0xfd01fd01fd01fc05,
+	0xf03ff03fffffffff, 0xfd01f501f401f015, 0xc0ffc0ffffffffff, 0xfd01f501f401c055,
+	0x03ff03ffffffffff, 0xf400f400f4000155, 0x0fff0fffffffffff, 0xf400f400f4000555,
+	0xfff0ffffffffffff, 0xff47ff03ff40ff50, 0xffc0ffffffffffff, 0xff47ff03ff40ffc0,
+	0xff03ffffffffffff, 0xff47ff03ff03ff03, 0xfc0fffffffffffff, 0xff47ff03fc07fc0f,
+	0xf03fffffffffffff, 0xff47ff03f007f017, 0xc0ffffffffffffff, 0xfd01fd01c001c055,
+	0x03ffffffffffffff, 0xf400f40000000155, 0x0fffffffffffffff, 0xf400f40004000555,
+	0xffffffffffffffff, 0xff47ff00ff40ff50, 0xffffffffffffffff, 0xffd9ff80ffc0ffc0,
+	0x0000000000000000, 0x0000000000000000, 0xffffffffffffffff, 0xfd9ffc0bfc0ffc0f,
+	0xffffffffffffffff, 0xff47f003f007f017, 0xffffffffffffffff, 0xfd01c001c001c055,
+	0xffffffffffffffff, 0xf400000000000155, 0xffffffffffffffff, 0xf400040004000555,
+	0xffffffffffffffff, 0xff40ff00ff40ff57, 0xffffffffffffffff, 0xffc0ff80ffc0ffff,
+	0xffffffffffffffff, 0xff03ff03ff03ffff, 0xffffffffffffffff, 0xfc0ffc0bfc0fffff,
+	0xffffffffffffffff, 0xf007f003f007ff57, 0xffffffffffffffff, 0xc001c001c001fd55,
+	0xffffffffffffffff, 0x000000000000f555, 0xffffffffffffffff, 0x040004000400f555,
+	0xffffffffffffffff, 0xff40ff00ff41ff55, 0xffffffffffffffff, 0xffc0ff00ff47ff57,
+	0xffffffffffffffff, 0xff03ff03ff47ff57, 0xffffffffffffffff, 0xfc0ffc03ff47ff57,
+	0xffffffffffffffff, 0xf007f003fd07fd57, 0xffffffffffffffff, 0xc001c001f401f555,
+	0xffffffffffffffff, 0x00000000f400f555, 0xffffffffffffffff, 0x04000400f400f555,
+	0xfffffffffff0fff0, 0xd001d001d001d555, 0xffffffffffc0ffc0, 0xd001d001d001d555,
+	0xffffffffff03ff03, 0xd001d001d001d555, 0xfffffffffc0ffc0f, 0xd001d001d001d555,
+	0xfffffffff03ff03f, 0xd001d001d001d555, 0xffffffffc0ffc0ff, 0xd001d001d001d555,
+	0xffffffff03ff03ff, 0xd001d001d001d555, 0xffffffff0fff0fff, 0xd001d001d001d555,
+	0xfffffff0fff0fff0, 0xd001d001d001d555, 0xffffffc0ffc0ffc0, 0xd001d001d001d555,
+	0xffffff03ff03ff03, 0xd001d001d001d555, 0xfffffc0ffc0ffc0f, 0xd001d001d001d555,
+	0xfffff03ff03ff03f, 0xd001d001d001d555, 0xffffc0ffc0ffc0ff, 0xd001d001d001d555,
+	0xffff03ff03ff03ff, 0xd001d001d001d555, 0xffff0fff0fff0fff, 0xd001d001d001d555,
+	0xfff0fff0fff0ffff, 0xd001d001d001d555, 0xffc0ffc0ffc0ffff, 0xd001d001d001d555,
+	0xff03ff03ff03ffff, 0xd001d001d001d555, 0xfc0ffc0ffc0fffff, 0xd001d001d001d555,
+	0xf03ff03ff03fffff, 0xd001d001d001d555, 0xc0ffc0ffc0ffffff, 0xd001d001d001d555,
+	0x03ff03ff03ffffff, 0xd001d001d001d555, 0x0fff0fff0fffffff, 0xd001d001d001d555,
+	0xfff0fff0ffffffff, 0xf407f405f401f550, 0xffc0ffc0ffffffff, 0xf407f405f401f540,
+	0xff03ff03ffffffff, 0xf407f407f407f503, 0xfc0ffc0fffffffff, 0xf407f407f407f407,
+	0xf03ff03fffffffff, 0xf407f407f407f017, 0xc0ffc0ffffffffff, 0xf407d407d007c057,
+	0x03ff03ffffffffff, 0xf407d407d0070157, 0x0fff0fffffffffff, 0xd001d001d0010555,
+	0xfff0ffffffffffff, 0xf407f407f400f550, 0xffc0ffffffffffff, 0xfd1ffc0ffd00fd40,
+	0xff03ffffffffffff, 0xfd1ffc0ffd03ff03, 0xfc0fffffffffffff, 0xfd1ffc0ffc0ffc0f,
+	0xf03fffffffffffff, 0xfd1ffc0ff01ff03f, 0xc0ffffffffffffff, 0xfd1ffc0fc01fc05f,
+	0x03ffffffffffffff, 0xf407f40700070157, 0x0fffffffffffffff, 0xd001d00100010555,
+	0xffffffffffffffff, 0xf407f400f400f550, 0xffffffffffffffff, 0xfd1ffc00fd00fd40,
+	0xffffffffffffffff, 0xff67fe03ff03ff03, 0x0000000000000000, 0x0000000000000000,
+	0xffffffffffffffff, 0xf67ff02ff03ff03f, 0xffffffffffffffff, 0xfd1fc00fc01fc05f,
+	0xffffffffffffffff, 0xf407000700070157, 0xffffffffffffffff, 0xd001000100010555,
+	0xffffffffffffffff, 0xf400f400f400f557, 0xffffffffffffffff, 0xfd00fc00fd00fd5f,
+	0xffffffffffffffff, 0xff03fe03ff03ffff, 0xffffffffffffffff, 0xfc0ffc0ffc0fffff,
+	0xffffffffffffffff, 0xf03ff02ff03fffff, 0xffffffffffffffff, 0xc01fc00fc01ffd5f,
+	0xffffffffffffffff, 0x000700070007f557, 0xffffffffffffffff, 0x000100010001d555,
+	0xffffffffffffffff, 0xf400f400f401f555, 0xffffffffffffffff, 0xfd00fc00fd07fd57,
+	0xffffffffffffffff, 0xff03fc03fd1ffd5f, 0xffffffffffffffff, 0xfc0ffc0ffd1ffd5f,
+	0xffffffffffffffff, 0xf03ff00ffd1ffd5f, 0xffffffffffffffff, 0xc01fc00ff41ff55f,
+	0xffffffffffffffff, 0x00070007d007d557, 0xffffffffffffffff, 0x00010001d001d555,
+	0xfffffffffff0fff0, 0xffd0ffd0ffd5ffff, 0xffffffffffc0ffc0, 0xffd0ffd0ffd5ffff,
+	0xffffffffff03ff03, 0xffd0ffd0ffd5ffff, 0xfffffffffc0ffc0f, 0xffd0ffd0ffd5ffff,
+	0xfffffffff03ff03f, 0xffd0ffd0ffd5ffff, 0xffffffffc0ffc0ff, 0xffd0ffd0ffd5ffff,
+	0xffffffff03ff03ff, 0xffd0ffd0ffd5ffff, 0xffffffff0fff0fff, 0xffd0ffd0ffd5ffff,
+	0xfffffff0fff0fff0, 0xffd0ffd0ffd5ffff, 0xffffffc0ffc0ffc0, 0xffd0ffd0ffd5ffff,
+	0xffffff03ff03ff03, 0xffd0ffd0ffd5ffff, 0xfffffc0ffc0ffc0f, 0xffd0ffd0ffd5ffff,
+	0xfffff03ff03ff03f, 0xffd0ffd0ffd5ffff, 0xffffc0ffc0ffc0ff, 0xffd0ffd0ffd5ffff,
+	0xffff03ff03ff03ff, 0xffd0ffd0ffd5ffff, 0xffff0fff0fff0fff, 0xffd0ffd0ffd5ffff,
+	0xfff0fff0fff0ffff, 0xffd0ffd0ffd5ffff, 0xffc0ffc0ffc0ffff, 0xffd0ffd0ffd5ffff,
+	0xff03ff03ff03ffff, 0xffd0ffd0ffd5ffff, 0xfc0ffc0ffc0fffff, 0xffd0ffd0ffd5ffff,
+	0xf03ff03ff03fffff, 0xffd0ffd0ffd5ffff, 0xc0ffc0ffc0ffffff, 0xffd0ffd0ffd5ffff,
+	0x03ff03ff03ffffff, 0xffd0ffd0ffd5ffff, 0x0fff0fff0fffffff, 0xffd0ffd0ffd5ffff,
+	0xfff0fff0ffffffff, 0xffd0ffd0ffd5fff0, 0xffc0ffc0ffffffff, 0xffd0ffd0ffd5ffc0,
+	0xff03ff03ffffffff, 0xffd0ffd0ffd5ff03, 0xfc0ffc0fffffffff, 0xffd0ffd0ffd5fc0f,
+	0xf03ff03fffffffff, 0xffd0ffd0ffd5f03f, 0xc0ffc0ffffffffff, 0xffd0ffd0ffd5c0ff,
+	0x03ff03ffffffffff, 0xffd0ffd0ffd503ff, 0x0fff0fffffffffff, 0xffd0ffd0ffd50fff,
+	0xfff0ffffffffffff, 0xffd0ffd0ffd0fff0, 0xffc0ffffffffffff, 0xffd0ffd0ffc0ffc0,
+	0xff03ffffffffffff, 0xffd0ffd0ff01ff03, 0xfc0fffffffffffff, 0xffd0ffd0fc05fc0f,
+	0xf03fffffffffffff, 0xffd0ffd0f015f03f, 0xc0ffffffffffffff, 0xffd0ffd0c0d5c0ff,
+	0x03ffffffffffffff, 0xffd0ffd003d503ff, 0x0fffffffffffffff, 0xffd0ffd00fd50fff,
+	0xffffffffffffffff, 0xfff0fff0fff0fff0, 0xffffffffffffffff, 0xfff0ffc0ffc0ffc0,
+	0xffffffffffffffff, 0xfff0ff00ff01ff03, 0xffffffffffffffff, 0xffd0fc00fc05fc0f,
+	0xffffffffffffffff, 0xffd0f010f015f03f, 0xffffffffffffffff, 0xffd0c0d0c0d5c0ff,
+	0xffffffffffffffff, 0xffd003d003d503ff, 0xffffffffffffffff, 0xffd00fd00fd50fff,
+	0x0000000000000000, 0x0000000000000000, 0xffffffffffffffff, 0xffc0ffc0ffc0ffff,
+	0xffffffffffffffff, 0xff00ff00ff01ffff, 0xffffffffffffffff, 0xfc00fc00fc05ffff,
+	0xffffffffffffffff, 0xf010f010f015ffff, 0xffffffffffffffff, 0xc0d0c0d0c0d5ffff,
+	0xffffffffffffffff, 0x03d003d003d5ffff, 0xffffffffffffffff, 0x0fd00fd00fd5ffff,
+	0xffffffffffffffff, 0xff40ff40ff57ffff, 0xffffffffffffffff, 0xffc0ffc0ffffffff,
+	0xffffffffffffffff, 0xff00ff00fff5ffff, 0xffffffffffffffff, 0xfc00fc00ffd5ffff,
+	0xffffffffffffffff, 0xf010f010ffd5ffff, 0xffffffffffffffff, 0xc0d0c0d0ffd5ffff,
+	0xffffffffffffffff, 0x03d003d0ffd5ffff, 0xffffffffffffffff, 0x0fd00fd0ffd5ffff,
+	0xfffffffffff0fff0, 0xff40ff40ff55ffff, 0xffffffffffc0ffc0, 0xff40ff40ff55ffff,
+	0xffffffffff03ff03, 0xff40ff40ff55ffff, 0xfffffffffc0ffc0f, 0xff40ff40ff55ffff,
+	0xfffffffff03ff03f, 0xff40ff40ff55ffff, 0xffffffffc0ffc0ff, 0xff40ff40ff55ffff,
+	0xffffffff03ff03ff, 0xff40ff40ff55ffff, 0xffffffff0fff0fff, 0xff40ff40ff55ffff,
+	0xfffffff0fff0fff0, 0xff40ff40ff55ffff, 0xffffffc0ffc0ffc0, 0xff40ff40ff55ffff,
+	0xffffff03ff03ff03, 0xff40ff40ff55ffff, 0xfffffc0ffc0ffc0f, 0xff40ff40ff55ffff,
+	0xfffff03ff03ff03f, 0xff40ff40ff55ffff, 0xffffc0ffc0ffc0ff, 0xff40ff40ff55ffff,
+	0xffff03ff03ff03ff, 0xff40ff40ff55ffff, 0xffff0fff0fff0fff, 0xff40ff40ff55ffff,
+	0xfff0fff0fff0ffff, 0xff40ff40ff55ffff, 0xffc0ffc0ffc0ffff, 0xff40ff40ff55ffff,
+	0xff03ff03ff03ffff, 0xff40ff40ff55ffff, 0xfc0ffc0ffc0fffff, 0xff40ff40ff55ffff,
+	0xf03ff03ff03fffff, 0xff40ff40ff55ffff, 0xc0ffc0ffc0ffffff, 0xff40ff40ff55ffff,
+	0x03ff03ff03ffffff, 0xff40ff40ff55ffff, 0x0fff0fff0fffffff, 0xff40ff40ff55ffff,
+	0xfff0fff0ffffffff, 0xff40ff40ff55fff0, 0xffc0ffc0ffffffff, 0xff40ff40ff55ffc0,
+	0xff03ff03ffffffff, 0xff40ff40ff55ff03, 0xfc0ffc0fffffffff, 0xff40ff40ff55fc0f,
+	0xf03ff03fffffffff, 0xff40ff40ff55f03f, 0xc0ffc0ffffffffff, 0xff40ff40ff55c0ff,
+	0x03ff03ffffffffff, 0xff40ff40ff5503ff, 0x0fff0fffffffffff, 0xff40ff40ff550fff,
+	0xfff0ffffffffffff, 0xff44ff40ff50fff0, 0xffc0ffffffffffff, 0xff44ff40ff40ffc0,
+	0xff03ffffffffffff, 0xff44ff40ff01ff03, 0xfc0fffffffffffff, 0xff44ff40fc05fc0f,
+	0xf03fffffffffffff, 0xff40ff40f015f03f, 0xc0ffffffffffffff, 0xff40ff40c055c0ff,
+	0x03ffffffffffffff, 0xff40ff40035503ff, 0x0fffffffffffffff, 0xff40ff400f550fff,
+	0xffffffffffffffff, 0xffc8ffd0fff0fff0, 0xffffffffffffffff, 0xffe6ffc0ffc0ffc0,
+	0xffffffffffffffff, 0xffc8ff01ff03ff03, 0xffffffffffffffff, 0xffc4fc01fc05fc0f,
+	0xffffffffffffffff, 0xff40f000f015f03f, 0xffffffffffffffff, 0xff40c040c055c0ff,
+	0xffffffffffffffff, 0xff400340035503ff, 0xffffffffffffffff, 0xff400f400f550fff,
+	0xffffffffffffffff, 0xffe0fff0fff0ffff, 0x0000000000000000, 0x0000000000000000,
+	0xffffffffffffffff, 0xff02ff03ff03ffff, 0xffffffffffffffff, 0xfc04fc01fc05ffff,
+	0xffffffffffffffff, 0xf000f000f015ffff, 0xffffffffffffffff, 0xc040c040c055ffff,
+	0xffffffffffffffff, 0x034003400355ffff, 0xffffffffffffffff, 0x0f400f400f55ffff,
+	0xffffffffffffffff, 0xffe0fff0ffffffff, 0xffffffffffffffff, 0xffc0ffc0ffffffff,
+	0xffffffffffffffff, 0xff02ff03ffffffff, 0xffffffffffffffff, 0xfc00fc01ffd5ffff,
+	0xffffffffffffffff, 0xf000f000ff55ffff, 0xffffffffffffffff, 0xc040c040ff55ffff,
+	0xffffffffffffffff, 0x03400340ff55ffff, 0xffffffffffffffff, 0x0f400f40ff55ffff,
+	0xfffffffffff0fff0, 0xfd01fd01fd55ffff, 0xffffffffffc0ffc0, 0xfd01fd01fd55ffff,
+	0xffffffffff03ff03, 0xfd01fd01fd55ffff, 0xfffffffffc0ffc0f, 0xfd01fd01fd55ffff,
+	0xfffffffff03ff03f, 0xfd01fd01fd55ffff, 0xffffffffc0ffc0ff, 0xfd01fd01fd55ffff,
+	0xffffffff03ff03ff, 0xfd01fd01fd55ffff, 0xffffffff0fff0fff, 0xfd01fd01fd55ffff,
+	0xfffffff0fff0fff0, 0xfd01fd01fd55ffff, 0xffffffc0ffc0ffc0, 0xfd01fd01fd55ffff,
+	0xffffff03ff03ff03, 0xfd01fd01fd55ffff, 0xfffffc0ffc0ffc0f, 0xfd01fd01fd55ffff,
+	0xfffff03ff03ff03f, 0xfd01fd01fd55ffff, 0xffffc0ffc0ffc0ff, 0xfd01fd01fd55ffff,
+	0xffff03ff03ff03ff, 0xfd01fd01fd55ffff, 0xffff0fff0fff0fff, 0xfd01fd01fd55ffff,
+	0xfff0fff0fff0ffff, 0xfd01fd01fd55ffff, 0xffc0ffc0ffc0ffff, 0xfd01fd01fd55ffff,
+	0xff03ff03ff03ffff, 0xfd01fd01fd55ffff, 0xfc0ffc0ffc0fffff, 0xfd01fd01fd55ffff,
+	0xf03ff03ff03fffff, 0xfd01fd01fd55ffff, 0xc0ffc0ffc0ffffff, 0xfd01fd01fd55ffff,
+	0x03ff03ff03ffffff, 0xfd01fd01fd55ffff, 0x0fff0fff0fffffff, 0xfd01fd01fd55ffff,
+	0xfff0fff0ffffffff, 0xfd01fd01fd55fff0, 0xffc0ffc0ffffffff, 0xfd01fd01fd55ffc0,
+	0xff03ff03ffffffff, 0xfd01fd01fd55ff03, 0xfc0ffc0fffffffff, 0xfd01fd01fd55fc0f,
+	0xf03ff03fffffffff, 0xfd01fd01fd55f03f, 0xc0ffc0ffffffffff, 0xfd01fd01fd55c0ff,
+	0x03ff03ffffffffff, 0xfd01fd01fd5503ff, 0x0fff0fffffffffff, 0xfd01fd01fd550fff,
+	0xfff0ffffffffffff, 0xfd11fd01fd50fff0, 0xffc0ffffffffffff, 0xfd11fd01fd40ffc0,
+	0xff03ffffffffffff, 0xfd11fd01fd01ff03, 0xfc0fffffffffffff, 0xfd11fd01fc05fc0f,
+	0xf03fffffffffffff, 0xfd11fd01f015f03f, 0xc0ffffffffffffff, 0xfd01fd01c055c0ff,
+	0x03ffffffffffffff, 0xfd01fd01015503ff, 0x0fffffffffffffff, 0xfd01fd010d550fff,
+	0xffffffffffffffff, 0xff11ff40ff50fff0, 0xffffffffffffffff, 0xff21ff40ffc0ffc0,
+	0xffffffffffffffff, 0xff9bff03ff03ff03, 0xffffffffffffffff, 0xff23fc07fc0ffc0f,
+	0xffffffffffffffff, 0xff13f007f017f03f, 0xffffffffffffffff, 0xfd01c001c055c0ff,
+	0xffffffffffffffff, 0xfd010101015503ff, 0xffffffffffffffff, 0xfd010d010d550fff,
+	0xffffffffffffffff, 0xff10ff40ff50ffff, 0xffffffffffffffff, 0xff80ffc0ffc0ffff,
+	0x0000000000000000, 0x0000000000000000, 0xffffffffffffffff, 0xfc0bfc0ffc0fffff,
+	0xffffffffffffffff, 0xf013f007f017ffff, 0xffffffffffffffff, 0xc001c001c055ffff,
+	0xffffffffffffffff, 0x010101010155ffff, 0xffffffffffffffff, 0x0d010d010d55ffff,
+	0xffffffffffffffff, 0xff00ff40ff57ffff, 0xffffffffffffffff, 0xff80ffc0ffffffff,
+	0xffffffffffffffff, 0xff03ff03ffffffff, 0xffffffffffffffff, 0xfc0bfc0fffffffff,
+	0xffffffffffffffff, 0xf003f007ff57ffff, 0xffffffffffffffff, 0xc001c001fd55ffff,
+	0xffffffffffffffff, 0x01010101fd55ffff, 0xffffffffffffffff, 0x0d010d01fd55ffff,
+	0xfffffffffff0fff0, 0xf407f407f557ffff, 0xffffffffffc0ffc0, 0xf407f407f557ffff,
+	0xffffffffff03ff03, 0xf407f407f557ffff, 0xfffffffffc0ffc0f, 0xf407f407f557ffff,
+	0xfffffffff03ff03f, 0xf407f407f557ffff, 0xffffffffc0ffc0ff, 0xf407f407f557ffff,
+	0xffffffff03ff03ff, 0xf407f407f557ffff, 0xffffffff0fff0fff, 0xf407f407f557ffff,
+	0xfffffff0fff0fff0, 0xf407f407f557ffff, 0xffffffc0ffc0ffc0, 0xf407f407f557ffff,
+	0xffffff03ff03ff03, 0xf407f407f557ffff, 0xfffffc0ffc0ffc0f, 0xf407f407f557ffff,
+	0xfffff03ff03ff03f, 0xf407f407f557ffff, 0xffffc0ffc0ffc0ff, 0xf407f407f557ffff,
+	0xffff03ff03ff03ff, 0xf407f407f557ffff, 0xffff0fff0fff0fff, 0xf407f407f557ffff,
+	0xfff0fff0fff0ffff, 0xf407f407f557ffff, 0xffc0ffc0ffc0ffff, 0xf407f407f557ffff,
+	0xff03ff03ff03ffff, 0xf407f407f557ffff, 0xfc0ffc0ffc0fffff, 0xf407f407f557ffff,
+	0xf03ff03ff03fffff, 0xf407f407f557ffff, 0xc0ffc0ffc0ffffff, 0xf407f407f557ffff,
+	0x03ff03ff03ffffff, 0xf407f407f557ffff, 0x0fff0fff0fffffff, 0xf407f407f557ffff,
+	0xfff0fff0ffffffff, 0xf407f407f557fff0, 0xffc0ffc0ffffffff, 0xf407f407f557ffc0,
+	0xff03ff03ffffffff, 0xf407f407f557ff03, 0xfc0ffc0fffffffff, 0xf407f407f557fc0f,
+	0xf03ff03fffffffff, 0xf407f407f557f03f, 0xc0ffc0ffffffffff, 0xf407f407f557c0ff,
+	0x03ff03ffffffffff, 0xf407f407f55703ff, 0x0fff0fffffffffff, 0xf407f407f5570fff,
+	0xfff0ffffffffffff, 0xf407f407f550fff0, 0xffc0ffffffffffff, 0xf447f407f540ffc0,
+	0xff03ffffffffffff, 0xf447f407f503ff03, 0xfc0fffffffffffff, 0xf447f407f407fc0f,
+	0xf03fffffffffffff, 0xf447f407f017f03f, 0xc0ffffffffffffff, 0xf447f407c057c0ff,
+	0x03ffffffffffffff, 0xf407f407015703ff, 0x0fffffffffffffff, 0xf407f40705570fff,
+	0xffffffffffffffff, 0xf407f400f550fff0, 0xffffffffffffffff, 0xfc4ffd00fd40ffc0,
+	0xffffffffffffffff, 0xfc8ffd03ff03ff03, 0xffffffffffffffff, 0xfe6ffc0ffc0ffc0f,
+	0xffffffffffffffff, 0xfc8ff01ff03ff03f, 0xffffffffffffffff, 0xfc4fc01fc05fc0ff,
+	0xffffffffffffffff, 0xf4070007015703ff, 0xffffffffffffffff, 0xf407040705570fff,
+	0xffffffffffffffff, 0xf400f400f550ffff, 0xffffffffffffffff, 0xfc40fd00fd40ffff,
+	0xffffffffffffffff, 0xfe03ff03ff03ffff, 0x0000000000000000, 0x0000000000000000,
+	0xffffffffffffffff, 0xf02ff03ff03fffff, 0xffffffffffffffff, 0xc04fc01fc05fffff,
+	0xffffffffffffffff, 0x000700070157ffff, 0xffffffffffffffff, 0x040704070557ffff,
+	0xffffffffffffffff, 0xf400f400f557ffff, 0xffffffffffffffff, 0xfc00fd00fd5fffff,
+	0xffffffffffffffff, 0xfe03ff03ffffffff, 0xffffffffffffffff, 0xfc0ffc0fffffffff,
+	0xffffffffffffffff, 0xf02ff03fffffffff, 0xffffffffffffffff, 0xc00fc01ffd5fffff,
+	0xffffffffffffffff, 0x00070007f557ffff, 0xffffffffffffffff, 0x04070407f557ffff,
+}
@@ -0,0 +1,33 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestProbeKPK(t *testing.T) {
+	tests := map[string]bool{
+		"8/8/8/8/8/8/4P3/K6k w - - 0 1":   true,  // the pawn outruns the king
+		"8/8/8/8/8/8/4p3/k6K b - - 0 1":   true,  // colors reversed
+		"8/8/8/8/8/8/3P4/k6K w - - 0 1":   true,  // mirrored
+		"k7/8/8/8/8/8/P7/K7 w - - 0 1":    false, // a rook pawn
+		"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1": true,
+		"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1": true,
+		"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1": false, // stalemate
+		"4k3/4P3/4K3/8/8/8/8/8 w - - 0 1": true,
+		"8/8/8/8/8/2k5/1P6/7K w - - 0 1":  false, // the pawn hangs
+	}
+	for fen, expected := range tests {
+		b := ParseFen(fen)
+		if win, ok := ProbeKPK(&b); !ok || win != expected {
+			t.Error("Expected", expected, "for", fen, "but got", win, ok)
+		}
+	}
+	for _, fen := range []string{Startpos, "8/8/8/8/8/8/4PP2/K6k w - - 0 1", "8/8/8/8/8/8/4P3/KN5k w - - 0 1",
+		"8/8/8/8/8/8/8/K6k w - - 0 1"} {
+		b := ParseFen(fen)
+		if _, ok := ProbeKPK(&b); ok {
+			t.Error("Expected no KPK result for", fen)
+		}
+	}
+
+}
@@ -0,0 +1,182 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// Determines whether a move obeys the movement rules of the piece on its origin
+// square, without considering whether it leaves our king in check.
+// Intended for validating moves that did not come from GenerateLegalMoves() on
+// this exact position (e.g. transposition table or killer moves), which may be
+// stale or entirely corrupt. Underpromotions are accepted, even though the move
+// generator only produces queen promotions.
+func (b *Board) IsPseudoLegal(m Move) bool {
+	if m == 0 {
+		return false
+	}
+	if m.IsDrop() {
+		return b.isPseudoLegalDrop(m)
+	}
+	var ourPieces, oppPieces *Bitboards
+	var ourStartingRank, ourPromotionRank uint64
+	var pawnPushDirection int
+	if b.Wtomove {
+		ourPieces = &(b.White)
+		oppPieces = &(b.Black)
+		ourStartingRank = onlyRank[0]
+		ourPromotionRank = onlyRank[7]
+		pawnPushDirection = 8
+	} else {
+		ourPieces = &(b.Black)
+		oppPieces = &(b.White)
+		ourStartingRank = onlyRank[7]
+		ourPromotionRank = onlyRank[0]
+		pawnPushDirection = -8
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	if fromBitboard&ourPieces.All == 0 || toBitboard&ourPieces.All != 0 {
+		return false
+	}
+	allPieces := ourPieces.All | oppPieces.All
+	pieceType, _ := determinePieceType(ourPieces, fromBitboard)
+
+	// Only pawns reaching the last rank may (and must) promote, in Antichess also
+	// to a king.
+	promote := m.Promote()
+	if pieceType == Pawn && toBitboard&ourPromotionRank != 0 {
+		if promote < Knight || promote > Queen && !(promote == King && b.Variant == Antichess) {
+			return false
+		}
+	} else if promote != Nothing {
+		return false
+	}
+
+	switch pieceType {
+	case Pawn:
+		delta := int(m.To()) - int(m.From())
+		if delta == pawnPushDirection { // single push
+			return toBitboard&allPieces == 0
+		}
+		if delta == 2*pawnPushDirection { // double push
+			skipped := uint64(1) << uint8(int(m.From())+pawnPushDirection)
+			return toBitboard&b.doublePushRanks(b.Wtomove) != 0 && (toBitboard|skipped)&allPieces == 0
+		}
+		east, west := b.pawnCaptureBitboards(fromBitboard)
+		if (east|west)&toBitboard == 0 {
+			return false
+		}
+		// pawnCaptureBitboards accepts any en passant square, so make sure there is
+		// actually a pawn to take.
+		if m.To() == b.Enpassant && b.Enpassant != 0 {
+			epVictim := uint8(int(b.Enpassant) - pawnPushDirection)
+			return oppPieces.Pawns&(uint64(1)<<epVictim) != 0
+		}
+		return true
+	case Knight:
+		return knightMasks[m.From()]&toBitboard != 0
+	case Bishop:
+		return CalculateBishopMoveBitboard(m.From(), allPieces)&toBitboard != 0
+	case Rook:
+		return CalculateRookMoveBitboard(m.From(), allPieces)&toBitboard != 0
+	case Queen:
+		return (CalculateBishopMoveBitboard(m.From(), allPieces)|
+			CalculateRookMoveBitboard(m.From(), allPieces))&toBitboard != 0
+	case King:
+		if kingMasks[m.From()]&toBitboard != 0 {
+			return true
+		}
+		// Castling: the king must be on its starting square, with rights, a rook in
+		// the corner, and nothing in between.
+		if fromBitboard&ourStartingRank == 0 || m.From()%8 != 4 {
+			return false
+		}
+		if m.To() == m.From()+2 {
+			between := (uint64(1) << (m.From() + 1)) | (uint64(1) << (m.From() + 2))
+			return b.canCastleKingside() && allPieces&between == 0 &&
+				ourPieces.Rooks&(uint64(1)<<(m.From()+3)) != 0
+		}
+		if int(m.To()) == int(m.From())-2 {
+			between := (uint64(1) << (m.From() - 1)) | (uint64(1) << (m.From() - 2)) |
+				(uint64(1) << (m.From() - 3))
+			return b.canCastleQueenside() && allPieces&between == 0 &&
+				ourPieces.Rooks&(uint64(1)<<(m.From()-4)) != 0
+		}
+	}
+	return false
+}
+
+// Determines whether a move is legal in the current position, without generating
+// the full move list. Any Move value is accepted, so this is safe to call on
+// possibly corrupt moves before passing them to Apply().
+func (b *Board) IsLegal(m Move) bool {
+	if !b.IsPseudoLegal(m) {
+		return false
+	}
+	if b.Variant != Standard {
+		if outcome, _ := b.VariantOutcome(); outcome != Ongoing || !b.variantAllows(m) {
+			return false
+		}
+		if b.Variant == Atomic || b.Variant == Antichess {
+			return true // variantAllows checked the king's safety, or there is none
+		}
+	}
+	if m.IsDrop() {
+		return b.isLegalDrop(m)
+	}
+	var ourPieces *Bitboards
+	var epDelta int
+	if b.Wtomove {
+		ourPieces = &(b.White)
+		epDelta = -8
+	} else {
+		ourPieces = &(b.Black)
+		epDelta = 8
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+
+	if ourPieces.Kings&fromBitboard != 0 {
+		// Castling may not start in, pass through, or end in check.
+		if m.To() == m.From()+2 {
+			return !b.anyUnderDirectAttack(b.Wtomove, m.From(), m.From()+1, m.From()+2)
+		}
+		if int(m.To()) == int(m.From())-2 {
+			return !b.anyUnderDirectAttack(b.Wtomove, m.From(), m.From()-1, m.From()-2)
+		}
+	}
+
+	// Play the move on a scratch copy of the bitboards, then look for attacks on
+	// our king. Only the occupancy matters, so piece types are only updated for
+	// the pieces that can attack.
+	scratch := *b
+	var ourScratch, oppScratch *Bitboards
+	if b.Wtomove {
+		ourScratch = &(scratch.White)
+		oppScratch = &(scratch.Black)
+	} else {
+		ourScratch = &(scratch.Black)
+		oppScratch = &(scratch.White)
+	}
+	ourScratch.All = ourScratch.All&^fromBitboard | toBitboard
+	if ourPieces.Kings&fromBitboard != 0 {
+		ourScratch.Kings = toBitboard
+	}
+	captureBitboard := toBitboard
+	if ourPieces.Pawns&fromBitboard != 0 && m.To() == b.Enpassant && b.Enpassant != 0 {
+		captureBitboard = uint64(1) << uint8(int(m.To())+epDelta)
+	}
+	oppScratch.Pawns &^= captureBitboard
+	oppScratch.Knights &^= captureBitboard
+	oppScratch.Bishops &^= captureBitboard
+	oppScratch.Rooks &^= captureBitboard
+	oppScratch.Queens &^= captureBitboard
+	oppScratch.Kings &^= captureBitboard
+	oppScratch.All &^= captureBitboard
+
+	if ourScratch.Kings == 0 { // no king, as for white in Horde
+		return true
+	}
+	kingLocation := uint8(bits.TrailingZeros64(ourScratch.Kings))
+	return !scratch.UnderDirectAttack(b.Wtomove, kingLocation)
+}
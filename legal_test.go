@@ -0,0 +1,78 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+// Every possible from/to pair (and every queen promotion) must be legal exactly
+// when the move generator produces it.
+func TestIsLegalMatchesGenerator(t *testing.T) {
+	positions := []string{
+		Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 0",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		"r3k3/1ppp1ppr/8/3Pp3/8/8/1PP1PPPP/R3K2R w - e6 3 0",
+		"8/8/8/KPp4r/8/8/8/7k w - c6 0 1",
+		"5k2/5p2/5P2/8/8/2r5/2rR2K1/4B2R w - - 0 1",
+		"4k3/8/8/8/8/8/8/R3K2r w Q - 0 1",
+	}
+	for _, fen := range positions {
+		b := ParseFen(fen)
+		generated := make(map[Move]bool)
+		for _, m := range b.GenerateLegalMoves() {
+			generated[m] = true
+			if !b.IsLegal(m) {
+				t.Error("Generated move", &m, "was reported illegal in", fen)
+			}
+		}
+		for from := Square(0); from < 64; from++ {
+			for to := Square(0); to < 64; to++ {
+				for _, promote := range []Piece{Nothing, Queen} {
+					var m Move
+					m.Setfrom(from).Setto(to).Setpromote(promote)
+					if b.IsLegal(m) != generated[m] {
+						t.Error("IsLegal disagrees with the move generator for", &m, "in", fen)
+					}
+				}
+			}
+		}
+		if b.ToFen() != fen {
+			t.Error("Legality checking corrupted board state for", fen)
+		}
+	}
+}
+
+func TestIsLegalCorruptMoves(t *testing.T) {
+	b := ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0")
+	illegal := []string{
+		"0000",  // null move
+		"a3a4",  // empty origin
+		"a7a6",  // opponent's piece
+		"e1d2",  // onto our own piece
+		"e5e6",  // knight moving like a pawn
+		"a2a4q", // promotion off the last rank
+		"d2d3",  // bishop moving like a pawn
+		"e4e5",  // pawn push onto an occupied square
+	}
+	for _, str := range illegal {
+		m := parseMove(str)
+		if b.IsPseudoLegal(m) || b.IsLegal(m) {
+			t.Error("Corrupt move", str, "was accepted.")
+		}
+	}
+	// Underpromotions are legal, even though they are never generated.
+	promo := ParseFen("8/1P2k3/8/8/8/8/8/4K3 w - - 0 1")
+	var toKing Move
+	toKing.Setfrom(Square(algebraicToIndexFatal("b7"))).Setto(Square(algebraicToIndexFatal("b8"))).Setpromote(King)
+	if !promo.IsLegal(parseMove("b7b8n")) || promo.IsLegal(parseMove("b7b8")) || promo.IsLegal(toKing) {
+		t.Error("Promotion legality check failed.")
+	}
+	// Pseudo-legal, but leaves the king in check.
+	pinned := ParseFen("4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1")
+	if !pinned.IsPseudoLegal(parseMove("e2c3")) || pinned.IsLegal(parseMove("e2c3")) {
+		t.Error("Pinned piece legality check failed.")
+	}
+}
@@ -0,0 +1,129 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// The Board keeps some evaluation state up to date as moves are applied, so
+// that evaluators do not need to recompute it from the bitboards:
+//
+//   - the material signature, which packs the number of pieces of each type and
+//     color in the order of pieceSquareZobristC (white pawns in the lowest
+//     bits, black kings in the highest), at the offsets of materialShift;
+//   - the sum of material and piece-square values from DefaultEvalWeights, for
+//     the middlegame and the endgame, from white's point of view;
+//   - the pawn hash, a Zobrist hash of the pawns and kings only;
+//   - the material key, a Zobrist hash of the piece counts.
+//
+// All are recomputed by ParseFen.
+
+// Material plus piece-square values from the default weights, indexed like
+// pieceSquareZobristC. Black's values are mirrored and negated.
+var pieceSquareScores [12][64]PhaseScore
+
+// Fills pieceSquareScores. Called by the evaluation init(), once the default
+// weights are ready.
+func initPieceSquareScores() {
+	for piece := Pawn; piece <= King; piece++ {
+		for sq := 0; sq < 64; sq++ {
+			white := defaultEvalWeights.PieceSquare[piece][sq]
+			black := defaultEvalWeights.PieceSquare[piece][sq^56]
+			material := defaultEvalWeights.Material[piece]
+			pieceSquareScores[piece-1][sq] = PhaseScore{material[0] + white[0], material[1] + white[1]}
+			pieceSquareScores[piece+5][sq] = PhaseScore{-material[0] - black[0], -material[1] - black[1]}
+		}
+	}
+}
+
+// The offsets of the piece counts in the material signature, indexed like
+// pieceSquareZobristC. Pawn counts take 6 bits, for the 36 pawns of Horde, and
+// the others 5 bits, for the pieces a side can gain by promotions and drops.
+var materialShift = [12]uint{0, 6, 11, 16, 21, 26, 31, 37, 42, 47, 52, 57}
+
+// The number of pieces of a kind, from the material signature.
+func (b *Board) materialCount(zobristIndex int) uint64 {
+	mask := uint64(0x1f)
+	if zobristIndex == 0 || zobristIndex == 6 {
+		mask = 0x3f
+	}
+	return (b.materialSignature >> materialShift[zobristIndex]) & mask
+}
+
+// Returns the number of pieces of a type and color. Cheap to call, since the
+// counts are updated incrementally.
+func (b *Board) PieceCount(white bool, piece Piece) int {
+	index := int(piece) - 1
+	if !white {
+		index += 6
+	}
+	return int(b.materialCount(index))
+}
+
+// Returns the material signature: a key that is equal for two positions if and
+// only if they have the same number of pieces of each type and color. Suitable
+// for recognizing specific endgames.
+func (b *Board) MaterialSignature() uint64 {
+	return b.materialSignature
+}
+
+// Returns the sum of material and piece-square values, from white's point of
+// view, for the middlegame and the endgame. The values are those of
+// DefaultEvalWeights.
+func (b *Board) PieceSquareScore() PhaseScore {
+	return b.pieceSquareScore
+}
+
+// Returns a Zobrist hash of the pawns and kings, for caching pawn structure
+// evaluation. Cheap to call, since it is incrementally updated.
+func (b *Board) PawnHash() uint64 {
+	return b.pawnHash
+}
+
+// Returns a Zobrist hash of the piece counts, for caching material evaluation
+// and looking up endgame specializations. Unlike MaterialSignature, it is
+// suitable for indexing hash tables directly.
+func (b *Board) MaterialKey() uint64 {
+	return b.materialKey
+}
+
+// Whether a pieceSquareZobristC index is a pawn or a king.
+func isPawnOrKing(zobristIndex int) bool {
+	return zobristIndex == 0 || zobristIndex == 5 || zobristIndex == 6 || zobristIndex == 11
+}
+
+// Adds a piece to the incremental material state. zobristIndex is the index of
+// the piece into pieceSquareZobristC.
+func (b *Board) addMaterial(zobristIndex int, square uint8) {
+	b.materialKey ^= materialZobristC[zobristIndex][b.materialCount(zobristIndex)]
+	if isPawnOrKing(zobristIndex) {
+		b.pawnHash ^= pieceSquareZobristC[zobristIndex][square]
+	}
+	b.materialSignature += 1 << materialShift[zobristIndex]
+	b.pieceSquareScore[0] += pieceSquareScores[zobristIndex][square][0]
+	b.pieceSquareScore[1] += pieceSquareScores[zobristIndex][square][1]
+}
+
+// Removes a piece from the incremental material state.
+func (b *Board) removeMaterial(zobristIndex int, square uint8) {
+	b.materialSignature -= 1 << materialShift[zobristIndex]
+	b.materialKey ^= materialZobristC[zobristIndex][b.materialCount(zobristIndex)]
+	if isPawnOrKing(zobristIndex) {
+		b.pawnHash ^= pieceSquareZobristC[zobristIndex][square]
+	}
+	b.pieceSquareScore[0] -= pieceSquareScores[zobristIndex][square][0]
+	b.pieceSquareScore[1] -= pieceSquareScores[zobristIndex][square][1]
+}
+
+// Recomputes the incremental material state from the bitboards.
+func (b *Board) recomputeMaterial() {
+	b.materialSignature, b.pawnHash, b.materialKey = 0, 0, 0
+	b.pieceSquareScore = PhaseScore{}
+	for color, bb := range [2]*Bitboards{&b.White, &b.Black} {
+		pieces := [6]uint64{bb.Pawns, bb.Knights, bb.Bishops, bb.Rooks, bb.Queens, bb.Kings}
+		for i, x := range pieces {
+			for ; x != 0; x &= x - 1 {
+				b.addMaterial(color*6+i, uint8(bits.TrailingZeros64(x)))
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package dragontoothmg
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMaterialSignature(t *testing.T) {
+	b := ParseFen(Startpos)
+	counts := map[Piece]int{Pawn: 8, Knight: 2, Bishop: 2, Rook: 2, Queen: 1, King: 1}
+	for piece, count := range counts {
+		if b.PieceCount(true, piece) != count || b.PieceCount(false, piece) != count {
+			t.Error("Wrong count for piece", piece)
+		}
+	}
+	if score := b.PieceSquareScore(); score != (PhaseScore{}) {
+		t.Error("The starting position should have an even piece-square score, got", score)
+	}
+	// The 36 pawns of Horde do not overflow into the knight count.
+	horde := ParseVariantFen(Horde.Startpos(), Horde)
+	hordeCounts := map[Piece]int{Pawn: 36, Knight: 0, Bishop: 0, Rook: 0, Queen: 0, King: 0}
+	for piece, count := range hordeCounts {
+		if horde.PieceCount(true, piece) != count || horde.PieceCount(false, piece) != counts[piece] {
+			t.Error("Wrong count for piece", piece, "in Horde")
+		}
+	}
+	fewer := ParseVariantFen("rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPP1 w kq - 0 1", Horde)
+	if horde.MaterialKey() == fewer.MaterialKey() || horde.MaterialSignature() == fewer.MaterialSignature() {
+		t.Error("The material key does not count the pawns of Horde.")
+	}
+	krk := ParseFen("8/8/8/3k4/8/8/8/R3K3 w - - 0 1")
+	other := ParseFen("R7/8/8/8/6k1/8/8/4K3 b - - 0 1")
+	kqk := ParseFen("8/8/8/3k4/8/8/8/Q3K3 w - - 0 1")
+	if krk.MaterialSignature() != other.MaterialSignature() || krk.MaterialSignature() == kqk.MaterialSignature() {
+		t.Error("Material signatures do not identify the material.")
+	}
+}
+
+// The incremental state must always match a recomputation, along random games
+// that include castling, en passant and (under)promotions.
+func TestMaterialIncremental(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	fens := append([]string{Startpos,
+		"r3k2r/1P4P1/8/2pP4/8/8/1p4p1/R3K2R w KQkq c6 0 1",
+		"rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1"}, movePickerPositions...)
+	for _, fen := range fens {
+		for game := 0; game < 20; game++ {
+			b := ParseFen(fen)
+			for ply := 0; ply < 100; ply++ {
+				moves := b.GenerateLegalExtMoves()
+				if len(moves) == 0 {
+					break
+				}
+				m := moves[r.Intn(len(moves))]
+				plain := m.Move()
+				if plain.Promote() != Nothing && r.Intn(2) == 0 {
+					plain.Setpromote(Piece(Knight + r.Intn(3)))
+					b.Apply(plain)
+				} else if r.Intn(2) == 0 {
+					b.ApplyExt(m)
+				} else {
+					b.Apply(plain)
+				}
+				expected := b
+				expected.recomputeMaterial()
+				if b.materialSignature != expected.materialSignature ||
+					b.pieceSquareScore != expected.pieceSquareScore ||
+					b.pawnHash != expected.pawnHash || b.materialKey != expected.materialKey {
+					t.Fatal("Incremental material state is wrong after", m.String(), "in", b.ToFen())
+				}
+			}
+		}
+	}
+}
+
+func TestPawnHashAndMaterialKey(t *testing.T) {
+	a := ParseFen("4k3/pp6/8/8/8/8/2PP4/R3K3 w - - 0 1")
+	b := ParseFen("4k3/pp6/8/8/8/8/2PP4/4K2N b - - 3 9")
+	c := ParseFen("4k3/pp6/8/8/8/3P4/2P5/R3K3 w - - 0 1")
+	if a.PawnHash() != b.PawnHash() || a.PawnHash() == c.PawnHash() || a.PawnHash() == a.Hash() {
+		t.Error("The pawn hash should only depend on pawns and kings.")
+	}
+	if a.MaterialKey() != c.MaterialKey() || a.MaterialKey() == b.MaterialKey() {
+		t.Error("The material key should only depend on piece counts.")
+	}
+	// One rook against two is different material from two rooks against one.
+	d := ParseFen("r3k2r/8/8/8/8/8/8/R3K3 w - - 0 1")
+	e := ParseFen("r3k3/8/8/8/8/8/8/R3K2R w - - 0 1")
+	if d.MaterialKey() == e.MaterialKey() {
+		t.Error("The material key does not distinguish the colors.")
+	}
+}
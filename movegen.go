@@ -13,7 +13,21 @@ import (
 
 // The main API entrypoint. Generates all legal moves for a given board.
 func (b *Board) GenerateLegalMoves() []Move {
+	if b.Variant != Standard {
+		return b.generateVariantMoves()
+	}
+	return b.generateLegalMoves()
+}
+
+// Generates the legal moves by the standard rules.
+func (b *Board) generateLegalMoves() []Move {
 	moves := make([]Move, 0, kDefaultMoveListLength)
+	b.generateLegal(moveList{moves: &moves})
+	return moves
+}
+
+// Generates the legal moves by the standard rules into a move list.
+func (b *Board) generateLegal(list moveList) {
 	// First, see if we are currently in check. If we are, invoke a special check-
 	// evasion move generator.
 	var kingLocation uint8
@@ -25,46 +39,288 @@ func (b *Board) GenerateLegalMoves() []Move {
 		kingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
 		ourPiecesPtr = &(b.Black)
 	}
+	if ourPiecesPtr.Kings == 0 { // no king to keep safe, as for white in Horde
+		b.pawnPushes(list, everything, everything)
+		b.pawnCaptures(list, everything, everything)
+		b.knightMoves(list, everything, everything)
+		b.rookMoves(list, everything, everything)
+		b.bishopMoves(list, everything, everything)
+		b.queenMoves(list, everything, everything)
+		return
+	}
 	kingAttackers, blockerDestinations := b.countAttacks(b.Wtomove, kingLocation, 2)
 	if kingAttackers >= 2 { // Under multiple attack, we must move the king.
-		b.kingPushes(&moves, ourPiecesPtr)
-		return moves
+		b.kingPushes(list, ourPiecesPtr)
+		return
 	}
 
 	// Several move types can work in single check, but we must block the check
 	if kingAttackers == 1 {
 		// calculate pinned pieces
-		pinnedPieces := b.generatePinnedMoves(&moves, blockerDestinations)
+		pinnedPieces := b.generatePinnedMoves(list, blockerDestinations)
 		nonpinnedPieces := ^pinnedPieces
 		// TODO
-		b.pawnPushes(&moves, nonpinnedPieces, blockerDestinations)
-		b.pawnCaptures(&moves, nonpinnedPieces, blockerDestinations)
-		b.knightMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.rookMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.bishopMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.queenMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.kingPushes(&moves, ourPiecesPtr)
-		return moves
+		b.pawnPushes(list, nonpinnedPieces, blockerDestinations)
+		b.pawnCaptures(list, nonpinnedPieces, blockerDestinations)
+		b.knightMoves(list, nonpinnedPieces, blockerDestinations)
+		b.rookMoves(list, nonpinnedPieces, blockerDestinations)
+		b.bishopMoves(list, nonpinnedPieces, blockerDestinations)
+		b.queenMoves(list, nonpinnedPieces, blockerDestinations)
+		b.kingPushes(list, ourPiecesPtr)
+		if b.Variant == Crazyhouse {
+			b.dropMoves(list, blockerDestinations)
+		}
+		return
 	}
 
 	// Then, calculate all the absolutely pinned pieces, and compute their moves.
 	// If we are in check, we can only move to squares that block the check.
-	pinnedPieces := b.generatePinnedMoves(&moves, everything)
+	pinnedPieces := b.generatePinnedMoves(list, everything)
 	nonpinnedPieces := ^pinnedPieces
 
 	// Finally, compute ordinary moves, ignoring absolutely pinned pieces on the board.
-	b.pawnPushes(&moves, nonpinnedPieces, everything)
-	b.pawnCaptures(&moves, nonpinnedPieces, everything)
-	b.knightMoves(&moves, nonpinnedPieces, everything)
-	b.rookMoves(&moves, nonpinnedPieces, everything)
-	b.bishopMoves(&moves, nonpinnedPieces, everything)
-	b.queenMoves(&moves, nonpinnedPieces, everything)
-	b.kingMoves(&moves)
+	b.pawnPushes(list, nonpinnedPieces, everything)
+	b.pawnCaptures(list, nonpinnedPieces, everything)
+	b.knightMoves(list, nonpinnedPieces, everything)
+	b.rookMoves(list, nonpinnedPieces, everything)
+	b.bishopMoves(list, nonpinnedPieces, everything)
+	b.queenMoves(list, nonpinnedPieces, everything)
+	b.kingMoves(list)
+	if b.Variant == Crazyhouse {
+		b.dropMoves(list, everything)
+	}
+}
+
+// Generates only the legal captures (including en passant) and promotions.
+// Together with GenerateLegalQuiets(), this produces the same set of moves as
+// GenerateLegalMoves(), which is useful for staged move ordering.
+func (b *Board) GenerateLegalCaptures() []Move {
+	if b.Variant != Standard {
+		return b.splitVariantMoves(true)
+	}
+	moves := make([]Move, 0, kDefaultMoveListLength)
+	b.generateLegalMovesMasked(&moves, true)
+	return moves
+}
+
+// Generates only the legal moves that are not captures or promotions,
+// including castling.
+func (b *Board) GenerateLegalQuiets() []Move {
+	if b.Variant != Standard {
+		return b.splitVariantMoves(false)
+	}
+	moves := make([]Move, 0, kDefaultMoveListLength)
+	b.generateLegalMovesMasked(&moves, false)
 	return moves
 }
 
+// Generates the moves that obey the movement rules of the pieces, ignoring pins
+// and checks, for variants with their own rules of king safety. Castling only
+// needs the rights and a clear path. Like the legal move generator, only queen
+// promotions are generated.
+func (b *Board) generatePseudoLegalMoves(moves *[]Move) {
+	list := moveList{moves: moves}
+	var ourPiecesPtr *Bitboards
+	var promotionRank uint64
+	var kingHome uint8
+	eastDelta, westDelta := -9, -7 // add these to a capture square to find the pawn
+	if b.Wtomove {
+		ourPiecesPtr = &(b.White)
+		promotionRank = onlyRank[7]
+		kingHome = 4
+	} else {
+		ourPiecesPtr = &(b.Black)
+		promotionRank = onlyRank[0]
+		kingHome = 60
+		eastDelta, westDelta = 7, 9
+	}
+	b.pawnPushes(list, everything, everything)
+	east, west := b.pawnCaptureBitboards(everything)
+	for i, targets := range [2]uint64{east, west} {
+		delta := [2]int{eastDelta, westDelta}[i]
+		for targets != 0 {
+			target := bits.TrailingZeros64(targets)
+			targets &= targets - 1
+			var move Move
+			move.Setfrom(Square(target + delta)).Setto(Square(target))
+			if promotionRank&(uint64(1)<<uint(target)) != 0 {
+				move.Setpromote(Queen)
+			}
+			*moves = append(*moves, move)
+		}
+	}
+	b.knightMoves(list, everything, everything)
+	b.rookMoves(list, everything, everything)
+	b.bishopMoves(list, everything, everything)
+	b.queenMoves(list, everything, everything)
+	for kings := ourPiecesPtr.Kings; kings != 0; kings &= kings - 1 {
+		king := bits.TrailingZeros64(kings)
+		genMovesFromTargets(moves, Square(king), kingMasks[king]&^ourPiecesPtr.All)
+	}
+	if ourPiecesPtr.Kings&(uint64(1)<<kingHome) == 0 {
+		return
+	}
+	allPieces := b.White.All | b.Black.All
+	if b.canCastleKingside() && allPieces&(uint64(3)<<(kingHome+1)) == 0 {
+		var move Move
+		move.Setfrom(Square(kingHome)).Setto(Square(kingHome + 2))
+		*moves = append(*moves, move)
+	}
+	if b.canCastleQueenside() && allPieces&(uint64(7)<<(kingHome-3)) == 0 {
+		var move Move
+		move.Setfrom(Square(kingHome)).Setto(Square(kingHome - 2))
+		*moves = append(*moves, move)
+	}
+}
+
+// Generates either the noisy (captures and promotions) or quiet subset of the legal moves.
+// Non-king pieces are restricted with destination masks; pinned pieces and king moves
+// are rare enough that they are generated in full and then filtered.
+func (b *Board) generateLegalMovesMasked(moves *[]Move, noisy bool) {
+	list := moveList{moves: moves}
+	var kingLocation uint8
+	var ourPiecesPtr *Bitboards
+	var oppPieces, promotionRank uint64
+	if b.Wtomove { // assumes only one king
+		kingLocation = uint8(bits.TrailingZeros64(b.White.Kings))
+		ourPiecesPtr = &(b.White)
+		oppPieces = b.Black.All
+		promotionRank = onlyRank[7]
+	} else {
+		kingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
+		ourPiecesPtr = &(b.Black)
+		oppPieces = b.White.All
+		promotionRank = onlyRank[0]
+	}
+	var pieceDest, pushDest uint64
+	if noisy {
+		pieceDest = oppPieces
+		pushDest = promotionRank
+	} else {
+		pieceDest = ^oppPieces
+		pushDest = ^promotionRank
+	}
+	if ourPiecesPtr.Kings == 0 { // no king to keep safe, as for white in Horde
+		b.pawnPushes(list, everything, pushDest)
+		if noisy {
+			b.pawnCaptures(list, everything, pieceDest)
+		}
+		b.knightMoves(list, everything, pieceDest)
+		b.rookMoves(list, everything, pieceDest)
+		b.bishopMoves(list, everything, pieceDest)
+		b.queenMoves(list, everything, pieceDest)
+		return
+	}
+	kingAttackers, blockerDestinations := b.countAttacks(b.Wtomove, kingLocation, 2)
+	if kingAttackers >= 2 { // Under multiple attack, we must move the king.
+		start := len(*moves)
+		b.kingPushes(list, ourPiecesPtr)
+		filterNoisyMoves(moves, start, oppPieces, noisy)
+		return
+	}
+	allowDest := everything
+	if kingAttackers == 1 {
+		allowDest = blockerDestinations
+	}
+
+	start := len(*moves)
+	pinnedPieces := b.generatePinnedMoves(list, allowDest)
+	filterNoisyMoves(moves, start, oppPieces, noisy)
+	nonpinnedPieces := ^pinnedPieces
+
+	b.pawnPushes(list, nonpinnedPieces, allowDest&pushDest)
+	if noisy { // en passant captures are always noisy
+		b.pawnCaptures(list, nonpinnedPieces, allowDest&pieceDest)
+	}
+	b.knightMoves(list, nonpinnedPieces, allowDest&pieceDest)
+	b.rookMoves(list, nonpinnedPieces, allowDest&pieceDest)
+	b.bishopMoves(list, nonpinnedPieces, allowDest&pieceDest)
+	b.queenMoves(list, nonpinnedPieces, allowDest&pieceDest)
+	start = len(*moves)
+	if kingAttackers == 1 {
+		b.kingPushes(list, ourPiecesPtr)
+	} else {
+		b.kingMoves(list)
+	}
+	filterNoisyMoves(moves, start, oppPieces, noisy)
+}
+
+// Helper: removes the moves after index start that are not in the requested
+// (noisy or quiet) category. oppPieces is the bitboard of capturable pieces.
+func filterNoisyMoves(moveList *[]Move, start int, oppPieces uint64, noisy bool) {
+	kept := start
+	for _, m := range (*moveList)[start:] {
+		isNoisy := (uint64(1)<<m.To())&oppPieces != 0 || m.Promote() != Nothing
+		if isNoisy == noisy {
+			(*moveList)[kept] = m
+			kept++
+		}
+	}
+	*moveList = (*moveList)[:kept]
+}
+
+// Generates all legal moves, annotated with the moving and captured pieces.
+// The results can be passed to ApplyExt() to skip re-probing the board.
+func (b *Board) GenerateLegalExtMoves() []ExtMove {
+	switch b.Variant {
+	case RacingKings, Atomic, Antichess:
+		// These variants filter the generated moves by playing or inspecting
+		// them, so the moves are annotated afterwards.
+		moves := b.GenerateLegalMoves()
+		extMoves := make([]ExtMove, len(moves))
+		for i, m := range moves {
+			extMoves[i] = b.ExtendMove(m)
+		}
+		return extMoves
+	}
+	extMoves := make([]ExtMove, 0, kDefaultMoveListLength)
+	if outcome, _ := b.VariantOutcome(); b.Variant != Standard && outcome != Ongoing {
+		return extMoves
+	}
+	b.generateLegal(moveList{ext: &extMoves})
+	return extMoves
+}
+
+// Packs a move with its moving and captured pieces, without any flags.
+func newExtMove(m Move, piece Piece, captured Piece) ExtMove {
+	return ExtMove(m) | ExtMove(piece)<<16 | ExtMove(captured)<<19
+}
+
+// Annotates a move with the moving and captured pieces and special move flags.
+// The move must be valid for this position.
+func (b *Board) ExtendMove(m Move) ExtMove {
+	if m.IsDrop() {
+		return newExtMove(m, m.Drop(), Nothing)
+	}
+	var ourPieces, oppPieces *Bitboards
+	if b.Wtomove {
+		ourPieces = &(b.White)
+		oppPieces = &(b.Black)
+	} else {
+		ourPieces = &(b.Black)
+		oppPieces = &(b.White)
+	}
+	pieceType, _ := determinePieceType(ourPieces, uint64(1)<<m.From())
+	capturedType, _ := determinePieceType(oppPieces, uint64(1)<<m.To())
+	ext := newExtMove(m, pieceType, capturedType)
+	switch pieceType {
+	case Pawn:
+		if m.To() == b.Enpassant && b.Enpassant != 0 {
+			ext |= ExtMove(Pawn)<<19 | extEnpassantFlag
+		} else if m.To()-m.From() == 16 || m.From()-m.To() == 16 {
+			ext |= extDoublePushFlag
+		}
+	case King:
+		if m.To()-m.From() == 2 || m.From()-m.To() == 2 {
+			ext |= extCastleFlag
+		}
+	}
+	return ext
+}
+
 func (b *Board) MyGenerateLegalMoves() ([]Move, bool) {
 	moves := make([]Move, 0, kDefaultMoveListLength)
+	list := moveList{moves: &moves}
 	// First, see if we are currently in check. If we are, invoke a special check-
 	// evasion move generator.
 	var kingLocation uint8
@@ -78,46 +334,46 @@ func (b *Board) MyGenerateLegalMoves() ([]Move, bool) {
 	}
 	kingAttackers, blockerDestinations := b.countAttacks(b.Wtomove, kingLocation, 2)
 	if kingAttackers >= 2 { // Under multiple attack, we must move the king.
-		b.kingPushes(&moves, ourPiecesPtr)
+		b.kingPushes(list, ourPiecesPtr)
 		return moves, true
 	}
 
 	// Several move types can work in single check, but we must block the check
 	if kingAttackers == 1 {
 		// calculate pinned pieces
-		pinnedPieces := b.generatePinnedMoves(&moves, blockerDestinations)
+		pinnedPieces := b.generatePinnedMoves(list, blockerDestinations)
 		nonpinnedPieces := ^pinnedPieces
 		// TODO
-		b.pawnPushes(&moves, nonpinnedPieces, blockerDestinations)
-		b.pawnCaptures(&moves, nonpinnedPieces, blockerDestinations)
-		b.knightMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.rookMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.bishopMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.queenMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.kingPushes(&moves, ourPiecesPtr)
+		b.pawnPushes(list, nonpinnedPieces, blockerDestinations)
+		b.pawnCaptures(list, nonpinnedPieces, blockerDestinations)
+		b.knightMoves(list, nonpinnedPieces, blockerDestinations)
+		b.rookMoves(list, nonpinnedPieces, blockerDestinations)
+		b.bishopMoves(list, nonpinnedPieces, blockerDestinations)
+		b.queenMoves(list, nonpinnedPieces, blockerDestinations)
+		b.kingPushes(list, ourPiecesPtr)
 		return moves, true
 	}
 
 	// Then, calculate all the absolutely pinned pieces, and compute their moves.
 	// If we are in check, we can only move to squares that block the check.
-	pinnedPieces := b.generatePinnedMoves(&moves, everything)
+	pinnedPieces := b.generatePinnedMoves(list, everything)
 	nonpinnedPieces := ^pinnedPieces
 
 	// Finally, compute ordinary moves, ignoring absolutely pinned pieces on the board.
-	b.pawnPushes(&moves, nonpinnedPieces, everything)
-	b.pawnCaptures(&moves, nonpinnedPieces, everything)
-	b.knightMoves(&moves, nonpinnedPieces, everything)
-	b.rookMoves(&moves, nonpinnedPieces, everything)
-	b.bishopMoves(&moves, nonpinnedPieces, everything)
-	b.queenMoves(&moves, nonpinnedPieces, everything)
-	b.kingMoves(&moves)
+	b.pawnPushes(list, nonpinnedPieces, everything)
+	b.pawnCaptures(list, nonpinnedPieces, everything)
+	b.knightMoves(list, nonpinnedPieces, everything)
+	b.rookMoves(list, nonpinnedPieces, everything)
+	b.bishopMoves(list, nonpinnedPieces, everything)
+	b.queenMoves(list, nonpinnedPieces, everything)
+	b.kingMoves(list)
 	return moves, false
 }
 
 // Calculate the available moves for absolutely pinned pieces (pinned to the king).
 // We are only allowed to move to squares in allowDest, to block checks.
 // Return a bitboard of all pieces that are pinned.
-func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
+func (b *Board) generatePinnedMoves(list moveList, allowDest uint64) uint64 {
 	var ourKingIdx uint8
 	var ourPieces, oppPieces *Bitboards
 	var allPinnedPieces uint64 = 0
@@ -168,7 +424,16 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 					pawnTargets |= (1 << uint8(int(pinnedPieceIdx)+16*pawnPushDirection)) & ^allPieces & doublePushRank
 				}
 				pawnTargets &= allowDest // TODO this might be a promotion. Is that possible?
-				genMovesFromTargets(moveList, Square(pinnedPieceIdx), pawnTargets)
+				for ; pawnTargets != 0; pawnTargets &= pawnTargets - 1 {
+					target := uint8(bits.TrailingZeros64(pawnTargets))
+					var move Move
+					move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(target))
+					var flags ExtMove
+					if target-pinnedPieceIdx == 16 || pinnedPieceIdx-target == 16 {
+						flags = extDoublePushFlag
+					}
+					list.add(move, Pawn, Nothing, flags)
+				}
 			}
 			continue
 		}
@@ -181,7 +446,11 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 		// actually available moves
 		pinnedTargets := pinnedPieceAllMoves & (rookTargets | kingOrthoTargets | (uint64(1) << currRookIdx))
 		pinnedTargets &= allowDest
-		genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
+		pinnedType := Piece(Queen)
+		if pinnedPiece&ourPieces.Rooks != 0 {
+			pinnedType = Rook
+		}
+		b.addTargets(list, Square(pinnedPieceIdx), pinnedType, pinnedTargets)
 	}
 
 	// Calculate king moves as if it was a bishop.
@@ -208,17 +477,21 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 		// if it's a pawn we might be able to capture with it
 		// the capture square must also be in allowdest
 		if pinnedPiece&ourPieces.Pawns != 0 {
+			pinnerType := Piece(Queen)
+			if oppPieces.Bishops&(uint64(1)<<currBishopIdx) != 0 {
+				pinnerType = Bishop
+			}
 			if (uint64(1)<<currBishopIdx)&allowDest != 0 {
 				if (b.Wtomove && (pinnedPieceIdx/8)+1 == currBishopIdx/8) ||
 					(!b.Wtomove && pinnedPieceIdx/8 == (currBishopIdx/8)+1) {
 					if ((uint64(1) << currBishopIdx) & ourPromotionRank) != 0 { // We get to promote!
 						var move Move
 						move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(currBishopIdx)).Setpromote(Queen)
-						*moveList = append(*moveList, move)
+						list.add(move, Pawn, pinnerType, 0)
 					} else { // no promotion
 						var move Move
 						move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(currBishopIdx))
-						*moveList = append(*moveList, move)
+						list.add(move, Pawn, pinnerType, 0)
 					}
 				}
 			}
@@ -233,14 +506,18 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 		// actually available moves
 		pinnedTargets := pinnedPieceAllMoves & (bishopTargets | kingDiagTargets | (uint64(1) << currBishopIdx))
 		pinnedTargets &= allowDest
-		genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
+		pinnedType := Piece(Queen)
+		if pinnedPiece&ourPieces.Bishops != 0 {
+			pinnedType = Bishop
+		}
+		b.addTargets(list, Square(pinnedPieceIdx), pinnedType, pinnedTargets)
 	}
 	return allPinnedPieces
 }
 
 // Generate moves involving advancing pawns.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) pawnPushes(list moveList, nonpinned uint64, allowDest uint64) {
 	targets, doubleTargets := b.pawnPushBitboards(nonpinned)
 	targets, doubleTargets = targets&allowDest, doubleTargets&allowDest
 	oneRankBack := 8
@@ -263,9 +540,9 @@ func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64)
 			// Only checking queen promotions can lead to a minor speedup though it breaks tests
 			// It also saves some memory and branching factor in mcts which is handy
 			move.Setpromote(Queen)
-			*moveList = append(*moveList, move)
+			list.add(move, Pawn, Nothing, 0)
 		} else {
-			*moveList = append(*moveList, move)
+			list.add(move, Pawn, Nothing, 0)
 		}
 	}
 	// push some pawns by two squares
@@ -274,7 +551,7 @@ func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		doubleTargets &= doubleTargets - 1 // unset the lowest active bit
 		var move Move
 		move.Setfrom(Square(doubleTarget + 2*oneRankBack)).Setto(Square(doubleTarget))
-		*moveList = append(*moveList, move)
+		list.add(move, Pawn, Nothing, extDoublePushFlag)
 	}
 }
 
@@ -284,18 +561,33 @@ func (b *Board) pawnPushBitboards(nonpinned uint64) (targets uint64, doubleTarge
 	if b.Wtomove {
 		movableWhitePawns := b.White.Pawns & nonpinned
 		targets = movableWhitePawns << 8 & free
-		doubleTargets = targets << 8 & onlyRank[3] & free
+		doubleTargets = targets << 8 & b.doublePushRanks(true) & free
 	} else {
 		movableBlackPawns := b.Black.Pawns & nonpinned
 		targets = movableBlackPawns >> 8 & free
-		doubleTargets = targets >> 8 & onlyRank[4] & free
+		doubleTargets = targets >> 8 & b.doublePushRanks(false) & free
 	}
 	return
 }
 
+// The ranks that a side's pawns may reach with a double push: the fourth, and in
+// Horde also the third, from the first rank.
+func (b *Board) doublePushRanks(white bool) uint64 {
+	if white {
+		if b.Variant == Horde {
+			return onlyRank[2] | onlyRank[3]
+		}
+		return onlyRank[3]
+	}
+	if b.Variant == Horde {
+		return onlyRank[4] | onlyRank[5]
+	}
+	return onlyRank[4]
+}
+
 // A function that computes available pawn captures.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) pawnCaptures(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) pawnCaptures(list moveList, nonpinned uint64, allowDest uint64) {
 	east, west := b.pawnCaptureBitboards(nonpinned)
 	if b.Enpassant > 0 { // always allow us to try en-passant captures
 		allowDest = allowDest | 1<<b.Enpassant
@@ -349,13 +641,13 @@ func (b *Board) pawnCaptures(moveList *[]Move, nonpinned uint64, allowDest uint6
 				if kingInCheck {
 					continue
 				}
+				list.add(move, Pawn, Pawn, extEnpassantFlag)
+				continue
 			}
 			if canPromote {
 				move.Setpromote(Queen)
-				*moveList = append(*moveList, move)
-				continue
 			}
-			*moveList = append(*moveList, move)
+			list.add(move, Pawn, b.victim(list, uint8(target)), 0)
 		}
 	}
 }
@@ -386,7 +678,7 @@ func (b *Board) pawnCaptureBitboards(nonpinned uint64) (east uint64, west uint64
 
 // Generate all knight moves.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) knightMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) knightMoves(list moveList, nonpinned uint64, allowDest uint64) {
 	var ourKnights, noFriendlyPieces uint64
 	if b.Wtomove {
 		ourKnights = b.White.Knights & nonpinned
@@ -399,12 +691,12 @@ func (b *Board) knightMoves(moveList *[]Move, nonpinned uint64, allowDest uint64
 		currentKnight := bits.TrailingZeros64(ourKnights)
 		ourKnights &= ourKnights - 1
 		targets := knightMasks[currentKnight] & noFriendlyPieces & allowDest
-		genMovesFromTargets(moveList, Square(currentKnight), targets)
+		b.addTargets(list, Square(currentKnight), Knight, targets)
 	}
 }
 
 // Computes king moves without castling.
-func (b *Board) kingPushes(moveList *[]Move, ptrToOurBitboards *Bitboards) {
+func (b *Board) kingPushes(list moveList, ptrToOurBitboards *Bitboards) {
 	ourKingLocation := uint8(bits.TrailingZeros64(ptrToOurBitboards.Kings))
 	noFriendlyPieces := ^(ptrToOurBitboards.All)
 
@@ -415,19 +707,18 @@ func (b *Board) kingPushes(moveList *[]Move, ptrToOurBitboards *Bitboards) {
 	ptrToOurBitboards.Kings = 0
 	ptrToOurBitboards.All &= ^(uint64(1) << ourKingLocation)
 	targets := kingMasks[ourKingLocation] & noFriendlyPieces
+	var safeTargets uint64
 	for targets != 0 {
 		target := bits.TrailingZeros64(targets)
 		targets &= targets - 1
-		if b.UnderDirectAttack(b.Wtomove, uint8(target)) {
-			continue
+		if !b.UnderDirectAttack(b.Wtomove, uint8(target)) {
+			safeTargets |= uint64(1) << target
 		}
-		var move Move
-		move.Setfrom(Square(ourKingLocation)).Setto(Square(target))
-		*moveList = append(*moveList, move)
 	}
 
 	ptrToOurBitboards.Kings = oldKings
 	ptrToOurBitboards.All |= (1 << ourKingLocation)
+	b.addTargets(list, Square(ourKingLocation), King, safeTargets)
 }
 
 // Generate all available king moves.
@@ -435,7 +726,7 @@ func (b *Board) kingPushes(moveList *[]Move, ptrToOurBitboards *Bitboards) {
 // Then, outputs castling moves (if any), and king moves.
 // Not thread-safe, since the king is removed from the board to compute
 // king-danger squares.
-func (b *Board) kingMoves(moveList *[]Move) {
+func (b *Board) kingMoves(list moveList) {
 	// castling
 	var ourKingLocation uint8
 	var canCastleQueenside, canCastleKingside bool
@@ -466,21 +757,21 @@ func (b *Board) kingMoves(moveList *[]Move) {
 	if canCastleKingside {
 		var move Move
 		move.Setfrom(Square(ourKingLocation)).Setto(Square(ourKingLocation + 2))
-		*moveList = append(*moveList, move)
+		list.add(move, King, Nothing, extCastleFlag)
 	}
 	if canCastleQueenside {
 		var move Move
 		move.Setfrom(Square(ourKingLocation)).Setto(Square(ourKingLocation - 2))
-		*moveList = append(*moveList, move)
+		list.add(move, King, Nothing, extCastleFlag)
 	}
 
 	// non-castling
-	b.kingPushes(moveList, ptrToOurBitboards)
+	b.kingPushes(list, ptrToOurBitboards)
 }
 
 // Generate all rook moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) rookMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) rookMoves(list moveList, nonpinned uint64, allowDest uint64) {
 	var ourRooks, friendlyPieces uint64
 	if b.Wtomove {
 		ourRooks = b.White.Rooks & nonpinned
@@ -494,13 +785,13 @@ func (b *Board) rookMoves(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		currRook := uint8(bits.TrailingZeros64(ourRooks))
 		ourRooks &= ourRooks - 1
 		targets := CalculateRookMoveBitboard(currRook, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currRook), targets)
+		b.addTargets(list, Square(currRook), Rook, targets)
 	}
 }
 
 // Generate all bishop moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) bishopMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) bishopMoves(list moveList, nonpinned uint64, allowDest uint64) {
 	var ourBishops, friendlyPieces uint64
 	if b.Wtomove {
 		ourBishops = b.White.Bishops & nonpinned
@@ -514,13 +805,13 @@ func (b *Board) bishopMoves(moveList *[]Move, nonpinned uint64, allowDest uint64
 		currBishop := uint8(bits.TrailingZeros64(ourBishops))
 		ourBishops &= ourBishops - 1
 		targets := CalculateBishopMoveBitboard(currBishop, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currBishop), targets)
+		b.addTargets(list, Square(currBishop), Bishop, targets)
 	}
 }
 
 // Generate all queen moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) queenMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) queenMoves(list moveList, nonpinned uint64, allowDest uint64) {
 	var ourQueens, friendlyPieces uint64
 	if b.Wtomove {
 		ourQueens = b.White.Queens & nonpinned
@@ -535,13 +826,60 @@ func (b *Board) queenMoves(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		ourQueens &= ourQueens - 1
 		// bishop motion
 		diag_targets := CalculateBishopMoveBitboard(currQueen, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currQueen), diag_targets)
+		b.addTargets(list, Square(currQueen), Queen, diag_targets)
 		// rook motion
 		ortho_targets := CalculateRookMoveBitboard(currQueen, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currQueen), ortho_targets)
+		b.addTargets(list, Square(currQueen), Queen, ortho_targets)
 	}
 }
 
+// Where the generators add the moves they find: plain moves to moves or, if ext
+// is set, extended moves to ext. The generators know the moving piece, the
+// captured piece and the kind of move as they produce them, so extended moves
+// are built without probing the board again.
+type moveList struct {
+	moves *[]Move
+	ext   *[]ExtMove
+}
+
+// Adds a move, with its moving and captured pieces and flags for an extended move.
+func (list moveList) add(m Move, piece Piece, captured Piece, flags ExtMove) {
+	if list.ext != nil {
+		*list.ext = append(*list.ext, newExtMove(m, piece, captured)|flags)
+		return
+	}
+	*list.moves = append(*list.moves, m)
+}
+
+// Adds the moves of a piece from origin to each of the targets.
+func (b *Board) addTargets(list moveList, origin Square, piece Piece, targets uint64) {
+	if list.ext == nil {
+		genMovesFromTargets(list.moves, origin, targets)
+		return
+	}
+	for targets != 0 {
+		target := bits.TrailingZeros64(targets)
+		targets &= targets - 1
+		var move Move
+		move.Setfrom(origin).Setto(Square(target))
+		*list.ext = append(*list.ext, newExtMove(move, piece, b.victim(list, uint8(target))))
+	}
+}
+
+// The type of the opponent's piece on a target square, for extended moves. It is
+// only looked up for occupied squares, and not at all for plain moves.
+func (b *Board) victim(list moveList, target uint8) Piece {
+	oppPieces := &(b.Black)
+	if !b.Wtomove {
+		oppPieces = &(b.White)
+	}
+	if list.ext == nil || oppPieces.All&(uint64(1)<<target) == 0 {
+		return Nothing
+	}
+	captured, _ := determinePieceType(oppPieces, uint64(1)<<target)
+	return captured
+}
+
 // Helper: converts a targets bitboard into moves, and adds them to the moves list.
 func genMovesFromTargets(moveList *[]Move, origin Square, targets uint64) {
 	for targets != 0 {
@@ -565,13 +903,21 @@ func (b *Board) anyUnderDirectAttack(byBlack bool, squares ...uint8) bool {
 }
 
 func (b *Board) OurKingInCheck() bool {
+	switch b.Variant {
+	case Atomic:
+		return b.atomicInCheck()
+	case Antichess:
+		return false
+	}
 	byBlack := b.Wtomove
-	var origin uint8
+	ourKings := b.Black.Kings
 	if b.Wtomove {
-		origin = uint8(bits.TrailingZeros64(b.White.Kings))
-	} else {
-		origin = uint8(bits.TrailingZeros64(b.Black.Kings))
+		ourKings = b.White.Kings
+	}
+	if ourKings == 0 { // no king, as for white in Horde
+		return false
 	}
+	origin := uint8(bits.TrailingZeros64(ourKings))
 	count, _ := b.countAttacks(byBlack, origin, 1)
 	return count >= 1
 }
@@ -695,3 +1041,7 @@ func CalculateBishopMoveBitboard(currBishop uint8, allPieces uint64) uint64 {
 func CalculateKnightMoveBitboard(currKnight uint8) uint64 {
 	return knightMasks[int(currKnight)]
 }
+
+func CalculateKingMoveBitboard(currKing uint8) uint64 {
+	return kingMasks[int(currKing)]
+}
@@ -14,7 +14,7 @@ func TestPawnPushes(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.pawnPushes(&moves, everything, everything)
+		b.pawnPushes(moveList{moves: &moves}, everything, everything)
 		if len(moves) != v {
 			t.Error("Pawn pushes: wrong length. Expected", v, "but got",
 				len(moves), "for FEN", b.ToFen())
@@ -31,7 +31,7 @@ func TestPawnCaptures(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.pawnCaptures(&moves, everything, everything)
+		b.pawnCaptures(moveList{moves: &moves}, everything, everything)
 		if len(moves) != v {
 			t.Error("Pawn captures: wrong length. Expected", v, "but got",
 				len(moves), "for FEN", b.ToFen())
@@ -64,14 +64,14 @@ func TestKnightPosition0(t *testing.T) {
 	testboard := Board{White: whitepieces, Black: blackpieces, Wtomove: true}
 
 	moves := make([]Move, 0, 45)
-	testboard.knightMoves(&moves, everything, everything)
+	testboard.knightMoves(moveList{moves: &moves}, everything, everything)
 	if len(moves) != 20 {
 		t.Error("Knight moves: wrong length. Expected 20, got", len(moves))
 	}
 
 	testboard.Wtomove = false
 	moves2 := make([]Move, 0, 45)
-	testboard.knightMoves(&moves2, everything, everything)
+	testboard.knightMoves(moveList{moves: &moves2}, everything, everything)
 	if len(moves2) != 27 {
 		t.Error("Knight moves: wrong length. Expected 27, got", len(moves2))
 	}
@@ -91,7 +91,7 @@ func TestKingPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.kingMoves(&moves)
+		b.kingMoves(moveList{moves: &moves})
 		if len(moves) != v {
 			t.Error("King moves: wrong length. Expected", v, "but got",
 				len(moves), "\nFor position:", k)
@@ -113,7 +113,7 @@ func TestRookPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.rookMoves(&moves, everything, everything)
+		b.rookMoves(moveList{moves: &moves}, everything, everything)
 		if len(moves) != v {
 			t.Error("Rook moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -130,7 +130,7 @@ func TestBishopPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.bishopMoves(&moves, everything, everything)
+		b.bishopMoves(moveList{moves: &moves}, everything, everything)
 		if len(moves) != v {
 			t.Error("Bishop moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -147,7 +147,7 @@ func TestQueenPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.queenMoves(&moves, everything, everything)
+		b.queenMoves(moveList{moves: &moves}, everything, everything)
 		if len(moves) != v {
 			t.Error("Queen moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -231,7 +231,7 @@ func TestPinnedBishop(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		b.generatePinnedMoves(moveList{moves: &moves}, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -246,7 +246,7 @@ func TestPinnedKnight(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		b.generatePinnedMoves(moveList{moves: &moves}, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -261,7 +261,7 @@ func TestPinnedQueen(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		b.generatePinnedMoves(moveList{moves: &moves}, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -294,7 +294,7 @@ func TestDiagPins(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		result := b.generatePinnedMoves(&moves, everything)
+		result := b.generatePinnedMoves(moveList{moves: &moves}, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for diagonal pins: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -357,7 +357,7 @@ func TestOrthoPins(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		result := b.generatePinnedMoves(&moves, everything)
+		result := b.generatePinnedMoves(moveList{moves: &moves}, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for orthogonal pins: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 			printMoves(moves)
@@ -0,0 +1,281 @@
+package dragontoothmg
+
+import (
+	"sort"
+)
+
+// Piece values used for static exchange evaluation and MVV-LVA ordering,
+// indexed by Piece.
+var seePieceValues = [7]int{0, 100, 320, 330, 500, 900, 20000}
+
+// Move picker stages, in the order they are searched.
+const (
+	stageHashMove = iota
+	stageGenerateCaptures
+	stageGoodCaptures
+	stageKillers
+	stageCounterMove
+	stageGenerateQuiets
+	stageQuiets
+	stageBadCaptures
+	stageDone
+)
+
+// A MovePicker yields the legal moves of a position lazily, in an order suited to
+// alpha-beta search: the hash move, captures that do not lose material (by MVV-LVA),
+// killer moves, the counter move, quiet moves by history score, and finally captures
+// that lose material. Each stage is only generated once the previous one runs out,
+// so a cutoff on an early move avoids generating the rest.
+// The board must not be modified while the picker is in use.
+type MovePicker struct {
+	b           *Board
+	hashMove    Move
+	killers     [2]Move
+	counterMove Move
+	history     func(m Move) int
+	stage       int
+	moves       []Move
+	scores      []int
+	index       int
+	badCaptures []Move
+}
+
+// Creates a move picker for the given board. Any of hashMove, killers and
+// counterMove may be zero, and need not be legal; they are validated before use.
+// The history function scores quiet moves (higher is searched first); it may be nil.
+func NewMovePicker(b *Board, hashMove Move, killers [2]Move, counterMove Move,
+	history func(m Move) int) *MovePicker {
+	return &MovePicker{b: b, hashMove: hashMove, killers: killers,
+		counterMove: counterMove, history: history}
+}
+
+// Returns the next move to search, or 0 once all legal moves have been returned.
+func (mp *MovePicker) Next() Move {
+	for {
+		switch mp.stage {
+		case stageHashMove:
+			mp.stage++
+			if mp.b.IsLegal(mp.hashMove) {
+				return mp.hashMove
+			}
+		case stageGenerateCaptures:
+			mp.moves = mp.b.GenerateLegalCaptures()
+			mp.scores = mp.scores[:0]
+			for _, m := range mp.moves {
+				mp.scores = append(mp.scores, mp.b.mvvLva(m))
+			}
+			mp.index = 0
+			mp.stage++
+		case stageGoodCaptures:
+			for mp.index < len(mp.moves) {
+				m := mp.pickBest()
+				if m == mp.hashMove {
+					continue
+				}
+				if mp.b.SEE(m) < 0 {
+					mp.badCaptures = append(mp.badCaptures, m)
+					continue
+				}
+				return m
+			}
+			mp.stage++
+			mp.index = 0
+		case stageKillers:
+			for mp.index < len(mp.killers) {
+				m := mp.killers[mp.index]
+				mp.index++
+				if mp.isUsefulQuiet(m) && (mp.index == 1 || m != mp.killers[0]) {
+					return m
+				}
+			}
+			mp.stage++
+		case stageCounterMove:
+			mp.stage++
+			m := mp.counterMove
+			if mp.isUsefulQuiet(m) && m != mp.killers[0] && m != mp.killers[1] {
+				return m
+			}
+		case stageGenerateQuiets:
+			mp.moves = mp.b.GenerateLegalQuiets()
+			mp.scores = mp.scores[:0]
+			for _, m := range mp.moves {
+				score := 0
+				if mp.history != nil {
+					score = mp.history(m)
+				}
+				mp.scores = append(mp.scores, score)
+			}
+			mp.index = 0
+			mp.stage++
+		case stageQuiets:
+			for mp.index < len(mp.moves) {
+				m := mp.pickBest()
+				if m == mp.hashMove || m == mp.killers[0] || m == mp.killers[1] ||
+					m == mp.counterMove {
+					continue
+				}
+				return m
+			}
+			mp.stage++
+			mp.index = 0
+		case stageBadCaptures:
+			if mp.index < len(mp.badCaptures) {
+				mp.index++
+				return mp.badCaptures[mp.index-1]
+			}
+			mp.stage++
+		default:
+			return 0
+		}
+	}
+}
+
+// Selects the highest-scoring remaining move of the current stage, and moves it to
+// the front of the unsearched part of the list. Sorting lazily means a cutoff
+// early in the stage wastes very little work.
+func (mp *MovePicker) pickBest() Move {
+	best := mp.index
+	for i := mp.index + 1; i < len(mp.moves); i++ {
+		if mp.scores[i] > mp.scores[best] {
+			best = i
+		}
+	}
+	mp.moves[mp.index], mp.moves[best] = mp.moves[best], mp.moves[mp.index]
+	mp.scores[mp.index], mp.scores[best] = mp.scores[best], mp.scores[mp.index]
+	mp.index++
+	return mp.moves[mp.index-1]
+}
+
+// Whether a killer or counter move should be tried in its own stage: it must be a
+// legal quiet move that was not already returned as the hash move.
+func (mp *MovePicker) isUsefulQuiet(m Move) bool {
+	if m == 0 || m == mp.hashMove || m.Promote() != Nothing || IsCapture(m, mp.b) {
+		return false
+	}
+	return mp.b.IsLegal(m)
+}
+
+// Most valuable victim, least valuable attacker score for a capture or promotion.
+func (b *Board) mvvLva(m Move) int {
+	victim, _ := GetPieceType(m.To(), b)
+	if victim == Nothing && IsCapture(m, b) { // en passant
+		victim = Pawn
+	}
+	attacker, _ := GetPieceType(m.From(), b)
+	if m.IsDrop() {
+		attacker = int(m.Drop())
+	}
+	return seePieceValues[victim]*8 + seePieceValues[m.Promote()] - attacker
+}
+
+// Sorts moves in place by descending MVV-LVA score. Moves must be legal for this board.
+// Useful for ordering captures in quiescence search.
+func (b *Board) SortMvvLva(moves []Move) {
+	sort.SliceStable(moves, func(i, j int) bool {
+		return b.mvvLva(moves[i]) > b.mvvLva(moves[j])
+	})
+}
+
+// Returns a bitboard of all pieces (of both colors) attacking a square, given the
+// occupancy. Pieces not in the occupancy bitboard are ignored.
+func (b *Board) attackersTo(square uint8, occupancy uint64) uint64 {
+	var whitePawnAttackers, blackPawnAttackers uint64
+	squareBitboard := uint64(1) << square
+	// A white pawn attacks the square if it is one rank below, diagonally. Shifting
+	// by 7 finds the pawn one file to the right, and by 9 the pawn one file to the
+	// left (the other way round for black), so the file the shift would wrap onto
+	// is masked off.
+	whitePawnAttackers = (squareBitboard>>7)&^onlyFile[0] | (squareBitboard>>9)&^onlyFile[7]
+	blackPawnAttackers = (squareBitboard<<7)&^onlyFile[7] | (squareBitboard<<9)&^onlyFile[0]
+	diagonal := CalculateBishopMoveBitboard(square, occupancy)
+	orthogonal := CalculateRookMoveBitboard(square, occupancy)
+	attackers := whitePawnAttackers&b.White.Pawns | blackPawnAttackers&b.Black.Pawns
+	attackers |= knightMasks[square] & (b.White.Knights | b.Black.Knights)
+	attackers |= kingMasks[square] & (b.White.Kings | b.Black.Kings)
+	attackers |= diagonal & (b.White.Bishops | b.Black.Bishops | b.White.Queens | b.Black.Queens)
+	attackers |= orthogonal & (b.White.Rooks | b.Black.Rooks | b.White.Queens | b.Black.Queens)
+	return attackers & occupancy
+}
+
+// Static exchange evaluation: the material balance (in centipawns, for the side to
+// move) of the sequence of captures on the destination square of m, assuming both
+// sides always recapture with their least valuable piece and may stop at any time.
+// Pins are ignored. Non-captures return the result of the opponent's best
+// sequence of captures on the destination square, i.e. zero or negative.
+func (b *Board) SEE(m Move) int {
+	from, to := m.From(), m.To()
+	occupancy := b.White.All | b.Black.All
+	var gain [32]int
+	attackerType, _ := GetPieceType(from, b)
+	victimType, _ := GetPieceType(to, b)
+	if m.IsDrop() {
+		attackerType = int(m.Drop())
+	} else if attackerType == Pawn && to == b.Enpassant && b.Enpassant != 0 {
+		victimType = Pawn
+		if b.Wtomove {
+			occupancy &^= uint64(1) << (to - 8)
+		} else {
+			occupancy &^= uint64(1) << (to + 8)
+		}
+	}
+	gain[0] = seePieceValues[victimType]
+	onSquare := seePieceValues[attackerType] // value of the piece that can be captured next
+	if m.Promote() != Nothing {
+		gain[0] += seePieceValues[m.Promote()] - seePieceValues[Pawn]
+		onSquare = seePieceValues[m.Promote()]
+	}
+	if !m.IsDrop() {
+		occupancy &^= uint64(1) << from
+	}
+	attackers := b.attackersTo(to, occupancy)
+	wtomove := !b.Wtomove
+	depth := 0
+	for {
+		var sidePieces *Bitboards
+		if wtomove {
+			sidePieces = &(b.White)
+		} else {
+			sidePieces = &(b.Black)
+		}
+		sideAttackers := attackers & sidePieces.All
+		if sideAttackers == 0 {
+			break
+		}
+		// find the least valuable attacker
+		var attackerBitboard uint64
+		var nextType Piece
+		for piece := Piece(Pawn); piece <= King; piece++ {
+			candidates := sideAttackers & *pieceBitboardPtr(sidePieces, piece)
+			if candidates != 0 {
+				attackerBitboard = candidates & -candidates
+				nextType = piece
+				break
+			}
+		}
+		// The king may only capture if the square is no longer defended.
+		if nextType == King && attackers&^sidePieces.All != 0 {
+			break
+		}
+		depth++
+		gain[depth] = onSquare - gain[depth-1]
+		onSquare = seePieceValues[nextType]
+		occupancy &^= attackerBitboard
+		// Removing the attacker may reveal a slider behind it.
+		attackers |= CalculateBishopMoveBitboard(to, occupancy) &
+			(b.White.Bishops | b.Black.Bishops | b.White.Queens | b.Black.Queens)
+		attackers |= CalculateRookMoveBitboard(to, occupancy) &
+			(b.White.Rooks | b.Black.Rooks | b.White.Queens | b.Black.Queens)
+		attackers &= occupancy
+		wtomove = !wtomove
+		if depth == len(gain)-1 {
+			break
+		}
+	}
+	// Either side may decline to continue the exchange.
+	for ; depth > 0; depth-- {
+		if -gain[depth] < gain[depth-1] {
+			gain[depth-1] = -gain[depth]
+		}
+	}
+	return gain[0]
+}
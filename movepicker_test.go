@@ -0,0 +1,136 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+var movePickerPositions = []string{
+	Startpos,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 0",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"r3k3/1ppp1ppr/8/3Pp3/8/8/1PP1PPPP/R3K2R w - e6 3 0",
+	"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+	"4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1",
+	"4k3/8/8/8/1b6/8/3P4/r3K3 w - - 0 1",
+	"4k3/8/8/8/8/8/p7/1N2K3 b - - 0 1",
+}
+
+// Captures and quiets must partition the full legal move list.
+func TestCapturesAndQuiets(t *testing.T) {
+	for _, fen := range movePickerPositions {
+		b := ParseFen(fen)
+		all := make(map[Move]bool)
+		for _, m := range b.GenerateLegalMoves() {
+			all[m] = true
+		}
+		seen := make(map[Move]bool)
+		for i, moves := range [][]Move{b.GenerateLegalCaptures(), b.GenerateLegalQuiets()} {
+			for _, m := range moves {
+				if !all[m] || seen[m] {
+					t.Error("Unexpected or duplicate move", &m, "in", fen)
+				}
+				seen[m] = true
+				noisy := IsCapture(m, &b) || m.Promote() != Nothing
+				if noisy != (i == 0) {
+					t.Error("Move", &m, "generated in the wrong category in", fen)
+				}
+			}
+		}
+		if len(seen) != len(all) {
+			t.Error("Captures and quiets are missing moves in", fen)
+		}
+		if b.ToFen() != fen {
+			t.Error("Move generation corrupted board state for", fen)
+		}
+	}
+}
+
+func TestMovePickerOrder(t *testing.T) {
+	for _, fen := range movePickerPositions {
+		b := ParseFen(fen)
+		legal := b.GenerateLegalMoves()
+		all := make(map[Move]bool)
+		for _, m := range legal {
+			all[m] = true
+		}
+		quiets := b.GenerateLegalQuiets()
+		var hashMove, killer, counter Move
+		if len(quiets) > 2 {
+			hashMove, killer, counter = quiets[len(quiets)-1], quiets[0], quiets[1]
+		}
+		// an illegal killer must be ignored
+		mp := NewMovePicker(&b, hashMove, [2]Move{killer, parseMove("a3a4")}, counter,
+			func(m Move) int { return int(m.To()) })
+		seen := make(map[Move]bool)
+		var order []Move
+		for m := mp.Next(); m != 0; m = mp.Next() {
+			if !all[m] || seen[m] {
+				t.Error("Move picker returned unexpected or duplicate move", &m, "in", fen)
+			}
+			seen[m] = true
+			order = append(order, m)
+		}
+		if len(order) != len(legal) {
+			t.Error("Move picker returned", len(order), "moves, expected", len(legal), "in", fen)
+			continue
+		}
+		if hashMove != 0 && order[0] != hashMove {
+			t.Error("Move picker did not return the hash move first in", fen)
+		}
+		// quiet moves (other than killers and the counter move) are in history order
+		lastScore := 64
+		for _, m := range order {
+			if IsCapture(m, &b) || m.Promote() != Nothing || m == hashMove || m == killer || m == counter {
+				continue
+			}
+			if int(m.To()) > lastScore {
+				t.Error("Quiet moves are not sorted by history in", fen)
+			}
+			lastScore = int(m.To())
+		}
+	}
+}
+
+// A push to a1 captures nothing, though a1 is square zero like an unset en passant target.
+func TestMvvLvaPromotion(t *testing.T) {
+	b := ParseFen("4k3/8/8/8/8/8/p7/1N2K3 b - - 0 1")
+	if score := b.mvvLva(parseMove("a2a1q")); score != seePieceValues[Queen]-Pawn {
+		t.Error("Expected a2a1q to score as a promotion only, got", score)
+	}
+	if b.mvvLva(parseMove("a2b1q")) <= b.mvvLva(parseMove("a2a1q")) {
+		t.Error("Expected a2b1q to score above a2a1q")
+	}
+}
+
+func TestSEE(t *testing.T) {
+	tests := map[string]map[string]int{
+		// undefended pawn
+		"4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1": {"d1d5": 100},
+		// pawn defended by a pawn
+		"4k3/8/4p3/3p4/8/8/8/3RK3 w - - 0 1": {"d1d5": 100 - 500},
+		// rook behind rook x-ray
+		"3rk3/3r4/8/3p4/8/8/3R4/3RK3 w - - 0 1": {"d2d5": 100 - 500 + 500 - 500},
+		// quiet moves onto safe and attacked squares
+		"4k3/8/8/8/8/3p4/8/2N4K w - - 0 1": {"c1e2": -320, "c1a2": 0},
+		// en passant
+		"4k3/8/8/3Pp3/8/8/8/4K3 w - e6 0 1": {"d5e6": 100},
+		// pawns defending from the edge files
+		"6rk/8/8/8/6N1/7P/8/K7 b - - 0 1":    {"g8g4": 320 - 500},
+		"4k3/8/p7/1p6/8/8/8/1R2K3 w - - 0 1": {"b1b5": 100 - 500},
+		"4k3/8/7p/6p1/8/8/8/4K1R1 w - - 0 1": {"g1g5": 100 - 500},
+		// pawns that would defend only by wrapping around the board
+		"k6r/8/8/8/P6N/8/8/K7 b - - 0 1":   {"h8h4": 320},
+		"4k3/8/8/p6p/8/8/8/R3K3 w - - 0 1": {"a1a5": 100},
+		"4k3/8/p7/8/7p/8/8/4K2R w - - 0 1": {"h1h4": 100},
+	}
+	for fen, moves := range tests {
+		b := ParseFen(fen)
+		for str, expected := range moves {
+			if see := b.SEE(parseMove(str)); see != expected {
+				t.Error("SEE of", str, "in", fen, "was", see, "expected", expected)
+			}
+		}
+	}
+}
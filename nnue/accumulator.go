@@ -0,0 +1,116 @@
+package nnue
+
+import (
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The hidden layer of a network from both perspectives, for one position. It is
+// updated incrementally as moves are applied, and must be copied (with Copy) to
+// be restored when moves are taken back.
+type Accumulator struct {
+	net      *Network
+	values   [2][]int16 // white's perspective, then black's
+	features []int      // scratch space for Refresh
+}
+
+// Creates an accumulator for the network. It must be refreshed before use.
+func (n *Network) NewAccumulator() *Accumulator {
+	return &Accumulator{net: n, values: [2][]int16{make([]int16, n.Hidden), make([]int16, n.Hidden)}}
+}
+
+// Sets the accumulator to a copy of another one of the same network.
+func (a *Accumulator) Copy(from *Accumulator) {
+	copy(a.values[0], from.values[0])
+	copy(a.values[1], from.values[1])
+}
+
+// Recomputes the accumulator for the board from scratch.
+func (a *Accumulator) Refresh(b *dragontoothmg.Board) {
+	a.refresh(b, true)
+	a.refresh(b, false)
+}
+
+func (a *Accumulator) refresh(b *dragontoothmg.Board, white bool) {
+	values := a.perspective(white)
+	copy(values, a.net.FeatureBias)
+	a.features = a.net.Features.Features(b, white, a.features[:0])
+	for _, index := range a.features {
+		a.add(values, index)
+	}
+}
+
+// Updates the accumulator for a move, given the board after the move and the
+// delta from Apply. A perspective whose king moved is refreshed.
+func (a *Accumulator) Update(b *dragontoothmg.Board, d *Delta) {
+	for _, white := range [2]bool{true, false} {
+		if d.KingMoved(white) {
+			a.refresh(b, white)
+			continue
+		}
+		ourKings := b.White.Kings
+		if !white {
+			ourKings = b.Black.Kings
+		}
+		if ourKings == 0 {
+			continue
+		}
+		king := kingSquare(ourKings)
+		values := a.perspective(white)
+		for _, p := range d.Removed {
+			if index, ok := a.net.Features.Index(white, king, p); ok {
+				a.sub(values, index)
+			}
+		}
+		for _, p := range d.Added {
+			if index, ok := a.net.Features.Index(white, king, p); ok {
+				a.add(values, index)
+			}
+		}
+	}
+}
+
+// Runs the output layer, giving the evaluation in centipawns from the point of
+// view of the side to move.
+func (a *Accumulator) Evaluate(wtomove bool) int {
+	us, them := a.values[0], a.values[1]
+	if !wtomove {
+		us, them = them, us
+	}
+	hidden := a.net.Hidden
+	weights := a.net.OutputWeights
+	sum := 0
+	for i := 0; i < hidden; i++ {
+		sum += clippedReLU(us[i])*int(weights[i]) + clippedReLU(them[i])*int(weights[hidden+i])
+	}
+	return (sum + int(a.net.OutputBias)) * evalScale / (activationMax * outputScale)
+}
+
+func (a *Accumulator) perspective(white bool) []int16 {
+	if white {
+		return a.values[0]
+	}
+	return a.values[1]
+}
+
+func (a *Accumulator) add(values []int16, index int) {
+	weights := a.net.FeatureWeights[index*a.net.Hidden : (index+1)*a.net.Hidden]
+	for i, w := range weights {
+		values[i] += w
+	}
+}
+
+func (a *Accumulator) sub(values []int16, index int) {
+	weights := a.net.FeatureWeights[index*a.net.Hidden : (index+1)*a.net.Hidden]
+	for i, w := range weights {
+		values[i] -= w
+	}
+}
+
+func clippedReLU(x int16) int {
+	if x < 0 {
+		return 0
+	} else if x > activationMax {
+		return activationMax
+	}
+	return int(x)
+}
@@ -0,0 +1,156 @@
+// Package nnue evaluates positions with efficiently updatable neural networks.
+//
+// A position is seen from each side's perspective as a sparse set of active
+// input features, which depend on the position of that side's king. The first
+// layer's output for each perspective, the accumulator, is the sum of the
+// weights of the active features. A move only changes a few features, so the
+// accumulator is updated by adding and subtracting a few weight rows, unless
+// the perspective's own king moved.
+package nnue
+
+import (
+	"math/bits"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A set of input features.
+type FeatureSet uint8
+
+const (
+	// A feature for each square of our king and each piece other than a king,
+	// with its color and square. Black's perspective is rotated by 180 degrees.
+	// Indices are as in the nnue-pytorch trainer, including the unused first
+	// feature of each king square.
+	HalfKP FeatureSet = iota
+	// As HalfKP, but with features for both kings. Black's perspective is
+	// flipped vertically.
+	HalfKA
+)
+
+func (fs FeatureSet) String() string {
+	if fs == HalfKA {
+		return "HalfKA"
+	}
+	return "HalfKP"
+}
+
+// The number of features.
+func (fs FeatureSet) Size() int {
+	if fs == HalfKA {
+		return 64 * 12 * 64
+	}
+	return 64 * 641
+}
+
+// A piece on a square.
+type PieceSquare struct {
+	Piece  dragontoothmg.Piece
+	White  bool
+	Square uint8
+}
+
+// The index of a piece's feature from a perspective, given that perspective's
+// king square. Returns false if the piece has no feature.
+func (fs FeatureSet) Index(white bool, king uint8, p PieceSquare) (int, bool) {
+	index := int(p.Piece-1) * 2
+	if p.White != white {
+		index++
+	}
+	switch fs {
+	case HalfKA:
+		if !white {
+			king, p.Square = king^56, p.Square^56
+		}
+		return int(king)*768 + index*64 + int(p.Square), true
+	default:
+		if p.Piece == dragontoothmg.King {
+			return 0, false
+		}
+		if !white {
+			king, p.Square = king^63, p.Square^63
+		}
+		return int(king)*641 + 1 + index*64 + int(p.Square), true
+	}
+}
+
+// Appends the indices of the active features of the board from a perspective.
+// A side without a king, as in Horde, has no active features.
+func (fs FeatureSet) Features(b *dragontoothmg.Board, white bool, features []int) []int {
+	ourKings := b.White.Kings
+	if !white {
+		ourKings = b.Black.Kings
+	}
+	if ourKings == 0 {
+		return features
+	}
+	king := kingSquare(ourKings)
+	for color, side := range [2]*dragontoothmg.Bitboards{&(b.White), &(b.Black)} {
+		for piece, bitboard := range pieceBitboards(side) {
+			for ; bitboard != 0; bitboard &= bitboard - 1 {
+				p := PieceSquare{dragontoothmg.Piece(piece + 1), color == 0, uint8(bits.TrailingZeros64(bitboard))}
+				if index, ok := fs.Index(white, king, p); ok {
+					features = append(features, index)
+				}
+			}
+		}
+	}
+	return features
+}
+
+// The pieces a move adds to and removes from the board. A piece that moves is
+// removed from one square and added to another.
+type Delta struct {
+	Added, Removed []PieceSquare
+}
+
+// Whether the move moved or removed a king of the side, which changes all of
+// the features from its perspective.
+func (d *Delta) KingMoved(white bool) bool {
+	for _, p := range d.Removed {
+		if p.Piece == dragontoothmg.King && p.White == white {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies a move to the board, and sets the delta to the pieces it added and
+// removed: the moving piece, a captured piece (also en passant), a promoted
+// piece, the rook of a castling move, and in variants, dropped and exploded
+// pieces. The delta's slices are reused.
+func Apply(b *dragontoothmg.Board, m dragontoothmg.Move, d *Delta) {
+	before := *b
+	b.Apply(m)
+	d.Added, d.Removed = d.Added[:0], d.Removed[:0]
+	for color := 0; color < 2; color++ {
+		beforeSide, afterSide := &(before.White), &(b.White)
+		if color == 1 {
+			beforeSide, afterSide = &(before.Black), &(b.Black)
+		}
+		if beforeSide.All == afterSide.All {
+			continue // none of its pieces moved, appeared or left
+		}
+		afterBitboards := pieceBitboards(afterSide)
+		for piece, beforeBitboard := range pieceBitboards(beforeSide) {
+			for removed := beforeBitboard &^ afterBitboards[piece]; removed != 0; removed &= removed - 1 {
+				d.Removed = append(d.Removed,
+					PieceSquare{dragontoothmg.Piece(piece + 1), color == 0, uint8(bits.TrailingZeros64(removed))})
+			}
+			for added := afterBitboards[piece] &^ beforeBitboard; added != 0; added &= added - 1 {
+				d.Added = append(d.Added,
+					PieceSquare{dragontoothmg.Piece(piece + 1), color == 0, uint8(bits.TrailingZeros64(added))})
+			}
+		}
+	}
+}
+
+// The square of the king on a bitboard.
+func kingSquare(kings uint64) uint8 {
+	return uint8(bits.TrailingZeros64(kings))
+}
+
+// The bitboards of a side, indexed by Piece minus one.
+func pieceBitboards(side *dragontoothmg.Bitboards) [6]uint64 {
+	return [6]uint64{side.Pawns, side.Knights, side.Bishops, side.Rooks, side.Queens, side.Kings}
+}
@@ -0,0 +1,116 @@
+package nnue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The quantization of the network: accumulator values are clipped to
+// [0, activationMax], output weights are scaled by outputScale, and the output
+// is scaled to centipawns by evalScale.
+const (
+	activationMax = 255
+	outputScale   = 64
+	evalScale     = 400
+)
+
+// A network with one hidden layer per perspective and a single output. The
+// hidden layers of the side to move and of the other side are clipped, and feed
+// the output together:
+//
+//	features (per perspective) -> Hidden -> clipped ReLU -> (2 x Hidden) -> 1
+//
+// Weights are quantized: feature weights and biases by activationMax, and
+// output weights by outputScale, so the evaluation in centipawns is
+// (output + OutputBias) * 400 / (255 * 64).
+type Network struct {
+	Features       FeatureSet
+	Hidden         int
+	FeatureWeights []int16 // by feature, then by hidden unit
+	FeatureBias    []int16
+	OutputWeights  []int16 // the side to move's hidden units first
+	OutputBias     int32
+}
+
+// A network file holds the magic, the feature set as one byte, and the number
+// of hidden units as a 32-bit number. Then come the feature weights, the
+// feature biases and the output weights as 16-bit numbers, and the output bias
+// as a 32-bit number. Numbers are little-endian.
+var fileMagic = []byte("DTNN")
+
+var errCorrupt = errors.New("Corrupt network file.")
+
+// The largest number of hidden units Read accepts.
+const maxHidden = 4096
+
+// Creates a network with zero weights, to be trained or filled in.
+func NewNetwork(features FeatureSet, hidden int) *Network {
+	return &Network{
+		Features:       features,
+		Hidden:         hidden,
+		FeatureWeights: make([]int16, features.Size()*hidden),
+		FeatureBias:    make([]int16, hidden),
+		OutputWeights:  make([]int16, 2*hidden),
+	}
+}
+
+// Reads a network written by WriteTo.
+func Read(r io.Reader) (*Network, error) {
+	br := bufio.NewReader(r)
+	var header struct {
+		Magic    [4]byte
+		Features FeatureSet
+		Hidden   uint32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, errCorrupt
+	}
+	if string(header.Magic[:]) != string(fileMagic) || header.Features > HalfKA ||
+		header.Hidden == 0 || header.Hidden > maxHidden {
+		return nil, errCorrupt
+	}
+	hidden := int(header.Hidden)
+	n := &Network{Features: header.Features, Hidden: hidden}
+	// The feature weights are read a row at a time, so that a short file fails
+	// before they are all allocated.
+	row := make([]int16, hidden)
+	for i := 0; i < n.Features.Size(); i++ {
+		if err := binary.Read(br, binary.LittleEndian, row); err != nil {
+			return nil, errCorrupt
+		}
+		n.FeatureWeights = append(n.FeatureWeights, row...)
+	}
+	n.FeatureBias, n.OutputWeights = make([]int16, hidden), make([]int16, 2*hidden)
+	for _, data := range []interface{}{n.FeatureBias, n.OutputWeights, &n.OutputBias} {
+		if err := binary.Read(br, binary.LittleEndian, data); err != nil {
+			return nil, errCorrupt
+		}
+	}
+	return n, nil
+}
+
+// Writes the network to a file that Read can load.
+func (n *Network) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.Write(fileMagic)
+	bw.WriteByte(byte(n.Features))
+	binary.Write(bw, binary.LittleEndian, uint32(n.Hidden))
+	for _, data := range []interface{}{n.FeatureWeights, n.FeatureBias, n.OutputWeights, n.OutputBias} {
+		binary.Write(bw, binary.LittleEndian, data)
+	}
+	size := int64(len(fileMagic) + 1 + 4 + 2*len(n.FeatureWeights) + 2*len(n.FeatureBias) + 2*len(n.OutputWeights) + 4)
+	return size, bw.Flush()
+}
+
+// Evaluates the board from scratch, in centipawns from the side to move's point
+// of view. Implements dragontoothmg.Evaluator. During a search, keeping an
+// Accumulator up to date is faster.
+func (n *Network) Evaluate(b *dragontoothmg.Board) int {
+	acc := n.NewAccumulator()
+	acc.Refresh(b)
+	return acc.Evaluate(b.Wtomove)
+}
@@ -0,0 +1,180 @@
+package nnue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+var _ dragontoothmg.Evaluator = &Network{}
+
+func randomNetwork(features FeatureSet, hidden int, r *rand.Rand) *Network {
+	n := NewNetwork(features, hidden)
+	for i := range n.FeatureWeights {
+		n.FeatureWeights[i] = int16(r.Intn(64) - 32)
+	}
+	for i := range n.FeatureBias {
+		n.FeatureBias[i] = int16(r.Intn(256))
+	}
+	for i := range n.OutputWeights {
+		n.OutputWeights[i] = int16(r.Intn(128) - 64)
+	}
+	n.OutputBias = int32(r.Intn(2000) - 1000)
+	return n
+}
+
+func TestIndex(t *testing.T) {
+	pawn := PieceSquare{dragontoothmg.Pawn, true, 12} // e2
+	tests := []struct {
+		features FeatureSet
+		white    bool
+		king     uint8
+		expected int
+	}{
+		{HalfKP, true, 4, 4*641 + 1 + 12},        // king e1
+		{HalfKP, false, 60, 3*641 + 1 + 64 + 51}, // king e8, rotated to d1
+		{HalfKA, true, 4, 4*768 + 12},            // king e1
+		{HalfKA, false, 60, 4*768 + 64 + 52},     // king e8, flipped to e1
+	}
+	for _, test := range tests {
+		if index, ok := test.features.Index(test.white, test.king, pawn); !ok || index != test.expected {
+			t.Error("Expected", test.expected, "for", test.features, test.white, "but got", index)
+		}
+	}
+	if _, ok := HalfKP.Index(true, 4, PieceSquare{dragontoothmg.King, false, 60}); ok {
+		t.Error("Expected no HalfKP feature for a king")
+	}
+	b := dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	if n := len(HalfKP.Features(&b, true, nil)); n != 30 {
+		t.Error("Expected 30 HalfKP features in the starting position, got", n)
+	}
+	if n := len(HalfKA.Features(&b, false, nil)); n != 32 {
+		t.Error("Expected 32 HalfKA features in the starting position, got", n)
+	}
+}
+
+// Plays random games, checking that the updated accumulator always matches a
+// refreshed one.
+func TestUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	positions := []struct {
+		fen     string
+		variant dragontoothmg.Variant
+	}{
+		{dragontoothmg.Startpos, dragontoothmg.Standard},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", dragontoothmg.Standard},
+		{"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", dragontoothmg.Standard},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", dragontoothmg.Standard},
+		{dragontoothmg.Atomic.Startpos(), dragontoothmg.Atomic},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R[QPPbn] w KQkq - 0 1", dragontoothmg.Crazyhouse},
+		{dragontoothmg.Horde.Startpos(), dragontoothmg.Horde},
+	}
+	for _, features := range []FeatureSet{HalfKP, HalfKA} {
+		n := randomNetwork(features, 8, r)
+		updated, refreshed := n.NewAccumulator(), n.NewAccumulator()
+		var d Delta
+		for _, pos := range positions {
+			for game := 0; game < 10; game++ {
+				b := dragontoothmg.ParseVariantFen(pos.fen, pos.variant)
+				updated.Refresh(&b)
+				for ply := 0; ply < 40; ply++ {
+					moves := b.GenerateLegalMoves()
+					if len(moves) == 0 {
+						break
+					}
+					m := moves[r.Intn(len(moves))]
+					Apply(&b, m, &d)
+					updated.Update(&b, &d)
+					refreshed.Refresh(&b)
+					if updated.Evaluate(b.Wtomove) != refreshed.Evaluate(b.Wtomove) ||
+						!equal(updated.values, refreshed.values) {
+						t.Fatal("The accumulator is out of date after", m.String(), "in", b.ToFen(), "with", features)
+					}
+				}
+			}
+		}
+	}
+}
+
+func equal(a, b [2][]int16) bool {
+	for i := range a {
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		fen            string
+		move           string
+		added, removed int
+	}{
+		{dragontoothmg.Startpos, "e2e4", 1, 1},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", 2, 2}, // castling
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 1, 2},    // en passant
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", 1, 2},    // promotion with a capture
+	}
+	for _, test := range tests {
+		b := dragontoothmg.ParseFen(test.fen)
+		m, _ := dragontoothmg.ParseMove(test.move)
+		var d Delta
+		Apply(&b, m, &d)
+		if len(d.Added) != test.added || len(d.Removed) != test.removed {
+			t.Error("Expected", test.added, "added and", test.removed, "removed after", test.move, "in",
+				test.fen, "but got", d.Added, d.Removed)
+		}
+	}
+}
+
+func TestNetworkFile(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	n := randomNetwork(HalfKP, 16, r)
+	var buf bytes.Buffer
+	written, err := n.WriteTo(&buf)
+	if err != nil || written != int64(buf.Len()) {
+		t.Fatal("Expected to write", buf.Len(), "bytes but got", written, err)
+	}
+	data := buf.Bytes()
+	read, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := dragontoothmg.ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if read.Evaluate(&b) != n.Evaluate(&b) || read.OutputBias != n.OutputBias {
+		t.Error("Expected the same evaluation from the network read back")
+	}
+	if _, err := Read(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Error("Expected an error reading a truncated network")
+	}
+
+	// A header promising a large network must not allocate it before the data
+	// is there.
+	for _, hidden := range []uint32{maxHidden, maxHidden + 1, 1 << 31} {
+		header := append(append([]byte{}, fileMagic...), byte(HalfKA))
+		header = binary.LittleEndian.AppendUint32(header, hidden)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := Read(bytes.NewReader(header)); err == nil {
+			t.Error("Expected an error reading a header without weights, for", hidden, "hidden units")
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Error("Reading a header for", hidden, "hidden units allocated", allocated, "bytes")
+		}
+	}
+
+	// With only an output bias, the evaluation is the bias scaled to centipawns.
+	n = NewNetwork(HalfKA, 4)
+	n.OutputBias = activationMax * outputScale
+	if score := n.Evaluate(&b); score != 400 {
+		t.Error("Expected 400 but got", score)
+	}
+}
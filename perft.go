@@ -14,8 +14,9 @@ func Perft(b Board, n int) int64 {
 	}
 	var count int64 = 0
 	for _, move := range moves {
-		b.Apply(move)
-		count += Perft(b, n-1)
+		next := b
+		next.Apply(move)
+		count += Perft(next, n-1)
 	}
 	return int64(count)
 }
@@ -24,8 +25,9 @@ func Perft(b Board, n int) int64 {
 func Divide(b Board, n int) {
 	moves := b.GenerateLegalMoves()
 	for _, move := range moves {
-		b.Apply(move)
-		result := Perft(b, n-1)
+		next := b
+		next.Apply(move)
+		result := Perft(next, n-1)
 		fmt.Printf( /*"Move   #%3d:   "*/ "%-6s =%9d\n" /*i+1, */, &move, result)
 	}
 }
@@ -10,7 +10,7 @@ import (
 
 func testDivide(t *testing.T) {
 	b := ParseFen("nqn5/P1Pk4/8/8/8/6K1/7p/5N2 w - - 0 1")
-	Divide(&b, 1)
+	Divide(b, 1)
 }
 
 // Uncomment lines in the solution maps for more thorough testing, although this takes longer
@@ -122,7 +122,7 @@ func checkPerftResults(fen string, perftSolutions map[int]int64, t *testing.T) {
 	b := ParseFen(fen)
 	for i := 1; i <= len(perftSolutions); i++ {
 		beforeFen := b.ToFen()
-		result := Perft(&b, i)
+		result := Perft(b, i)
 		afterFen := b.ToFen()
 		if beforeFen != afterFen {
 			t.Error("Perft corrupted board state.")
@@ -0,0 +1,244 @@
+package dragontoothmg
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// The symbols of the pieces, indexed by Piece, for white and then for black.
+var asciiPieces = [2][7]string{{".", "P", "N", "B", "R", "Q", "K"}, {".", "p", "n", "b", "r", "q", "k"}}
+var unicodePieces = [2][7]string{{"·", "♙", "♘", "♗", "♖", "♕", "♔"}, {"·", "♟", "♞", "♝", "♜", "♛", "♚"}}
+
+// Draws the board as text, with white at the bottom, followed by its FEN:
+//
+//	8 r n b q k b n r
+//	7 p p p p p p p p
+//	6 . . . . . . . .
+//	  ...
+//	1 R N B Q K B N R
+//	  a b c d e f g h
+//	rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1
+//
+// It has a value receiver, so that boards print as diagrams with fmt and in
+// test failures.
+func (b Board) String() string {
+	return b.diagram(&asciiPieces)
+}
+
+// Draws the board as String does, with Unicode chess symbols.
+func (b *Board) UnicodeString() string {
+	return b.diagram(&unicodePieces)
+}
+
+func (b *Board) diagram(symbols *[2][7]string) string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		sb.WriteByte(byte('1' + rank))
+		for file := 0; file < 8; file++ {
+			piece, white := GetPieceType(uint8(rank*8+file), b)
+			color := 0
+			if !white {
+				color = 1
+			}
+			sb.WriteString(" " + symbols[color][piece])
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("  a b c d e f g h\n")
+	sb.WriteString(b.ToFen())
+	return sb.String()
+}
+
+// Options for drawing a board as SVG. The zero value draws a plain 360 pixel
+// board with white at the bottom.
+type SVGOptions struct {
+	Size        int  // the width and height in pixels, or 0 for 360
+	Coordinates bool // label the files and ranks around the board
+	Flipped     bool // draw black at the bottom
+	LastMove    Move // highlight the squares of this move, unless it is 0
+	Check       bool // highlight the king of the side to move, if it is in check
+	Arrows      []Arrow
+	Marks       []Mark
+	// Shade the squares controlled by each side, more strongly when more kinds
+	// of pieces control them, as computed by GenerateControlArea.
+	HeatmapWhite, HeatmapBlack bool
+}
+
+// An arrow between the centers of two squares. The color is an SVG color, or
+// empty for green.
+type Arrow struct {
+	From, To uint8
+	Color    string
+}
+
+// A circle around a square. The color is an SVG color, or empty for green.
+type Mark struct {
+	Square uint8
+	Color  string
+}
+
+const (
+	svgLightSquare  = "#f0d9b5"
+	svgDarkSquare   = "#b58863"
+	svgLastMove     = "#cdd26a"
+	svgCheck        = "#e32a2a"
+	svgDefaultColor = "#15781b"
+	svgHeatWhite    = "#1e64ff"
+	svgHeatBlack    = "#ff3c1e"
+)
+
+// Draws the board as a standalone SVG image. Pieces are drawn with the Unicode
+// chess symbols, so no images are needed, but their look depends on the fonts
+// of the viewer.
+func (b *Board) SVG(opts SVGOptions) string {
+	size := opts.Size
+	if size <= 0 {
+		size = 360
+	}
+	margin := 0.0
+	if opts.Coordinates {
+		margin = float64(size) / 20
+	}
+	square := (float64(size) - 2*margin) / 8
+	// The top left corner of a square.
+	corner := func(sq uint8) (float64, float64) {
+		file, rank := int(sq%8), int(sq/8)
+		if opts.Flipped {
+			file, rank = 7-file, 7-rank
+		}
+		return margin + float64(file)*square, margin + float64(7-rank)*square
+	}
+	center := func(sq uint8) (float64, float64) {
+		x, y := corner(sq)
+		return x + square/2, y + square/2
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		size, size, size, size)
+	sb.WriteByte('\n')
+	if opts.Coordinates {
+		fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#404040"/>`+"\n", size, size)
+	}
+	for sq := uint8(0); sq < 64; sq++ {
+		x, y := corner(sq)
+		fill := svgDarkSquare
+		if (sq/8+sq%8)%2 == 1 {
+			fill = svgLightSquare
+		}
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, square, square, fill)
+	}
+	if opts.LastMove != 0 {
+		squares := []uint8{opts.LastMove.To()}
+		if !opts.LastMove.IsDrop() {
+			squares = append(squares, opts.LastMove.From())
+		}
+		for _, sq := range squares {
+			x, y := corner(sq)
+			fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" opacity="0.8"/>`+"\n",
+				x, y, square, square, svgLastMove)
+		}
+	}
+	for _, heatmap := range []struct {
+		enabled, white bool
+		color          string
+	}{{opts.HeatmapWhite, true, svgHeatWhite}, {opts.HeatmapBlack, false, svgHeatBlack}} {
+		if !heatmap.enabled {
+			continue
+		}
+		for sq, count := range b.controlCounts(heatmap.white) {
+			if count == 0 {
+				continue
+			}
+			x, y := corner(uint8(sq))
+			fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" opacity="%.2f"/>`+"\n",
+				x, y, square, square, heatmap.color, math.Min(0.15*float64(count), 0.9))
+		}
+	}
+	if opts.Check && b.OurKingInCheck() {
+		kings := b.White.Kings
+		if !b.Wtomove {
+			kings = b.Black.Kings
+		}
+		for ; kings != 0; kings &= kings - 1 {
+			x, y := center(uint8(bits.TrailingZeros64(kings)))
+			fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" opacity="0.7"/>`+"\n",
+				x, y, square/2, svgCheck)
+		}
+	}
+	if opts.Coordinates {
+		fontSize := margin * 0.7
+		for i := 0; i < 8; i++ {
+			x, _ := corner(uint8(i))     // file i
+			_, y := corner(uint8(i * 8)) // rank i
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="%.1f" fill="#e5e5e5" text-anchor="middle">%c</text>`+"\n",
+				x+square/2, float64(size)-margin*0.3, fontSize, 'a'+i)
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="%.1f" fill="#e5e5e5" text-anchor="middle">%c</text>`+"\n",
+				margin/2, y+square/2+fontSize/3, fontSize, '1'+i)
+		}
+	}
+	// The filled symbols are drawn for both sides, in white or black.
+	for sq := uint8(0); sq < 64; sq++ {
+		piece, white := GetPieceType(sq, b)
+		if piece == Nothing {
+			continue
+		}
+		fill, stroke := "#000000", "#ffffff"
+		if white {
+			fill, stroke = "#ffffff", "#000000"
+		}
+		x, y := center(sq)
+		fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" font-size="%.1f" text-anchor="middle" dominant-baseline="central" `+
+			`fill="%s" stroke="%s" stroke-width="%.1f">%s</text>`+"\n",
+			x, y, square*0.8, fill, stroke, square/40, unicodePieces[1][piece])
+	}
+	for _, mark := range opts.Marks {
+		x, y := center(mark.Square)
+		fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="%s" stroke-width="%.1f" opacity="0.8"/>`+"\n",
+			x, y, square*0.45, svgColor(mark.Color), square/12)
+	}
+	for _, arrow := range opts.Arrows {
+		x1, y1 := center(arrow.From)
+		x2, y2 := center(arrow.To)
+		length := math.Hypot(x2-x1, y2-y1)
+		if length == 0 {
+			continue
+		}
+		// Unit vectors along and across the arrow.
+		dx, dy := (x2-x1)/length, (y2-y1)/length
+		nx, ny := -dy, dx
+		head, width := square*0.4, square*0.15
+		bx, by := x2-dx*head, y2-dy*head // the base of the head
+		color := svgColor(arrow.Color)
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.1f" `+
+			`stroke-linecap="round" opacity="0.8"/>`+"\n", x1, y1, bx, by, color, width)
+		fmt.Fprintf(&sb, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="%s" opacity="0.8"/>`+"\n",
+			x2, y2, bx+nx*head/2, by+ny*head/2, bx-nx*head/2, by-ny*head/2, color)
+	}
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+func svgColor(color string) string {
+	if color == "" {
+		return svgDefaultColor
+	}
+	return html.EscapeString(color)
+}
+
+// For each square, the number of kinds of pieces of a side that control it.
+func (b *Board) controlCounts(white bool) [64]int {
+	side := *b
+	side.Wtomove = white
+	area := side.GenerateControlArea()
+	var counts [64]int
+	for _, bitboard := range []uint64{area.Pawns, area.Knights, area.Bishops, area.Rooks, area.Queens, area.Kings, area.Pinned} {
+		for ; bitboard != 0; bitboard &= bitboard - 1 {
+			counts[bits.TrailingZeros64(bitboard)]++
+		}
+	}
+	return counts
+}
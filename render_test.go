@@ -0,0 +1,94 @@
+package dragontoothmg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	b := ParseFen("4k3/8/8/8/8/8/4P3/4K2R w K - 0 1")
+	expected := "8 . . . . k . . .\n" +
+		"7 . . . . . . . .\n" +
+		"6 . . . . . . . .\n" +
+		"5 . . . . . . . .\n" +
+		"4 . . . . . . . .\n" +
+		"3 . . . . . . . .\n" +
+		"2 . . . . P . . .\n" +
+		"1 . . . . K . . R\n" +
+		"  a b c d e f g h\n" +
+		"4k3/8/8/8/8/8/4P3/4K2R w K - 0 1"
+	if b.String() != expected {
+		t.Error("Expected\n" + expected + "\nbut got\n" + b.String())
+	}
+	if fmt.Sprint(b) != expected || fmt.Sprint(&b) != expected {
+		t.Error("Expected boards to print as diagrams")
+	}
+	if unicode := b.UnicodeString(); !strings.HasPrefix(unicode, "8 · · · · ♚ · · ·\n") ||
+		!strings.Contains(unicode, "1 · · · · ♔ · · ♖\n") {
+		t.Error("Unexpected Unicode diagram\n" + unicode)
+	}
+}
+
+// Counts the elements of an SVG image, checking that it is well formed.
+func svgElements(t *testing.T, svg string) map[string]int {
+	counts := make(map[string]int)
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return counts
+		} else if err != nil {
+			t.Fatal("Malformed SVG:", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	b := ParseFen(Startpos)
+	counts := svgElements(t, b.SVG(SVGOptions{}))
+	if counts["svg"] != 1 || counts["rect"] != 64 || counts["text"] != 32 {
+		t.Error("Expected 64 squares and 32 pieces but got", counts)
+	}
+	if svg := b.SVG(SVGOptions{Size: 400}); !strings.Contains(svg, `width="400"`) ||
+		!strings.Contains(svg, `<rect x="0.0" y="350.0" width="50.0" height="50.0" fill="#b58863"/>`) {
+		t.Error("Expected a dark a1 square at the bottom left")
+	}
+	if svg := b.SVG(SVGOptions{Size: 400, Flipped: true}); !strings.Contains(svg,
+		`<rect x="350.0" y="0.0" width="50.0" height="50.0" fill="#b58863"/>`) {
+		t.Error("Expected a dark a1 square at the top right of a flipped board")
+	}
+	counts = svgElements(t, b.SVG(SVGOptions{Coordinates: true}))
+	if counts["rect"] != 65 || counts["text"] != 32+16 {
+		t.Error("Expected a background and 16 coordinates but got", counts)
+	}
+
+	// Last move, check, marks and arrows.
+	b = ParseFen("4k3/8/8/8/8/8/4q3/4K3 w - - 0 1")
+	opts := SVGOptions{LastMove: parseMove("e7e2"), Check: true,
+		Marks:  []Mark{{Square: 0}},
+		Arrows: []Arrow{{From: 4, To: 12, Color: "red"}, {From: 1, To: 1}}}
+	counts = svgElements(t, b.SVG(opts))
+	if counts["rect"] != 66 || counts["circle"] != 2 || counts["line"] != 1 || counts["polygon"] != 1 {
+		t.Error("Expected two highlighted squares, a check, a mark and an arrow but got", counts)
+	}
+	opts.LastMove = parseMove("N@d4")
+	if counts = svgElements(t, b.SVG(opts)); counts["rect"] != 65 {
+		t.Error("Expected only the square of a drop to be highlighted, got", counts)
+	}
+
+	// The king controls 5 squares, and the queen 16 more.
+	opts = SVGOptions{HeatmapWhite: true}
+	if counts = svgElements(t, b.SVG(opts)); counts["rect"] != 64+5 {
+		t.Error("Expected 5 squares controlled by white but got", counts["rect"]-64)
+	}
+	opts = SVGOptions{HeatmapBlack: true}
+	if counts = svgElements(t, b.SVG(opts)); counts["rect"] <= 64+5 {
+		t.Error("Expected the squares controlled by black but got", counts["rect"]-64)
+	}
+}
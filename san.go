@@ -0,0 +1,216 @@
+package dragontoothmg
+
+import (
+	"errors"
+	"strings"
+)
+
+// Piece letters used in standard algebraic notation, indexed by Piece.
+var sanPieceLetters = [7]string{"", "", "N", "B", "R", "Q", "K"}
+
+// Converts a legal move to standard algebraic notation (SAN), e.g. "Nf3", "exd5",
+// "O-O", "e8=Q+", "Rad1#" or, in Crazyhouse, "N@f3". The move must be legal in
+// this position.
+func (b *Board) ToSan(m Move) string {
+	from, to := m.From(), m.To()
+	piece, _ := GetPieceType(from, b)
+	var san string
+	if m.IsDrop() {
+		san = m.String()
+	} else if piece == King && (int(to)-int(from) == 2 || int(to)-int(from) == -2) {
+		if to > from {
+			san = "O-O"
+		} else {
+			san = "O-O-O"
+		}
+	} else {
+		capture := IsCapture(m, b)
+		if piece == Pawn {
+			if capture {
+				san = IndexToAlgebraic(Square(from))[:1]
+			}
+		} else {
+			san = sanPieceLetters[piece] + b.sanDisambiguation(m, Piece(piece))
+		}
+		if capture {
+			san += "x"
+		}
+		san += IndexToAlgebraic(Square(to))
+		if m.Promote() != Nothing {
+			san += "=" + sanPieceLetters[m.Promote()]
+		}
+	}
+	after := *b
+	after.Apply(m)
+	if after.OurKingInCheck() {
+		if len(after.GenerateLegalMoves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	return san
+}
+
+// Finds the shortest origin qualifier (none, file, rank, or square) that tells m
+// apart from other legal moves of the same piece type to the same square.
+func (b *Board) sanDisambiguation(m Move, piece Piece) string {
+	var ourPieces *Bitboards
+	if b.Wtomove {
+		ourPieces = &(b.White)
+	} else {
+		ourPieces = &(b.Black)
+	}
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range b.GenerateLegalMoves() {
+		if other.To() != m.To() || other.From() == m.From() || other.IsDrop() {
+			continue
+		}
+		if *pieceBitboardPtr(ourPieces, piece)&(uint64(1)<<other.From()) == 0 {
+			continue
+		}
+		ambiguous = true
+		if other.From()%8 == m.From()%8 {
+			sameFile = true
+		}
+		if other.From()/8 == m.From()/8 {
+			sameRank = true
+		}
+	}
+	origin := IndexToAlgebraic(Square(m.From()))
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return origin[:1]
+	case !sameRank:
+		return origin[1:]
+	}
+	return origin
+}
+
+// Parses a move in standard algebraic notation for this position. Annotations
+// ("+", "#", "!", "?") are ignored, and the capture mark, the "=" before a
+// promotion, and extra disambiguation are optional. Castling may be written with
+// letter O or digit 0. Crazyhouse drops are written "N@f3", and pawn drops may
+// omit the letter ("@e4"). Returns an error if the move is not legal or is
+// ambiguous.
+func (b *Board) ParseSan(san string) (Move, error) {
+	str := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if strings.HasPrefix(str, "@") {
+		str = "P" + str
+	}
+	if len(str) == 4 && str[1] == '@' {
+		mv, err := ParseMove(str)
+		if err != nil || str[0] == 'p' {
+			return 0, errors.New("Invalid SAN move " + san)
+		}
+		return b.legalOrError(mv, san)
+	}
+	var kingFrom uint8
+	if b.Wtomove {
+		kingFrom = 4
+	} else {
+		kingFrom = 60
+	}
+	switch str {
+	case "O-O", "0-0":
+		var mv Move
+		mv.Setfrom(Square(kingFrom)).Setto(Square(kingFrom + 2))
+		return b.legalOrError(mv, san)
+	case "O-O-O", "0-0-0":
+		var mv Move
+		mv.Setfrom(Square(kingFrom)).Setto(Square(kingFrom - 2))
+		return b.legalOrError(mv, san)
+	}
+	if len(str) < 2 {
+		return 0, errors.New("Invalid SAN move " + san)
+	}
+
+	// Moving piece; lowercase "b" is always a file.
+	piece := Piece(Pawn)
+	for p := Knight; p <= King; p++ {
+		if str[0] == sanPieceLetters[p][0] {
+			piece = Piece(p)
+			str = str[1:]
+			break
+		}
+	}
+	// Promotion suffix
+	promote := Piece(Nothing)
+	if len(str) > 2 {
+		last := str[len(str)-1]
+		for p := Knight; p <= King; p++ {
+			if last == sanPieceLetters[p][0] || (last == strings.ToLower(sanPieceLetters[p])[0] &&
+				piece == Pawn && last != 'b') {
+				promote = Piece(p)
+				str = strings.TrimSuffix(str[:len(str)-1], "=")
+				break
+			}
+		}
+	}
+	if len(str) < 2 {
+		return 0, errors.New("Invalid SAN move " + san)
+	}
+	to, err := AlgebraicToIndex(str[len(str)-2:])
+	if err != nil {
+		return 0, errors.New("Invalid SAN move " + san)
+	}
+	// Whatever is left is an optional origin qualifier and capture mark.
+	qualifier := strings.Replace(strings.Replace(str[:len(str)-2], "x", "", 1), ":", "", 1)
+	fromFile, fromRank := -1, -1
+	for _, c := range qualifier {
+		switch {
+		case c >= 'a' && c <= 'h':
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8':
+			fromRank = int(c - '1')
+		default:
+			return 0, errors.New("Invalid SAN move " + san)
+		}
+	}
+
+	var ourPieces *Bitboards
+	if b.Wtomove {
+		ourPieces = &(b.White)
+	} else {
+		ourPieces = &(b.Black)
+	}
+	var found Move
+	matches := 0
+	for _, mv := range b.GenerateLegalMoves() {
+		if mv.To() != to || mv.IsDrop() || *pieceBitboardPtr(ourPieces, piece)&(uint64(1)<<mv.From()) == 0 {
+			continue
+		}
+		if (fromFile >= 0 && int(mv.From()%8) != fromFile) ||
+			(fromRank >= 0 && int(mv.From()/8) != fromRank) {
+			continue
+		}
+		if piece == King && (int(mv.To())-int(mv.From()) == 2 || int(mv.To())-int(mv.From()) == -2) {
+			continue // castling must be written as such
+		}
+		// The generator only produces queen promotions, except in Antichess; others
+		// are otherwise identical.
+		if (mv.Promote() != Nothing) != (promote != Nothing) || (promote != Nothing && mv.Promote() != Queen) {
+			continue
+		}
+		mv.Setpromote(promote)
+		found = mv
+		matches++
+	}
+	if matches == 0 {
+		return 0, errors.New("Illegal SAN move " + san)
+	} else if matches > 1 {
+		return 0, errors.New("Ambiguous SAN move " + san)
+	} else if promote == King {
+		return b.legalOrError(found, san)
+	}
+	return found, nil
+}
+
+func (b *Board) legalOrError(m Move, san string) (Move, error) {
+	if !b.IsLegal(m) {
+		return 0, errors.New("Illegal SAN move " + san)
+	}
+	return m, nil
+}
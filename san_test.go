@@ -0,0 +1,75 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestToSan(t *testing.T) {
+	tests := map[string]map[string]string{
+		Startpos: {"e2e4": "e4", "g1f3": "Nf3"},
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0": {
+			"e1g1": "O-O", "e1c1": "O-O-O", "d5e6": "dxe6", "e5f7": "Nxf7", "c3b1": "Nb1",
+			"e2a6": "Bxa6", "f3f6": "Qxf6", "g2h3": "gxh3", "a1b1": "Rb1"},
+		// knights on the same file, rooks on the same rank
+		"4k3/8/8/1N6/8/1N6/4K3/R6R w - - 0 1": {"b5d4": "N5d4", "b3d4": "N3d4", "a1d1": "Rad1"},
+		// three queens need a full square
+		"k7/8/8/8/8/2Q1Q3/8/2Q1K3 w - - 0 1": {"c3d2": "Qc3d2", "e3d2": "Qed2"},
+		// promotion, check and mate
+		"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1":      {"b7b8q": "b8=Q+"},
+		"4k3/8/8/8/8/8/p7/4K3 b - - 0 1":       {"a2a1q": "a1=Q+"}, // no en passant target on a1
+		"6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1": {"a1a8": "Ra8#"},
+		// en passant
+		"r3k3/1ppp1ppr/8/3Pp3/8/8/1PP1PPPP/R3K2R w - e6 3 0": {"d5e6": "dxe6"},
+	}
+	for fen, moves := range tests {
+		b := ParseFen(fen)
+		for str, san := range moves {
+			if result := b.ToSan(parseMove(str)); result != san {
+				t.Error("Expected", san, "for", str, "but got", result, "in", fen)
+			}
+		}
+	}
+}
+
+func TestParseSan(t *testing.T) {
+	b := ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0")
+	tests := map[string]string{
+		"O-O": "e1g1", "0-0-0": "e1c1", "dxe6": "d5e6", "de6": "d5e6", "Nxf7": "e5f7",
+		"Nf7+": "e5f7", "Nb1": "c3b1", "Nc3b1": "c3b1", "Bxa6!?": "e2a6", "gxh3": "g2h3",
+	}
+	for san, expected := range tests {
+		m, err := b.ParseSan(san)
+		if err != nil || m.String() != expected {
+			t.Error("Expected", expected, "for", san, "but got", &m, err)
+		}
+	}
+	for _, san := range []string{"Na3", "Ke3", "e5", "Qxa8", "O-O-O-O", "", "x", "Zf3"} {
+		if _, err := b.ParseSan(san); err == nil {
+			t.Error("Invalid or ambiguous SAN", san, "was accepted.")
+		}
+	}
+	promo := ParseFen("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	for san, expected := range map[string]string{"b8=Q": "b7b8q", "b8N": "b7b8n", "b8=R+": "b7b8r"} {
+		m, err := promo.ParseSan(san)
+		if err != nil || m.String() != expected {
+			t.Error("Expected", expected, "for", san, "but got", &m, err)
+		}
+	}
+	if _, err := promo.ParseSan("b8"); err == nil {
+		t.Error("Promotion without a piece was accepted.")
+	}
+}
+
+// Every legal move must survive conversion to SAN and back.
+func TestSanRoundTrip(t *testing.T) {
+	for _, fen := range movePickerPositions {
+		b := ParseFen(fen)
+		for _, m := range b.GenerateLegalMoves() {
+			san := b.ToSan(m)
+			parsed, err := b.ParseSan(san)
+			if err != nil || parsed != m {
+				t.Error("SAN round trip failed for", &m, "via", san, "in", fen, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"math/bits"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// An Evaluator statically scores a position in centipawns, from the perspective
+// of the side to move. It is only called on positions that are not checkmate or
+// stalemate. See dragontoothmg.ClassicalEvaluator for the default.
+type Evaluator = dragontoothmg.Evaluator
+
+// Adapts an ordinary function to the Evaluator interface.
+type EvaluatorFunc func(b *dragontoothmg.Board) int
+
+func (f EvaluatorFunc) Evaluate(b *dragontoothmg.Board) int {
+	return f(b)
+}
+
+// Centipawn values used by MaterialEvaluator.
+var materialValues = [...]int{
+	dragontoothmg.Pawn:   100,
+	dragontoothmg.Knight: 320,
+	dragontoothmg.Bishop: 330,
+	dragontoothmg.Rook:   500,
+	dragontoothmg.Queen:  900,
+}
+
+// A minimal evaluator that only counts material.
+type MaterialEvaluator struct{}
+
+func (MaterialEvaluator) Evaluate(b *dragontoothmg.Board) int {
+	score := materialBalance(&b.White) - materialBalance(&b.Black)
+	if !b.Wtomove {
+		score = -score
+	}
+	return score
+}
+
+func materialBalance(bb *dragontoothmg.Bitboards) int {
+	return bits.OnesCount64(bb.Pawns)*materialValues[dragontoothmg.Pawn] +
+		bits.OnesCount64(bb.Knights)*materialValues[dragontoothmg.Knight] +
+		bits.OnesCount64(bb.Bishops)*materialValues[dragontoothmg.Bishop] +
+		bits.OnesCount64(bb.Rooks)*materialValues[dragontoothmg.Rook] +
+		bits.OnesCount64(bb.Queens)*materialValues[dragontoothmg.Queen]
+}
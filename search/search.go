@@ -0,0 +1,504 @@
+// Package search is a reference alpha-beta searcher built on the dragontoothmg
+// move generator. It provides iterative deepening, principal variation search,
+// quiescence search, a transposition table, null-move pruning and mate-distance
+// scoring, with a pluggable evaluation function.
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/tt"
+)
+
+const (
+	// Larger than any possible score.
+	Infinity = 32001
+	// The score for delivering checkmate immediately. Mate in n plies scores MateScore - n.
+	MateScore = 32000
+	// The maximum search depth, in plies.
+	MaxPly = 128
+)
+
+// Time reserved for communication overhead when searching on a clock.
+const moveOverhead = 20 * time.Millisecond
+
+// How often (in nodes) to check the clock and the context.
+const checkInterval = 1024
+
+// Limits on a search. The zero value searches until the context is cancelled
+// (or MaxPly is reached).
+type Limits struct {
+	Depth    int    // maximum depth in plies, or 0 for no limit
+	Nodes    uint64 // maximum number of nodes, or 0 for no limit
+	MoveTime time.Duration
+	// Remaining clock time and increment for each side, used to budget time
+	// when MoveTime is not set.
+	WTime, BTime time.Duration
+	WInc, BInc   time.Duration
+	MovesToGo    int
+	// Ignore the clock, and search until cancelled or another limit is reached.
+	Infinite bool
+}
+
+// Progress information, reported after each completed iteration.
+type Info struct {
+	Depth    int
+	SelDepth int
+	Score    int // centipawns from the side to move's perspective, or a mate score
+	Nodes    uint64
+	Time     time.Duration
+	Hashfull int // permille of the transposition table used by this search
+	PV       []dragontoothmg.Move
+}
+
+// The outcome of a search.
+type Result struct {
+	BestMove dragontoothmg.Move // 0 if there are no legal moves
+	Score    int
+	Depth    int
+	Nodes    uint64
+	PV       []dragontoothmg.Move
+}
+
+// Converts a score to a mate distance in moves, if it is a mate score.
+// The distance is positive if the side to move delivers mate, and negative if it
+// is mated.
+func MateIn(score int) (int, bool) {
+	if score >= MateScore-MaxPly {
+		return (MateScore - score + 1) / 2, true
+	} else if score <= -MateScore+MaxPly {
+		return -(MateScore + score) / 2, true
+	}
+	return 0, false
+}
+
+// A Searcher holds the state that persists between searches (the transposition
+// table and move ordering heuristics). It must not be used by multiple goroutines
+// at once.
+type Searcher struct {
+	Evaluator Evaluator
+	// If set, called after every completed iteration of a search.
+	OnInfo func(Info)
+
+	tt           *tt.Table
+	history      [2][64][64]int
+	historyFuncs [2]func(m dragontoothmg.Move) int
+	killers      [MaxPly + 1][2]dragontoothmg.Move
+	counterMoves [64][64]dragontoothmg.Move
+	pv           [MaxPly + 1][MaxPly + 1]dragontoothmg.Move
+	pvLength     [MaxPly + 1]int
+	positions    []uint64 // hashes of the game history and the current search path
+
+	ctx            context.Context
+	limits         Limits
+	start          time.Time
+	deadline       time.Time // zero if there is no time limit
+	softDeadline   time.Time // do not start a new iteration after this
+	nodes          uint64
+	selDepth       int
+	completedDepth int
+	stopped        bool
+}
+
+// Creates a searcher with a transposition table of roughly hashMb megabytes.
+// A nil evaluator selects the classical evaluator with its default weights.
+func NewSearcher(evaluator Evaluator, hashMb int) *Searcher {
+	if evaluator == nil {
+		evaluator = dragontoothmg.NewClassicalEvaluator(nil)
+	}
+	s := &Searcher{Evaluator: evaluator, tt: tt.New(hashMb)}
+	for side := range s.historyFuncs {
+		table := &s.history[side]
+		s.historyFuncs[side] = func(m dragontoothmg.Move) int {
+			return table[m.From()][m.To()]
+		}
+	}
+	return s
+}
+
+// Reallocates the transposition table, discarding its contents.
+func (s *Searcher) SetHashSize(hashMb int) {
+	s.tt.Resize(hashMb)
+}
+
+// Forgets everything learned from previous searches, e.g. for a new game.
+func (s *Searcher) Clear() {
+	s.tt.Clear()
+	s.history = [2][64][64]int{}
+	s.killers = [MaxPly + 1][2]dragontoothmg.Move{}
+	s.counterMoves = [64][64]dragontoothmg.Move{}
+}
+
+// Searches the position for the best move. History contains the hashes of the
+// positions that preceded b in the game (oldest first), for repetition detection.
+// The search stops when a limit is reached or the context is done, and always
+// completes at least one iteration if there are legal moves.
+func (s *Searcher) Search(ctx context.Context, b dragontoothmg.Board, history []uint64,
+	limits Limits) Result {
+	s.ctx = ctx
+	s.limits = limits
+	s.start = time.Now()
+	s.nodes = 0
+	s.completedDepth = 0
+	s.stopped = false
+	s.positions = append(s.positions[:0], history...)
+	s.killers = [MaxPly + 1][2]dragontoothmg.Move{}
+	s.tt.NewSearch()
+	s.setDeadlines(b.Wtomove)
+
+	var result Result
+	maxDepth := MaxPly
+	if limits.Depth > 0 && limits.Depth < MaxPly {
+		maxDepth = limits.Depth
+	}
+	for depth := 1; depth <= maxDepth; depth++ {
+		s.selDepth = 0
+		score := s.negamax(&b, depth, -Infinity, Infinity, 0, 0, false)
+		if s.stopped && s.completedDepth > 0 {
+			break // the partial iteration is unreliable
+		}
+		s.completedDepth = depth
+		result = Result{Score: score, Depth: depth, Nodes: s.nodes,
+			PV: append([]dragontoothmg.Move(nil), s.pv[0][:s.pvLength[0]]...)}
+		if len(result.PV) > 0 {
+			result.BestMove = result.PV[0]
+		}
+		if s.OnInfo != nil {
+			s.OnInfo(Info{Depth: depth, SelDepth: s.selDepth, Score: score, Nodes: s.nodes,
+				Time: time.Since(s.start), Hashfull: s.tt.Hashfull(), PV: result.PV})
+		}
+		if result.BestMove == 0 { // checkmate or stalemate
+			break
+		}
+		if _, mate := MateIn(score); mate && !limits.Infinite && limits.Depth == 0 {
+			break
+		}
+		if s.stopped || s.shouldStop() ||
+			(!s.softDeadline.IsZero() && time.Now().After(s.softDeadline)) {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+	return result
+}
+
+// Budgets time for this move from the limits.
+func (s *Searcher) setDeadlines(wtomove bool) {
+	s.deadline, s.softDeadline = time.Time{}, time.Time{}
+	if s.limits.Infinite {
+		return
+	}
+	if s.limits.MoveTime > 0 {
+		s.deadline = s.start.Add(s.limits.MoveTime)
+		return
+	}
+	remaining, increment := s.limits.WTime, s.limits.WInc
+	if !wtomove {
+		remaining, increment = s.limits.BTime, s.limits.BInc
+	}
+	if remaining <= 0 {
+		return
+	}
+	movesToGo := s.limits.MovesToGo
+	if movesToGo <= 0 || movesToGo > 30 {
+		movesToGo = 30
+	}
+	available := remaining - moveOverhead
+	if available < time.Millisecond {
+		available = time.Millisecond
+	}
+	soft := available/time.Duration(movesToGo) + increment*3/4
+	hard := soft * 4
+	if hard > available/2 {
+		hard = available / 2
+	}
+	if soft > hard {
+		soft = hard
+	}
+	s.deadline = s.start.Add(hard)
+	s.softDeadline = s.start.Add(soft / 2)
+}
+
+// Checks the node limit, the clock and the context. Limits are not enforced until
+// the first iteration completes, so that a move is always available.
+func (s *Searcher) shouldStop() bool {
+	if s.completedDepth == 0 {
+		return false
+	}
+	if s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes {
+		return true
+	}
+	if s.nodes%checkInterval != 0 {
+		return false
+	}
+	if s.ctx.Err() != nil {
+		return true
+	}
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
+
+// The principal variation search. Returns a score from the side to move's perspective.
+func (s *Searcher) negamax(b *dragontoothmg.Board, depth int, alpha int, beta int, ply int,
+	prevMove dragontoothmg.Move, allowNull bool) int {
+	s.pvLength[ply] = 0
+	if ply > 0 {
+		s.nodes++
+		if s.stopped || s.shouldStop() {
+			s.stopped = true
+			return 0
+		}
+		if s.isDraw(b) {
+			return 0
+		}
+		// Mate distance pruning: no line from here can beat a shorter mate already found.
+		if alpha < -MateScore+ply {
+			alpha = -MateScore + ply
+		}
+		if beta > MateScore-ply-1 {
+			beta = MateScore - ply - 1
+		}
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	if ply > s.selDepth {
+		s.selDepth = ply
+	}
+	inCheck := b.OurKingInCheck()
+	if inCheck {
+		depth++ // check extension
+	}
+	if depth <= 0 {
+		return s.quiescence(b, alpha, beta, ply)
+	}
+	if ply >= MaxPly {
+		return s.Evaluator.Evaluate(b)
+	}
+
+	pvNode := beta-alpha > 1
+	hash := b.Hash()
+	var ttMove dragontoothmg.Move
+	if entry, ok := s.tt.Probe(hash); ok {
+		ttMove = entry.Move
+		score := scoreFromTT(entry.Score, ply)
+		if !pvNode && ply > 0 && entry.Depth >= depth {
+			if entry.Bound == tt.BoundExact ||
+				(entry.Bound == tt.BoundLower && score >= beta) ||
+				(entry.Bound == tt.BoundUpper && score <= alpha) {
+				return score
+			}
+		}
+	}
+
+	s.positions = append(s.positions, hash)
+	defer func() { s.positions = s.positions[:len(s.positions)-1] }()
+
+	// Null-move pruning: if passing still fails high, a real move almost surely will.
+	// Skipped without non-pawn material, where zugzwang is common.
+	if allowNull && !pvNode && !inCheck && depth >= 3 && hasNonPawnMaterial(b) &&
+		beta < MateScore-MaxPly && s.Evaluator.Evaluate(b) >= beta {
+		reduction := 2
+		if depth >= 7 {
+			reduction = 3
+		}
+		nullBoard := *b
+		nullBoard.MakeNullMove()
+		score := -s.negamax(&nullBoard, depth-1-reduction, -beta, -beta+1, ply+1, 0, false)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			if score >= MateScore-MaxPly {
+				score = beta
+			}
+			return score
+		}
+	}
+
+	side := 0
+	if !b.Wtomove {
+		side = 1
+	}
+	var counterMove dragontoothmg.Move
+	if prevMove != 0 {
+		counterMove = s.counterMoves[prevMove.From()][prevMove.To()]
+	}
+	picker := dragontoothmg.NewMovePicker(b, ttMove, s.killers[ply], counterMove,
+		s.historyFuncs[side])
+	originalAlpha := alpha
+	bestScore := -Infinity
+	var bestMove dragontoothmg.Move
+	moveCount := 0
+	for m := picker.Next(); m != 0; m = picker.Next() {
+		moveCount++
+		quiet := m.Promote() == dragontoothmg.Nothing && !dragontoothmg.IsCapture(m, b)
+		child := *b
+		child.Apply(m)
+		var score int
+		if moveCount == 1 {
+			score = -s.negamax(&child, depth-1, -beta, -alpha, ply+1, m, true)
+		} else {
+			// Late move reductions for quiet moves that are unlikely to be best.
+			reduction := 0
+			if depth >= 3 && moveCount > 3 && quiet && !inCheck && !child.OurKingInCheck() {
+				reduction = 1
+				if moveCount > 8 && !pvNode {
+					reduction = 2
+				}
+			}
+			score = -s.negamax(&child, depth-1-reduction, -alpha-1, -alpha, ply+1, m, true)
+			if score > alpha && reduction > 0 {
+				score = -s.negamax(&child, depth-1, -alpha-1, -alpha, ply+1, m, true)
+			}
+			if score > alpha && score < beta {
+				score = -s.negamax(&child, depth-1, -beta, -alpha, ply+1, m, true)
+			}
+		}
+		if s.stopped {
+			return 0
+		}
+		if score > bestScore {
+			bestScore = score
+			bestMove = m
+			if score > alpha {
+				alpha = score
+				s.updatePV(ply, m)
+				if alpha >= beta {
+					if quiet {
+						s.updateQuietHeuristics(side, ply, depth, m, prevMove)
+					}
+					break
+				}
+			}
+		}
+	}
+	if moveCount == 0 {
+		if inCheck {
+			return -MateScore + ply
+		}
+		return 0 // stalemate
+	}
+
+	bound := tt.BoundUpper
+	if bestScore >= beta {
+		bound = tt.BoundLower
+	} else if bestScore > originalAlpha {
+		bound = tt.BoundExact
+	}
+	s.tt.Store(hash, tt.Entry{Move: bestMove, Score: scoreToTT(bestScore, ply), Depth: depth, Bound: bound})
+	return bestScore
+}
+
+// Searches captures (or all evasions, when in check) until the position is quiet.
+func (s *Searcher) quiescence(b *dragontoothmg.Board, alpha int, beta int, ply int) int {
+	s.pvLength[ply] = 0
+	s.nodes++
+	if s.stopped || s.shouldStop() {
+		s.stopped = true
+		return 0
+	}
+	if ply > s.selDepth {
+		s.selDepth = ply
+	}
+	if ply >= MaxPly {
+		return s.Evaluator.Evaluate(b)
+	}
+	inCheck := b.OurKingInCheck()
+	var moves []dragontoothmg.Move
+	bestScore := -Infinity
+	if inCheck {
+		moves = b.GenerateLegalMoves()
+		if len(moves) == 0 {
+			return -MateScore + ply
+		}
+	} else {
+		bestScore = s.Evaluator.Evaluate(b) // stand pat
+		if bestScore >= beta {
+			return bestScore
+		}
+		if bestScore > alpha {
+			alpha = bestScore
+		}
+		moves = b.GenerateLegalCaptures()
+		b.SortMvvLva(moves)
+	}
+	for _, m := range moves {
+		if !inCheck && b.SEE(m) < 0 {
+			continue
+		}
+		child := *b
+		child.Apply(m)
+		score := -s.quiescence(&child, -beta, -alpha, ply+1)
+		if s.stopped {
+			return 0
+		}
+		if score > bestScore {
+			bestScore = score
+			if score > alpha {
+				alpha = score
+				s.updatePV(ply, m)
+				if alpha >= beta {
+					break
+				}
+			}
+		}
+	}
+	return bestScore
+}
+
+// Records m followed by the child's principal variation as the PV at this ply.
+func (s *Searcher) updatePV(ply int, m dragontoothmg.Move) {
+	s.pv[ply][0] = m
+	copy(s.pv[ply][1:], s.pv[ply+1][:s.pvLength[ply+1]])
+	s.pvLength[ply] = s.pvLength[ply+1] + 1
+}
+
+// Rewards a quiet move that caused a beta cutoff.
+func (s *Searcher) updateQuietHeuristics(side int, ply int, depth int, m dragontoothmg.Move,
+	prevMove dragontoothmg.Move) {
+	if s.killers[ply][0] != m {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = m
+	}
+	if prevMove != 0 {
+		s.counterMoves[prevMove.From()][prevMove.To()] = m
+	}
+	entry := &s.history[side][m.From()][m.To()]
+	*entry += depth * depth
+	if *entry > 1<<20 { // age the table so that it keeps adapting
+		for from := range s.history[side] {
+			for to := range s.history[side][from] {
+				s.history[side][from][to] /= 2
+			}
+		}
+	}
+}
+
+// Detects draws by the fifty-move rule, repetition and insufficient material.
+// Any repetition within the search is scored as a draw.
+func (s *Searcher) isDraw(b *dragontoothmg.Board) bool {
+	if b.Halfmoveclock >= 100 {
+		return true
+	}
+	hash := b.Hash()
+	oldest := len(s.positions) - int(b.Halfmoveclock)
+	if oldest < 0 {
+		oldest = 0
+	}
+	for i := len(s.positions) - 2; i >= oldest; i -= 2 {
+		if s.positions[i] == hash {
+			return true
+		}
+	}
+	return b.InsufficientMaterial()
+}
+
+func hasNonPawnMaterial(b *dragontoothmg.Board) bool {
+	ours := &b.White
+	if !b.Wtomove {
+		ours = &b.Black
+	}
+	return ours.Knights|ours.Bishops|ours.Rooks|ours.Queens != 0
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+func TestMateIn(t *testing.T) {
+	if moves, ok := MateIn(MateScore - 1); !ok || moves != 1 {
+		t.Error("Mate in 1 ply should be mate in 1 move, got", moves)
+	}
+	if moves, ok := MateIn(MateScore - 3); !ok || moves != 2 {
+		t.Error("Mate in 3 plies should be mate in 2 moves, got", moves)
+	}
+	if moves, ok := MateIn(-MateScore + 2); !ok || moves != -1 {
+		t.Error("Mated in 2 plies should be mated in 1 move, got", moves)
+	}
+	if _, ok := MateIn(350); ok {
+		t.Error("An ordinary score was reported as mate.")
+	}
+}
+
+func TestFindsMate(t *testing.T) {
+	positions := map[string]struct {
+		move  string
+		moves int
+	}{
+		// back rank mate
+		"6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1": {"a1a8", 1},
+		// mate in three with a queen sacrifice
+		"r1b3kr/ppp1Bp1p/1b6/n2P4/2p3q1/2Q2N2/P4PPP/RN2R1K1 w - - 1 0": {"c3h8", 3},
+		// black to move and mate
+		"6k1/8/8/8/8/r7/1r6/7K b - - 0 1": {"a3a1", 1},
+	}
+	for fen, expected := range positions {
+		s := NewSearcher(nil, 16)
+		result := s.Search(context.Background(), dragontoothmg.ParseFen(fen), nil, Limits{Depth: 5})
+		if result.BestMove.String() != expected.move {
+			t.Error("Expected", expected.move, "but got", &result.BestMove, "for", fen)
+		}
+		if moves, ok := MateIn(result.Score); !ok || moves != expected.moves {
+			t.Error("Expected mate in", expected.moves, "but got score", result.Score, "for", fen)
+		}
+	}
+}
+
+func TestWinsMaterial(t *testing.T) {
+	// The knight on e5 is undefended.
+	b := dragontoothmg.ParseFen("4k3/8/8/4n3/8/8/8/4RK2 w - - 0 1")
+	s := NewSearcher(nil, 16)
+	result := s.Search(context.Background(), b, nil, Limits{Depth: 4})
+	if result.BestMove.String() != "e1e5" {
+		t.Error("Expected the free knight to be taken, but got", &result.BestMove)
+	}
+	if len(result.PV) == 0 || result.PV[0] != result.BestMove {
+		t.Error("The principal variation does not start with the best move.")
+	}
+}
+
+func TestNoLegalMoves(t *testing.T) {
+	stalemate := dragontoothmg.ParseFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	s := NewSearcher(nil, 1)
+	result := s.Search(context.Background(), stalemate, nil, Limits{Depth: 3})
+	if result.BestMove != 0 || result.Score != 0 {
+		t.Error("Stalemate should have no best move and a draw score, got", result.Score)
+	}
+	checkmate := dragontoothmg.ParseFen("7k/6Q1/6K1/8/8/8/8/8 b - - 0 1")
+	result = s.Search(context.Background(), checkmate, nil, Limits{Depth: 3})
+	if result.BestMove != 0 || result.Score != -MateScore {
+		t.Error("Checkmate should have no best move and a mated score, got", result.Score)
+	}
+}
+
+func TestRepetitionIsDraw(t *testing.T) {
+	// White is a queen down, but can repeat the position with perpetual check.
+	b := dragontoothmg.ParseFen("6k1/5q2/8/8/8/8/6Q1/6K1 w - - 0 1")
+	s := NewSearcher(nil, 16)
+	var history []uint64
+	for _, str := range []string{"g2g4", "g8h8", "g4h4", "h8g8"} {
+		history = append(history, b.Hash())
+		m, _ := dragontoothmg.ParseMove(str)
+		b.Apply(m)
+	}
+	result := s.Search(context.Background(), b, history, Limits{Depth: 6})
+	if result.Score < 0 {
+		t.Error("Expected the repetition to be found, but the score was", result.Score)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	b := dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	s := NewSearcher(nil, 16)
+	result := s.Search(context.Background(), b, nil, Limits{Nodes: 5000})
+	if result.BestMove == 0 || result.Nodes > 6000 {
+		t.Error("Node limit was not respected:", result.Nodes)
+	}
+
+	var infos []Info
+	s.OnInfo = func(info Info) { infos = append(infos, info) }
+	result = s.Search(context.Background(), b, nil, Limits{Depth: 3})
+	if result.Depth != 3 || len(infos) != 3 || infos[2].Depth != 3 {
+		t.Error("Depth limit was not respected, or progress was not reported.")
+	}
+	s.OnInfo = nil
+
+	start := time.Now()
+	result = s.Search(context.Background(), b, nil, Limits{MoveTime: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond || result.BestMove == 0 {
+		t.Error("Move time was not respected:", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	result = s.Search(ctx, b, nil, Limits{Infinite: true})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond || result.BestMove == 0 {
+		t.Error("Cancellation was not respected:", elapsed)
+	}
+}
+
+func TestCustomEvaluator(t *testing.T) {
+	// An evaluator that loves having a knight on a1 (for white).
+	eval := EvaluatorFunc(func(b *dragontoothmg.Board) int {
+		score := 0
+		if b.White.Knights&1 != 0 {
+			score = 1000
+		}
+		if !b.Wtomove {
+			score = -score
+		}
+		return score
+	})
+	s := NewSearcher(eval, 1)
+	b := dragontoothmg.ParseFen("4k3/8/8/8/8/1N6/8/4K3 w - - 0 1")
+	result := s.Search(context.Background(), b, nil, Limits{Depth: 2})
+	if result.BestMove.String() != "b3a1" {
+		t.Error("Custom evaluator was not used; best move was", &result.BestMove)
+	}
+}
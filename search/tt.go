@@ -0,0 +1,21 @@
+package search
+
+// Mate scores are stored relative to the node, rather than the root, so that
+// they remain correct when the position is reached at a different ply.
+func scoreToTT(score int, ply int) int {
+	if score >= MateScore-MaxPly {
+		return score + ply
+	} else if score <= -MateScore+MaxPly {
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score int, ply int) int {
+	if score >= MateScore-MaxPly {
+		return score - ply
+	} else if score <= -MateScore+MaxPly {
+		return score + ply
+	}
+	return score
+}
@@ -0,0 +1,135 @@
+package syzygy
+
+// Tables for computing the index of a position in a table, as defined by the
+// Syzygy format. Positions are reduced by symmetry: pawnless tables only store
+// positions with the leading piece in the a1-d1-d4 triangle (and below the
+// a1-h8 diagonal, if the leading group starts on it), and tables with pawns only
+// store positions with the leading pawn on files a-d.
+
+var (
+	// Encodes squares below the a1-h8 diagonal as 0..27.
+	mapB1H1H7 [64]int
+	// Encodes the a1-d1-d4 triangle as 0..9, with the diagonal squares last.
+	mapA1D1D4 [64]int
+	// Encodes the 462 placements of two kings, with the first in the a1-d1-d4
+	// triangle, indexed by mapA1D1D4 of the first king and the square of the second.
+	mapKK [10][64]uint64
+	// Encodes squares a2-h7 as 0..47, giving higher numbers to squares nearer the
+	// edge, and then to lower ranks. The pawn with the highest value leads.
+	mapPawns [64]int
+	// The number of ways to choose k of n squares, for k up to 5.
+	binomial [6][64]uint64
+	// The index of a leading pawn group, by size and leading pawn square.
+	leadPawnIdx [6][64]uint64
+	// The number of encodings of a leading pawn group, by size and file.
+	leadPawnsSize [6][4]uint64
+)
+
+// The number of ranks a square is above the a1-h8 diagonal (negative below it).
+func offA1H8(sq int) int {
+	return sq>>3 - sq&7
+}
+
+func kingsAdjacent(a int, b int) bool {
+	df, dr := a&7-b&7, a>>3-b>>3
+	return df >= -1 && df <= 1 && dr >= -1 && dr <= 1
+}
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	code = 0
+	var diagonal []int
+	for sq := 0; sq <= 27; sq++ { // a1 to d4
+		if offA1H8(sq) < 0 && sq&7 <= 3 {
+			mapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 && sq&7 <= 3 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	// With the first king on the diagonal, the second king must not be above it.
+	// Placements with both kings on the diagonal are encoded last.
+	var kkCode uint64
+	var bothOnDiagonal [][2]int
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if mapA1D1D4[s1] != idx || (idx == 0 && s1 != 1) { // b1 is the only square mapped to 0
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				if kingsAdjacent(s1, s2) || (offA1H8(s1) == 0 && offA1H8(s2) > 0) {
+					continue
+				}
+				if offA1H8(s1) == 0 && offA1H8(s2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, [2]int{idx, s2})
+					continue
+				}
+				mapKK[idx][s2] = kkCode
+				kkCode++
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		mapKK[p[0]][p[1]] = kkCode
+		kkCode++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for count := 1; count <= 5; count++ {
+		for file := 0; file < 4; file++ {
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if count == 1 {
+					mapPawns[sq] = available
+					mapPawns[sq^7] = available - 1
+					available -= 2
+				}
+				leadPawnIdx[count][sq] = idx
+				idx += binomial[count-1][mapPawns[sq]]
+			}
+			leadPawnsSize[count][file] = idx
+		}
+	}
+}
+
+// Sorts a few squares in place, stably, by a key.
+func sortSquares(squares []int, key func(int) int) {
+	for i := 1; i < len(squares); i++ {
+		for j := i; j > 0 && key(squares[j]) < key(squares[j-1]); j-- {
+			squares[j], squares[j-1] = squares[j-1], squares[j]
+		}
+	}
+}
+
+func squareKey(sq int) int {
+	return sq
+}
+
+func pawnKey(sq int) int {
+	return mapPawns[sq]
+}
@@ -0,0 +1,386 @@
+// Package syzygy probes Syzygy endgame tablebases: WDL (.rtbw) files, which
+// give the result of a position with perfect play, and DTZ (.rtbz) files, which
+// give the distance to the next capture or pawn move (zeroing the 50-move
+// counter) along a winning line.
+//
+// Results follow the 50-move rule: a cursed win is a position that would be won
+// without it, and a blessed loss is the other side of one. Tables do not store
+// positions where a capture is the best move, or en passant captures, so the
+// probes search captures (and, for DTZ, pawn moves) before reading the tables.
+// Positions with castling rights are not covered.
+//
+// Tables are read into memory when first needed. A Tablebase is safe for use by
+// multiple goroutines.
+package syzygy
+
+import (
+	"errors"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A result with perfect play, from the side to move's point of view.
+type WDL int
+
+const (
+	Loss        WDL = -2
+	BlessedLoss WDL = -1 // a loss, but drawn by the 50-move rule
+	Draw        WDL = 0
+	CursedWin   WDL = 1 // a win, but drawn by the 50-move rule
+	Win         WDL = 2
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case BlessedLoss:
+		return "blessed loss"
+	case CursedWin:
+		return "cursed win"
+	case Win:
+		return "win"
+	}
+	return "draw"
+}
+
+// A legal move at the root, with the result of playing it.
+type RootMove struct {
+	Move dragontoothmg.Move
+	// The result for the side to move at the root, counting the moves already
+	// played towards the 50-move rule.
+	WDL WDL
+	// The number of plies from the root to the next capture or pawn move (or
+	// mate) on the best line: positive if the side to move wins, negative if it
+	// loses, and 0 if the move draws. Cursed wins and blessed losses count an
+	// extra 100 plies.
+	DTZ int
+}
+
+var (
+	ErrCastling      = errors.New("Syzygy tables do not cover positions with castling rights.")
+	ErrTooManyPieces = errors.New("Too many pieces for the Syzygy tables.")
+)
+
+// A set of table files.
+type Tablebase struct {
+	maxPieces int
+	paths     map[string]string // by file name
+
+	mu     sync.Mutex
+	tables map[string]*loadedTable // by file name
+}
+
+type loadedTable struct {
+	once  sync.Once
+	table *table
+	err   error
+}
+
+var tableName = regexp.MustCompile(`^(K[QRBNP]*)v(K[QRBNP]*)\.rtb[wz]$`)
+
+// Finds the table files in the given directories. Files are not read until a
+// position needs them.
+func Open(dirs ...string) (*Tablebase, error) {
+	tb := &Tablebase{paths: map[string]string{}, tables: map[string]*loadedTable{}}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			match := tableName.FindStringSubmatch(entry.Name())
+			if match == nil || len(match[1])+len(match[2]) > maxPieces {
+				continue
+			}
+			if _, ok := tb.paths[entry.Name()]; !ok {
+				tb.paths[entry.Name()] = filepath.Join(dir, entry.Name())
+			}
+			if pieces := len(match[1]) + len(match[2]); pieces > tb.maxPieces {
+				tb.maxPieces = pieces
+			}
+		}
+	}
+	return tb, nil
+}
+
+// The largest number of pieces, including kings, of any table found.
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// Returns the table for a position's material, loading it if needed.
+func (tb *Tablebase) table(b *dragontoothmg.Board, kind tableKind) (*table, error) {
+	white, black := sideName(&b.White), sideName(&b.Black)
+	name := white + "v" + black
+	if _, ok := tb.paths[name+extensions[kind]]; !ok {
+		name = black + "v" + white
+	}
+	file := name + extensions[kind]
+	path, ok := tb.paths[file]
+	if !ok {
+		return nil, errors.New("Missing Syzygy table " + white + "v" + black + extensions[kind] + ".")
+	}
+	tb.mu.Lock()
+	loaded, ok := tb.tables[file]
+	if !ok {
+		loaded = &loadedTable{}
+		tb.tables[file] = loaded
+	}
+	tb.mu.Unlock()
+	loaded.once.Do(func() {
+		loaded.table, loaded.err = loadTable(path, name, kind)
+	})
+	return loaded.table, loaded.err
+}
+
+func (tb *Tablebase) check(b *dragontoothmg.Board) error {
+	if b.HasCastlingRights() {
+		return ErrCastling
+	}
+	if bits.OnesCount64(b.White.All|b.Black.All) > tb.maxPieces {
+		return ErrTooManyPieces
+	}
+	return nil
+}
+
+// Probes the result of a position, assuming the 50-move counter is zero, as it
+// is after a capture or pawn move. It only reads WDL tables, so it is the probe
+// to use during search; see ProbeDTZ for positions with a running counter.
+func (tb *Tablebase) ProbeWDL(b *dragontoothmg.Board) (WDL, error) {
+	if err := tb.check(b); err != nil {
+		return Draw, err
+	}
+	wdl, _, err := tb.search(b, false)
+	return wdl, err
+}
+
+// Probes the result of a position, counting the board's Halfmoveclock towards
+// the 50-move rule, and its distance to zeroing, as in RootMove.DTZ.
+func (tb *Tablebase) ProbeDTZ(b *dragontoothmg.Board) (WDL, int, error) {
+	if err := tb.check(b); err != nil {
+		return Draw, 0, err
+	}
+	dtz, err := tb.probeDTZ(b)
+	if err != nil {
+		return Draw, 0, err
+	}
+	return wdlFromDTZ(dtz, int(b.Halfmoveclock)), dtz, nil
+}
+
+// Probes every legal move of a position, and returns them best first: wins
+// before draws before losses, the fastest wins first and the slowest losses
+// first, so that always playing the first move wins any won position that the
+// 50-move rule allows. Repetitions are not detected.
+func (tb *Tablebase) ProbeRoot(b *dragontoothmg.Board) ([]RootMove, error) {
+	if err := tb.check(b); err != nil {
+		return nil, err
+	}
+	moves := b.GenerateLegalMoves()
+	root := make([]RootMove, len(moves))
+	for i, m := range moves {
+		next := *b
+		next.Apply(m)
+		var dtz int
+		if next.Halfmoveclock == 0 {
+			wdl, _, err := tb.search(&next, false)
+			if err != nil {
+				return nil, err
+			}
+			dtz = dtzBeforeZeroing(-wdl)
+		} else if next.Halfmoveclock >= 100 && !isMate(&next) {
+			dtz = 0 // drawn by the 50-move rule
+		} else {
+			d, err := tb.probeDTZ(&next)
+			if err != nil {
+				return nil, err
+			}
+			dtz = -d
+			if dtz > 0 {
+				dtz++
+			} else if dtz < 0 {
+				dtz--
+			}
+		}
+		if dtz == 2 && isMate(&next) {
+			dtz = 1
+		}
+		root[i] = RootMove{Move: m, WDL: wdlFromDTZ(dtz, int(b.Halfmoveclock)), DTZ: dtz}
+	}
+	sort.SliceStable(root, func(i, j int) bool {
+		if root[i].WDL != root[j].WDL {
+			return root[i].WDL > root[j].WDL
+		}
+		return root[i].DTZ < root[j].DTZ
+	})
+	return root, nil
+}
+
+// The result of a position with a DTZ, when halfmoves have already been played
+// since the last zeroing move.
+func wdlFromDTZ(dtz int, halfmoves int) WDL {
+	switch {
+	case dtz > 0 && dtz+halfmoves <= 100:
+		return Win
+	case dtz > 0:
+		return CursedWin
+	case dtz < 0 && -dtz+halfmoves <= 100:
+		return Loss
+	case dtz < 0:
+		return BlessedLoss
+	}
+	return Draw
+}
+
+// The DTZ of a position whose best move zeroes the 50-move counter.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+func isMate(b *dragontoothmg.Board) bool {
+	return b.OurKingInCheck() && len(b.GenerateLegalMoves()) == 0
+}
+
+func isCapture(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	if (b.White.All|b.Black.All)&(uint64(1)<<m.To()) != 0 {
+		return true
+	}
+	return isPawnMove(b, m) && m.From()&7 != m.To()&7 // en passant
+}
+
+func isPawnMove(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	return (b.White.Pawns|b.Black.Pawns)&(uint64(1)<<m.From()) != 0
+}
+
+// Finds the WDL result of a position. Tables may store any value for positions
+// where a capture is at least as good as the stored result, so the captures
+// (and, with checkZeroing, pawn moves) are searched too. zeroing reports
+// whether such a move is best, in which case a DTZ table may not hold the
+// position's value.
+func (tb *Tablebase) search(b *dragontoothmg.Board, checkZeroing bool) (wdl WDL, zeroing bool, err error) {
+	moves := b.GenerateLegalMoves()
+	best, searched := Loss, 0
+	for _, m := range moves {
+		if !isCapture(b, m) && (!checkZeroing || !isPawnMove(b, m)) {
+			continue
+		}
+		searched++
+		next := *b
+		next.Apply(m)
+		value, _, err := tb.search(&next, false)
+		if err != nil {
+			return Draw, false, err
+		}
+		if -value > best {
+			best = -value
+			if best >= Win {
+				return best, true, nil
+			}
+		}
+	}
+
+	// If every move was searched, the table may be wrong: it does not consider
+	// en passant, for one.
+	allSearched := searched > 0 && searched == len(moves)
+	value := best
+	if !allSearched {
+		if bits.OnesCount64(b.White.All|b.Black.All) == 2 {
+			value = Draw // no table for two kings
+		} else {
+			t, err := tb.table(b, wdlTable)
+			if err != nil {
+				return Draw, false, err
+			}
+			value = t.probeWDL(b)
+		}
+	}
+	if best >= value {
+		return best, best > Draw || allSearched, nil
+	}
+	return value, false, nil
+}
+
+// Finds the DTZ of a position. DTZ tables only store one side to move, so the
+// other side is found with a one-ply search.
+func (tb *Tablebase) probeDTZ(b *dragontoothmg.Board) (int, error) {
+	wdl, zeroing, err := tb.search(b, true)
+	if err != nil || wdl == Draw {
+		return 0, err
+	}
+	if zeroing {
+		return dtzBeforeZeroing(wdl), nil
+	}
+	t, err := tb.table(b, dtzTable)
+	if err != nil {
+		return 0, err
+	}
+	if dtz, ok := t.probeDTZ(b, wdl); ok {
+		if wdl == CursedWin || wdl == BlessedLoss {
+			dtz += 100
+		}
+		if wdl < 0 {
+			return -dtz, nil
+		}
+		return dtz, nil
+	}
+
+	minDTZ := 0xffff
+	for _, m := range b.GenerateLegalMoves() {
+		zeroingMove := isCapture(b, m) || isPawnMove(b, m)
+		next := *b
+		next.Apply(m)
+		var dtz int
+		if zeroingMove {
+			// The sign of the result after the move, and the DTZ before it.
+			value, _, err := tb.search(&next, false)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -dtzBeforeZeroing(value)
+		} else {
+			d, err := tb.probeDTZ(&next)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -d
+		}
+		if dtz == 1 && isMate(&next) {
+			minDTZ = 1
+		}
+		if !zeroingMove {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xffff {
+		return -1, nil // mated
+	}
+	return minDTZ, nil
+}
+
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
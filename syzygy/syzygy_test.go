@@ -0,0 +1,153 @@
+package syzygy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// Writes the test tables: a correct KQvK WDL table, a KQvK DTZ table where
+// every position is 5 moves from zeroing, a KPvK table where pawns on the
+// fifth rank or beyond win, and a drawn KPvKP table.
+func writeTestTables(t *testing.T) *Tablebase {
+	dir := t.TempDir()
+	kqk := fillItems(newTestTable("KQvK", wdlTable), func(b *dragontoothmg.Board) int {
+		if b.Wtomove {
+			return int(Win) + 2
+		}
+		moves := b.GenerateLegalMoves()
+		if len(moves) == 0 && !b.OurKingInCheck() {
+			return int(Draw) + 2 // stalemate
+		}
+		for _, m := range moves {
+			if b.White.Queens&(uint64(1)<<m.To()) != 0 {
+				return int(Draw) + 2
+			}
+		}
+		return int(Loss) + 2
+	})
+	writeTable(t, dir, "KQvK", wdlTable, kqk)
+	writeTable(t, dir, "KQvK", dtzTable, [2][4]testItem{{{single: 5, flags: flagSingleValue}}})
+
+	kpk := fillItems(newTestTable("KPvK", wdlTable), func(b *dragontoothmg.Board) int {
+		if b.White.Pawns < 1<<32 {
+			return int(Draw) + 2
+		} else if b.Wtomove {
+			return int(Win) + 2
+		}
+		return int(Loss) + 2
+	})
+	writeTable(t, dir, "KPvK", wdlTable, kpk)
+	var drawn [2][4]testItem
+	for f := range drawn[0] {
+		drawn[0][f] = testItem{single: int(Draw) + 2, flags: flagSingleValue}
+	}
+	writeTable(t, dir, "KPvKP", wdlTable, drawn)
+
+	tb, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.MaxPieces() != 4 {
+		t.Error("Expected 4 piece tables, got", tb.MaxPieces())
+	}
+	return tb
+}
+
+func TestProbe(t *testing.T) {
+	tb := writeTestTables(t)
+
+	t.Run("WDL", func(t *testing.T) {
+		tests := map[string]WDL{
+			"8/8/8/3k4/8/8/1Q6/K7 w - - 0 1":  Win,
+			"8/8/8/3k4/8/8/1Q6/K7 b - - 0 1":  Loss,
+			"k7/1q6/8/8/3K4/8/8/8 b - - 0 1":  Win,  // colors reversed
+			"8/8/8/8/8/2k5/1Q6/7K b - - 0 1":  Draw, // the queen hangs
+			"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1":  Draw, // stalemate
+			"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1":  Loss, // checkmate
+			"8/8/8/8/8/8/8/K6k w - - 0 1":     Draw,
+			"8/8/8/4P3/8/8/8/K6k w - - 0 1":   Win,
+			"8/8/8/4P3/8/8/8/K6k b - - 0 1":   Loss,
+			"8/8/8/8/8/4p3/8/K6k w - - 0 1":   Loss, // colors reversed
+			"8/8/8/8/8/4P3/8/K6k w - - 0 1":   Draw,
+			"8/8/8/3pP3/8/8/8/K6k w - d6 0 2": Win, // only by capturing en passant
+			"8/8/8/3pP3/8/8/8/K6k w - - 0 2":  Draw,
+		}
+		for fen, expected := range tests {
+			b := dragontoothmg.ParseFen(fen)
+			if wdl, err := tb.ProbeWDL(&b); err != nil || wdl != expected {
+				t.Error("Expected", expected, "for", fen, "but got", wdl, err)
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for fen, expected := range map[string]string{
+			"r3k3/8/8/8/8/8/8/4K3 w q - 0 1":                        ErrCastling.Error(),
+			"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1": ErrTooManyPieces.Error(),
+			"8/8/8/3k4/8/8/1R6/K7 w - - 0 1":                        "Missing Syzygy table KRvK.rtbw.",
+		} {
+			b := dragontoothmg.ParseFen(fen)
+			if _, err := tb.ProbeWDL(&b); err == nil || !strings.Contains(err.Error(), expected) {
+				t.Error("Expected", expected, "for", fen, "but got", err)
+			}
+		}
+	})
+
+	t.Run("DTZ", func(t *testing.T) {
+		tests := map[string]struct {
+			wdl WDL
+			dtz int
+		}{
+			"8/8/8/3k4/8/8/1Q6/K7 w - - 0 1":  {Win, 11},
+			"8/8/8/3k4/8/8/1Q6/K7 w - - 90 1": {CursedWin, 11},
+			"8/8/8/3k4/8/8/1Q6/K7 b - - 0 1":  {Loss, -12},
+			"8/8/8/8/8/2k5/1Q6/7K b - - 0 1":  {Draw, 0},
+			"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1":  {Loss, -1},
+			"8/8/8/3pP3/8/8/8/K6k w - d6 0 2": {Win, 1},
+			"k7/1q6/8/8/3K4/8/8/8 b - - 0 1":  {Win, 11},
+			"k7/1q6/8/8/3K4/8/8/8 b - - 89 1": {Win, 11},
+		}
+		for fen, expected := range tests {
+			b := dragontoothmg.ParseFen(fen)
+			if wdl, dtz, err := tb.ProbeDTZ(&b); err != nil || wdl != expected.wdl || dtz != expected.dtz {
+				t.Error("Expected", expected, "for", fen, "but got", wdl, dtz, err)
+			}
+		}
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		// The only move that does not lose is taking the queen.
+		b := dragontoothmg.ParseFen("8/8/8/8/8/2k5/1Q6/7K b - - 0 1")
+		moves, err := tb.ProbeRoot(&b)
+		if err != nil || len(moves) == 0 || moves[0].Move.String() != "c3b2" || moves[0].WDL != Draw ||
+			moves[len(moves)-1].WDL != Loss {
+			t.Error("Expected Kxb2 to draw, got", moves, err)
+		}
+
+		// Mate is the fastest win, even with the 50-move rule about to draw the
+		// game. Qb1, Qb2 and Qc7 stalemate.
+		for halfmoves, expected := range map[string]WDL{"0": Win, "90": CursedWin, "99": Draw} {
+			b = dragontoothmg.ParseFen("k7/8/1K6/8/8/8/8/2Q5 w - - " + halfmoves + " 60")
+			moves, err = tb.ProbeRoot(&b)
+			if err != nil || len(moves) == 0 || moves[0].Move.String() != "c1c8" || moves[0].WDL != Win ||
+				moves[0].DTZ != 1 {
+				t.Fatal("Expected mate with", halfmoves, "halfmoves, got", moves, err)
+			}
+			for _, m := range moves[1:] {
+				next := b
+				next.Apply(m.Move)
+				stalemate := len(next.GenerateLegalMoves()) == 0
+				if stalemate && m.WDL != Draw {
+					t.Error("Stalemate was not a draw:", m)
+				} else if !stalemate && (m.WDL != expected || (expected != Draw && m.DTZ != 13)) {
+					t.Error("Expected", expected, "in 13 with", halfmoves, "halfmoves, got", m)
+				}
+			}
+			if moves[len(moves)-1].WDL != Draw {
+				t.Error("The stalemates should be last:", moves)
+			}
+		}
+	})
+}
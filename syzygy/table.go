@@ -0,0 +1,665 @@
+package syzygy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The kinds of table files.
+type tableKind int
+
+const (
+	wdlTable tableKind = iota
+	dtzTable
+)
+
+var magics = [2][4]byte{{0x71, 0xe8, 0x23, 0x5d}, {0xd7, 0x66, 0x0c, 0xa5}}
+
+var extensions = [2]string{".rtbw", ".rtbz"}
+
+// The most pieces a table can have.
+const maxPieces = 7
+
+// Flags of a pairsData.
+const (
+	flagSTM         = 1 // the side to move stored in a DTZ table (0 for white)
+	flagMapped      = 2 // DTZ values go through the map
+	flagWinPlies    = 4 // winning DTZ values are in plies, rather than moves
+	flagLossPlies   = 8
+	flagWide        = 16 // the DTZ map has 16 bit entries
+	flagSingleValue = 128
+)
+
+// Pieces in table files are 1-6 for white pawn to king, and 9-14 for black.
+const blackPieceCode = 8
+
+// One compressed sub-table: for a side to move and, in tables with pawns, the
+// file of the leading pawn.
+type pairsData struct {
+	flags       uint8
+	pieces      [maxPieces]uint8 // the order in which pieces are encoded
+	groupLen    [maxPieces + 1]int
+	groupIdx    [maxPieces + 1]uint64 // the multiplier of each group; the last is the table size
+	singleValue int
+
+	// Values are compressed by recursive pairing into symbols, and the symbols
+	// into blocks with a canonical Huffman code. blockLength holds the number of
+	// values in each block, less one, and sparseIndex locates every span'th
+	// value to avoid summing them.
+	blockSize       uint64
+	span            uint64
+	blocksNum       int
+	blockLengthSize int
+	sparseIndexSize int
+	sparseIndex     []byte // 4 byte block and 2 byte offset per entry
+	blockLength     []byte // 16 bits per block
+	data            []byte
+	minSymLen       int
+	lowestSym       []byte // 16 bits per symbol length
+	base64          []uint64
+	btree           []byte  // 12 bit left and right children per symbol
+	symLen          []uint8 // the number of values a symbol expands to, less one
+	mapIdx          [4]int  // offsets into the DTZ map, plus one
+}
+
+// A table file, loaded into memory.
+type table struct {
+	kind            tableKind
+	white, black    string // the pieces of each side, as in the file name
+	symmetric       bool
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // of the leading color, then the other
+	items           [2][4]pairsData
+	dtzMap          []byte
+}
+
+var errCorrupt = errors.New("Corrupt Syzygy table.")
+
+// Reads and indexes a table file. The name is the file name without extension.
+func loadTable(path string, name string, kind tableKind) (*table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := newTable(name, kind)
+	if err := t.parse(data); err != nil {
+		return nil, errors.New("Corrupt Syzygy table " + path + ".")
+	}
+	return t, nil
+}
+
+// Sets up a table from its name, such as "KRPvKR", without reading the file.
+func newTable(name string, kind tableKind) *table {
+	t := &table{kind: kind}
+	t.white, t.black, _ = strings.Cut(name, "v")
+	t.symmetric = t.white == t.black
+	t.pieceCount = len(t.white) + len(t.black)
+	whitePawns, blackPawns := strings.Count(t.white, "P"), strings.Count(t.black, "P")
+	t.hasPawns = whitePawns+blackPawns > 0
+	for _, side := range []string{t.white, t.black} {
+		for _, piece := range "QRBNP" {
+			if strings.Count(side, string(piece)) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	// The leading color is the one with fewer pawns, which compresses better.
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		t.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		t.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+	return t
+}
+
+func (t *table) sides() int {
+	if t.kind == wdlTable && !t.symmetric {
+		return 2
+	}
+	return 1
+}
+
+func (t *table) files() int {
+	if t.hasPawns {
+		return 4
+	}
+	return 1
+}
+
+func (t *table) item(stm int, file int) *pairsData {
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.items[stm%t.sides()][file]
+}
+
+// Parses the table headers. The data must stay unchanged while the table is used.
+func (t *table) parse(data []byte) (err error) {
+	// The headers are trusted to be consistent; an index out of range means
+	// the file is corrupt.
+	defer func() {
+		if recover() != nil {
+			err = errCorrupt
+		}
+	}()
+	if len(data) < 5 || !bytes.Equal(data[:4], magics[t.kind][:]) ||
+		(data[4]&2 != 0) != t.hasPawns || (data[4]&1 != 0) != (t.sides() == 2) {
+		return errCorrupt
+	}
+	pos := 5
+	pp := t.hasPawns && t.pawnCount[1] > 0 // pawns on both sides
+	for f := 0; f < t.files(); f++ {
+		order := [2][2]int{{int(data[pos] & 0xf), 0xf}, {int(data[pos] >> 4), 0xf}}
+		if pp {
+			order[0][1], order[1][1] = int(data[pos+1]&0xf), int(data[pos+1]>>4)
+			pos++
+		}
+		pos++
+		for k := 0; k < t.pieceCount; k++ {
+			t.items[0][f].pieces[k] = data[pos] & 0xf
+			t.items[1][f].pieces[k] = data[pos] >> 4
+			pos++
+		}
+		for i := 0; i < t.sides(); i++ {
+			t.setGroups(&t.items[i][f], order[i], f)
+		}
+	}
+	pos += pos & 1
+
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			pos = t.items[i][f].setSizes(data, pos)
+		}
+	}
+	if t.kind == dtzTable {
+		pos = t.setDTZMap(data, pos)
+	}
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			d := &t.items[i][f]
+			d.sparseIndex, pos = data[pos:pos+6*d.sparseIndexSize], pos+6*d.sparseIndexSize
+		}
+	}
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			d := &t.items[i][f]
+			d.blockLength, pos = data[pos:pos+2*d.blockLengthSize], pos+2*d.blockLengthSize
+		}
+	}
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			d := &t.items[i][f]
+			pos = (pos + 63) &^ 63
+			size := d.blocksNum * int(d.blockSize)
+			d.data, pos = data[pos:pos+size], pos+size
+		}
+	}
+	return nil
+}
+
+// The index of the zero terminating groupLen, where groupIdx holds the table size.
+func (d *pairsData) tableSizeIndex() int {
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	return n
+}
+
+// Splits the pieces into groups, which are encoded together. The leading group
+// is the leading pawns, or the kings and, if there are unique pieces, one more
+// piece; pieces of the same kind form the other groups. order gives the
+// position of the leading group, and of the remaining pawns, in the encoding.
+func (t *table) setGroups(d *pairsData, order [2]int, file int) {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			if t.hasPawns {
+				idx *= leadPawnsSize[d.groupLen[0]][file]
+			} else if t.hasUniquePieces {
+				idx *= 31332
+			} else {
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// Reads the compression parameters, returning the position after them.
+func (d *pairsData) setSizes(data []byte, pos int) int {
+	d.flags = data[pos]
+	pos++
+	if d.flags&flagSingleValue != 0 {
+		d.blocksNum, d.blockLengthSize, d.sparseIndexSize = 0, 0, 0
+		d.singleValue = int(data[pos])
+		return pos + 1
+	}
+	d.blockSize = 1 << data[pos]
+	d.span = 1 << data[pos+1]
+	d.sparseIndexSize = int((d.groupIdx[d.tableSizeIndex()] + d.span - 1) / d.span)
+	padding := int(data[pos+2])
+	d.blocksNum = int(binary.LittleEndian.Uint32(data[pos+3:]))
+	d.blockLengthSize = d.blocksNum + padding // so that sparseIndex never points past the end
+	maxSymLen := int(data[pos+7])
+	d.minSymLen = int(data[pos+8])
+	pos += 9
+	if maxSymLen < d.minSymLen || d.minSymLen == 0 {
+		panic(errCorrupt)
+	}
+
+	// The canonical code gives longer codes lower values. base64[i] is the lowest
+	// code of length minSymLen + i, left-aligned in 64 bits, so that a code of
+	// that length read from the stream lies between base64[i] and base64[i-1].
+	lengths := maxSymLen - d.minSymLen + 1
+	d.lowestSym = data[pos : pos+2*lengths]
+	d.base64 = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSymbol(i)) - uint64(d.lowestSymbol(i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	pos += 2 * lengths
+
+	symbols := int(binary.LittleEndian.Uint16(data[pos:]))
+	pos += 2
+	d.btree = data[pos : pos+3*symbols]
+	d.symLen = make([]uint8, symbols)
+	visited := make([]bool, symbols)
+	for sym := 0; sym < symbols; sym++ {
+		if !visited[sym] {
+			d.symLen[sym] = d.setSymLen(sym, visited)
+		}
+	}
+	return pos + 3*symbols + symbols&1
+}
+
+func (d *pairsData) lowestSymbol(i int) uint16 {
+	return binary.LittleEndian.Uint16(d.lowestSym[2*i:])
+}
+
+func (d *pairsData) left(sym int) int {
+	return int(d.btree[3*sym+1]&0xf)<<8 | int(d.btree[3*sym])
+}
+
+func (d *pairsData) right(sym int) int {
+	return int(d.btree[3*sym+2])<<4 | int(d.btree[3*sym+1]>>4)
+}
+
+// Computes the number of values a symbol expands to, less one. Symbols
+// without a right child are values; the others are pairs of symbols.
+func (d *pairsData) setSymLen(sym int, visited []bool) uint8 {
+	visited[sym] = true
+	right := d.right(sym)
+	if right == 0xfff {
+		return 0
+	}
+	left := d.left(sym)
+	if !visited[left] {
+		d.symLen[left] = d.setSymLen(left, visited)
+	}
+	if !visited[right] {
+		d.symLen[right] = d.setSymLen(right, visited)
+	}
+	return d.symLen[left] + d.symLen[right] + 1
+}
+
+// Reads the DTZ value maps, which translate stored values to distances, one
+// map per WDL result and leading pawn file.
+func (t *table) setDTZMap(data []byte, pos int) int {
+	base := pos
+	t.dtzMap = data[base:]
+	for f := 0; f < t.files(); f++ {
+		d := &t.items[0][f]
+		if d.flags&flagMapped == 0 {
+			continue
+		}
+		if d.flags&flagWide != 0 {
+			pos += pos & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (pos-base)/2 + 1
+				pos += 2*int(binary.LittleEndian.Uint16(data[pos:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = pos - base + 1
+				pos += int(data[pos]) + 1
+			}
+		}
+	}
+	return pos + pos&1
+}
+
+// Reads 32 big-endian bits, which are zero past the end of the data.
+func (d *pairsData) read32(pos uint64) uint64 {
+	if pos+4 > uint64(len(d.data)) {
+		var buf [4]byte
+		if pos < uint64(len(d.data)) {
+			copy(buf[:], d.data[pos:])
+		}
+		return uint64(binary.BigEndian.Uint32(buf[:]))
+	}
+	return uint64(binary.BigEndian.Uint32(d.data[pos:]))
+}
+
+// Returns the value stored at an index.
+func (d *pairsData) decompress(idx uint64) int {
+	if d.flags&flagSingleValue != 0 {
+		return d.singleValue
+	}
+
+	// Find the block holding idx, starting from the nearest sparse index entry,
+	// which records the block and offset of the value at k * span + span / 2.
+	k := idx / d.span
+	entry := d.sparseIndex[6*k:]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+	for offset < 0 {
+		block--
+		offset += d.blockLen(block) + 1
+	}
+	for offset > d.blockLen(block) {
+		offset -= d.blockLen(block) + 1
+		block++
+	}
+
+	// Decode symbols from the start of the block until the one covering offset.
+	pos := uint64(block) * d.blockSize
+	buf := d.read32(pos)<<32 | d.read32(pos+4)
+	pos += 8
+	bufSize := 64
+	var sym int
+	for {
+		length := 0
+		for buf < d.base64[length] {
+			length++
+		}
+		sym = int((buf-d.base64[length])>>uint(64-length-d.minSymLen)) + int(d.lowestSymbol(length))
+		if offset < int(d.symLen[sym])+1 {
+			break
+		}
+		offset -= int(d.symLen[sym]) + 1
+		length += d.minSymLen
+		buf <<= uint(length)
+		bufSize -= length
+		if bufSize <= 32 {
+			bufSize += 32
+			buf |= d.read32(pos) << uint(64-bufSize)
+			pos += 4
+		}
+	}
+
+	// Expand the symbol's pairs down to the value at offset.
+	for d.symLen[sym] != 0 {
+		left := d.left(sym)
+		if offset < int(d.symLen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symLen[left]) + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym)
+}
+
+func (d *pairsData) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(d.blockLength[2*block:]))
+}
+
+// The code of the piece on a square in table files.
+func pieceCode(b *dragontoothmg.Board, sq int) uint8 {
+	piece, white := dragontoothmg.GetPieceType(uint8(sq), b)
+	if !white {
+		return uint8(piece) + blackPieceCode
+	}
+	return uint8(piece)
+}
+
+// The pieces of one side, in the order of table names.
+func sideName(bb *dragontoothmg.Bitboards) string {
+	var name strings.Builder
+	for _, piece := range []struct {
+		letter string
+		bb     uint64
+	}{{"K", bb.Kings}, {"Q", bb.Queens}, {"R", bb.Rooks}, {"B", bb.Bishops}, {"N", bb.Knights}, {"P", bb.Pawns}} {
+		name.WriteString(strings.Repeat(piece.letter, bits.OnesCount64(piece.bb)))
+	}
+	return name.String()
+}
+
+// Computes the sub-table and index of a position. The position's material must
+// match the table, with either color. ok is false if the position's side to
+// move is not stored in this DTZ table.
+func (t *table) encode(b *dragontoothmg.Board) (d *pairsData, idx uint64, ok bool) {
+	var squares [maxPieces]int
+	var pieces [maxPieces]uint8
+
+	// Tables store positions with the first side of the name as white, and
+	// symmetric tables store only white to move. Otherwise, flip the colors.
+	flip := (t.symmetric && !b.Wtomove) || (!t.symmetric && sideName(&b.White) != t.white)
+	var flipColor uint8
+	flipSquares, stm := 0, 0
+	if flip {
+		flipColor, flipSquares = blackPieceCode, 56
+	}
+	if flip == b.Wtomove {
+		stm = 1
+	}
+
+	// The leading pawns are those of the color of the first piece, and the
+	// one with the highest mapPawns value selects the sub-table by its file.
+	size, leadPawnsCount, file := 0, 0, 0
+	var leadPawns uint64
+	if t.hasPawns {
+		if t.items[0][0].pieces[0]^flipColor < blackPieceCode {
+			leadPawns = b.White.Pawns
+		} else {
+			leadPawns = b.Black.Pawns
+		}
+		for x := leadPawns; x != 0; x &= x - 1 {
+			squares[size] = bits.TrailingZeros64(x) ^ flipSquares
+			size++
+		}
+		leadPawnsCount = size
+		for i := 1; i < leadPawnsCount; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[0]] {
+				squares[0], squares[i] = squares[i], squares[0]
+			}
+		}
+		file = squares[0] & 7
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+	d = t.item(stm, file)
+	if t.kind == dtzTable && int(d.flags&flagSTM) != stm && (!t.symmetric || t.hasPawns) {
+		return d, 0, false
+	}
+
+	for x := (b.White.All | b.Black.All) &^ leadPawns; x != 0; x &= x - 1 {
+		sq := bits.TrailingZeros64(x)
+		squares[size] = sq ^ flipSquares
+		pieces[size] = pieceCode(b, sq) ^ flipColor
+		size++
+	}
+
+	// Put the pieces in the order of the table.
+	for i := leadPawnsCount; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror the board so that the leading piece is on files a-d.
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	if t.hasPawns {
+		idx = leadPawnIdx[leadPawnsCount][squares[0]]
+		sortSquares(squares[1:leadPawnsCount], pawnKey)
+		for i := 1; i < leadPawnsCount; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		// Without pawns, also mirror to put the leading piece on ranks 1-4, and
+		// then the first piece of the leading group off the diagonal below it.
+		if squares[0]>>3 > 3 {
+			for i := 0; i < size; i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+		idx = encodeLeadingPieces(t.hasUniquePieces, squares[:])
+	}
+
+	// Encode the remaining groups in ascending order of squares, skipping the
+	// squares taken by earlier groups, and rank 1 for the remaining pawns.
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sortSquares(group, squareKey)
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, earlier := range squares[:start] {
+				if sq > earlier {
+					adjust++
+				}
+			}
+			sq -= adjust
+			if remainingPawns {
+				sq -= 8
+			}
+			n += binomial[i+1][sq]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += len(group)
+	}
+	return d, idx, true
+}
+
+// Encodes the leading group of a pawnless table: the first three pieces if
+// there are unique pieces, or else the two kings. The first piece must be in the
+// a1-d1-d4 triangle, and the first piece off the diagonal below it.
+func encodeLeadingPieces(uniquePieces bool, squares []int) uint64 {
+	if !uniquePieces {
+		return mapKK[mapA1D1D4[squares[0]]][squares[1]]
+	}
+	s0, s1, s2 := squares[0], squares[1], squares[2]
+	adjust1, adjust2 := 0, 0
+	if s1 > s0 {
+		adjust1++
+	}
+	if s2 > s0 {
+		adjust2++
+	}
+	if s2 > s1 {
+		adjust2++
+	}
+	switch {
+	case offA1H8(s0) != 0:
+		return uint64((mapA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2)
+	case offA1H8(s1) != 0:
+		return uint64((6*63+(s0>>3)*28+mapB1H1H7[s1])*62 + s2 - adjust2)
+	case offA1H8(s2) != 0:
+		return uint64(6*63*62 + 4*28*62 + (s0>>3)*7*28 + (s1>>3-adjust1)*28 + mapB1H1H7[s2])
+	}
+	return uint64(6*63*62 + 4*28*62 + 4*7*28 + (s0>>3)*7*6 + (s1>>3-adjust1)*6 + s2>>3 - adjust2)
+}
+
+// The WDL result of a position from a WDL table.
+func (t *table) probeWDL(b *dragontoothmg.Board) WDL {
+	d, idx, _ := t.encode(b)
+	return WDL(d.decompress(idx) - 2)
+}
+
+// The DTZ of a position in plies, given its WDL result, from a DTZ table. ok is
+// false if the table does not store the position's side to move.
+func (t *table) probeDTZ(b *dragontoothmg.Board, wdl WDL) (dtz int, ok bool) {
+	d, idx, ok := t.encode(b)
+	if !ok {
+		return 0, false
+	}
+	value := d.decompress(idx)
+	if d.flags&flagMapped != 0 {
+		// The maps are stored in the order win, loss, cursed win, blessed loss.
+		mapIdx := d.mapIdx[[5]int{1, 3, 0, 2, 0}[wdl+2]]
+		if d.flags&flagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.dtzMap[2*(mapIdx+value):]))
+		} else {
+			value = int(t.dtzMap[mapIdx+value])
+		}
+	}
+	if (wdl == Win && d.flags&flagWinPlies == 0) || (wdl == Loss && d.flags&flagLossPlies == 0) ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2 // stored in moves
+	}
+	return value + 1, true
+}
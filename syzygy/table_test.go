@@ -0,0 +1,386 @@
+package syzygy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The tests cannot download real tables, so they write their own, in the
+// Syzygy format but with a fixed Huffman code: values 0-3 have 3 bit codes,
+// and value 4 (a win, or a loss for DTZ) and a pair of 4s have 2 bit codes.
+
+var testSymbolCodes = [6]struct{ code, length uint64 }{{0, 3}, {1, 3}, {2, 3}, {3, 3}, {2, 2}, {3, 2}}
+
+const (
+	testBlockSizeLog = 6
+	testSpanLog      = 5
+)
+
+// Describes the contents of one sub-table to writeTable.
+type testItem struct {
+	values []int // nil for a single value table
+	single int
+	flags  uint8
+}
+
+// The piece order that the test tables use: the leading pawns, the other pawns,
+// the kings and then the other pieces, white first.
+func testPieceOrder(t *table) []uint8 {
+	var order []uint8
+	codes := func(side string, color uint8, pawns bool) {
+		for _, letter := range side {
+			if (letter == 'P') == pawns && letter != 'K' {
+				order = append(order, uint8(strings.IndexRune(" PNBRQK", letter))|color)
+			}
+		}
+	}
+	if t.hasPawns {
+		leading, other := uint8(0), uint8(blackPieceCode)
+		if t.pawnCount[0] != strings.Count(t.white, "P") {
+			leading, other = other, leading
+		}
+		sides := map[uint8]string{0: t.white, blackPieceCode: t.black}
+		codes(sides[leading], leading, true)
+		codes(sides[other], other, true)
+	}
+	order = append(order, 6, 6|blackPieceCode)
+	codes(t.white, 0, false)
+	codes(t.black, blackPieceCode, false)
+	return order
+}
+
+// Sets up a table's sub-tables as writeTable will write them.
+func newTestTable(name string, kind tableKind) *table {
+	t := newTable(name, kind)
+	order := [2]int{0, 0xf}
+	if t.hasPawns && t.pawnCount[1] > 0 {
+		order[1] = 1
+	}
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			copy(t.items[i][f].pieces[:], testPieceOrder(t))
+			t.setGroups(&t.items[i][f], order, f)
+		}
+	}
+	return t
+}
+
+// Calls visit with every legal placement of a table's pieces, for each side to
+// move. The boards only have their bitboards set.
+func forEachPosition(t *table, visit func(b *dragontoothmg.Board)) {
+	var pieces []string
+	for _, letter := range t.white {
+		pieces = append(pieces, "w"+string(letter))
+	}
+	for _, letter := range t.black {
+		pieces = append(pieces, "b"+string(letter))
+	}
+	var b dragontoothmg.Board
+	var place func(i int)
+	place = func(i int) {
+		if i == len(pieces) {
+			for _, wtomove := range []bool{true, false} {
+				b.Wtomove = !wtomove
+				if b.OurKingInCheck() { // the side not to move is in check
+					continue
+				}
+				b.Wtomove = wtomove
+				visit(&b)
+			}
+			return
+		}
+		side := &b.White
+		if pieces[i][0] == 'b' {
+			side = &b.Black
+		}
+		bb := map[byte]*uint64{'K': &side.Kings, 'Q': &side.Queens, 'R': &side.Rooks, 'B': &side.Bishops,
+			'N': &side.Knights, 'P': &side.Pawns}[pieces[i][1]]
+		for sq := 0; sq < 64; sq++ {
+			bit := uint64(1) << uint(sq)
+			if (b.White.All|b.Black.All)&bit != 0 || (pieces[i][1] == 'P' && (sq < 8 || sq >= 56)) {
+				continue
+			}
+			*bb |= bit
+			side.All |= bit
+			place(i + 1)
+			*bb &^= bit
+			side.All &^= bit
+		}
+	}
+	place(0)
+}
+
+// Fills the sub-tables of a table by calling value for every legal position.
+// Indexes that no position reaches are left as draws.
+func fillItems(t *table, value func(b *dragontoothmg.Board) int) [2][4]testItem {
+	var items [2][4]testItem
+	for f := 0; f < t.files(); f++ {
+		for i := 0; i < t.sides(); i++ {
+			d := &t.items[i][f]
+			items[i][f].values = make([]int, d.groupIdx[d.tableSizeIndex()])
+			for j := range items[i][f].values {
+				items[i][f].values[j] = 2
+			}
+		}
+	}
+	forEachPosition(t, func(b *dragontoothmg.Board) {
+		d, idx, ok := t.encode(b)
+		if !ok {
+			return
+		}
+		for f := 0; f < t.files(); f++ {
+			for i := 0; i < t.sides(); i++ {
+				if d == &t.items[i][f] {
+					items[i][f].values[idx] = value(b)
+				}
+			}
+		}
+	})
+	return items
+}
+
+// Compresses values into blocks, returning the blocks and the number of values
+// in each.
+func compressValues(values []int) (data []byte, lengths []int) {
+	blockBits := uint64(8 << testBlockSizeLog)
+	for i := 0; i < len(values); {
+		block := make([]byte, 1<<testBlockSizeLog)
+		used, count := uint64(0), 0
+		for i < len(values) {
+			sym, n := values[i], 1
+			if values[i] == 4 && i+1 < len(values) && values[i+1] == 4 {
+				sym, n = 5, 2
+			}
+			code := testSymbolCodes[sym]
+			if used+code.length > blockBits {
+				break
+			}
+			for bit := uint64(0); bit < code.length; bit++ {
+				if code.code>>(code.length-1-bit)&1 != 0 {
+					block[(used+bit)/8] |= 0x80 >> ((used + bit) % 8)
+				}
+			}
+			used += code.length
+			count += n
+			i += n
+		}
+		data = append(data, block...)
+		lengths = append(lengths, count)
+	}
+	return data, lengths
+}
+
+// Writes a table file, with the piece order of testPieceOrder.
+func writeTable(t *testing.T, dir string, name string, kind tableKind, items [2][4]testItem) {
+	tbl := newTestTable(name, kind)
+	var out bytes.Buffer
+	out.Write(magics[kind][:])
+	flags := byte(0)
+	if tbl.sides() == 2 {
+		flags |= 1
+	}
+	if tbl.hasPawns {
+		flags |= 2
+	}
+	out.WriteByte(flags)
+	pp := tbl.hasPawns && tbl.pawnCount[1] > 0
+	for f := 0; f < tbl.files(); f++ {
+		out.WriteByte(0)
+		if pp {
+			out.WriteByte(0x11)
+		}
+		for _, piece := range testPieceOrder(tbl) {
+			out.WriteByte(piece | piece<<4)
+		}
+	}
+	if out.Len()&1 != 0 {
+		out.WriteByte(0)
+	}
+
+	type compressed struct {
+		data    []byte
+		lengths []int
+	}
+	var blocks [2][4]compressed
+	for f := 0; f < tbl.files(); f++ {
+		for i := 0; i < tbl.sides(); i++ {
+			item := items[i][f]
+			out.WriteByte(item.flags)
+			if item.values == nil {
+				out.WriteByte(byte(item.single))
+				continue
+			}
+			data, lengths := compressValues(item.values)
+			blocks[i][f] = compressed{data, lengths}
+			out.Write([]byte{testBlockSizeLog, testSpanLog, 1})
+			binary.Write(&out, binary.LittleEndian, uint32(len(lengths)))
+			out.Write([]byte{3, 2})                                        // maximum and minimum code lengths
+			binary.Write(&out, binary.LittleEndian, []uint16{4, 0, 6})     // lowest symbols, symbol count
+			out.Write([]byte{0, 0xf0, 0xff, 1, 0xf0, 0xff, 2, 0xf0, 0xff}) // values 0-2
+			out.Write([]byte{3, 0xf0, 0xff, 4, 0xf0, 0xff, 4, 0x40, 0x00}) // 3, 4, and a pair of 4s
+		}
+	}
+	for f := 0; f < tbl.files(); f++ {
+		for i := 0; i < tbl.sides(); i++ {
+			if items[i][f].values == nil {
+				continue
+			}
+			// Each entry locates the value at k * span + span / 2.
+			lengths := blocks[i][f].lengths
+			span := 1 << testSpanLog
+			for k := 0; k*span < len(items[i][f].values); k++ {
+				target := k*span + span/2
+				block, start := 0, 0
+				for block < len(lengths)-1 && start+lengths[block] <= target {
+					start += lengths[block]
+					block++
+				}
+				binary.Write(&out, binary.LittleEndian, uint32(block))
+				binary.Write(&out, binary.LittleEndian, uint16(target-start))
+			}
+		}
+	}
+	for f := 0; f < tbl.files(); f++ {
+		for i := 0; i < tbl.sides(); i++ {
+			for _, length := range blocks[i][f].lengths {
+				binary.Write(&out, binary.LittleEndian, uint16(length-1))
+			}
+			if items[i][f].values != nil {
+				binary.Write(&out, binary.LittleEndian, uint16(0)) // padding
+			}
+		}
+	}
+	for f := 0; f < tbl.files(); f++ {
+		for i := 0; i < tbl.sides(); i++ {
+			for out.Len()%64 != 0 {
+				out.WriteByte(0)
+			}
+			out.Write(blocks[i][f].data)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+extensions[kind]), out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexTables(t *testing.T) {
+	if mapKK[9][63] == 0 || mapA1D1D4[27] != 9 || mapB1H1H7[55] != 27 {
+		t.Error("Unexpected symmetry maps:", mapKK[9][63], mapA1D1D4[27], mapB1H1H7[55])
+	}
+	max := uint64(0)
+	for i := range mapKK {
+		for _, code := range mapKK[i] {
+			if code > max {
+				max = code
+			}
+		}
+	}
+	if max != 461 {
+		t.Error("Expected 462 placements of two kings, got", max+1)
+	}
+	if binomial[2][5] != 10 || binomial[5][63] != 7028847 || binomial[0][40] != 1 {
+		t.Error("Unexpected binomials:", binomial[2][5], binomial[5][63], binomial[0][40])
+	}
+	if mapPawns[8] != 47 || mapPawns[15] != 46 || mapPawns[16] != 45 || mapPawns[52] != 0 {
+		t.Error("Unexpected pawn map:", mapPawns[8], mapPawns[15], mapPawns[16], mapPawns[52])
+	}
+	if leadPawnsSize[1][0] != 6 || leadPawnsSize[2][0] != 47+45+43+41+39+37 {
+		t.Error("Unexpected leading pawn sizes:", leadPawnsSize[1][0], leadPawnsSize[2][0])
+	}
+}
+
+// Positions that are the same up to symmetry must have the same index, and
+// indexes must fit in the table.
+func TestEncodingSymmetry(t *testing.T) {
+	fens := map[string][]string{
+		"KQvK": {"8/8/8/3k4/8/8/1Q6/K7 w - - 0 1", "7K/6Q1/8/8/4k3/8/8/8 w - - 0 1",
+			"k7/1q6/8/8/3K4/8/8/8 b - - 0 1", "8/8/8/4k3/8/8/6Q1/7K w - - 0 1"},
+		"KRvKR": {"8/8/8/3k4/8/2r5/1R6/K7 w - - 0 1", "k7/1r6/2R5/8/3K4/8/8/8 b - - 0 1"},
+		"KPvK":  {"8/8/8/3k4/8/8/1P6/K7 w - - 0 1", "8/8/8/4k3/8/8/6P1/7K w - - 0 1", "k7/1p6/8/8/3K4/8/8/8 b - - 0 1"},
+		"KPvKP": {"8/p7/8/3k4/8/8/1P6/K7 w - - 0 1", "k7/1p6/8/8/3K4/8/P7/8 b - - 0 1"},
+	}
+	for name, positions := range fens {
+		tbl := newTestTable(name, wdlTable)
+		var first uint64
+		for i, fen := range positions {
+			b := dragontoothmg.ParseFen(fen)
+			d, idx, _ := tbl.encode(&b)
+			if idx >= d.groupIdx[d.tableSizeIndex()] {
+				t.Error("Index out of range for", fen)
+			}
+			if i == 0 {
+				first = idx
+			} else if idx != first {
+				t.Error("Symmetric positions", positions[0], "and", fen, "have different indexes:", first, idx)
+			}
+		}
+	}
+
+	// Every legal position has a distinct index, up to symmetry.
+	tbl := newTestTable("KRvK", wdlTable)
+	type key struct {
+		d   *pairsData
+		idx uint64
+	}
+	seen := map[key]bool{}
+	count := 0
+	forEachPosition(tbl, func(b *dragontoothmg.Board) {
+		d, idx, _ := tbl.encode(b)
+		seen[key{d, idx}] = true
+		if idx >= d.groupIdx[d.tableSizeIndex()] {
+			t.Fatal("Index out of range for", b.ToFen())
+		}
+		count++
+	})
+	if len(seen) < count/8 || len(seen) > count {
+		t.Error("Unexpected number of distinct indexes:", len(seen), "for", count, "positions")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	dir := t.TempDir()
+	tbl := newTestTable("KPvK", wdlTable)
+	var items [2][4]testItem
+	for f := 0; f < 4; f++ {
+		for i := 0; i < 2; i++ {
+			d := &tbl.items[i][f]
+			values := make([]int, d.groupIdx[d.tableSizeIndex()])
+			for j := range values {
+				values[j] = (j + i + f) % 4
+				if j/5%3 == 0 { // runs of wins, which are encoded in pairs
+					values[j] = 4
+				}
+			}
+			items[i][f].values = values
+		}
+	}
+	writeTable(t, dir, "KPvK", wdlTable, items)
+	loaded, err := loadTable(filepath.Join(dir, "KPvK.rtbw"), "KPvK", wdlTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f := 0; f < 4; f++ {
+		for i := 0; i < 2; i++ {
+			for idx, value := range items[i][f].values {
+				if got := loaded.items[i][f].decompress(uint64(idx)); got != value {
+					t.Fatal("Expected", value, "at", i, f, idx, "but got", got)
+				}
+			}
+		}
+	}
+
+	// A corrupt file is an error, not a panic.
+	data, _ := os.ReadFile(filepath.Join(dir, "KPvK.rtbw"))
+	if err := newTable("KPvK", wdlTable).parse(data[:200]); err == nil {
+		t.Error("A truncated table was accepted.")
+	}
+	if err := newTable("KQvK", wdlTable).parse(data); err == nil {
+		t.Error("A table with the wrong pieces was accepted.")
+	}
+}
@@ -131,6 +131,9 @@ func (b *Board) kingControls() uint64 {
 		ourKing = b.Black.Kings
 	}
 
+	if ourKing == 0 { // no king, as for white in Horde
+		return 0
+	}
 	ourKingLocation := uint8(bits.TrailingZeros64(ourKing))
 
 	targets := kingMasks[ourKingLocation]
@@ -155,6 +158,9 @@ func (b *Board) generatePinnedThreats() (uint64,uint64) {
 		oppPieces = &(b.White)
 	}
 	allPieces := oppPieces.All | ourPieces.All
+	if ourPieces.Kings == 0 { // nothing is pinned without a king
+		return 0, 0
+	}
 
 	// Calculate king moves as if it was a rook.
 	// "king targets" includes our own friendly pieces, for the purpose of identifying pins.
@@ -0,0 +1,198 @@
+package trainingdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A chained file starts with the magic, followed by chains. A chain stores a run
+// of consecutive positions of one game, each the position after the previous
+// entry's move, one ply later and with the result negated. Its first entry is:
+//
+//	uvarint: length of the board, then the board as Board.MarshalBinary
+//	2 bytes: move, little-endian
+//	varint: score
+//	uvarint: ply
+//	1 byte: result, as a signed byte
+//
+// and then a uvarint count of the entries that follow, each stored as:
+//
+//	2 bytes: move, little-endian
+//	varint: score plus the previous entry's score
+//
+// Since scores mostly keep their size and change sign between plies, the score
+// is usually one byte.
+var chainedMagic = []byte("DTCH")
+
+var errCorrupt = errors.New("Corrupt chained training data.")
+
+// Writes entries in the chained format. Entries continue the current chain as
+// long as they follow on from the previous one, so the entries of each game
+// should be written in order.
+type ChainedWriter struct {
+	w       *bufio.Writer
+	started bool
+	chain   []Entry // the unwritten chain
+}
+
+func NewChainedWriter(w io.Writer) *ChainedWriter {
+	return &ChainedWriter{w: bufio.NewWriter(w)}
+}
+
+// Writes an entry. It is buffered until its chain ends or Flush is called.
+func (cw *ChainedWriter) Write(e Entry) error {
+	if len(cw.chain) > 0 && !continues(&cw.chain[len(cw.chain)-1], &e) {
+		if err := cw.writeChain(); err != nil {
+			return err
+		}
+	}
+	cw.chain = append(cw.chain, e)
+	return nil
+}
+
+// Writes the current chain and any buffered data to the underlying writer. The
+// next entry starts a new chain.
+func (cw *ChainedWriter) Flush() error {
+	if err := cw.writeChain(); err != nil {
+		return err
+	}
+	return cw.w.Flush()
+}
+
+func (cw *ChainedWriter) writeChain() error {
+	if len(cw.chain) == 0 {
+		return nil
+	}
+	var buf []byte
+	if !cw.started {
+		buf = append(buf, chainedMagic...)
+		cw.started = true
+	}
+	first := &cw.chain[0]
+	board, err := first.Board.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(board)))
+	buf = append(buf, board...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(first.Move))
+	buf = binary.AppendVarint(buf, int64(first.Score))
+	buf = binary.AppendUvarint(buf, uint64(first.Ply))
+	buf = append(buf, byte(first.Result))
+	buf = binary.AppendUvarint(buf, uint64(len(cw.chain)-1))
+	for i := 1; i < len(cw.chain); i++ {
+		e := &cw.chain[i]
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(e.Move))
+		buf = binary.AppendVarint(buf, int64(e.Score)+int64(cw.chain[i-1].Score))
+	}
+	cw.chain = cw.chain[:0]
+	_, err = cw.w.Write(buf)
+	return err
+}
+
+// Reads entries in the chained format.
+type ChainedReader struct {
+	r         *bufio.Reader
+	started   bool
+	last      Entry // the previous entry of the current chain
+	remaining uint64
+}
+
+func NewChainedReader(r io.Reader) *ChainedReader {
+	return &ChainedReader{r: bufio.NewReader(r)}
+}
+
+// Reads the next entry. Returns io.EOF when there are no more entries.
+func (cr *ChainedReader) Read() (Entry, error) {
+	if !cr.started {
+		magic := make([]byte, len(chainedMagic))
+		if _, err := io.ReadFull(cr.r, magic); err == io.EOF {
+			return Entry{}, io.EOF
+		} else if err != nil || !bytes.Equal(magic, chainedMagic) {
+			return Entry{}, errCorrupt
+		}
+		cr.started = true
+	}
+	if cr.remaining > 0 {
+		return cr.readNext()
+	}
+	if _, err := cr.r.Peek(1); err == io.EOF {
+		return Entry{}, io.EOF
+	}
+	return cr.readFirst()
+}
+
+// Reads the first entry of a chain.
+func (cr *ChainedReader) readFirst() (Entry, error) {
+	var e Entry
+	length, err := binary.ReadUvarint(cr.r)
+	if err != nil || length > 64 {
+		return e, errCorrupt
+	}
+	board := make([]byte, length)
+	if _, err := io.ReadFull(cr.r, board); err != nil {
+		return e, errCorrupt
+	}
+	if err := e.Board.UnmarshalBinary(board); err != nil {
+		return e, err
+	}
+	if e.Move, err = cr.readMove(); err != nil {
+		return e, err
+	}
+	score, err := binary.ReadVarint(cr.r)
+	if err != nil || int64(int16(score)) != score {
+		return e, errCorrupt
+	}
+	e.Score = int16(score)
+	ply, err := binary.ReadUvarint(cr.r)
+	if err != nil || ply > 0xffff {
+		return e, errCorrupt
+	}
+	e.Ply = uint16(ply)
+	result, err := cr.r.ReadByte()
+	if err != nil || int8(result) < -1 || int8(result) > 1 {
+		return e, errCorrupt
+	}
+	e.Result = int8(result)
+	if cr.remaining, err = binary.ReadUvarint(cr.r); err != nil {
+		return e, errCorrupt
+	}
+	cr.last = e
+	return e, nil
+}
+
+// Reads an entry that continues the chain.
+func (cr *ChainedReader) readNext() (Entry, error) {
+	prev := &cr.last
+	if prev.Move == 0 || !prev.Board.IsLegal(prev.Move) {
+		return Entry{}, errCorrupt
+	}
+	e := Entry{Board: prev.Board, Ply: prev.Ply + 1, Result: -prev.Result}
+	e.Board.Apply(prev.Move)
+	var err error
+	if e.Move, err = cr.readMove(); err != nil {
+		return e, err
+	}
+	score, err := binary.ReadVarint(cr.r)
+	score -= int64(prev.Score)
+	if err != nil || int64(int16(score)) != score {
+		return e, errCorrupt
+	}
+	e.Score = int16(score)
+	cr.remaining--
+	cr.last = e
+	return e, nil
+}
+
+func (cr *ChainedReader) readMove() (dragontoothmg.Move, error) {
+	var move [2]byte
+	if _, err := io.ReadFull(cr.r, move[:]); err != nil {
+		return 0, errCorrupt
+	}
+	return dragontoothmg.Move(binary.LittleEndian.Uint16(move[:])), nil
+}
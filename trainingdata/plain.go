@@ -0,0 +1,120 @@
+package trainingdata
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The plain format lists each entry as lines of a key and a value, ended by a
+// line holding "e":
+//
+//	fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1
+//	move e2e4
+//	score 32
+//	ply 0
+//	result 0
+//	e
+
+// Writes entries in the plain text format.
+type PlainWriter struct {
+	w *bufio.Writer
+}
+
+func NewPlainWriter(w io.Writer) *PlainWriter {
+	return &PlainWriter{w: bufio.NewWriter(w)}
+}
+
+// Writes an entry. It may be buffered until Flush is called.
+func (pw *PlainWriter) Write(e Entry) error {
+	_, err := fmt.Fprintf(pw.w, "fen %s\nmove %s\nscore %d\nply %d\nresult %d\ne\n",
+		e.Board.ToFen(), e.Move.String(), e.Score, e.Ply, e.Result)
+	return err
+}
+
+// Writes any buffered data to the underlying writer.
+func (pw *PlainWriter) Flush() error {
+	return pw.w.Flush()
+}
+
+// Reads entries in the plain text format.
+type PlainReader struct {
+	// The rules to parse positions with, Standard by default.
+	Variant dragontoothmg.Variant
+
+	s    *bufio.Scanner
+	line int
+}
+
+func NewPlainReader(r io.Reader) *PlainReader {
+	return &PlainReader{s: bufio.NewScanner(r)}
+}
+
+// Reads the next entry. Returns io.EOF when there are no more entries, and
+// io.ErrUnexpectedEOF if the input ends inside an entry.
+func (pr *PlainReader) Read() (Entry, error) {
+	var e Entry
+	seen := make(map[string]bool)
+	for pr.s.Scan() {
+		pr.line++
+		line := strings.TrimSpace(pr.s.Text())
+		if line == "" && len(seen) == 0 {
+			continue
+		}
+		if line == "e" {
+			if !seen["fen"] || !seen["move"] {
+				return e, pr.errorf("Entry without a fen or a move.")
+			}
+			return e, nil
+		}
+		key, value, _ := strings.Cut(line, " ")
+		if seen[key] {
+			return e, pr.errorf("Repeated " + key + ".")
+		}
+		seen[key] = true
+		var err error
+		switch key {
+		case "fen":
+			e.Board.Variant = pr.Variant
+			err = e.Board.UnmarshalText([]byte(value))
+		case "move":
+			err = e.Move.UnmarshalText([]byte(value))
+		case "score":
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 16)
+			e.Score = int16(n)
+		case "ply":
+			var n uint64
+			n, err = strconv.ParseUint(value, 10, 16)
+			e.Ply = uint16(n)
+		case "result":
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 8)
+			if n < -1 || n > 1 {
+				err = errors.New("Result out of range.")
+			}
+			e.Result = int8(n)
+		default:
+			err = errors.New("Unknown key.")
+		}
+		if err != nil {
+			return e, pr.errorf(line + ": " + err.Error())
+		}
+	}
+	if err := pr.s.Err(); err != nil {
+		return e, err
+	}
+	if len(seen) != 0 {
+		return e, io.ErrUnexpectedEOF
+	}
+	return e, io.EOF
+}
+
+func (pr *PlainReader) errorf(msg string) error {
+	return errors.New("Line " + strconv.Itoa(pr.line) + ": " + msg)
+}
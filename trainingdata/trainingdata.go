@@ -0,0 +1,34 @@
+// Package trainingdata reads and writes training data for NNUE networks: scored
+// positions, each with the move played and the result of its game.
+//
+// Two formats are supported. The plain text format is the one read and written
+// by the Stockfish trainer tools, which convert it to their other formats. The
+// chained format is a compact binary one in the style of binpack: consecutive
+// positions of a game are stored as the move between them and a small score
+// difference, usually three bytes per position. It is specific to this package,
+// and is not the Stockfish binpack encoding.
+package trainingdata
+
+import (
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A training position.
+type Entry struct {
+	Board  dragontoothmg.Board
+	Move   dragontoothmg.Move // the move played, or searched to be best
+	Score  int16              // in centipawns, from the side to move's point of view
+	Ply    uint16             // the number of plies played in the game so far
+	Result int8               // 1, 0 or -1 for a win, draw or loss for the side to move
+}
+
+// Whether next is the position after e in the same game, so that it can be
+// stored as e's move.
+func continues(e *Entry, next *Entry) bool {
+	if e.Move == 0 || next.Ply != e.Ply+1 || next.Result != -e.Result || !e.Board.IsLegal(e.Move) {
+		return false
+	}
+	board := e.Board
+	board.Apply(e.Move)
+	return board == next.Board
+}
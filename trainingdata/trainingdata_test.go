@@ -0,0 +1,115 @@
+package trainingdata
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The entries of a game played from the starting position, with made up scores,
+// followed by an unrelated position.
+func testEntries(t *testing.T) []Entry {
+	var entries []Entry
+	b := dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	result := int8(1)
+	for i, str := range []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1b5", "a7a6", "e1g1", "g8f6"} {
+		m, err := dragontoothmg.ParseMove(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		score := int16(20 + i*5)
+		if i%2 == 1 {
+			score = -score
+		}
+		entries = append(entries, Entry{Board: b, Move: m, Score: score, Ply: uint16(i), Result: result})
+		b.Apply(m)
+		result = -result
+	}
+	m, _ := dragontoothmg.ParseMove("a7a8n")
+	entries = append(entries, Entry{Board: dragontoothmg.ParseFen("4k3/P7/8/8/8/8/8/4K3 w - - 3 60"),
+		Move: m, Score: -1200, Ply: 118, Result: 0})
+	return entries
+}
+
+func TestPlain(t *testing.T) {
+	entries := testEntries(t)
+	var buf bytes.Buffer
+	w := NewPlainWriter(&buf)
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	expected := "fen " + dragontoothmg.Startpos + "\nmove e2e4\nscore 20\nply 0\nresult 1\ne\n"
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Error("Expected the output to start with", expected, "but got", buf.String()[:len(expected)])
+	}
+	r := NewPlainReader(&buf)
+	for _, e := range entries {
+		if read, err := r.Read(); err != nil || read != e {
+			t.Error("Expected", e.Board.ToFen(), e.Move.String(), "but got", read.Board.ToFen(), read.Move.String(), err)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Error("Expected io.EOF but got", err)
+	}
+
+	for _, input := range []string{
+		"fen 8/8 w - -\nmove e2e4\ne\n",
+		"fen " + dragontoothmg.Startpos + "\nmove e2e9\ne\n",
+		"fen " + dragontoothmg.Startpos + "\nmove e2e4\nresult 2\ne\n",
+		"fen " + dragontoothmg.Startpos + "\nscore 1\ne\n",
+		"fen " + dragontoothmg.Startpos + "\nmove e2e4\n",
+	} {
+		if _, err := NewPlainReader(strings.NewReader(input)).Read(); err == nil {
+			t.Error("Expected an error reading", input)
+		}
+	}
+}
+
+func TestChained(t *testing.T) {
+	entries := testEntries(t)
+	var buf bytes.Buffer
+	w := NewChainedWriter(&buf)
+	for _, e := range entries {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	// The game is one chain of 8 entries, and the last entry a chain of its own.
+	if buf.Len() > 4+2*(1+31+2+1+1+1+1)+7*3 {
+		t.Error("Expected the game to be chained, but the output is", buf.Len(), "bytes")
+	}
+	data := buf.Bytes()
+	r := NewChainedReader(bytes.NewReader(data))
+	for _, e := range entries {
+		if read, err := r.Read(); err != nil || read != e {
+			t.Error("Expected", e.Board.ToFen(), e.Move.String(), e.Score, "but got",
+				read.Board.ToFen(), read.Move.String(), read.Score, err)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Error("Expected io.EOF but got", err)
+	}
+	if _, err := NewChainedReader(bytes.NewReader(nil)).Read(); err != io.EOF {
+		t.Error("Expected io.EOF for an empty file but got", err)
+	}
+
+	// Truncated files are corrupt, unless they end between chains.
+	for n := 5; n < len(data); n++ {
+		r := NewChainedReader(bytes.NewReader(data[:n]))
+		read := 0
+		var err error
+		for ; err == nil; read++ {
+			_, err = r.Read()
+		}
+		if err == io.EOF && read-1 != 8 {
+			t.Error("Expected an error reading the first", n, "bytes")
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package tt provides a fixed-size transposition table for chess search, keyed
+// by dragontoothmg.Board.Hash(). It is safe for concurrent use by multiple
+// search threads without locks.
+//
+// Entries are grouped into buckets of four, which fill one 64-byte cache line,
+// so a probe touches a single line of memory. Each entry is two 64-bit words:
+// the packed data, and the key XORed with the data. A reader accepts an entry
+// only if the two words agree with the key, so a write torn by a concurrent
+// writer is seen as a miss rather than as corrupt data.
+package tt
+
+import (
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The kind of score stored in an entry.
+type Bound uint8
+
+const (
+	BoundNone  Bound = iota
+	BoundUpper       // the score is at most this (a fail low)
+	BoundLower       // the score is at least this (a fail high)
+	BoundExact
+)
+
+// The contents of a table entry.
+type Entry struct {
+	Move  dragontoothmg.Move
+	Score int // from the side to move's point of view
+	Eval  int // the static evaluation, if the search stored one
+	Depth int // in plies; may be negative for quiescence search entries
+	Bound Bound
+}
+
+const (
+	entriesPerBucket = 4
+	bucketSize       = 64 // bytes
+	ageBits          = 6
+	ageMask          = 1<<ageBits - 1
+)
+
+type entry struct {
+	keyXorData uint64
+	data       uint64
+}
+
+type bucket [entriesPerBucket]entry
+
+// A transposition table. The zero value is not usable; see New.
+type Table struct {
+	buckets []bucket
+	age     uint8 // the current search generation, in the low 6 bits
+}
+
+// Allocates a table of at most sizeMb megabytes.
+func New(sizeMb int) *Table {
+	t := &Table{}
+	t.Resize(sizeMb)
+	return t
+}
+
+// Reallocates the table with a new size in megabytes, discarding all entries.
+// It must not be called while other goroutines use the table.
+func (t *Table) Resize(sizeMb int) {
+	if sizeMb < 1 {
+		sizeMb = 1
+	}
+	t.buckets = make([]bucket, uint64(sizeMb)<<20/bucketSize)
+}
+
+// Empties the table. It must not be called while other goroutines use the table.
+func (t *Table) Clear() {
+	for i := range t.buckets {
+		t.buckets[i] = bucket{}
+	}
+	t.age = 0
+}
+
+// Starts a new search generation, so that entries from earlier searches are
+// replaced first. Like Resize and Clear, it must be called between searches.
+func (t *Table) NewSearch() {
+	t.age = (t.age + 1) & ageMask
+}
+
+// The number of entries in the table.
+func (t *Table) Size() int {
+	return len(t.buckets) * entriesPerBucket
+}
+
+// Maps a key to a bucket, using the high bits of key * len(buckets), so that
+// any table size can be used.
+func (t *Table) bucket(key uint64) *bucket {
+	index, _ := bits.Mul64(key, uint64(len(t.buckets)))
+	return &t.buckets[index]
+}
+
+// Packs entry data into 64 bits: move (16), score (16), eval (16), depth (8),
+// bound (2) and age (6).
+func pack(e Entry, age uint8) uint64 {
+	return uint64(e.Move) | uint64(uint16(int16(e.Score)))<<16 | uint64(uint16(int16(e.Eval)))<<32 |
+		uint64(uint8(int8(e.Depth)))<<48 | uint64(e.Bound&3)<<56 | uint64(age&ageMask)<<58
+}
+
+func unpack(data uint64) Entry {
+	return Entry{
+		Move:  dragontoothmg.Move(data),
+		Score: int(int16(data >> 16)),
+		Eval:  int(int16(data >> 32)),
+		Depth: int(int8(data >> 48)),
+		Bound: Bound(data>>56) & 3,
+	}
+}
+
+func dataAge(data uint64) uint8 {
+	return uint8(data>>58) & ageMask
+}
+
+// Looks up a position. Scores outside the int16 range cannot be stored.
+func (t *Table) Probe(key uint64) (Entry, bool) {
+	b := t.bucket(key)
+	for i := range b {
+		data := atomic.LoadUint64(&b[i].data)
+		if atomic.LoadUint64(&b[i].keyXorData)^data == key && Bound(data>>56)&3 != BoundNone {
+			return unpack(data), true
+		}
+	}
+	return Entry{}, false
+}
+
+// Stores a search result. An existing entry for the same position is replaced
+// unless it is from this search and much deeper, and keeps its move if the new
+// result has none. Otherwise, the least valuable entry in the bucket is
+// replaced: the oldest, and then the shallowest.
+func (t *Table) Store(key uint64, e Entry) {
+	b := t.bucket(key)
+	victim := 0
+	worst := int(^uint(0) >> 1)
+	for i := range b {
+		data := atomic.LoadUint64(&b[i].data)
+		keyXorData := atomic.LoadUint64(&b[i].keyXorData)
+		if keyXorData^data == key && Bound(data>>56)&3 != BoundNone {
+			old := unpack(data)
+			if e.Bound != BoundExact && dataAge(data) == t.age && old.Depth > e.Depth+3 {
+				return
+			}
+			if e.Move == 0 {
+				e.Move = old.Move
+			}
+			victim = i
+			break
+		}
+		if data == 0 && keyXorData == 0 { // empty
+			victim, worst = i, -1<<31
+			continue
+		}
+		// Older entries count as shallower, by 8 plies per generation.
+		relativeAge := int((t.age - dataAge(data)) & ageMask)
+		if value := int(int8(data>>48)) - 8*relativeAge; value < worst {
+			victim, worst = i, value
+		}
+	}
+	data := pack(e, t.age)
+	atomic.StoreUint64(&b[victim].data, data)
+	atomic.StoreUint64(&b[victim].keyXorData, key^data)
+}
+
+// Reports how full the table is, in permille, counting only entries from the
+// current search, as in the UCI "hashfull" info. It samples the first thousand
+// entries.
+func (t *Table) Hashfull() int {
+	count, sampled := 0, 0
+	for i := 0; i < len(t.buckets) && sampled < 1000; i++ {
+		for j := range t.buckets[i] {
+			data := atomic.LoadUint64(&t.buckets[i][j].data)
+			if Bound(data>>56)&3 != BoundNone && dataAge(data) == t.age {
+				count++
+			}
+			sampled++
+		}
+	}
+	if sampled == 0 {
+		return 0
+	}
+	return count * 1000 / sampled
+}
@@ -0,0 +1,142 @@
+package tt
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+func TestStoreProbe(t *testing.T) {
+	table := New(1)
+	if table.Size() != 1<<20/16 {
+		t.Error("Unexpected table size", table.Size())
+	}
+	if _, ok := table.Probe(12345); ok {
+		t.Error("An empty table returned an entry.")
+	}
+	m, _ := dragontoothmg.ParseMove("e7e8q")
+	stored := Entry{Move: m, Score: -31990, Eval: 57, Depth: -2, Bound: BoundLower}
+	table.Store(12345, stored)
+	if entry, ok := table.Probe(12345); !ok || entry != stored {
+		t.Error("Expected", stored, "but got", entry, ok)
+	}
+	if _, ok := table.Probe(54321); ok {
+		t.Error("A different key returned an entry.")
+	}
+
+	// A shallower result without a move keeps the old move.
+	table.Store(12345, Entry{Score: 3, Depth: 1, Bound: BoundUpper})
+	if entry, _ := table.Probe(12345); entry.Move != m || entry.Score != 3 {
+		t.Error("The entry was not updated, or lost its move:", entry)
+	}
+	// A much shallower bound does not replace a deep entry from the same search.
+	table.Store(12345, Entry{Score: 9, Depth: 10, Bound: BoundLower})
+	table.Store(12345, Entry{Score: 4, Depth: 2, Bound: BoundUpper})
+	if entry, _ := table.Probe(12345); entry.Score != 9 {
+		t.Error("A deep entry was replaced by a shallow one:", entry)
+	}
+	table.Store(12345, Entry{Score: 5, Depth: 2, Bound: BoundExact})
+	if entry, _ := table.Probe(12345); entry.Score != 5 {
+		t.Error("An exact entry did not replace a deeper one:", entry)
+	}
+}
+
+// Finds keys that map to the same bucket as key.
+func collidingKeys(table *Table, key uint64, count int) []uint64 {
+	var keys []uint64
+	for k := key + 1; len(keys) < count; k += 1 << 40 {
+		if table.bucket(k) == table.bucket(key) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestReplacement(t *testing.T) {
+	table := New(1)
+	keys := append([]uint64{1 << 63}, collidingKeys(table, 1<<63, entriesPerBucket)...)
+	for i, key := range keys[:entriesPerBucket] {
+		table.Store(key, Entry{Depth: 10 - i, Bound: BoundExact})
+	}
+	// The bucket is full, so the shallowest entry is replaced.
+	table.Store(keys[entriesPerBucket], Entry{Depth: 1, Bound: BoundExact})
+	if _, ok := table.Probe(keys[entriesPerBucket-1]); ok {
+		t.Error("The shallowest entry was not replaced.")
+	}
+	for i, key := range keys {
+		if _, ok := table.Probe(key); !ok && i != entriesPerBucket-1 {
+			t.Error("Entry", key, "was lost.")
+		}
+	}
+
+	// Entries from earlier searches are replaced before deeper ones.
+	table.NewSearch()
+	table.NewSearch()
+	table.Store(keys[entriesPerBucket-1], Entry{Depth: 1, Bound: BoundExact})
+	table.Store(keys[entriesPerBucket], Entry{Depth: 1, Bound: BoundExact})
+	_, ok1 := table.Probe(keys[entriesPerBucket-1])
+	_, ok2 := table.Probe(keys[entriesPerBucket])
+	if !ok1 || !ok2 {
+		t.Error("A shallow new entry was replaced instead of a deeper old one.")
+	}
+}
+
+func TestHashfullClearResize(t *testing.T) {
+	table := New(1)
+	if table.Hashfull() != 0 {
+		t.Error("An empty table is not empty:", table.Hashfull())
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < table.Size()*4; i++ {
+		table.Store(r.Uint64(), Entry{Depth: 1, Bound: BoundExact})
+	}
+	if full := table.Hashfull(); full < 950 {
+		t.Error("The table should be nearly full, got", full)
+	}
+	table.NewSearch()
+	if full := table.Hashfull(); full != 0 {
+		t.Error("Old entries should not count towards hashfull, got", full)
+	}
+	table.Clear()
+	if full := table.Hashfull(); full != 0 {
+		t.Error("The cleared table is not empty:", full)
+	}
+	table.Store(7, Entry{Depth: 1, Bound: BoundExact})
+	table.Resize(2)
+	if _, ok := table.Probe(7); ok || table.Size() != 2<<20/16 {
+		t.Error("Resize did not reallocate the table.")
+	}
+}
+
+// Concurrent writers to the same few buckets must never let a reader see an
+// entry that is inconsistent with its key. Run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	table := New(1)
+	keys := append([]uint64{42}, collidingKeys(table, 42, 7)...)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 20000; i++ {
+				key := keys[r.Intn(len(keys))]
+				if r.Intn(2) == 0 {
+					// Every field is derived from the key, so a torn entry is detectable.
+					table.Store(key, Entry{Move: dragontoothmg.Move(key), Score: int(int16(key >> 3)),
+						Eval: int(int16(key >> 5)), Depth: int(key % 50), Bound: BoundExact})
+					continue
+				}
+				if entry, ok := table.Probe(key); ok && (entry.Move != dragontoothmg.Move(key) ||
+					entry.Score != int(int16(key>>3)) || entry.Eval != int(int16(key>>5)) ||
+					entry.Depth != int(key%50)) {
+					t.Error("Torn entry for", key, entry)
+					return
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+}
@@ -23,6 +23,16 @@ type Board struct {
 	White         Bitboards
 	Black         Bitboards
 	hash          uint64
+	// Incrementally updated evaluation state; see material.go.
+	materialSignature uint64
+	pieceSquareScore  PhaseScore
+	pawnHash          uint64
+	materialKey       uint64
+	// The rules in play; see variant.go.
+	Variant  Variant
+	checks   [2]uint8    // the checks given by white and black, in ThreeCheck
+	pockets  [2][6]uint8 // the pieces in hand of white and black by Piece, in Crazyhouse
+	promoted uint64      // the pieces that were promoted from pawns, in Crazyhouse
 }
 
 // Return the Zobrist hash value for the board.
@@ -42,6 +52,11 @@ func (b *Board) Hash() uint64 {
 // This just indicates whether castling rights have been lost, not whether
 // castling is actually possible.
 
+// Whether either side has any castling rights left.
+func (b *Board) HasCastlingRights() bool {
+	return b.castlerights != 0
+}
+
 // Castling helper functions for all 16 possible scenarios
 func (b *Board) whiteCanCastleQueenside() bool {
 	return b.castlerights&1 == 1
@@ -143,6 +158,8 @@ type Bitboards struct {
 // 6 bits: destination square
 // 6 bits: source square
 // 3 bits: promotion
+// 1 bit: drop, in Crazyhouse. The promotion bits then hold the dropped piece,
+// and the source square is unused.
 
 // Move bitwise structure; internal implementation is private.
 type Move uint16
@@ -156,6 +173,22 @@ func (m *Move) From() uint8 {
 
 // Whether the move involves promoting a pawn.
 func (m *Move) Promote() Piece {
+	if *m&0x8000 != 0 {
+		return Nothing
+	}
+	return Piece((*m & 0x7000) >> 12)
+}
+
+// Whether the move drops a piece from the pocket, in Crazyhouse.
+func (m *Move) IsDrop() bool {
+	return *m&0x8000 != 0
+}
+
+// The type of the piece being dropped, or Nothing if the move is not a drop.
+func (m *Move) Drop() Piece {
+	if *m&0x8000 == 0 {
+		return Nothing
+	}
 	return Piece((*m & 0x7000) >> 12)
 }
 func (m *Move) Setto(s Square) *Move {
@@ -170,12 +203,19 @@ func (m *Move) Setpromote(p Piece) *Move {
 	*m = *m & ^(Move(0x7000)) | (Move(p) << 12)
 	return m
 }
+func (m *Move) Setdrop(p Piece) *Move {
+	*m = *m & ^(Move(0xFFC0)) | 0x8000 | (Move(p) << 12)
+	return m
+}
 func (m *Move) String() string {
 	/*return fmt.Sprintf("[from: %v, to: %v, promote: %v]",
 	IndexToAlgebraic(Square(m.From())), IndexToAlgebraic(Square(m.To())), m.Promote())*/
 	if *m == 0 {
 		return "0000"
 	}
+	if m.IsDrop() {
+		return dropLetters[m.Drop():m.Drop()+1] + "@" + IndexToAlgebraic(Square(m.To()))
+	}
 	result := IndexToAlgebraic(Square(m.From())) + IndexToAlgebraic(Square(m.To()))
 	switch m.Promote() {
 	case Queen:
@@ -186,11 +226,62 @@ func (m *Move) String() string {
 		result += "r"
 	case Bishop:
 		result += "b"
+	case King:
+		result += "k"
 	default:
 	}
 	return result
 }
 
+// Data stored inside, from LSB
+// 16 bits: the compact Move (destination, source, promotion)
+// 3 bits: type of the moving piece
+// 3 bits: type of the captured piece (a pawn for en passant captures)
+// 1 bit: castling
+// 1 bit: en passant capture
+// 1 bit: pawn double push
+
+// Extended move, which also records information about the position it was
+// generated in. Use Move() to convert to the compact 16-bit form for storage.
+type ExtMove uint32
+
+const (
+	extCastleFlag     = 1 << 22
+	extEnpassantFlag  = 1 << 23
+	extDoublePushFlag = 1 << 24
+)
+
+// The compact form of the move.
+func (m *ExtMove) Move() Move {
+	return Move(*m & 0xFFFF)
+}
+
+// The type of the piece being moved.
+func (m *ExtMove) Piece() Piece {
+	return Piece((*m >> 16) & 0x7)
+}
+
+// The type of the piece being captured, or Nothing.
+func (m *ExtMove) Captured() Piece {
+	return Piece((*m >> 19) & 0x7)
+}
+func (m *ExtMove) IsCapture() bool {
+	return m.Captured() != Nothing
+}
+func (m *ExtMove) IsCastle() bool {
+	return *m&extCastleFlag != 0
+}
+func (m *ExtMove) IsEnpassant() bool {
+	return *m&extEnpassantFlag != 0
+}
+func (m *ExtMove) IsDoublePush() bool {
+	return *m&extDoublePushFlag != 0
+}
+func (m *ExtMove) String() string {
+	move := m.Move()
+	return move.String()
+}
+
 // Square index values from 0-63.
 type Square uint8
 
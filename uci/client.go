@@ -0,0 +1,515 @@
+// Package uci drives external chess engines through the UCI protocol. The
+// client keeps the position with dragontoothmg, so every move an engine reports
+// is checked for legality before it is returned.
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// DefaultTimeout is how long the client waits for replies to "uci" and "isready",
+// and for "bestmove" after "stop".
+const DefaultTimeout = 10 * time.Second
+
+// An option advertised by the engine with "option name ... type ...".
+type Option struct {
+	Name    string
+	Type    string // check, spin, combo, button or string
+	Default string
+	Min     int
+	Max     int
+	Vars    []string // the choices of a combo option
+}
+
+// Search limits for "go". Zero values are omitted.
+type Limits struct {
+	Depth     int
+	Nodes     uint64
+	Mate      int
+	MoveTime  time.Duration
+	WTime     time.Duration
+	BTime     time.Duration
+	WInc      time.Duration
+	BInc      time.Duration
+	MovesToGo int
+	Infinite  bool
+}
+
+// A score from the engine's point of view. For mate scores, Mate is the number of
+// moves to mate, which is negative if the engine is getting mated.
+type Score struct {
+	Cp         int
+	Mate       int
+	IsMate     bool
+	Lowerbound bool
+	Upperbound bool
+}
+
+// A parsed "info" line. Fields that the engine did not send are zero.
+type Info struct {
+	Depth    int
+	SelDepth int
+	MultiPV  int // 1 for the best line
+	Score    Score
+	HasScore bool
+	Nodes    uint64
+	NPS      uint64
+	Time     time.Duration
+	Hashfull int
+	PV       []dragontoothmg.Move
+	String   string // the text of "info string"
+}
+
+// The outcome of a search.
+type Result struct {
+	BestMove dragontoothmg.Move // 0 if the position has no legal moves
+	Ponder   dragontoothmg.Move // 0 if the engine did not suggest one
+	Lines    []Info             // the last info with a PV for each multipv line, best first
+}
+
+// A running engine process. An Engine is not safe for concurrent use.
+type Engine struct {
+	Name    string
+	Author  string
+	Options map[string]Option // by lowercase name
+	Timeout time.Duration
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	done  chan struct{} // closed by Close, to release the reader
+
+	board dragontoothmg.Board // the position last sent with SetPosition
+}
+
+// Launches an engine, and performs the "uci" handshake.
+func Start(path string, args ...string) (*Engine, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		Options: make(map[string]Option),
+		Timeout: DefaultTimeout,
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string),
+		done:    make(chan struct{}),
+		board:   dragontoothmg.ParseFen(dragontoothmg.Startpos),
+	}
+	go e.read(stdout)
+
+	if err := e.send("uci"); err != nil {
+		e.Close()
+		return nil, err
+	}
+	for {
+		line, err := e.readLine()
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		tokens := strings.Fields(line)
+		switch {
+		case line == "uciok":
+			return e, nil
+		case len(tokens) > 2 && tokens[0] == "id" && tokens[1] == "name":
+			e.Name = strings.Join(tokens[2:], " ")
+		case len(tokens) > 2 && tokens[0] == "id" && tokens[1] == "author":
+			e.Author = strings.Join(tokens[2:], " ")
+		case len(tokens) > 0 && tokens[0] == "option":
+			if option, ok := parseOption(tokens[1:]); ok {
+				e.Options[strings.ToLower(option.Name)] = option
+			}
+		}
+	}
+}
+
+// Forwards the engine's output, a line at a time, until it exits or the engine is closed.
+func (e *Engine) read(stdout io.Reader) {
+	defer close(e.lines)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		select {
+		case e.lines <- strings.TrimSpace(scanner.Text()):
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Waits for the next line of output, for at most e.Timeout.
+func (e *Engine) readLine() (string, error) {
+	timer := time.NewTimer(e.Timeout)
+	defer timer.Stop()
+	select {
+	case line, ok := <-e.lines:
+		if !ok {
+			return "", errors.New("Engine exited.")
+		}
+		return line, nil
+	case <-timer.C:
+		return "", errors.New("Timed out waiting for the engine.")
+	}
+}
+
+func (e *Engine) send(command string) error {
+	_, err := io.WriteString(e.stdin, command+"\n")
+	return err
+}
+
+// Parses the tokens after "option".
+func parseOption(tokens []string) (Option, bool) {
+	var option Option
+	var name, current []string
+	var key string
+	flush := func() {
+		value := strings.Join(current, " ")
+		switch key {
+		case "name":
+			name = current
+		case "type":
+			option.Type = value
+		case "default":
+			option.Default = value
+		case "min":
+			option.Min, _ = strconv.Atoi(value)
+		case "max":
+			option.Max, _ = strconv.Atoi(value)
+		case "var":
+			option.Vars = append(option.Vars, value)
+		}
+		current = nil
+	}
+	for _, token := range tokens {
+		switch token {
+		case "name", "type", "default", "min", "max", "var":
+			if key != "name" || token == "type" { // option names may contain keywords
+				flush()
+				key = token
+				continue
+			}
+		}
+		current = append(current, token)
+	}
+	flush()
+	option.Name = strings.Join(name, " ")
+	return option, option.Name != "" && option.Type != ""
+}
+
+// Sets an option, which must have been advertised by the engine. Buttons take no value.
+func (e *Engine) SetOption(name string, value string) error {
+	option, ok := e.Options[strings.ToLower(name)]
+	if !ok {
+		return errors.New("Unknown engine option " + name)
+	}
+	if option.Type == "button" {
+		return e.send("setoption name " + option.Name)
+	}
+	return e.send("setoption name " + option.Name + " value " + value)
+}
+
+// Sends "isready", and waits for "readyok".
+func (e *Engine) IsReady() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// Tells the engine that the next position is from a different game.
+func (e *Engine) NewGame() error {
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.IsReady()
+}
+
+// Sends the position reached by playing moves from start. Returns an error,
+// without sending anything, if one of the moves is illegal.
+func (e *Engine) SetPosition(start dragontoothmg.Board, moves []dragontoothmg.Move) error {
+	command := "position fen " + start.ToFen()
+	if command == "position fen "+dragontoothmg.Startpos {
+		command = "position startpos"
+	}
+	board := start
+	if len(moves) > 0 {
+		command += " moves"
+	}
+	for i := range moves {
+		if !isLegal(&board, moves[i]) {
+			return errors.New("Illegal move in position: " + moves[i].String())
+		}
+		command += " " + moves[i].String()
+		board.Apply(moves[i])
+	}
+	if err := e.send(command); err != nil {
+		return err
+	}
+	e.board = board
+	return nil
+}
+
+// Whether m is among the legal moves. The generator only produces queen
+// promotions, so other promotions to a knight, bishop or rook (or a king, in
+// Antichess) are matched against those.
+func isLegal(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	query := m
+	switch promote := m.Promote(); {
+	case promote == dragontoothmg.Nothing:
+	case promote >= dragontoothmg.Knight && promote <= dragontoothmg.Queen,
+		promote == dragontoothmg.King && b.Variant == dragontoothmg.Antichess:
+		query.Setpromote(dragontoothmg.Queen)
+	default:
+		return false
+	}
+	for _, legal := range b.GenerateLegalMoves() {
+		if legal == query {
+			return true
+		}
+	}
+	return false
+}
+
+// Searches the position from the last SetPosition, calling onInfo (if not nil)
+// for each info line, and returns the engine's best move. If ctx is done before
+// the engine replies, the search is stopped with "stop". An error is returned if
+// the engine reports an illegal best move or principal variation, although the
+// search is still allowed to finish.
+func (e *Engine) Go(ctx context.Context, limits Limits, onInfo func(Info)) (Result, error) {
+	if err := e.send(goCommand(limits)); err != nil {
+		return Result{}, err
+	}
+	var result Result
+	var firstErr error
+	cancelled := ctx.Done()
+	var timeout <-chan time.Time // armed after "stop"
+	for {
+		var line string
+		select {
+		case l, ok := <-e.lines:
+			if !ok {
+				return result, errors.New("Engine exited.")
+			}
+			line = l
+		case <-cancelled:
+			if err := e.send("stop"); err != nil {
+				return result, err
+			}
+			cancelled = nil
+			timeout = time.After(e.Timeout)
+			continue
+		case <-timeout:
+			return result, errors.New("Timed out waiting for the engine.")
+		}
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		switch tokens[0] {
+		case "info":
+			info, err := ParseInfo(line, &e.board)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if len(info.PV) > 0 {
+				index := info.MultiPV - 1
+				if index < 0 {
+					index = 0
+				}
+				for len(result.Lines) <= index {
+					result.Lines = append(result.Lines, Info{})
+				}
+				result.Lines[index] = info
+			}
+			if onInfo != nil {
+				onInfo(info)
+			}
+		case "bestmove":
+			err := e.parseBestMove(tokens[1:], &result)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return result, firstErr
+		}
+	}
+}
+
+// Builds the "go" command for limits.
+func goCommand(limits Limits) string {
+	command := "go"
+	ms := func(d time.Duration) string { return strconv.FormatInt(d.Milliseconds(), 10) }
+	if limits.Depth > 0 {
+		command += " depth " + strconv.Itoa(limits.Depth)
+	}
+	if limits.Nodes > 0 {
+		command += " nodes " + strconv.FormatUint(limits.Nodes, 10)
+	}
+	if limits.Mate > 0 {
+		command += " mate " + strconv.Itoa(limits.Mate)
+	}
+	if limits.MoveTime > 0 {
+		command += " movetime " + ms(limits.MoveTime)
+	}
+	if limits.WTime > 0 || limits.BTime > 0 {
+		command += " wtime " + ms(limits.WTime) + " btime " + ms(limits.BTime)
+	}
+	if limits.WInc > 0 || limits.BInc > 0 {
+		command += " winc " + ms(limits.WInc) + " binc " + ms(limits.BInc)
+	}
+	if limits.MovesToGo > 0 {
+		command += " movestogo " + strconv.Itoa(limits.MovesToGo)
+	}
+	if limits.Infinite {
+		command += " infinite"
+	}
+	return command
+}
+
+// Parses the tokens after "bestmove", checking that the moves are legal.
+func (e *Engine) parseBestMove(tokens []string, result *Result) error {
+	if len(tokens) == 0 {
+		return errors.New("Missing best move.")
+	}
+	if tokens[0] == "(none)" || tokens[0] == "0000" {
+		return nil
+	}
+	m, err := dragontoothmg.ParseMove(tokens[0])
+	if err != nil || !isLegal(&e.board, m) {
+		return errors.New("Illegal best move " + tokens[0])
+	}
+	result.BestMove = m
+	if len(tokens) >= 3 && tokens[1] == "ponder" {
+		after := e.board
+		after.Apply(m)
+		if ponder, err := dragontoothmg.ParseMove(tokens[2]); err == nil && isLegal(&after, ponder) {
+			result.Ponder = ponder
+		}
+	}
+	return nil
+}
+
+// Parses an "info" line sent while searching b. The principal variation is
+// checked move by move; if a move is illegal, the PV is cut short before it and
+// an error is returned along with the rest of the info.
+func ParseInfo(line string, b *dragontoothmg.Board) (Info, error) {
+	var info Info
+	tokens := strings.Fields(line)
+	if len(tokens) == 0 || tokens[0] != "info" {
+		return info, errors.New("Not an info line.")
+	}
+	var err error
+	for i := 1; i < len(tokens); i++ {
+		key := tokens[i]
+		switch key {
+		case "string":
+			info.String = strings.Join(tokens[i+1:], " ")
+			return info, err
+		case "pv":
+			board := *b
+			for i+1 < len(tokens) {
+				m, parseErr := dragontoothmg.ParseMove(tokens[i+1])
+				if parseErr != nil {
+					break // the next keyword
+				}
+				i++
+				if err == nil && !isLegal(&board, m) {
+					err = errors.New("Illegal move in principal variation: " + tokens[i])
+				}
+				if err == nil {
+					info.PV = append(info.PV, m)
+					board.Apply(m)
+				}
+			}
+			continue
+		case "score":
+			info.HasScore = true
+			for i+2 < len(tokens) && (tokens[i+1] == "cp" || tokens[i+1] == "mate") {
+				value, _ := strconv.Atoi(tokens[i+2])
+				if tokens[i+1] == "cp" {
+					info.Score.Cp = value
+				} else {
+					info.Score.Mate, info.Score.IsMate = value, true
+				}
+				i += 2
+			}
+			for i+1 < len(tokens) && (tokens[i+1] == "lowerbound" || tokens[i+1] == "upperbound") {
+				info.Score.Lowerbound = info.Score.Lowerbound || tokens[i+1] == "lowerbound"
+				info.Score.Upperbound = info.Score.Upperbound || tokens[i+1] == "upperbound"
+				i++
+			}
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		value, convErr := strconv.ParseUint(tokens[i+1], 10, 64)
+		if convErr != nil {
+			continue // an unknown keyword, or one with a non-numeric value such as currmove
+		}
+		switch key {
+		case "depth":
+			info.Depth = int(value)
+		case "seldepth":
+			info.SelDepth = int(value)
+		case "multipv":
+			info.MultiPV = int(value)
+		case "nodes":
+			info.Nodes = value
+		case "nps":
+			info.NPS = value
+		case "time":
+			info.Time = time.Duration(value) * time.Millisecond
+		case "hashfull":
+			info.Hashfull = int(value)
+		}
+		i++
+	}
+	return info, err
+}
+
+// Sends "quit", and waits briefly for the engine to exit before killing it.
+func (e *Engine) Close() error {
+	select {
+	case <-e.done:
+		return nil // already closed
+	default:
+	}
+	e.send("quit")
+	e.stdin.Close()
+	close(e.done)
+	exited := make(chan error, 1)
+	go func() { exited <- e.cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(e.Timeout):
+		e.cmd.Process.Kill()
+		return <-exited
+	}
+}
@@ -0,0 +1,213 @@
+package uci
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The tests run this test binary as a fake engine, which follows a script.
+func TestMain(m *testing.M) {
+	if os.Getenv("UCI_FAKE_ENGINE") == "1" {
+		fakeEngine()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func fakeEngine() {
+	scanner := bufio.NewScanner(os.Stdin)
+	position := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "uci":
+			fmt.Println("id name Fake Engine 1.0")
+			fmt.Println("id author Someone")
+			fmt.Println("option name Hash type spin default 16 min 1 max 1024")
+			fmt.Println("option name Clear Hash type button")
+			fmt.Println("option name Style type combo default Normal var Solid var Normal var Risky")
+			fmt.Println("uciok")
+		case line == "isready":
+			fmt.Println("readyok")
+		case strings.HasPrefix(line, "position"):
+			position = line
+		case line == "go infinite":
+			fmt.Println("info depth 1 score cp 5 pv e2e4")
+		case line == "stop":
+			fmt.Println("bestmove e2e4")
+		case strings.HasPrefix(line, "go"):
+			switch position {
+			case "position startpos moves e2e4":
+				fmt.Println("info string thinking about " + position)
+				fmt.Println("info depth 1 seldepth 2 multipv 1 score cp 31 nodes 20 nps 2000 time 10 pv e7e5 g1f3")
+				fmt.Println("info depth 1 seldepth 2 multipv 2 score cp 12 lowerbound nodes 20 pv c7c5")
+				fmt.Println("info depth 2 currmove e7e5 currmovenumber 1 hashfull 3")
+				fmt.Println("bestmove e7e5 ponder g1f3")
+			case "position fen 6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1":
+				fmt.Println("info depth 1 score mate 1 nodes 3 pv a1a8")
+				fmt.Println("bestmove a1a8")
+			case "position fen 7k/5Q2/6K1/8/8/8/8/8 b - - 0 1":
+				fmt.Println("bestmove (none)")
+			default: // plays illegal moves
+				fmt.Println("info depth 1 score cp 0 pv e2e4 e2e4")
+				fmt.Println("bestmove e1e8")
+			}
+		case line == "quit":
+			return
+		}
+	}
+}
+
+func startFake(t *testing.T) *Engine {
+	os.Setenv("UCI_FAKE_ENGINE", "1")
+	defer os.Unsetenv("UCI_FAKE_ENGINE")
+	e, err := Start(os.Args[0])
+	if err != nil {
+		t.Fatal("Could not start the fake engine:", err)
+	}
+	return e
+}
+
+func TestHandshakeAndOptions(t *testing.T) {
+	e := startFake(t)
+	defer e.Close()
+	if e.Name != "Fake Engine 1.0" || e.Author != "Someone" {
+		t.Error("Unexpected engine id:", e.Name, e.Author)
+	}
+	hash := e.Options["hash"]
+	if hash.Type != "spin" || hash.Default != "16" || hash.Min != 1 || hash.Max != 1024 {
+		t.Error("Hash option was not parsed:", hash)
+	}
+	if e.Options["clear hash"].Type != "button" || len(e.Options["style"].Vars) != 3 {
+		t.Error("Options were not parsed:", e.Options)
+	}
+	if e.SetOption("Hash", "64") != nil || e.SetOption("Clear Hash", "") != nil {
+		t.Error("Valid options were rejected.")
+	}
+	if e.SetOption("Threads", "2") == nil {
+		t.Error("An unknown option was accepted.")
+	}
+	if err := e.NewGame(); err != nil {
+		t.Error("ucinewgame failed:", err)
+	}
+}
+
+func TestGo(t *testing.T) {
+	e := startFake(t)
+	defer e.Close()
+	e4, _ := dragontoothmg.ParseMove("e2e4")
+	if err := e.SetPosition(dragontoothmg.ParseFen(dragontoothmg.Startpos), []dragontoothmg.Move{e4}); err != nil {
+		t.Fatal(err)
+	}
+	var infos []Info
+	result, err := e.Go(context.Background(), Limits{Depth: 2}, func(info Info) { infos = append(infos, info) })
+	if err != nil {
+		t.Fatal("Search failed:", err)
+	}
+	if result.BestMove.String() != "e7e5" || result.Ponder.String() != "g1f3" {
+		t.Error("Unexpected best move:", &result.BestMove, &result.Ponder)
+	}
+	if len(infos) != 4 || !strings.HasPrefix(infos[0].String, "thinking about") {
+		t.Fatal("Unexpected infos:", infos)
+	}
+	first := infos[1]
+	if first.Depth != 1 || first.SelDepth != 2 || first.MultiPV != 1 || first.Score.Cp != 31 ||
+		first.Nodes != 20 || first.NPS != 2000 || first.Time != 10*time.Millisecond || len(first.PV) != 2 {
+		t.Error("Info was not parsed:", first)
+	}
+	if !infos[2].Score.Lowerbound || infos[3].Hashfull != 3 || infos[3].HasScore {
+		t.Error("Info was not parsed:", infos[2], infos[3])
+	}
+	if len(result.Lines) != 2 || result.Lines[1].PV[0].String() != "c7c5" {
+		t.Error("Multipv lines were not collected:", result.Lines)
+	}
+
+	e.SetPosition(dragontoothmg.ParseFen("6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1"), nil)
+	result, err = e.Go(context.Background(), Limits{MoveTime: time.Second}, nil)
+	if err != nil || !result.Lines[0].Score.IsMate || result.Lines[0].Score.Mate != 1 {
+		t.Error("Mate score was not parsed:", result, err)
+	}
+	e.SetPosition(dragontoothmg.ParseFen("7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"), nil)
+	if result, err = e.Go(context.Background(), Limits{}, nil); err != nil || result.BestMove != 0 {
+		t.Error("Expected no best move:", result, err)
+	}
+}
+
+func TestIllegalMoves(t *testing.T) {
+	e := startFake(t)
+	defer e.Close()
+	d4, _ := dragontoothmg.ParseMove("d2d5")
+	if e.SetPosition(dragontoothmg.ParseFen(dragontoothmg.Startpos), []dragontoothmg.Move{d4}) == nil {
+		t.Error("An illegal position move was accepted.")
+	}
+	e.SetPosition(dragontoothmg.ParseFen(dragontoothmg.Startpos), nil)
+	var infos []Info
+	_, err := e.Go(context.Background(), Limits{Nodes: 100}, func(info Info) { infos = append(infos, info) })
+	if err == nil || !strings.Contains(err.Error(), "principal variation") {
+		t.Error("The illegal PV was not reported:", err)
+	}
+	if len(infos) != 1 || len(infos[0].PV) != 1 {
+		t.Error("The PV was not cut short at the illegal move:", infos)
+	}
+}
+
+func TestIsLegalPromotions(t *testing.T) {
+	b := dragontoothmg.ParseFen("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	antichess := dragontoothmg.ParseVariantFen("8/P7/8/8/8/8/8/7k w - - 0 1", dragontoothmg.Antichess)
+	tests := []struct {
+		board    *dragontoothmg.Board
+		move     string
+		expected bool
+	}{
+		{&b, "a7a8q", true},
+		{&b, "a7a8n", true},
+		{&b, "a7a8r", true},
+		{&b, "a7a8k", false},
+		{&antichess, "a7a8k", true},
+		{&antichess, "a7a8b", true},
+	}
+	for _, test := range tests {
+		m, _ := dragontoothmg.ParseMove(test.move)
+		if isLegal(test.board, m) != test.expected {
+			t.Error("Expected", test.move, "legal:", test.expected, "in", test.board.ToFen())
+		}
+	}
+	pawn, _ := dragontoothmg.ParseMove("a7a8q")
+	pawn.Setpromote(dragontoothmg.Pawn)
+	if isLegal(&b, pawn) {
+		t.Error("Expected a promotion to a pawn to be illegal")
+	}
+}
+
+func TestStop(t *testing.T) {
+	e := startFake(t)
+	defer e.Close()
+	e.SetPosition(dragontoothmg.ParseFen(dragontoothmg.Startpos), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := e.Go(ctx, Limits{Infinite: true}, nil)
+	if err != nil || result.BestMove.String() != "e2e4" {
+		t.Error("Infinite search did not stop:", result, err)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	b := dragontoothmg.ParseFen("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	info, err := ParseInfo("info depth 3 score mate -2 upperbound pv b7b8n e8e7 tbhits 0", &b)
+	if err != nil || len(info.PV) != 2 || info.PV[0].Promote() != dragontoothmg.Knight {
+		t.Error("Underpromotion in PV was rejected:", info, err)
+	}
+	if info.Score.Mate != -2 || !info.Score.IsMate || !info.Score.Upperbound {
+		t.Error("Score was not parsed:", info.Score)
+	}
+	if _, err := ParseInfo("bestmove e2e4", &b); err == nil {
+		t.Error("A non-info line was accepted.")
+	}
+}
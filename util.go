@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/bits"
 	"strconv"
 	"strings"
 )
@@ -26,6 +27,13 @@ func recomputeBoardHash(b *Board) uint64 {
 		hash ^= castleRightsZobristC[3]
 	}
 	hash ^= uint64(b.Enpassant)
+	hash ^= checksZobristC[0][b.checks[0]] ^ checksZobristC[1][b.checks[1]]
+	for piece := Pawn; piece <= Queen; piece++ {
+		hash ^= pocketZobristC[0][piece][b.pockets[0][piece]] ^ pocketZobristC[1][piece][b.pockets[1][piece]]
+	}
+	for promoted := b.promoted; promoted != 0; promoted &= promoted - 1 {
+		hash ^= promotedZobristC[bits.TrailingZeros64(promoted)]
+	}
 	for i := uint8(0); i < 64; i++ {
 		whitePiece, _ := determinePieceType(&(b.White), uint64(1)<<i)
 		blackPiece, _ := determinePieceType(&(b.Black), uint64(1)<<i)
@@ -40,14 +48,18 @@ func recomputeBoardHash(b *Board) uint64 {
 }
 
 func IsCapture(m Move, b *Board) bool {
+	if m.IsDrop() {
+		return false
+	}
 	toBitboard := (uint64(1) << m.To())
 	if (toBitboard&b.White.All != 0) || (toBitboard&b.Black.All != 0) {
 		return true
 	}
-	// Is it an en passant capture?
+	// Is it an en passant capture? A zero square means there is no target, as a1
+	// can never be one.
 	fromBitboard := (uint64(1) << m.From())
 	originIsPawn := fromBitboard&b.White.Pawns != 0 || fromBitboard&b.Black.Pawns != 0
-	return originIsPawn && (toBitboard&(uint64(1)<<b.Enpassant) != 0)
+	return originIsPawn && b.Enpassant != 0 && m.To() == b.Enpassant
 }
 
 func GetPieceType(square uint8, b *Board) (int, bool) {
@@ -109,6 +121,15 @@ func ParseMove(movestr string) (Move, error) {
 		return 0, nil
 	}
 	var mv Move
+	if len(movestr) == 4 && movestr[1] == '@' { // a drop, in Crazyhouse
+		piece := strings.IndexByte(dropLetters, movestr[0]&^0x20) // uppercase
+		to, err := AlgebraicToIndex(movestr[2:4])
+		if piece <= 0 || err != nil {
+			return mv, errors.New("Invalid drop to parse.")
+		}
+		mv.Setto(Square(to)).Setdrop(Piece(piece))
+		return mv, nil
+	}
 	if len(movestr) < 4 || len(movestr) > 5 {
 		return mv, errors.New("Invalid move to parse.")
 	}
@@ -128,6 +149,8 @@ func ParseMove(movestr string) (Move, error) {
 			mv.Setpromote(Queen)
 		case 'r':
 			mv.Setpromote(Rook)
+		case 'k':
+			mv.Setpromote(King)
 		default:
 			return mv, errors.New("Invalid promotion symbol in move.")
 		}
@@ -227,6 +250,9 @@ func (b *Board) ToFen() string {
 		} else {
 			empty++
 		}
+		if b.promoted&currMask != 0 {
+			toprint += "~"
+		}
 		if toprint != "" {
 			if empty != 0 {
 				position += strconv.Itoa(empty)
@@ -245,6 +271,9 @@ func (b *Board) ToFen() string {
 			}
 		}
 	}
+	if b.Variant == Crazyhouse {
+		position += b.pocketFen()
+	}
 	if b.Wtomove {
 		position += " w"
 	} else {
@@ -278,10 +307,13 @@ func (b *Board) ToFen() string {
 		position += "-"
 	}
 	position = position + " " + strconv.Itoa(int(b.Halfmoveclock)) + " " + strconv.Itoa(int(b.Fullmoveno))
+	if b.Variant == ThreeCheck {
+		position += " +" + strconv.Itoa(int(b.checks[0])) + "+" + strconv.Itoa(int(b.checks[1]))
+	}
 	return position
 }
 
-// Parse a board from a FEN string.
+// Parse a board from a FEN string. Use ParseFenChecked for untrusted input.
 func ParseFen(fen string) Board {
 	// BUG(dylhunn): This FEN parsing implementation doesn't handle malformed inputs.
 	tokens := strings.Fields(fen)
@@ -368,5 +400,6 @@ func ParseFen(fen string) Board {
 		b.Fullmoveno = uint16(result)
 	}
 	b.hash = recomputeBoardHash(&b)
+	b.recomputeMaterial()
 	return b
 }
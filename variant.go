@@ -0,0 +1,268 @@
+package dragontoothmg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A set of rules, stored in Board.Variant. Variants keep the standard piece
+// movement, but change how the game is won or which moves are legal. Once a
+// variant's win condition is met, there are no legal moves; Game.Outcome and
+// VariantOutcome report the result.
+type Variant uint8
+
+const (
+	Standard      Variant = iota
+	ThreeCheck            // giving a third check wins
+	KingOfTheHill         // bringing the king to d4, e4, d5 or e5 wins
+	RacingKings           // giving check is illegal, and the first king to the eighth rank wins
+	Crazyhouse            // captured pieces may be dropped back onto the board; see crazyhouse.go
+	Atomic                // captures explode the pieces around them; see atomic.go
+	Antichess             // captures are compulsory, and losing every piece wins; see antichess.go
+	Horde                 // white has 36 pawns and no king, and black wins by capturing them all
+)
+
+// The names of the variants, as in the UCI_Variant option.
+var variantNames = [...]string{
+	Standard:      "chess",
+	ThreeCheck:    "3check",
+	KingOfTheHill: "kingofthehill",
+	RacingKings:   "racingkings",
+	Crazyhouse:    "crazyhouse",
+	Atomic:        "atomic",
+	Antichess:     "antichess",
+	Horde:         "horde",
+}
+
+// The starting position of each variant.
+var variantStartpos = [...]string{
+	Standard:      Startpos,
+	ThreeCheck:    Startpos + " +0+0",
+	KingOfTheHill: Startpos,
+	RacingKings:   "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1",
+	Crazyhouse:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+	Atomic:        Startpos,
+	Antichess:     "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+	Horde:         "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1",
+}
+
+// The d4, e4, d5 and e5 squares.
+const centerSquares = uint64(1)<<27 | uint64(1)<<28 | uint64(1)<<35 | uint64(1)<<36
+
+func (v Variant) String() string {
+	if int(v) < len(variantNames) {
+		return variantNames[v]
+	}
+	return "unknown"
+}
+
+// The FEN of the variant's starting position.
+func (v Variant) Startpos() string {
+	return variantStartpos[v]
+}
+
+// Parses a variant name, as in the UCI_Variant option ("chess", "3check",
+// "kingofthehill", "racingkings", "crazyhouse", "atomic", "antichess", "horde").
+// "standard" is accepted for chess.
+func ParseVariant(name string) (Variant, error) {
+	name = strings.ToLower(name)
+	if name == "standard" {
+		return Standard, nil
+	}
+	for v, n := range variantNames {
+		if n == name {
+			return Variant(v), nil
+		}
+	}
+	return Standard, errors.New("Unknown variant " + name + ".")
+}
+
+// Parses a board from a FEN string, with a variant's rules. For ThreeCheck, the
+// checks each side has given may follow the move numbers as "+2+1" (white has
+// given two checks), or the checks each side has left may precede them as "1+2".
+// For Crazyhouse, the pockets may follow the board as "[QNp]", and promoted
+// pieces may be marked as "Q~". Castling rights are ignored in Antichess.
+func ParseVariantFen(fen string, v Variant) Board {
+	var checks [2]uint8
+	var pockets [2][6]uint8
+	var promoted uint64
+	var fields []string
+	for i, field := range strings.Fields(fen) {
+		if v == Crazyhouse && i == 0 {
+			field, pockets, promoted = parsePocketFen(field)
+		}
+		counts := strings.Split(strings.TrimPrefix(field, "+"), "+")
+		if v != ThreeCheck || len(counts) != 2 {
+			fields = append(fields, field)
+			continue
+		}
+		for i, count := range counts {
+			n, _ := strconv.Atoi(count)
+			if !strings.HasPrefix(field, "+") {
+				n = 3 - n // checks remaining
+			}
+			if n > 3 {
+				n = 3
+			} else if n < 0 {
+				n = 0
+			}
+			checks[i] = uint8(n)
+		}
+	}
+	b := ParseFen(strings.Join(fields, " "))
+	b.Variant = v
+	b.checks = checks
+	if v == Antichess {
+		b.castlerights = 0
+	}
+	b.pockets = pockets
+	b.promoted = promoted & (b.White.All | b.Black.All) &^ (b.White.Pawns | b.Black.Pawns | b.White.Kings | b.Black.Kings)
+	b.hash = recomputeBoardHash(&b)
+	return b
+}
+
+// The number of checks a side has given, in ThreeCheck.
+func (b *Board) Checks(white bool) int {
+	if white {
+		return int(b.checks[0])
+	}
+	return int(b.checks[1])
+}
+
+// Counts a check given by a side, in ThreeCheck.
+func (b *Board) giveCheck(white bool) {
+	color := 1
+	if white {
+		color = 0
+	}
+	if b.checks[color] < 3 {
+		b.hash ^= checksZobristC[color][b.checks[color]] ^ checksZobristC[color][b.checks[color]+1]
+		b.checks[color]++
+	}
+}
+
+// Reports whether the game is over by the variant's own win condition: a third
+// check, a king in the center, a king reaching the eighth rank, an exploded
+// king, running out of pieces or moves in Antichess, or the capture of the whole
+// horde. In Racing
+// Kings, if white reaches the eighth rank first, black may still draw by
+// reaching it on the next move. Checkmate and draws by the standard rules are
+// left to Game.Outcome. The reason is empty while the game is ongoing.
+func (b *Board) VariantOutcome() (Outcome, string) {
+	switch b.Variant {
+	case ThreeCheck:
+		if b.checks[0] >= 3 {
+			return WhiteWins, "three checks"
+		} else if b.checks[1] >= 3 {
+			return BlackWins, "three checks"
+		}
+	case KingOfTheHill:
+		if b.White.Kings&centerSquares != 0 {
+			return WhiteWins, "king of the hill"
+		} else if b.Black.Kings&centerSquares != 0 {
+			return BlackWins, "king of the hill"
+		}
+	case RacingKings:
+		whiteHome, blackHome := b.White.Kings&onlyRank[7] != 0, b.Black.Kings&onlyRank[7] != 0
+		switch {
+		case whiteHome && blackHome:
+			return Draw, "both kings reached the eighth rank"
+		case blackHome:
+			return BlackWins, "king reached the eighth rank"
+		case whiteHome && !b.Wtomove:
+			for _, m := range b.generateLegalMoves() {
+				if b.Black.Kings&(uint64(1)<<m.From()) != 0 && m.To() >= 56 && b.variantAllows(m) {
+					return Ongoing, "" // black can still draw
+				}
+			}
+			return WhiteWins, "king reached the eighth rank"
+		case whiteHome:
+			return WhiteWins, "king reached the eighth rank"
+		}
+	case Atomic:
+		if b.White.Kings == 0 {
+			return BlackWins, "king exploded"
+		} else if b.Black.Kings == 0 {
+			return WhiteWins, "king exploded"
+		}
+	case Antichess:
+		winner, ourPieces := BlackWins, b.Black.All
+		if b.Wtomove {
+			winner, ourPieces = WhiteWins, b.White.All
+		}
+		if ourPieces == 0 {
+			return winner, "lost all pieces"
+		} else if len(b.generateAntichessMoves()) == 0 {
+			return winner, "stalemate"
+		}
+	case Horde:
+		if b.White.All == 0 {
+			return BlackWins, "horde captured"
+		}
+	}
+	return Ongoing, ""
+}
+
+// Generates the legal moves of a variant: none once the game is won, and
+// otherwise the standard moves that the variant allows. Atomic starts from the
+// pseudo-legal moves instead, since pins and checks work differently.
+func (b *Board) generateVariantMoves() []Move {
+	if b.Variant == Antichess { // no moves once the game is won anyway
+		return b.generateAntichessMoves()
+	}
+	if outcome, _ := b.VariantOutcome(); outcome != Ongoing {
+		return []Move{}
+	}
+	var moves []Move
+	if b.Variant == Atomic {
+		moves = make([]Move, 0, kDefaultMoveListLength)
+		b.generatePseudoLegalMoves(&moves)
+	} else {
+		moves = b.generateLegalMoves()
+	}
+	if b.Variant != RacingKings && b.Variant != Atomic {
+		return moves
+	}
+	kept := 0
+	for _, m := range moves {
+		if b.variantAllows(m) {
+			moves[kept] = m
+			kept++
+		}
+	}
+	return moves[:kept]
+}
+
+// Whether the variant allows a legal move: checks are illegal in Racing Kings.
+// In Atomic, where moves are only pseudo-legal, this is the full legality check,
+// and in Antichess, captures are compulsory. Whether the game is already won is
+// checked separately.
+func (b *Board) variantAllows(m Move) bool {
+	if b.Variant == Atomic {
+		return b.atomicAllows(m)
+	}
+	if b.Variant == Antichess {
+		return IsCapture(m, b) || !b.antichessCaptureExists()
+	}
+	if b.Variant == RacingKings {
+		next := *b
+		next.Apply(m)
+		return !next.OurKingInCheck()
+	}
+	return true
+}
+
+// Generates the noisy (captures and promotions) or quiet subset of a variant's
+// legal moves.
+func (b *Board) splitVariantMoves(noisy bool) []Move {
+	moves := b.GenerateLegalMoves()
+	kept := 0
+	for _, m := range moves {
+		if (IsCapture(m, b) || m.Promote() != Nothing) == noisy {
+			moves[kept] = m
+			kept++
+		}
+	}
+	return moves[:kept]
+}
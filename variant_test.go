@@ -0,0 +1,159 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestParseVariant(t *testing.T) {
+	for name, expected := range map[string]Variant{"chess": Standard, "standard": Standard, "3check": ThreeCheck,
+		"KingOfTheHill": KingOfTheHill, "racingkings": RacingKings, "crazyhouse": Crazyhouse, "atomic": Atomic, "antichess": Antichess, "horde": Horde} {
+		if v, err := ParseVariant(name); err != nil || v != expected {
+			t.Error("Expected", expected, "for", name, "but got", v, err)
+		}
+	}
+	if _, err := ParseVariant("fischerandom"); err == nil {
+		t.Error("Expected an unknown variant")
+	}
+}
+
+func TestVariantPerft(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		fen     string
+		counts  []int64
+	}{
+		{ThreeCheck, ThreeCheck.Startpos(), []int64{20, 400, 8902, 197281}},
+		{KingOfTheHill, KingOfTheHill.Startpos(), []int64{20, 400, 8902, 197281}},
+		{RacingKings, RacingKings.Startpos(), []int64{21, 421, 11264, 296242}},
+		{Atomic, Atomic.Startpos(), []int64{20, 400, 8902, 197326, 4864979}},
+		{Antichess, Antichess.Startpos(), []int64{20, 400, 8067, 153299, 2732672}},
+		{Antichess, "8/P7/8/8/8/8/8/k7 w - - 0 1", []int64{5}},
+		{Horde, Horde.Startpos(), []int64{8, 128, 1274, 23310}},
+		{Crazyhouse, Crazyhouse.Startpos(), []int64{20, 400, 8902, 197281, 4888832}},
+		{Crazyhouse, "2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", []int64{301}},
+	}
+	for _, test := range tests {
+		b := ParseVariantFen(test.fen, test.variant)
+		for i, expected := range test.counts {
+			if count := Perft(b, i+1); count != expected {
+				t.Error("Expected", expected, "nodes at depth", i+1, "in", test.variant, "but got", count)
+			}
+		}
+	}
+}
+
+func TestThreeCheck(t *testing.T) {
+	b := ParseVariantFen("4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+0", ThreeCheck)
+	other := ParseVariantFen("4k3/8/8/8/8/8/8/R3K3 w - - 1+3 0 1", ThreeCheck)
+	if b != other || b.Checks(true) != 2 || b.Checks(false) != 0 {
+		t.Error("Expected both check notations to parse the same, got", b.ToFen(), other.ToFen())
+	}
+	if b.ToFen() != "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+0" {
+		t.Error("Expected the checks in the FEN, got", b.ToFen())
+	}
+	standard := ParseVariantFen("4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +0+0", ThreeCheck)
+	if b.Hash() == standard.Hash() {
+		t.Error("Expected the checks to change the hash")
+	}
+
+	// A quiet move does not count, and the third check wins.
+	quiet := b
+	quiet.Apply(parseMove("a1a2"))
+	if quiet.Checks(true) != 2 {
+		t.Error("Expected 2 checks, got", quiet.Checks(true))
+	}
+	b.Apply(parseMove("a1a8"))
+	if b.Checks(true) != 3 || b.Hash() != recomputeBoardHash(&b) {
+		t.Error("Expected 3 checks and a matching hash, got", b.ToFen())
+	}
+	g := NewGame(b)
+	if outcome, reason := g.Outcome(); outcome != WhiteWins || reason != "three checks" ||
+		len(b.GenerateLegalMoves()) != 0 {
+		t.Error("Expected white to win by three checks, got", outcome, reason)
+	}
+	if b.InsufficientMaterial() {
+		t.Error("A rook is sufficient material")
+	}
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	g := NewGame(ParseVariantFen("8/8/8/8/8/3K4/8/k7 w - - 0 1", KingOfTheHill))
+	if g.Board.InsufficientMaterial() {
+		t.Error("Bare kings can still win")
+	}
+	if err := g.Play(parseMove("d3d4")); err != nil {
+		t.Fatal(err)
+	}
+	if outcome, reason := g.Outcome(); outcome != WhiteWins || reason != "king of the hill" {
+		t.Error("Expected white to win by reaching the center, got", outcome, reason)
+	}
+	if len(g.Board.GenerateLegalMoves()) != 0 || g.Board.IsLegal(parseMove("a1a2")) {
+		t.Error("Expected no legal moves after the game is won")
+	}
+}
+
+func TestRacingKings(t *testing.T) {
+	// Giving check is illegal.
+	b := ParseVariantFen("8/8/8/8/8/8/k7/6KR w - - 0 1", RacingKings)
+	for _, m := range b.GenerateLegalMoves() {
+		next := b
+		next.Apply(m)
+		if next.OurKingInCheck() {
+			t.Error("Expected no checking moves, got", m.String())
+		}
+	}
+	if b.IsLegal(parseMove("h1h2")) || !b.IsLegal(parseMove("h1h3")) {
+		t.Error("Expected Rh2 to be illegal and Rh3 legal")
+	}
+	if captures, quiets := b.GenerateLegalCaptures(), b.GenerateLegalQuiets(); len(captures) != 0 ||
+		len(quiets) != len(b.GenerateLegalMoves()) {
+		t.Error("Expected only quiet moves, got", captures, quiets)
+	}
+
+	tests := map[string]Outcome{
+		"7K/8/8/8/8/8/8/k7 b - - 0 1": WhiteWins,
+		"7K/k7/8/8/8/8/8/8 b - - 0 1": Ongoing, // black can still draw
+		"k6K/8/8/8/8/8/8/8 w - - 0 1": Draw,
+		"k7/8/8/8/8/8/8/7K w - - 0 1": BlackWins,
+		"8/8/8/8/8/8/k7/7K w - - 0 1": Ongoing,
+	}
+	for fen, expected := range tests {
+		g := NewGame(ParseVariantFen(fen, RacingKings))
+		if outcome, _ := g.Outcome(); outcome != expected {
+			t.Error("Expected", expected, "for", fen, "but got", outcome)
+		}
+	}
+}
+
+func TestHorde(t *testing.T) {
+	// Pawns may double-push from the first rank, and only in Horde.
+	b := ParseVariantFen("4k3/8/8/8/8/8/8/P7 w - - 0 1", Horde)
+	if len(b.GenerateLegalMoves()) != 2 || !b.IsLegal(parseMove("a1a3")) {
+		t.Error("Expected a double push from the first rank, got", b.GenerateLegalMoves())
+	}
+	b.Apply(parseMove("a1a3"))
+	if b.ToFen() != "4k3/8/8/8/8/P7/8/8 b - a2 0 1" {
+		t.Error("Expected an en passant square after the double push, got", b.ToFen())
+	}
+	if b := ParseFen("4k3/8/8/8/8/8/8/P7 w - - 0 1"); b.IsLegal(parseMove("a1a3")) {
+		t.Error("Expected no double push from the first rank in standard chess")
+	}
+
+	// Positions without a white king are supported by the standard generator.
+	b = ParseFen("4k3/8/8/8/8/1r6/P7/8 w - - 0 1")
+	if captures, quiets := b.GenerateLegalCaptures(), b.GenerateLegalQuiets(); len(captures) != 1 ||
+		len(quiets) != 2 || len(b.GenerateLegalMoves()) != 3 || b.OurKingInCheck() {
+		t.Error("Expected one capture and two pushes, got", captures, quiets)
+	}
+	if area := b.GenerateControlArea(); area.Kings != 0 || area.Pawns != uint64(1)<<17 {
+		t.Error("Expected the pawn to control b3, got", area)
+	}
+
+	g := NewGame(ParseVariantFen("r3k3/8/8/8/8/8/P7/8 b - - 0 1", Horde))
+	if err := g.Play(parseMove("a8a2")); err != nil {
+		t.Fatal(err)
+	}
+	if outcome, reason := g.Outcome(); outcome != BlackWins || reason != "horde captured" {
+		t.Error("Expected black to win by capturing the horde, got", outcome, reason)
+	}
+}